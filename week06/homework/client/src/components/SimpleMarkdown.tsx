@@ -1,67 +1,39 @@
-import { ReactNode } from 'react'
+import { ReactNode, useEffect, useState } from 'react'
+import { renderMarkdown } from '../services/api'
+import '../styles/Markdown.css'
 
 interface SimpleMarkdownProps {
-  children: string
+  // 服务端渲染好的HTML，例如题目的html字段
+  html?: string
+  // 没有html时，把这段Markdown文本交给服务端渲染
+  children?: string
 }
 
-const SimpleMarkdown = ({ children }: SimpleMarkdownProps): ReactNode => {
-  if (!children) return null
+// 显示服务端渲染的Markdown。服务端已经去掉原始HTML并转义了其余内容，
+// 这里不再自己解析，只负责插入页面
+const SimpleMarkdown = ({ html, children }: SimpleMarkdownProps): ReactNode => {
+  const [rendered, setRendered] = useState<string>('')
 
-  // 将文本分割成行
-  const lines = children.split('\n')
-  
-  // 存储处理后的HTML元素
-  const elements: ReactNode[] = []
-  
-  // 跟踪我们是否在一个列表中
-  let inList = false
-  let listItems: ReactNode[] = []
-  
-  // 处理每一行
-  lines.forEach((line, index) => {
-    const trimmedLine = line.trim()
-    
-    // 空行结束当前段落或列表
-    if (!trimmedLine) {
-      if (inList) {
-        elements.push(<ul key={`list-${index}`}>{listItems}</ul>)
-        listItems = []
-        inList = false
-      }
+  useEffect(() => {
+    if (html !== undefined || !children) {
+      setRendered('')
       return
     }
-    
-    // 处理标题
-    if (trimmedLine.startsWith('# ')) {
-      elements.push(<h1 key={index} dangerouslySetInnerHTML={{ __html: trimmedLine.substring(2) }} />)
-    } else if (trimmedLine.startsWith('## ')) {
-      elements.push(<h2 key={index} dangerouslySetInnerHTML={{ __html: trimmedLine.substring(3) }} />)
-    } else if (trimmedLine.startsWith('### ')) {
-      elements.push(<h3 key={index} dangerouslySetInnerHTML={{ __html: trimmedLine.substring(4) }} />)
-    } 
-    // 处理列表项
-    else if (trimmedLine.startsWith('- ') || trimmedLine.startsWith('* ')) {
-      inList = true
-      listItems.push(<li key={`item-${index}`} dangerouslySetInnerHTML={{ __html: trimmedLine.substring(2) }} />)
+    let cancelled = false
+    renderMarkdown(children)
+      .then(result => {
+        if (!cancelled) setRendered(result)
+      })
+      .catch(err => console.error('渲染Markdown失败:', err))
+    return () => {
+      cancelled = true
     }
-    // 处理代码块
-    else if (trimmedLine.startsWith('```') && trimmedLine.length > 3) {
-      elements.push(<pre key={index}><code>{trimmedLine.substring(3)}</code></pre>)
-    }
-    // 常规段落
-    else {
-      if (!trimmedLine.startsWith('```') && !trimmedLine.endsWith('```')) {
-        elements.push(<p key={index} dangerouslySetInnerHTML={{ __html: trimmedLine }} />)
-      }
-    }
-  })
-  
-  // 如果结束时仍在列表中，添加列表
-  if (inList && listItems.length > 0) {
-    elements.push(<ul key="final-list">{listItems}</ul>)
-  }
-  
-  return <div>{elements}</div>
+  }, [html, children])
+
+  const content = html ?? rendered
+  if (!content) return null
+
+  return <div className="markdown-body" dangerouslySetInnerHTML={{ __html: content }} />
 }
 
-export default SimpleMarkdown 
\ No newline at end of file
+export default SimpleMarkdown
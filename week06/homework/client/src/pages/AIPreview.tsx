@@ -14,9 +14,10 @@ import {
   Empty,
   Alert
 } from 'antd'
-import type { AIResponse, QuestionRequest, QuestionData } from '../types'
+import type { AIResponse, QuestionRequest, QuestionData, QuestionHTML } from '../types'
 import { QuestionType, QuestionDifficulty } from '../types'
-import { addQuestion } from '../services/api'
+import { addQuestion, previewQuestion } from '../services/api'
+import SimpleMarkdown from '../components/SimpleMarkdown'
 import '../styles/AIPreview.css'
 
 const { Title, Paragraph } = Typography
@@ -28,6 +29,7 @@ const AIPreview = () => {
   const [aiResponses, setAiResponses] = useState<AIResponse[]>([])
   const [request, setRequest] = useState<QuestionRequest | null>(null)
   const [selectedQuestions, setSelectedQuestions] = useState<number[]>([])
+  const [previews, setPreviews] = useState<(QuestionHTML | undefined)[]>([])
 
   useEffect(() => {
     // 从location state获取数据
@@ -46,6 +48,13 @@ const AIPreview = () => {
     }
   }, [location, navigate])
 
+  // 题干和选项由服务端按Markdown渲染
+  useEffect(() => {
+    if (aiResponses.length === 0) return
+    Promise.all(aiResponses.map(aiRes => previewQuestion({ aiReq: request ?? {}, aiRes }).catch(() => undefined)))
+      .then(setPreviews)
+  }, [aiResponses, request])
+
   // 添加选中的题目到数据库
   const handleAddSelectedQuestions = async () => {
     if (!request || aiResponses.length === 0) {
@@ -110,7 +119,7 @@ const AIPreview = () => {
   }
 
   // 渲染选择题选项
-  const renderChoiceOptions = (answer: string[], right: number[]) => {
+  const renderChoiceOptions = (answer: string[], right: number[], html?: string[]) => {
     return (
       <div className="options-container">
         <List
@@ -125,7 +134,7 @@ const AIPreview = () => {
                   ) : (
                     <Checkbox checked={isCorrect} disabled />
                   )}
-                  <div dangerouslySetInnerHTML={{ __html: item }} />
+                  {html?.[index] !== undefined ? <SimpleMarkdown html={html[index]} /> : <span>{item}</span>}
                 </Space>
                 {isCorrect && <Tag color="success">正确答案</Tag>}
               </List.Item>
@@ -221,7 +230,11 @@ const AIPreview = () => {
             <div className="question-content">
               <Title level={4}>题目</Title>
               <Paragraph>
-                <div dangerouslySetInnerHTML={{ __html: aiRes.title.replace(/\n/g, '<br/>') }} />
+                {previews[index] ? (
+                  <SimpleMarkdown html={previews[index]!.title} />
+                ) : (
+                  <div style={{ whiteSpace: 'pre-wrap' }}>{aiRes.title}</div>
+                )}
               </Paragraph>
 
               <Divider />
@@ -230,7 +243,7 @@ const AIPreview = () => {
               {isProgramming ? (
                 renderProgrammingCode(aiRes.code || '', request?.language ? [request.language] : [])
               ) : (
-                renderChoiceOptions(aiRes.answer, aiRes.right)
+                renderChoiceOptions(aiRes.answer, aiRes.right, previews[index]?.options)
               )}
             </div>
           </Card>
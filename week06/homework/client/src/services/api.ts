@@ -5,7 +5,8 @@ import type {
   QuestionQueryRequest,
   QuestionListResponse,
   HTTPResponse,
-  QuestionDeleteRequest
+  QuestionDeleteRequest,
+  QuestionHTML
 } from '../types'
 
 // 创建axios实例
@@ -100,4 +101,16 @@ export const deleteQuestions = async (params: QuestionDeleteRequest): Promise<HT
   }
 }
 
+// 预览题目渲染后的HTML，不保存
+export const previewQuestion = async (data: Partial<QuestionData>): Promise<QuestionHTML> => {
+  const response = await api.post<{ code: number; msg: string; html: QuestionHTML }>('/questions/preview', data)
+  return response.data.html
+}
+
+// 渲染一段Markdown文本，使用与题干相同的规则
+export const renderMarkdown = async (text: string): Promise<string> => {
+  const html = await previewQuestion({ aiRes: { title: text, answer: [], right: [] } })
+  return html.title
+}
+
 export default api 
\ No newline at end of file
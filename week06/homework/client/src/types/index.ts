@@ -2,7 +2,10 @@
 export enum QuestionType {
   SingleChoice = 1,
   MultiChoice = 2,
-  Programming = 3
+  Programming = 3,
+  TrueFalse = 4,
+  FillBlank = 5,
+  ShortAnswer = 6
 }
 
 // 题目难度
@@ -25,6 +28,8 @@ export interface QuestionRequest {
   type?: QuestionType
   difficulty?: QuestionDifficulty
   count?: number
+  review?: boolean
+  reviewModel?: ModelProvider
 }
 
 // AI生成的题目响应
@@ -33,6 +38,20 @@ export interface AIResponse {
   answer: string[]
   right: number[]
   code?: string
+  explanation?: string
+  rationale?: string[]
+  blanks?: string[][]
+  reference?: string
+  review?: QuestionReview
+}
+
+// 二次审核结果
+export interface QuestionReview {
+  reviewer: ModelProvider
+  score: number
+  comments: string
+  reviewerAnswer: number[]
+  disagree: boolean
 }
 
 // 完整的题目数据
@@ -45,6 +64,17 @@ export interface QuestionData {
   aiRes: AIResponse
   difficulty: QuestionDifficulty
   createdAt: string
+  html?: QuestionHTML
+}
+
+// 服务端按Markdown渲染后的题目，已去掉原始HTML，可以直接插入页面
+export interface QuestionHTML {
+  title: string
+  options?: string[]
+  code?: string
+  explanation?: string
+  rationale?: string[]
+  reference?: string
 }
 
 // HTTP响应
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"question-generator/config"
+	"question-generator/middleware"
+	"question-generator/services"
+	"strings"
+	"time"
+)
+
+// 运维子命令，除restore外服务运行时也可以执行
+const commandUsage = `用法: question-server [参数] [命令]
+
+不带命令时启动服务。命令:
+  backup          创建数据库快照
+  backups         列出所有快照
+  restore <快照>  从快照恢复数据库，恢复前自动备份当前数据库；需要先停止服务，恢复后重新启动
+  check           检查数据库完整性
+  token <用户ID>  签发用户令牌，请求时放在Authorization: Bearer <令牌>中
+
+参数:
+`
+
+func printUsage() {
+	fmt.Fprint(flag.CommandLine.Output(), commandUsage)
+	flag.PrintDefaults()
+}
+
+// 执行子命令，返回进程退出码
+func runCommand(cfg *config.Configuration, args []string) int {
+	// 签发令牌只需要密钥，不打开数据库
+	if args[0] == "token" {
+		return issueToken(cfg, args[1:])
+	}
+
+	storage, err := services.OpenStorageFile(cfg.DatabasePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer storage.DB.Close()
+
+	backup := services.NewBackupService(storage, cfg.Backup)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "backup":
+		info, backupErr := backup.Backup(ctx)
+		if err = backupErr; err == nil {
+			fmt.Printf("已创建快照 %s (%d字节)\n", info.Name, info.Size)
+		}
+	case "backups":
+		backups, listErr := backup.List()
+		if err = listErr; err == nil {
+			for _, b := range backups {
+				fmt.Printf("%s\t%d\t%s\n", b.Name, b.Size, b.CreatedAt.Format("2006-01-02 15:04:05"))
+			}
+		}
+	case "restore":
+		if len(args) != 2 {
+			printUsage()
+			return 2
+		}
+		result, restoreErr := backup.Restore(ctx, args[1])
+		if err = restoreErr; err == nil {
+			fmt.Printf("已从 %s 恢复，恢复前的数据库已备份为 %s\n", result.Restored, result.PreRestore.Name)
+			fmt.Println("请重新启动服务，启动时会补齐快照中缺少的表和列")
+		}
+	case "check":
+		report, checkErr := backup.IntegrityCheck(ctx)
+		if err = checkErr; err == nil {
+			fmt.Println(strings.Join(report.Messages, "\n"))
+			if !report.OK {
+				return 1
+			}
+		}
+	default:
+		printUsage()
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// 用AUTH_SECRET签发用户令牌，有效期为AUTH_TOKEN_TTL
+func issueToken(cfg *config.Configuration, args []string) int {
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		printUsage()
+		return 2
+	}
+	if cfg.Auth.Secret == "" {
+		fmt.Fprintln(os.Stderr, "未配置AUTH_SECRET，无法签发用户令牌")
+		return 1
+	}
+
+	expires := time.Now().Add(cfg.Auth.TokenTTL)
+	fmt.Println(middleware.SignUserToken(cfg.Auth.Secret, strings.TrimSpace(args[0]), expires))
+	fmt.Fprintf(os.Stderr, "有效期至 %s\n", expires.Format("2006-01-02 15:04:05"))
+	return 0
+}
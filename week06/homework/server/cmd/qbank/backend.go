@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"question-generator/models"
+	"question-generator/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 题库操作，HTTP接口和本地数据库各有一种实现
+type backend interface {
+	Generate(req models.QuestionRequest) ([]models.AIResponse, error)
+	Query(filter models.QuestionFilter) (*models.QuestionListResponse, error)
+	Add(q *models.QuestionData) (int64, error)
+	Edit(id int64, q *models.QuestionData) error
+	Delete(ids []int64) error
+	Close() error
+}
+
+// 出题需要等待模型返回，超时与服务端的写超时一致
+const httpTimeout = 6 * time.Minute
+
+// 通过HTTP接口操作正在运行的服务
+type httpBackend struct {
+	baseURL string
+	token   string // 用户令牌，为空时以匿名用户访问
+	client  *http.Client
+}
+
+func newHTTPBackend(baseURL, token string) *httpBackend {
+	return &httpBackend{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: httpTimeout},
+	}
+}
+
+// 接口响应中用到的字段
+type apiResponse struct {
+	Code       int                   `json:"code"`
+	Msg        string                `json:"msg"`
+	AIRes      []models.AIResponse   `json:"aiRes"`
+	ID         int64                 `json:"id"`
+	Total      int                   `json:"total"`
+	List       []models.QuestionData `json:"list"`
+	NextCursor string                `json:"nextCursor"`
+}
+
+// 发送请求并解析响应，code不为0时返回服务端的错误信息
+func (b *httpBackend) do(method, path string, body interface{}) (*apiResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, b.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求服务失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("无法解析服务响应（HTTP %d）: %w", resp.StatusCode, err)
+	}
+	if result.Code != 0 {
+		return nil, errors.New(result.Msg)
+	}
+	return &result, nil
+}
+
+func (b *httpBackend) Generate(req models.QuestionRequest) ([]models.AIResponse, error) {
+	resp, err := b.do(http.MethodPost, "/api/questions/create", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.AIRes, nil
+}
+
+func (b *httpBackend) Query(filter models.QuestionFilter) (*models.QuestionListResponse, error) {
+	resp, err := b.do(http.MethodGet, "/api/questions/list?"+filterQuery(filter).Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &models.QuestionListResponse{Total: resp.Total, List: resp.List, NextCursor: resp.NextCursor}, nil
+}
+
+// 把筛选条件转换为列表接口的查询参数
+func filterQuery(filter models.QuestionFilter) url.Values {
+	query := url.Values{}
+	for _, t := range filter.Types {
+		query.Add("types", strconv.Itoa(int(t)))
+	}
+	for _, d := range filter.Difficulties {
+		query.Add("difficulties", strconv.Itoa(int(d)))
+	}
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	set("language", string(filter.Language))
+	set("tag", filter.Tag)
+	set("status", string(filter.Status))
+	set("title", filter.Title)
+	set("sort", string(filter.Sort))
+	set("cursor", filter.Cursor)
+	if filter.Asc {
+		query.Set("order", "asc")
+	}
+	if len(filter.BankIDs) == 1 {
+		query.Set("bankId", strconv.FormatInt(filter.BankIDs[0], 10))
+	}
+	if filter.Page > 0 {
+		query.Set("page", strconv.Itoa(filter.Page))
+	}
+	if filter.PageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(filter.PageSize))
+	}
+	return query
+}
+
+func (b *httpBackend) Add(q *models.QuestionData) (int64, error) {
+	resp, err := b.do(http.MethodPost, "/api/questions/add", q)
+	if err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+func (b *httpBackend) Edit(id int64, q *models.QuestionData) error {
+	_, err := b.do(http.MethodPut, fmt.Sprintf("/api/questions/edit/%d", id), q)
+	return err
+}
+
+func (b *httpBackend) Delete(ids []int64) error {
+	_, err := b.do(http.MethodDelete, "/api/questions/delete", models.QuestionDeleteRequest{IDs: ids})
+	return err
+}
+
+func (b *httpBackend) Close() error {
+	return nil
+}
+
+// 直接读写数据库文件，不经过服务，也不会触发webhook等服务端的处理
+type localBackend struct {
+	storage *services.StorageService
+}
+
+func openLocalBackend(path string) (*localBackend, error) {
+	storage, err := services.OpenStorageFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &localBackend{storage: storage}, nil
+}
+
+func (b *localBackend) Generate(req models.QuestionRequest) ([]models.AIResponse, error) {
+	return nil, errors.New("直接操作数据库时不能生成题目，请去掉-db参数连接服务")
+}
+
+func (b *localBackend) Query(filter models.QuestionFilter) (*models.QuestionListResponse, error) {
+	return b.storage.QueryQuestions(filter)
+}
+
+func (b *localBackend) Add(q *models.QuestionData) (int64, error) {
+	if err := q.Validate(); err != nil {
+		return 0, err
+	}
+	return b.storage.AddQuestion(q)
+}
+
+func (b *localBackend) Edit(id int64, q *models.QuestionData) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	return b.storage.EditQuestion(id, q)
+}
+
+func (b *localBackend) Delete(ids []int64) error {
+	return b.storage.DeleteQuestions(ids)
+}
+
+func (b *localBackend) Close() error {
+	return b.storage.DB.Close()
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"question-generator/models"
+	"strconv"
+	"strings"
+)
+
+// 导出时每次查询的题目数，与列表接口的上限一致
+const exportPageSize = 100
+
+type app struct {
+	backend backend
+	output  string
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+// 列表类命令共用的筛选参数
+type filterFlags struct {
+	types        string
+	difficulties string
+	language     string
+	tag          string
+	status       string
+	title        string
+	sort         string
+	asc          bool
+	bank         int64
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.types, "type", "", "题型，逗号分隔: 1=单选 2=多选 3=编程 4=判断 5=填空 6=简答")
+	fs.StringVar(&f.difficulties, "difficulty", "", "难度，逗号分隔: 1=简单 2=中等 3=困难")
+	fs.StringVar(&f.language, "language", "", "编程语言")
+	fs.StringVar(&f.tag, "tag", "", "标签")
+	fs.StringVar(&f.status, "status", "", "状态: active / draft / archived")
+	fs.StringVar(&f.title, "title", "", "标题包含的文字")
+	fs.StringVar(&f.sort, "sort", "", "排序字段: id / created / difficulty / usage")
+	fs.BoolVar(&f.asc, "asc", false, "升序排列，默认降序")
+	fs.Int64Var(&f.bank, "bank", 0, "题库ID，默认为能访问的全部题库")
+}
+
+func (f *filterFlags) filter() (models.QuestionFilter, error) {
+	filter := models.QuestionFilter{
+		Language: models.ProgrammingLanguage(f.language),
+		Tag:      strings.TrimSpace(f.tag),
+		Status:   models.QuestionStatus(f.status),
+		Title:    f.title,
+		Sort:     models.QuestionSort(f.sort),
+		Asc:      f.asc,
+	}
+	if f.bank > 0 {
+		filter.BankIDs = []int64{f.bank}
+	}
+	if filter.Status != "" && !filter.Status.Valid() {
+		return filter, fmt.Errorf("不支持的题目状态: %s", f.status)
+	}
+
+	types, err := parseInts(f.types)
+	if err != nil {
+		return filter, fmt.Errorf("无效的题型: %w", err)
+	}
+	for _, t := range types {
+		filter.Types = append(filter.Types, models.QuestionType(t))
+	}
+	difficulties, err := parseInts(f.difficulties)
+	if err != nil {
+		return filter, fmt.Errorf("无效的难度: %w", err)
+	}
+	for _, d := range difficulties {
+		filter.Difficulties = append(filter.Difficulties, models.QuestionDifficulty(d))
+	}
+	return filter, nil
+}
+
+// 解析逗号分隔的整数
+func parseInts(value string) ([]int, error) {
+	var result []int
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s", item)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// 调用模型生成题目
+func (a *app) generate(args []string) error {
+	fs := a.flagSet("generate", "")
+	var req models.QuestionRequest
+	var qType, difficulty int
+	var save bool
+	fs.IntVar(&qType, "type", 1, "题型: 1=单选 2=多选 3=编程 4=判断 5=填空 6=简答")
+	fs.IntVar(&difficulty, "difficulty", 2, "难度: 1=简单 2=中等 3=困难")
+	fs.StringVar((*string)(&req.Language), "language", "go", "编程语言")
+	fs.IntVar(&req.Count, "count", 1, "题目数量")
+	fs.StringVar((*string)(&req.Model), "model", "", "出题模型: tongyi / deepseek，默认tongyi")
+	fs.BoolVar(&req.Review, "review", false, "生成后用另一个模型审核")
+	fs.BoolVar(&save, "save", false, "把生成的题目保存到题库")
+	if err := a.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	req.Type = models.QuestionType(qType)
+	req.Difficulty = models.QuestionDifficulty(difficulty)
+
+	generated, err := a.backend.Generate(req)
+	if err != nil {
+		return fmt.Errorf("生成题目失败: %w", err)
+	}
+
+	if !save {
+		if a.output == "json" {
+			return writeJSON(a.stdout, generated)
+		}
+		for i, res := range generated {
+			writeGenerated(a.stdout, i+1, res)
+		}
+		return nil
+	}
+
+	// 保存时与网页上选择题目后入库的方式相同
+	req.Count = 0
+	var saved []models.QuestionData
+	for _, res := range generated {
+		q := models.QuestionData{AIReq: req, AIRes: res, Difficulty: req.Difficulty}
+		q.AIRes.Review = nil
+		id, err := a.backend.Add(&q)
+		if err != nil {
+			return fmt.Errorf("保存题目失败: %w", err)
+		}
+		q.ID = id
+		saved = append(saved, q)
+	}
+	return a.printQuestions(saved, len(saved))
+}
+
+// 按条件查询一页题目
+func (a *app) list(args []string) error {
+	return a.query("list", "", args)
+}
+
+// 按标题关键字搜索
+func (a *app) search(args []string) error {
+	return a.query("search", "<关键字>", args)
+}
+
+// 查询一页题目，positional不为空时最后一个参数是标题关键字
+func (a *app) query(name, positional string, args []string) error {
+	fs := a.flagSet(name, positional)
+	var ff filterFlags
+	ff.register(fs)
+	page := fs.Int("page", 1, "页码")
+	pageSize := fs.Int("size", 20, "每页题目数，最多100")
+
+	n := 0
+	if positional != "" {
+		n = 1
+	}
+	if err := a.parse(fs, args, n, n); err != nil {
+		return err
+	}
+	if n > 0 {
+		ff.title = fs.Arg(0)
+	}
+
+	filter, err := ff.filter()
+	if err != nil {
+		return err
+	}
+	filter.Page, filter.PageSize = *page, *pageSize
+
+	result, err := a.backend.Query(filter)
+	if err != nil {
+		return fmt.Errorf("查询题目失败: %w", err)
+	}
+	return a.printQuestions(result.List, result.Total)
+}
+
+// 从文件添加题目，遇到错误立即停止
+func (a *app) add(args []string) error {
+	fs := a.flagSet("add", "<文件>")
+	if err := a.parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	questions, err := readQuestions(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for i := range questions {
+		id, err := a.backend.Add(&questions[i])
+		if err != nil {
+			return fmt.Errorf("添加第%d道题失败: %w", i+1, err)
+		}
+		questions[i].ID = id
+	}
+	return a.printQuestions(questions, len(questions))
+}
+
+// 用文件中的内容替换题目
+func (a *app) edit(args []string) error {
+	fs := a.flagSet("edit", "<ID> <文件>")
+	if err := a.parse(fs, args, 2, 2); err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的题目ID: %s", fs.Arg(0))
+	}
+	questions, err := readQuestions(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if len(questions) != 1 {
+		return fmt.Errorf("文件中应只有一道题，实际有%d道", len(questions))
+	}
+
+	if err := a.backend.Edit(id, &questions[0]); err != nil {
+		return fmt.Errorf("编辑题目失败: %w", err)
+	}
+	fmt.Fprintf(a.stderr, "已更新题目%d\n", id)
+	return nil
+}
+
+// 删除题目
+func (a *app) delete(args []string) error {
+	fs := a.flagSet("delete", "<ID>...")
+	if err := a.parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	var ids []int64
+	for _, arg := range fs.Args() {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("无效的题目ID: %s", arg)
+		}
+		ids = append(ids, id)
+	}
+	if err := a.backend.Delete(ids); err != nil {
+		return fmt.Errorf("删除题目失败: %w", err)
+	}
+	fmt.Fprintf(a.stderr, "已删除%d道题目\n", len(ids))
+	return nil
+}
+
+// 批量导入，通常是export的输出。出错的题目记录到标准错误后继续
+func (a *app) importQuestions(args []string) error {
+	fs := a.flagSet("import", "<文件>")
+	if err := a.parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	questions, err := readQuestions(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var imported []models.QuestionData
+	failed := 0
+	for i := range questions {
+		q := questions[i]
+		// 导入后由题库重新分配ID和使用次数
+		q.ID, q.UsageCount = 0, 0
+		id, err := a.backend.Add(&q)
+		if err != nil {
+			fmt.Fprintf(a.stderr, "第%d道题导入失败: %v\n", i+1, err)
+			failed++
+			continue
+		}
+		q.ID = id
+		imported = append(imported, q)
+	}
+
+	fmt.Fprintf(a.stderr, "已导入%d道题目，失败%d道\n", len(imported), failed)
+	if a.output == "json" {
+		if err := writeJSON(a.stdout, imported); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("有%d道题目导入失败", failed)
+	}
+	return nil
+}
+
+// 导出符合条件的全部题目，格式由-format决定，与-o无关
+func (a *app) export(args []string) error {
+	fs := a.flagSet("export", "")
+	var ff filterFlags
+	ff.register(fs)
+	format := fs.String("format", "json", "导出格式: json / yaml")
+	out := fs.String("out", "", "输出文件，默认输出到标准输出")
+	if err := a.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "yaml" {
+		return fmt.Errorf("不支持的导出格式: %s", *format)
+	}
+
+	filter, err := ff.filter()
+	if err != nil {
+		return err
+	}
+	filter.PageSize = exportPageSize
+
+	// 按游标翻页，导出期间有新增题目也不会重复或遗漏
+	questions := []models.QuestionData{}
+	for {
+		result, err := a.backend.Query(filter)
+		if err != nil {
+			return fmt.Errorf("查询题目失败: %w", err)
+		}
+		questions = append(questions, result.List...)
+		if result.NextCursor == "" {
+			break
+		}
+		filter.Cursor = result.NextCursor
+	}
+	// 渲染后的HTML由服务端生成，不写入导出文件
+	for i := range questions {
+		questions[i].HTML = nil
+	}
+
+	w := a.stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("无法创建输出文件: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeQuestions(w, questions, *format); err != nil {
+		return err
+	}
+	if *out != "" {
+		fmt.Fprintf(a.stderr, "已导出%d道题目到%s\n", len(questions), *out)
+	}
+	return nil
+}
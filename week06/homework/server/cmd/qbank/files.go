@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"question-generator/models"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 读取JSON或YAML文件中的题目，文件中可以是一道题或题目数组。
+// 扩展名为.yaml或.yml时按YAML解析，字段名与JSON相同
+func readQuestions(path string) ([]models.QuestionData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取文件: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// 先转换为JSON，沿用models中的json标签
+		var value interface{}
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("无效的YAML文件: %w", err)
+		}
+		if data, err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("无效的YAML文件: %w", err)
+		}
+	}
+
+	data = bytes.TrimSpace(data)
+	var questions []models.QuestionData
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &questions)
+	} else {
+		var q models.QuestionData
+		err = json.Unmarshal(data, &q)
+		questions = append(questions, q)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无效的题目文件: %w", err)
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("文件中没有题目: %s", path)
+	}
+	return questions, nil
+}
+
+// 按指定格式写出题目数组
+func writeQuestions(w io.Writer, questions []models.QuestionData, format string) error {
+	if format != "yaml" {
+		return writeJSON(w, questions)
+	}
+
+	// 经过JSON转换，使YAML的字段名与JSON相同，便于再次导入
+	data, err := json.Marshal(questions)
+	if err != nil {
+		return err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,138 @@
+// qbank是题库服务的命令行客户端。默认通过HTTP接口操作正在运行的服务，
+// 指定-db时直接读写questions.db文件
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const usage = `用法: qbank [全局参数] <命令> [参数]
+
+命令:
+  generate             调用模型生成题目，-save时保存到题库
+  list                 按条件查询题目
+  search <关键字>      按标题搜索题目
+  add <文件>           从JSON或YAML文件添加题目，文件中可以是一道题或题目数组
+  edit <ID> <文件>     用文件中的内容替换指定题目
+  delete <ID>...       删除题目
+  import <文件>        批量导入题目，跳过出错的题目继续导入
+  export               导出符合条件的全部题目
+
+每个命令的参数可以通过 qbank <命令> -h 查看
+
+全局参数:
+`
+
+// 参数错误，打印用法后以退出码2退出
+var errUsage = errors.New("参数错误")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// 执行命令，返回进程退出码
+func run(args []string, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("qbank", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	server := global.String("server", envOr("QBANK_SERVER", "http://localhost:8080"), "题库服务地址，也可以通过QBANK_SERVER设置")
+	dbPath := global.String("db", "", "直接操作的questions.db文件，指定时不连接服务")
+	token := global.String("token", os.Getenv("QBANK_TOKEN"), "用户令牌（question-server token <用户ID>签发），也可以通过QBANK_TOKEN设置")
+	output := global.String("o", "table", "输出格式: table / json")
+	global.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		global.PrintDefaults()
+	}
+
+	if err := global.Parse(args); err != nil {
+		return 2
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return 2
+	}
+
+	var b backend
+	if *dbPath != "" {
+		local, err := openLocalBackend(*dbPath)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		b = local
+	} else {
+		b = newHTTPBackend(*server, *token)
+	}
+	defer b.Close()
+
+	a := &app{backend: b, output: *output, stdout: stdout, stderr: stderr}
+	commands := map[string]func([]string) error{
+		"generate": a.generate,
+		"list":     a.list,
+		"search":   a.search,
+		"add":      a.add,
+		"edit":     a.edit,
+		"delete":   a.delete,
+		"import":   a.importQuestions,
+		"export":   a.export,
+	}
+
+	name, rest := global.Arg(0), global.Args()[1:]
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "未知的命令: %s\n\n", name)
+		global.Usage()
+		return 2
+	}
+
+	if err := command(rest); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// 每个命令的参数，-o可以写在命令之后覆盖全局参数
+func (a *app) flagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&a.output, "o", a.output, "输出格式: table / json")
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "用法: qbank %s [参数] %s\n\n参数:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// 解析命令参数并检查位置参数的个数，max为-1表示不限
+func (a *app) parse(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		return errUsage
+	}
+	a.output = strings.ToLower(a.output)
+	switch a.output {
+	case "table", "json":
+	default:
+		fmt.Fprintf(a.stderr, "不支持的输出格式: %s\n", a.output)
+		return errUsage
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"question-generator/models"
+	"strings"
+	"testing"
+)
+
+// 执行一条命令，返回退出码和标准输出
+func runCommand(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	if stderr.Len() > 0 {
+		t.Logf("qbank %s: %s", strings.Join(args, " "), stderr.String())
+	}
+	return code, stdout.String()
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func decodeList(t *testing.T, out string) []models.QuestionData {
+	t.Helper()
+	var list []models.QuestionData
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		t.Fatalf("decode output %q: %v", out, err)
+	}
+	return list
+}
+
+func TestLocalRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "questions.db")
+
+	yamlFile := writeFile(t, dir, "questions.yaml", `
+- aiReq: {type: 1, language: go}
+  aiRes:
+    title: Go中哪个关键字用于启动协程
+    answer: [go, defer, chan, select]
+    right: [0]
+  difficulty: 1
+  tags: [并发]
+- aiReq: {type: 2, language: go}
+  aiRes:
+    title: 以下哪些是Go的引用类型
+    answer: [map, slice, int, chan]
+    right: [0, 1, 3]
+  difficulty: 2
+`)
+	code, out := runCommand(t, "-db", db, "-o", "json", "add", yamlFile)
+	if code != 0 {
+		t.Fatalf("add exit code = %d", code)
+	}
+	added := decodeList(t, out)
+	if len(added) != 2 || added[0].ID == 0 || added[1].ID == 0 {
+		t.Fatalf("added = %+v", added)
+	}
+
+	code, out = runCommand(t, "-db", db, "list", "-tag", "并发")
+	if code != 0 || !strings.Contains(out, "启动协程") || strings.Contains(out, "引用类型") || !strings.Contains(out, "共1道题目") {
+		t.Fatalf("list: code=%d out=%q", code, out)
+	}
+
+	code, out = runCommand(t, "-db", db, "search", "-o", "json", "引用")
+	if list := decodeList(t, out); code != 0 || len(list) != 1 || list[0].ID != added[1].ID {
+		t.Fatalf("search: code=%d out=%q", code, out)
+	}
+
+	jsonFile := writeFile(t, dir, "edit.json", `{
+		"aiReq": {"type": 1, "language": "go"},
+		"aiRes": {"title": "Go中用哪个关键字延迟执行", "answer": ["go", "defer"], "right": [1]},
+		"difficulty": 1
+	}`)
+	id := jsonID(added[0].ID)
+	if code, _ = runCommand(t, "-db", db, "edit", id, jsonFile); code != 0 {
+		t.Fatalf("edit exit code = %d", code)
+	}
+
+	exported := filepath.Join(dir, "export.yaml")
+	if code, _ = runCommand(t, "-db", db, "export", "-format", "yaml", "-out", exported); code != 0 {
+		t.Fatalf("export exit code = %d", code)
+	}
+
+	if code, _ = runCommand(t, "-db", db, "delete", id, jsonID(added[1].ID)); code != 0 {
+		t.Fatalf("delete exit code = %d", code)
+	}
+	code, out = runCommand(t, "-db", db, "-o", "json", "list")
+	if list := decodeList(t, out); code != 0 || len(list) != 0 {
+		t.Fatalf("list after delete: code=%d out=%q", code, out)
+	}
+
+	code, out = runCommand(t, "-db", db, "-o", "json", "import", exported)
+	if code != 0 {
+		t.Fatalf("import exit code = %d", code)
+	}
+	imported := decodeList(t, out)
+	if len(imported) != 2 {
+		t.Fatalf("imported = %+v", imported)
+	}
+	titles := imported[0].AIRes.Title + imported[1].AIRes.Title
+	if !strings.Contains(titles, "延迟执行") || !strings.Contains(titles, "引用类型") {
+		t.Fatalf("imported titles = %q", titles)
+	}
+}
+
+func TestLocalValidation(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "questions.db")
+	invalid := writeFile(t, dir, "invalid.json", `[
+		{"aiReq": {"type": 1}, "aiRes": {"title": "只有一个选项", "answer": ["a"], "right": [0]}},
+		{"aiReq": {"type": 1}, "aiRes": {"title": "正常的题目", "answer": ["a", "b"], "right": [1]}}
+	]`)
+
+	if code, _ := runCommand(t, "-db", db, "add", invalid); code != 1 {
+		t.Fatalf("add exit code = %d, want 1", code)
+	}
+	// 导入跳过出错的题目，退出码仍然表示失败
+	if code, _ := runCommand(t, "-db", db, "import", invalid); code != 1 {
+		t.Fatalf("import exit code = %d, want 1", code)
+	}
+	code, out := runCommand(t, "-db", db, "-o", "json", "list")
+	if list := decodeList(t, out); code != 0 || len(list) != 1 || list[0].AIRes.Title != "正常的题目" {
+		t.Fatalf("list: code=%d out=%q", code, out)
+	}
+
+	if code, _ := runCommand(t, "-db", db, "generate"); code != 1 {
+		t.Fatalf("generate with -db exit code = %d, want 1", code)
+	}
+	if code, _ := runCommand(t, "-db", db, "search"); code != 2 {
+		t.Fatalf("search without keyword exit code = %d, want 2", code)
+	}
+	if code, _ := runCommand(t, "-db", db, "-o", "xml", "list"); code != 2 {
+		t.Fatalf("unsupported output exit code = %d, want 2", code)
+	}
+	if code, _ := runCommand(t, "unknown"); code != 2 {
+		t.Fatalf("unknown command exit code = %d, want 2", code)
+	}
+}
+
+func TestHTTPBackend(t *testing.T) {
+	var query, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/questions/list":
+			query, authorization = r.URL.RawQuery, r.Header.Get("Authorization")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code":  0,
+				"total": 1,
+				"list": []models.QuestionData{{
+					ID:    7,
+					AIReq: models.QuestionRequest{Type: models.SingleChoice},
+					AIRes: models.AIResponse{Title: "来自服务的题目"},
+				}},
+			})
+		case "/api/questions/delete":
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": -1, "msg": "题目不存在"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	code, out := runCommand(t, "-server", server.URL, "-token", "alice-token", "list",
+		"-type", "1,2", "-difficulty", "3", "-tag", "并发", "-sort", "usage", "-asc", "-size", "5")
+	if code != 0 || !strings.Contains(out, "来自服务的题目") {
+		t.Fatalf("list: code=%d out=%q", code, out)
+	}
+	if authorization != "Bearer alice-token" {
+		t.Fatalf("Authorization = %q", authorization)
+	}
+	want := "difficulties=3&order=asc&page=1&pageSize=5&sort=usage&tag=%E5%B9%B6%E5%8F%91&types=1&types=2"
+	if query != want {
+		t.Fatalf("query = %q, want %q", query, want)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-server", server.URL, "delete", "1"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("delete exit code = %d, want 1", code)
+	}
+	if !strings.Contains(stderr.String(), "题目不存在") {
+		t.Fatalf("stderr = %q", stderr.String())
+	}
+}
+
+func jsonID(id int64) string {
+	b, _ := json.Marshal(id)
+	return string(b)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"question-generator/models"
+	"strings"
+	"text/tabwriter"
+)
+
+// 表格中标题的最大显示长度（字符）
+const maxTitleWidth = 40
+
+var typeNames = map[models.QuestionType]string{
+	models.SingleChoice: "单选",
+	models.MultiChoice:  "多选",
+	models.Programming:  "编程",
+	models.TrueFalse:    "判断",
+	models.FillBlank:    "填空",
+	models.ShortAnswer:  "简答",
+}
+
+var difficultyNames = map[models.QuestionDifficulty]string{
+	models.Easy:   "简单",
+	models.Medium: "中等",
+	models.Hard:   "困难",
+}
+
+// 按输出格式打印题目，表格末尾显示总数
+func (a *app) printQuestions(questions []models.QuestionData, total int) error {
+	if a.output == "json" {
+		if questions == nil {
+			questions = []models.QuestionData{}
+		}
+		return writeJSON(a.stdout, questions)
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\t题型\t难度\t状态\t标签\t标题")
+	for _, q := range questions {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			q.ID,
+			nameOr(typeNames[q.AIReq.Type], q.AIReq.Type),
+			nameOr(difficultyNames[q.Difficulty], q.Difficulty),
+			q.Status,
+			strings.Join(q.Tags, ","),
+			truncate(q.AIRes.Title, maxTitleWidth),
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "共%d道题目\n", total)
+	return nil
+}
+
+// 打印一道生成的题目，包括选项和答案
+func writeGenerated(w io.Writer, n int, res models.AIResponse) {
+	fmt.Fprintf(w, "%d. %s\n", n, res.Title)
+	for i, option := range res.Answer {
+		mark := " "
+		for _, right := range res.Right {
+			if right == i {
+				mark = "*"
+			}
+		}
+		fmt.Fprintf(w, "  %s %c. %s\n", mark, 'A'+i, option)
+	}
+	for i, accepted := range res.Blanks {
+		fmt.Fprintf(w, "  空%d: %s\n", i+1, strings.Join(accepted, " / "))
+	}
+	if res.Reference != "" {
+		fmt.Fprintf(w, "  参考答案: %s\n", res.Reference)
+	}
+	if res.Code != "" {
+		fmt.Fprintf(w, "%s\n", res.Code)
+	}
+	if res.Explanation != "" {
+		fmt.Fprintf(w, "  解析: %s\n", res.Explanation)
+	}
+	if res.Review != nil {
+		fmt.Fprintf(w, "  审核: %d分 %s\n", res.Review.Score, res.Review.Comments)
+	}
+	fmt.Fprintln(w)
+}
+
+func nameOr[T any](name string, value T) string {
+	if name != "" {
+		return name
+	}
+	return fmt.Sprint(value)
+}
+
+// 把标题压成一行并截断到max个字符
+func truncate(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
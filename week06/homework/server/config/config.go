@@ -1,65 +1,272 @@
 package config
 
 import (
-	"log"
-	"os"
-	"strconv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"question-generator/models"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // 存储应用配置
 type Configuration struct {
-	QwenAPIKey string
-	QwenAPIURL string
-	Port       int
-	Host       string
+	QwenAPIKey         string
+	QwenAPIURL         string
+	QwenOutputMode     models.OutputMode // 为空时使用服务商的默认能力
+	DeepSeekAPIKey     string
+	DeepSeekAPIURL     string
+	DeepSeekOutputMode models.OutputMode     // 为空时使用服务商的默认能力
+	ReviewModel        models.ModelProvider  // 二次审核默认使用的模型
+	MockLLM            bool                  // 使用内置的假模型服务，离线开发时不需要真实密钥
+	ModelPrices        map[string]ModelPrice // 按模型名称索引的单价表
+	Quotas             QuotaConfig
+	RateLimits         RateLimitConfig
+	Port               int
+	Host               string
+	Server             ServerConfig
+	DatabasePath       string // 题库数据库文件，所在目录同时是数据目录
+	CORS               CORSConfig
+	LogLevel           slog.Level
+	Prompts            PromptConfig
+	Backup             BackupConfig
+	AdminToken         string // 管理接口的访问令牌，为空时管理接口不可用
+	Auth               AuthConfig
+	StatsCacheTTL      time.Duration // 题库统计结果的缓存时间
+	ItemAnalysis       ItemAnalysisConfig
+	Adaptive           AdaptiveConfig
+	Webhook            WebhookConfig
+	Attachment         AttachmentConfig
 }
 
-// 从环境变量加载配置
-func LoadConfig() *Configuration {
-	err := godotenv.Load()
+// HTTP服务的超时设置
+type ServerConfig struct {
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration // 需要覆盖出题和审核两次模型调用
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // 关闭时等待进行中的请求和后台任务的最长时间，到期后取消它们，优先于模型调用的超时
+	// 可信的反向代理（IP或CIDR），只有来自这些地址的请求才采用X-Forwarded-For中的客户端IP。
+	// 为空时直接使用连接的对端地址，客户端无法伪造IP绕过限流和配额
+	TrustedProxies []string
+}
+
+// 跨域访问设置，前端与接口同源时不需要配置
+type CORSConfig struct {
+	AllowOrigins []string      // 允许跨域访问的来源，*表示任意来源，为空时不处理跨域请求
+	MaxAge       time.Duration // 浏览器缓存预检结果的时间
+}
+
+// 调用模型时的参数
+type PromptConfig struct {
+	Temperature  float64
+	TopP         float64
+	MaxTokens    int
+	Instructions string // 附加在出题提示语末尾的要求，例如统一的出题风格
+}
+
+// 用户令牌设置。用户身份只来自用密钥签名的令牌，未配置密钥时所有请求都是匿名用户
+type AuthConfig struct {
+	Secret   string        // 签发和验证用户令牌的密钥
+	TokenTTL time.Duration // token命令签发的令牌的有效期
+}
+
+// 数据库备份设置
+type BackupConfig struct {
+	Dir  string // 快照目录，为空时使用数据目录下的backups
+	Keep int    // 最多保留的快照数量，0表示不删除旧快照
+}
+
+// 题目分析设置
+type ItemAnalysisConfig struct {
+	MinResponses    int  // 作答次数达到该值后才标记问题和重新标定难度
+	AutoRecalibrate bool // 交卷后自动把实测难度与标注不符的题目改为实测难度
+}
+
+// 自适应测试的默认设置，开始测试时可以单独指定
+type AdaptiveConfig struct {
+	MaxQuestions int     // 每次测试最多的题数
+	TargetSE     float64 // 能力估计的标准误低于该值时结束测试
+}
+
+// webhook投递设置
+type WebhookConfig struct {
+	MaxAttempts  int           // 最多投递次数，用尽后进入死信列表
+	RetryBase    time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	RetryMax     time.Duration // 重试等待时间的上限
+	Timeout      time.Duration // 单次投递的超时时间
+	PollInterval time.Duration // 检查待投递事件的间隔
+}
+
+// 题目图片设置
+type AttachmentConfig struct {
+	Dir       string        // 图片目录，为空时使用数据目录下的attachments
+	MaxBytes  int64         // 单张图片的大小上限
+	OrphanTTL time.Duration // 没有被任何题目引用的图片保留的时间，之后被清理
+}
+
+// 配置来源，优先级从低到高依次为默认值、配置文件、环境变量（包括.env）和命令行参数
+type Options struct {
+	File  string            // 配置文件路径，为空时使用CONFIG_FILE，都为空时不读取配置文件
+	Flags map[string]string // 命令行参数，键为对应的环境变量名
+}
+
+// 加载并校验配置，所有无效的配置项合并在一个错误中返回
+func Load(opts Options) (*Configuration, error) {
+	if err := godotenv.Load(); err != nil {
+		slog.Debug("未找到.env文件，使用环境变量")
+	}
+
+	values, err := collect(opts)
 	if err != nil {
-		log.Println("警告: 未找到.env文件，使用环境变量")
+		return nil, err
 	}
 
-	// 加载API配置
-	qwenAPIKey := os.Getenv("QWEN_API_KEY")
-	qwenAPIURL := os.Getenv("QWEN_API_URL")
+	p := &parser{values: values}
+	config := &Configuration{
+		QwenAPIKey:         p.str("QWEN_API_KEY"),
+		QwenAPIURL:         p.str("QWEN_API_URL"),
+		QwenOutputMode:     models.OutputMode(p.str("QWEN_OUTPUT_MODE")),
+		DeepSeekAPIKey:     p.str("DEEPSEEK_API_KEY"),
+		DeepSeekAPIURL:     p.str("DEEPSEEK_API_URL"),
+		DeepSeekOutputMode: models.OutputMode(p.str("DEEPSEEK_OUTPUT_MODE")),
+		ReviewModel:        models.ModelProvider(p.str("REVIEW_MODEL")),
+		MockLLM:            p.bool("MOCK_LLM"),
+		ModelPrices:        p.modelPrices("MODEL_PRICES"),
+		Quotas: QuotaConfig{
+			UserDaily:     p.float("QUOTA_USER_DAILY"),
+			UserMonthly:   p.float("QUOTA_USER_MONTHLY"),
+			GlobalDaily:   p.float("QUOTA_GLOBAL_DAILY"),
+			GlobalMonthly: p.float("QUOTA_GLOBAL_MONTHLY"),
+		},
+		RateLimits: p.rateLimits(),
+		Port:       p.int("PORT"),
+		Host:       p.str("HOST"),
+		Server: ServerConfig{
+			ReadTimeout:     p.duration("SERVER_READ_TIMEOUT"),
+			WriteTimeout:    p.duration("SERVER_WRITE_TIMEOUT"),
+			IdleTimeout:     p.duration("SERVER_IDLE_TIMEOUT"),
+			ShutdownTimeout: p.duration("SERVER_SHUTDOWN_TIMEOUT"),
+			TrustedProxies:  p.list("TRUSTED_PROXIES"),
+		},
+		DatabasePath: p.str("DATABASE_PATH"),
+		CORS: CORSConfig{
+			AllowOrigins: p.list("CORS_ALLOW_ORIGINS"),
+			MaxAge:       p.duration("CORS_MAX_AGE"),
+		},
+		LogLevel: p.logLevel("LOG_LEVEL"),
+		Prompts: PromptConfig{
+			Temperature:  p.float("PROMPT_TEMPERATURE"),
+			TopP:         p.float("PROMPT_TOP_P"),
+			MaxTokens:    p.int("PROMPT_MAX_TOKENS"),
+			Instructions: p.str("PROMPT_INSTRUCTIONS"),
+		},
+		Backup: BackupConfig{
+			Dir:  p.str("BACKUP_DIR"),
+			Keep: p.int("BACKUP_KEEP"),
+		},
+		AdminToken: p.str("ADMIN_TOKEN"),
+		Auth: AuthConfig{
+			Secret:   p.str("AUTH_SECRET"),
+			TokenTTL: p.duration("AUTH_TOKEN_TTL"),
+		},
+		StatsCacheTTL: p.duration("STATS_CACHE_TTL"),
+		ItemAnalysis: ItemAnalysisConfig{
+			MinResponses:    p.int("ITEM_MIN_RESPONSES"),
+			AutoRecalibrate: p.bool("ITEM_AUTO_RECALIBRATE"),
+		},
+		Adaptive: AdaptiveConfig{
+			MaxQuestions: p.int("ADAPTIVE_MAX_QUESTIONS"),
+			TargetSE:     p.float("ADAPTIVE_TARGET_SE"),
+		},
+		Webhook: WebhookConfig{
+			MaxAttempts:  p.int("WEBHOOK_MAX_ATTEMPTS"),
+			RetryBase:    p.duration("WEBHOOK_RETRY_BASE"),
+			RetryMax:     p.duration("WEBHOOK_RETRY_MAX"),
+			Timeout:      p.duration("WEBHOOK_TIMEOUT"),
+			PollInterval: p.duration("WEBHOOK_POLL_INTERVAL"),
+		},
+		Attachment: AttachmentConfig{
+			Dir:       p.str("ATTACHMENT_DIR"),
+			MaxBytes:  int64(p.int("ATTACHMENT_MAX_BYTES")),
+			OrphanTTL: p.duration("ATTACHMENT_ORPHAN_TTL"),
+		},
+	}
 
-	portStr := os.Getenv("PORT")
-	port := 8081
-	if portStr != "" {
-		portInt, err := strconv.Atoi(portStr)
-		if err == nil {
-			port = portInt
+	validate(p, config)
+	if len(p.errs) > 0 {
+		return nil, fmt.Errorf("配置无效:\n%w", errors.Join(p.errs...))
+	}
+	return config, nil
+}
+
+// 检查各配置项之间的约束和取值范围，错误记录到p中
+func validate(p *parser, config *Configuration) {
+	if !config.MockLLM {
+		if config.QwenAPIURL == "" {
+			p.fail("QWEN_API_URL", "未设置，不使用假模型服务时必须配置")
+		}
+		if config.DeepSeekAPIKey != "" && config.DeepSeekAPIURL == "" {
+			p.fail("DEEPSEEK_API_URL", "配置了DeepSeek密钥时必须配置")
 		}
 	}
+	p.check("QWEN_API_URL", config.QwenAPIURL == "" || isHTTPURL(config.QwenAPIURL, true), "不是有效的http(s)地址")
+	p.check("DEEPSEEK_API_URL", config.DeepSeekAPIURL == "" || isHTTPURL(config.DeepSeekAPIURL, true), "不是有效的http(s)地址")
 
-	host := os.Getenv("HOST")
-	if host == "" {
-		host = "localhost"
+	p.check("QWEN_OUTPUT_MODE", validOutputMode(config.QwenOutputMode), "只能是text、json_object或json_schema")
+	p.check("DEEPSEEK_OUTPUT_MODE", validOutputMode(config.DeepSeekOutputMode), "只能是text、json_object或json_schema")
+	switch config.ReviewModel {
+	case models.Tongyi, models.DeepSeek:
+	default:
+		p.fail("REVIEW_MODEL", "只能是tongyi或deepseek")
 	}
 
-	// 创建并返回配置
-	config := &Configuration{
-		QwenAPIKey: qwenAPIKey,
-		QwenAPIURL: qwenAPIURL,
-		Port:       port,
-		Host:       host,
+	p.check("PORT", config.Port >= 1 && config.Port <= 65535, "端口应在1-65535之间")
+	p.check("HOST", config.Host != "", "不能为空")
+	p.check("DATABASE_PATH", config.DatabasePath != "", "不能为空")
+	for _, proxy := range config.Server.TrustedProxies {
+		p.check("TRUSTED_PROXIES", isIPOrCIDR(proxy), fmt.Sprintf("%s不是有效的IP或CIDR", proxy))
+	}
+	for _, origin := range config.CORS.AllowOrigins {
+		p.check("CORS_ALLOW_ORIGINS", origin == "*" || isHTTPURL(origin, false), fmt.Sprintf("%s不是有效的来源，应为*或http(s)://域名[:端口]", origin))
 	}
 
-	// 验证必要配置
-	validateConfig(config)
+	p.check("PROMPT_TEMPERATURE", config.Prompts.Temperature <= 2, "应在0-2之间")
+	p.check("PROMPT_TOP_P", config.Prompts.TopP > 0 && config.Prompts.TopP <= 1, "应大于0且不超过1")
+	p.check("PROMPT_MAX_TOKENS", config.Prompts.MaxTokens > 0, "应大于0")
+	p.check("AUTH_SECRET", config.Auth.Secret == "" || len(config.Auth.Secret) >= 16, "至少需要16个字符")
+	p.check("AUTH_TOKEN_TTL", config.Auth.TokenTTL > 0, "应大于0")
+	p.check("ADAPTIVE_MAX_QUESTIONS", config.Adaptive.MaxQuestions > 0, "应大于0")
+	p.check("ADAPTIVE_TARGET_SE", config.Adaptive.TargetSE > 0, "应大于0")
+	p.check("WEBHOOK_MAX_ATTEMPTS", config.Webhook.MaxAttempts > 0, "应大于0")
+	p.check("WEBHOOK_RETRY_MAX", config.Webhook.RetryMax >= config.Webhook.RetryBase, "不能小于WEBHOOK_RETRY_BASE")
+	p.check("ATTACHMENT_MAX_BYTES", config.Attachment.MaxBytes > 0, "应大于0")
+}
 
-	return config
+func isIPOrCIDR(value string) bool {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	return net.ParseIP(value) != nil
 }
 
-// 验证配置有效性
-func validateConfig(config *Configuration) {
-	// 设置URL
-	if config.QwenAPIURL == "" {
-		log.Println("警告: 未设置qwen URL")
+// 为空表示使用服务商的默认能力
+func validOutputMode(mode models.OutputMode) bool {
+	switch mode {
+	case "", models.OutputText, models.OutputJSONObject, models.OutputJSONSchema:
+		return true
 	}
+	return false
+}
 
+// 是否为http(s)地址，withPath为false时不能带路径，用于校验跨域来源
+func isHTTPURL(value string, withPath bool) bool {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return withPath || (strings.TrimSuffix(u.Path, "/") == "" && u.RawQuery == "")
 }
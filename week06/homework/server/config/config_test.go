@@ -0,0 +1,212 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"question-generator/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 清空测试用到的环境变量，避免运行环境影响结果
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for key := range defaults {
+		t.Setenv(key, "")
+	}
+	t.Setenv("CONFIG_FILE", "")
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, "config.yaml", `
+providers:
+  qwen:
+    apiURL: https://qwen.example.com/v1
+    outputMode: json_object
+  deepseek:
+    outputMode: json_schema
+  prices:
+    qwen-turbo: {prompt: 0.001, completion: 0.002}
+server:
+  port: 9000
+  host: 0.0.0.0
+database:
+  path: /tmp/bank/questions.db
+cors:
+  allowOrigins: [https://a.example.com, "http://localhost:5173"]
+rateLimits:
+  routes:
+    POST /api/exams/grade: 30/1m
+prompts:
+  temperature: 0.5
+logging:
+  level: debug
+`)
+	t.Setenv("PORT", "9100")
+	t.Setenv("PROMPT_TEMPERATURE", "0.7")
+	t.Setenv("QWEN_OUTPUT_MODE", "text")
+
+	cfg, err := Load(Options{File: path, Flags: map[string]string{"PORT": "9200"}})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// 命令行参数 > 环境变量 > 配置文件 > 默认值
+	if cfg.Port != 9200 || cfg.Prompts.Temperature != 0.7 || cfg.Host != "0.0.0.0" || cfg.Prompts.MaxTokens != 8000 {
+		t.Errorf("port=%d temperature=%v host=%q maxTokens=%d", cfg.Port, cfg.Prompts.Temperature, cfg.Host, cfg.Prompts.MaxTokens)
+	}
+	if cfg.QwenOutputMode != models.OutputText || cfg.DeepSeekOutputMode != models.OutputJSONSchema {
+		t.Errorf("output modes: qwen=%q deepseek=%q", cfg.QwenOutputMode, cfg.DeepSeekOutputMode)
+	}
+	if cfg.DatabasePath != "/tmp/bank/questions.db" || cfg.LogLevel.String() != "DEBUG" {
+		t.Errorf("database=%q level=%v", cfg.DatabasePath, cfg.LogLevel)
+	}
+	if want := []string{"https://a.example.com", "http://localhost:5173"}; !reflect.DeepEqual(cfg.CORS.AllowOrigins, want) {
+		t.Errorf("origins = %v", cfg.CORS.AllowOrigins)
+	}
+	if cfg.ModelPrices["qwen-turbo"] != (ModelPrice{PromptPer1K: 0.001, CompletionPer1K: 0.002}) {
+		t.Errorf("prices = %+v", cfg.ModelPrices)
+	}
+	if cfg.ModelPrices["deepseek-chat"] != defaultModelPrices["deepseek-chat"] {
+		t.Errorf("built-in price lost: %+v", cfg.ModelPrices)
+	}
+	if cfg.RateLimits.Routes["POST /api/exams/grade"].Requests != 30 || cfg.RateLimits.Routes[GenerateRoute].Requests != 5 {
+		t.Errorf("routes = %+v", cfg.RateLimits.Routes)
+	}
+	// 关闭时的等待时间不短于单次模型调用的180秒
+	if cfg.Webhook.RetryBase != 30*time.Second || cfg.Server.WriteTimeout != 6*time.Minute || cfg.Server.ShutdownTimeout < 180*time.Second {
+		t.Errorf("defaults not applied: %+v %+v", cfg.Webhook, cfg.Server)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, "config.toml", `
+[providers]
+mock = true
+
+[quotas]
+userDaily = 2.5
+
+[webhook]
+retryBase = "1m"
+retryMax = "2h"
+`)
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load(Options{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.MockLLM || cfg.Quotas.UserDaily != 2.5 || cfg.Webhook.RetryBase != time.Minute || cfg.Webhook.RetryMax != 2*time.Hour {
+		t.Errorf("cfg = %+v", cfg)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "unknown key",
+			file: "server:\n  prot: 9000\nlogging: {level: info, format: json}\n",
+			want: []string{"server.prot", "logging.format"},
+		},
+		{
+			name: "invalid values",
+			env: map[string]string{
+				"PORT":                 "abc",
+				"LOG_LEVEL":            "verbose",
+				"RATE_LIMIT_LIST":      "10",
+				"CORS_ALLOW_ORIGINS":   "example.com",
+				"QWEN_API_KEY":         "sk-secret",
+				"QWEN_OUTPUT_MODE":     "xml",
+				"DEEPSEEK_OUTPUT_MODE": "yaml",
+			},
+			want: []string{"server.port（PORT）", "logging.level", "rateLimits.list", "example.com", "providers.qwen.apiURL",
+				"providers.qwen.outputMode", "providers.deepseek.outputMode"},
+		},
+		{
+			name: "out of range",
+			env: map[string]string{
+				"MOCK_LLM":             "true",
+				"PORT":                 "70000",
+				"PROMPT_TOP_P":         "1.5",
+				"WEBHOOK_MAX_ATTEMPTS": "0",
+				"WEBHOOK_RETRY_MAX":    "1s",
+			},
+			want: []string{"PORT", "PROMPT_TOP_P", "WEBHOOK_MAX_ATTEMPTS", "WEBHOOK_RETRY_MAX"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			var opts Options
+			if tt.file != "" {
+				opts.File = writeConfig(t, "config.yaml", tt.file)
+			}
+
+			_, err := Load(opts)
+			if err == nil {
+				t.Fatal("want error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+			if strings.Contains(err.Error(), "sk-secret") {
+				t.Errorf("error leaks api key: %q", err)
+			}
+		})
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("MOCK_LLM", "true")
+	old, err := Load(Options{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	t.Setenv("RATE_LIMIT_GENERATE", "1/1m")
+	t.Setenv("PROMPT_INSTRUCTIONS", "题干不超过50字")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("PORT", "9000")
+	new, err := Load(Options{})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got := RestartRequired(old, new); !reflect.DeepEqual(got, []string{"Port"}) {
+		t.Errorf("RestartRequired = %v, want [Port]", got)
+	}
+}
+
+// 仓库中的示例配置文件必须始终有效
+func TestExampleConfig(t *testing.T) {
+	clearEnv(t)
+	if _, err := Load(Options{File: "../config.example.yaml"}); err != nil {
+		t.Fatalf("config.example.yaml: %v", err)
+	}
+}
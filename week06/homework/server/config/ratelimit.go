@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 令牌桶限流参数：每个Period补充Requests个令牌，桶容量为Burst。
+// Requests为0表示不限流
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// 是否开启限流
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// 路由限流配置，Routes的键为"方法 路由"，例如"POST /api/questions/create"，
+// 未单独配置的接口使用Default
+type RateLimitConfig struct {
+	Default RateLimit
+	Routes  map[string]RateLimit
+}
+
+// 内置限流规则：生成题目需要调用付费模型，比查询接口严格得多
+const (
+	GenerateRoute = "POST /api/questions/create"
+	ListRoute     = "GET /api/questions/list"
+
+	defaultRateLimit    = "60/1m"
+	defaultGenerateRate = "5/1m"
+	defaultListRate     = "120/1m"
+)
+
+// 解析限流规则，格式为"次数/周期[:突发容量]"，例如 5/1m 或 10/1m:3，
+// off或0表示不限流。未指定突发容量时等于次数
+func ParseRateLimit(value string) (RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "off" || value == "0" {
+		return RateLimit{}, nil
+	}
+
+	rate, burstStr, hasBurst := strings.Cut(value, ":")
+	countStr, periodStr, ok := strings.Cut(rate, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("无效的限流规则: %s", value)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(countStr))
+	if err != nil || count < 0 {
+		return RateLimit{}, fmt.Errorf("无效的请求次数: %s", value)
+	}
+	period, err := time.ParseDuration(strings.TrimSpace(periodStr))
+	if err != nil || period <= 0 {
+		return RateLimit{}, fmt.Errorf("无效的限流周期: %s", value)
+	}
+
+	burst := count
+	if hasBurst {
+		burst, err = strconv.Atoi(strings.TrimSpace(burstStr))
+		if err != nil || burst < 1 {
+			return RateLimit{}, fmt.Errorf("无效的突发容量: %s", value)
+		}
+	}
+
+	return RateLimit{Requests: count, Period: period, Burst: burst}, nil
+}
+
+// 解析限流配置。RATE_LIMIT_ROUTES用于覆盖任意接口，
+// 格式为"方法 路由=规则"，多条用分号分隔
+func (p *parser) rateLimits() RateLimitConfig {
+	cfg := RateLimitConfig{
+		Default: p.rateLimit("RATE_LIMIT_DEFAULT"),
+		Routes: map[string]RateLimit{
+			GenerateRoute: p.rateLimit("RATE_LIMIT_GENERATE"),
+			ListRoute:     p.rateLimit("RATE_LIMIT_LIST"),
+		},
+	}
+
+	for _, item := range strings.Split(p.values["RATE_LIMIT_ROUTES"], ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		route, rule, ok := strings.Cut(item, "=")
+		if !ok {
+			p.fail("RATE_LIMIT_ROUTES", fmt.Sprintf("%s应为\"方法 路由=规则\"", item))
+			continue
+		}
+		limit, err := ParseRateLimit(rule)
+		if err != nil {
+			p.fail("RATE_LIMIT_ROUTES", fmt.Sprintf("%s: %v", strings.TrimSpace(route), err))
+			continue
+		}
+		cfg.Routes[strings.Join(strings.Fields(route), " ")] = limit
+	}
+
+	return cfg
+}
+
+func (p *parser) rateLimit(key string) RateLimit {
+	limit, err := ParseRateLimit(p.values[key])
+	if err != nil {
+		p.fail(key, err.Error())
+	}
+	return limit
+}
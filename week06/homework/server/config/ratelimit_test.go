@@ -0,0 +1,85 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RateLimit
+		wantErr bool
+	}{
+		{value: "5/1m", want: RateLimit{Requests: 5, Period: time.Minute, Burst: 5}},
+		{value: " 10 / 30s : 3 ", want: RateLimit{Requests: 10, Period: 30 * time.Second, Burst: 3}},
+		{value: "off"},
+		{value: "0"},
+		{value: "5", wantErr: true},
+		{value: "x/1m", wantErr: true},
+		{value: "5/abc", wantErr: true},
+		{value: "5/0s", wantErr: true},
+		{value: "5/1m:0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRateLimit(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("want error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRateLimit: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRateLimitConfig(t *testing.T) {
+	p := &parser{values: map[string]string{
+		"RATE_LIMIT_DEFAULT":  defaultRateLimit,
+		"RATE_LIMIT_GENERATE": defaultGenerateRate,
+		"RATE_LIMIT_LIST":     "off",
+		"RATE_LIMIT_ROUTES":   "POST  /api/exams/grade=30/1m; ;GET /api/usage/report=20/1m:5",
+	}}
+
+	cfg := p.rateLimits()
+	if len(p.errs) > 0 {
+		t.Fatalf("errors: %v", p.errs)
+	}
+	if cfg.Routes[GenerateRoute] != (RateLimit{Requests: 5, Period: time.Minute, Burst: 5}) {
+		t.Errorf("generate = %+v, want default", cfg.Routes[GenerateRoute])
+	}
+	if cfg.Routes[ListRoute].Enabled() {
+		t.Errorf("list should be unlimited: %+v", cfg.Routes[ListRoute])
+	}
+	if cfg.Routes["POST /api/exams/grade"].Requests != 30 {
+		t.Errorf("grade override missing: %+v", cfg.Routes)
+	}
+	if cfg.Routes["GET /api/usage/report"].Burst != 5 {
+		t.Errorf("report override = %+v", cfg.Routes["GET /api/usage/report"])
+	}
+	if cfg.Default.Requests != 60 {
+		t.Errorf("default = %+v", cfg.Default)
+	}
+}
+
+func TestParseRateLimitConfigInvalid(t *testing.T) {
+	p := &parser{values: map[string]string{
+		"RATE_LIMIT_DEFAULT":  defaultRateLimit,
+		"RATE_LIMIT_GENERATE": "bad",
+		"RATE_LIMIT_LIST":     defaultListRate,
+		"RATE_LIMIT_ROUTES":   "broken;GET /api/usage/report=x",
+	}}
+
+	p.rateLimits()
+	if len(p.errs) != 3 {
+		t.Fatalf("errors = %v, want 3", p.errs)
+	}
+}
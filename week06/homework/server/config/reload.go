@@ -0,0 +1,27 @@
+package config
+
+import "reflect"
+
+// 收到SIGHUP时可以直接生效的配置，其余配置修改后需要重启服务
+var reloadableFields = map[string]bool{
+	"RateLimits": true,
+	"Quotas":     true,
+	"Prompts":    true,
+	"LogLevel":   true,
+}
+
+// 返回两份配置中不同的、需要重启才能生效的字段名
+func RestartRequired(old, new *Configuration) []string {
+	var changed []string
+	oldValue, newValue := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		name := oldValue.Type().Field(i).Name
+		if reloadableFields[name] {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,364 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// 所有配置项的默认值，键为环境变量名。配置文件和命令行参数最终都转换为这些键
+var defaults = map[string]string{
+	"QWEN_API_KEY":            "",
+	"QWEN_API_URL":            "",
+	"QWEN_OUTPUT_MODE":        "",
+	"DEEPSEEK_API_KEY":        "",
+	"DEEPSEEK_API_URL":        "",
+	"DEEPSEEK_OUTPUT_MODE":    "",
+	"REVIEW_MODEL":            "tongyi",
+	"MOCK_LLM":                "false",
+	"MODEL_PRICES":            "",
+	"QUOTA_USER_DAILY":        "0",
+	"QUOTA_USER_MONTHLY":      "0",
+	"QUOTA_GLOBAL_DAILY":      "0",
+	"QUOTA_GLOBAL_MONTHLY":    "0",
+	"RATE_LIMIT_DEFAULT":      defaultRateLimit,
+	"RATE_LIMIT_GENERATE":     defaultGenerateRate,
+	"RATE_LIMIT_LIST":         defaultListRate,
+	"RATE_LIMIT_ROUTES":       "",
+	"PORT":                    "8081",
+	"HOST":                    "localhost",
+	"SERVER_READ_TIMEOUT":     "15s",
+	"SERVER_WRITE_TIMEOUT":    "6m",
+	"SERVER_IDLE_TIMEOUT":     "2m",
+	"SERVER_SHUTDOWN_TIMEOUT": "3m30s", // 不短于单次模型调用的180秒，关闭时进行中的出题可以完成
+	"TRUSTED_PROXIES":         "",
+	"DATABASE_PATH":           "./data/questions.db",
+	"CORS_ALLOW_ORIGINS":      "",
+	"CORS_MAX_AGE":            "12h",
+	"LOG_LEVEL":               "info",
+	"PROMPT_TEMPERATURE":      "0.1",
+	"PROMPT_TOP_P":            "0.95",
+	"PROMPT_MAX_TOKENS":       "8000",
+	"PROMPT_INSTRUCTIONS":     "",
+	"ADMIN_TOKEN":             "",
+	"AUTH_SECRET":             "",
+	"AUTH_TOKEN_TTL":          "720h",
+	"BACKUP_DIR":              "",
+	"BACKUP_KEEP":             "10",
+	"STATS_CACHE_TTL":         "30s",
+	"ITEM_MIN_RESPONSES":      "30",
+	"ITEM_AUTO_RECALIBRATE":   "false",
+	"ADAPTIVE_MAX_QUESTIONS":  "20",
+	"ADAPTIVE_TARGET_SE":      "0.3",
+	"WEBHOOK_MAX_ATTEMPTS":    "8",
+	"WEBHOOK_RETRY_BASE":      "30s",
+	"WEBHOOK_RETRY_MAX":       "1h",
+	"WEBHOOK_TIMEOUT":         "10s",
+	"WEBHOOK_POLL_INTERVAL":   "5s",
+	"ATTACHMENT_DIR":          "",
+	"ATTACHMENT_MAX_BYTES":    "5242880",
+	"ATTACHMENT_ORPHAN_TTL":   "24h",
+}
+
+// 配置文件中的字段路径与环境变量名的对应关系
+var fileKeys = map[string]string{
+	"providers.qwen.apiKey":         "QWEN_API_KEY",
+	"providers.qwen.apiURL":         "QWEN_API_URL",
+	"providers.qwen.outputMode":     "QWEN_OUTPUT_MODE",
+	"providers.deepseek.apiKey":     "DEEPSEEK_API_KEY",
+	"providers.deepseek.apiURL":     "DEEPSEEK_API_URL",
+	"providers.deepseek.outputMode": "DEEPSEEK_OUTPUT_MODE",
+	"providers.reviewModel":         "REVIEW_MODEL",
+	"providers.mock":                "MOCK_LLM",
+	"providers.prices":              "MODEL_PRICES",
+	"quotas.userDaily":              "QUOTA_USER_DAILY",
+	"quotas.userMonthly":            "QUOTA_USER_MONTHLY",
+	"quotas.globalDaily":            "QUOTA_GLOBAL_DAILY",
+	"quotas.globalMonthly":          "QUOTA_GLOBAL_MONTHLY",
+	"rateLimits.default":            "RATE_LIMIT_DEFAULT",
+	"rateLimits.generate":           "RATE_LIMIT_GENERATE",
+	"rateLimits.list":               "RATE_LIMIT_LIST",
+	"rateLimits.routes":             "RATE_LIMIT_ROUTES",
+	"server.port":                   "PORT",
+	"server.host":                   "HOST",
+	"server.readTimeout":            "SERVER_READ_TIMEOUT",
+	"server.writeTimeout":           "SERVER_WRITE_TIMEOUT",
+	"server.idleTimeout":            "SERVER_IDLE_TIMEOUT",
+	"server.shutdownTimeout":        "SERVER_SHUTDOWN_TIMEOUT",
+	"server.trustedProxies":         "TRUSTED_PROXIES",
+	"database.path":                 "DATABASE_PATH",
+	"cors.allowOrigins":             "CORS_ALLOW_ORIGINS",
+	"cors.maxAge":                   "CORS_MAX_AGE",
+	"logging.level":                 "LOG_LEVEL",
+	"prompts.temperature":           "PROMPT_TEMPERATURE",
+	"prompts.topP":                  "PROMPT_TOP_P",
+	"prompts.maxTokens":             "PROMPT_MAX_TOKENS",
+	"prompts.instructions":          "PROMPT_INSTRUCTIONS",
+	"admin.token":                   "ADMIN_TOKEN",
+	"auth.secret":                   "AUTH_SECRET",
+	"auth.tokenTTL":                 "AUTH_TOKEN_TTL",
+	"backup.dir":                    "BACKUP_DIR",
+	"backup.keep":                   "BACKUP_KEEP",
+	"stats.cacheTTL":                "STATS_CACHE_TTL",
+	"itemAnalysis.minResponses":     "ITEM_MIN_RESPONSES",
+	"itemAnalysis.autoRecalibrate":  "ITEM_AUTO_RECALIBRATE",
+	"adaptive.maxQuestions":         "ADAPTIVE_MAX_QUESTIONS",
+	"adaptive.targetSE":             "ADAPTIVE_TARGET_SE",
+	"webhook.maxAttempts":           "WEBHOOK_MAX_ATTEMPTS",
+	"webhook.retryBase":             "WEBHOOK_RETRY_BASE",
+	"webhook.retryMax":              "WEBHOOK_RETRY_MAX",
+	"webhook.timeout":               "WEBHOOK_TIMEOUT",
+	"webhook.pollInterval":          "WEBHOOK_POLL_INTERVAL",
+	"attachments.dir":               "ATTACHMENT_DIR",
+	"attachments.maxBytes":          "ATTACHMENT_MAX_BYTES",
+	"attachments.orphanTTL":         "ATTACHMENT_ORPHAN_TTL",
+}
+
+// 按优先级合并各来源的配置，结果的键为环境变量名
+func collect(opts Options) (map[string]string, error) {
+	values := make(map[string]string, len(defaults))
+	for key, value := range defaults {
+		values[key] = value
+	}
+
+	path := opts.File
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		fileValues, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileValues {
+			values[key] = value
+		}
+	}
+
+	// 环境变量为空时视为未设置，.env中留空的配置项不会覆盖配置文件
+	for key := range defaults {
+		if value := os.Getenv(key); value != "" {
+			values[key] = value
+		}
+	}
+
+	for key, value := range opts.Flags {
+		if _, ok := defaults[key]; !ok {
+			return nil, fmt.Errorf("未知的配置项: %s", key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// 读取YAML或TOML配置文件，格式由扩展名决定。出现未知的字段时报错，避免拼写错误被忽略
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取配置文件: %w", err)
+	}
+
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("不支持的配置文件格式: %s，应为.yaml、.yml或.toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无法解析配置文件%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	var errs []error
+	flatten("", raw, values, &errs)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("配置文件%s有误:\n%w", path, errors.Join(errs...))
+	}
+	return values, nil
+}
+
+// 把嵌套的配置展开为环境变量名到取值的映射
+func flatten(prefix string, raw map[string]interface{}, values map[string]string, errs *[]error) {
+	for name, value := range raw {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		key, ok := fileKeys[path]
+		if !ok {
+			if nested, isMap := value.(map[string]interface{}); isMap {
+				flatten(path, nested, values, errs)
+			} else {
+				*errs = append(*errs, fmt.Errorf("未知的配置项: %s", path))
+			}
+			continue
+		}
+
+		text, err := fileValue(key, value)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		values[key] = text
+	}
+}
+
+// 把配置文件中的取值转换为环境变量的格式
+func fileValue(key string, value interface{}) (string, error) {
+	switch key {
+	case "MODEL_PRICES":
+		// 模型名称: {prompt: 输入单价, completion: 输出单价}
+		prices, ok := value.(map[string]interface{})
+		if !ok {
+			return "", errors.New("应为模型名称到单价的映射")
+		}
+		var items []string
+		for model, price := range prices {
+			rates, ok := price.(map[string]interface{})
+			if !ok || len(rates) != 2 || rates["prompt"] == nil || rates["completion"] == nil {
+				return "", fmt.Errorf("%s的单价应包含且只包含prompt和completion", model)
+			}
+			items = append(items, fmt.Sprintf("%s=%v:%v", model, rates["prompt"], rates["completion"]))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ","), nil
+	case "RATE_LIMIT_ROUTES":
+		// "方法 路由": 规则
+		routes, ok := value.(map[string]interface{})
+		if !ok {
+			return "", errors.New("应为接口到限流规则的映射")
+		}
+		var items []string
+		for route, rule := range routes {
+			items = append(items, fmt.Sprintf("%s=%v", route, rule))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ";"), nil
+	case "CORS_ALLOW_ORIGINS", "TRUSTED_PROXIES":
+		list, ok := value.([]interface{})
+		if !ok {
+			return "", errors.New("应为列表")
+		}
+		var items []string
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ","), nil
+	}
+
+	switch value.(type) {
+	case map[string]interface{}, []interface{}, nil:
+		return "", errors.New("应为单个取值")
+	}
+	return fmt.Sprint(value), nil
+}
+
+// 配置项在错误信息中的名称，同时给出配置文件字段和环境变量名
+func keyName(key string) string {
+	for path, k := range fileKeys {
+		if k == key {
+			return path + "（" + key + "）"
+		}
+	}
+	return key
+}
+
+// 把合并后的配置解析为具体类型，错误全部记录下来一次返回
+type parser struct {
+	values map[string]string
+	errs   []error
+}
+
+func (p *parser) fail(key, reason string) {
+	// 密钥不出现在错误信息中
+	value := p.values[key]
+	if value != "" && !strings.Contains(reason, value) && !strings.HasSuffix(key, "_KEY") && key != "ADMIN_TOKEN" && key != "AUTH_SECRET" {
+		reason += fmt.Sprintf("，当前值: %s", value)
+	}
+	p.errs = append(p.errs, fmt.Errorf("%s: %s", keyName(key), reason))
+}
+
+func (p *parser) check(key string, ok bool, reason string) {
+	if !ok {
+		p.fail(key, reason)
+	}
+}
+
+func (p *parser) str(key string) string {
+	return strings.TrimSpace(p.values[key])
+}
+
+// 逗号分隔的列表，忽略空项
+func (p *parser) list(key string) []string {
+	var items []string
+	for _, item := range strings.Split(p.values[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (p *parser) bool(key string) bool {
+	b, err := strconv.ParseBool(p.str(key))
+	if err != nil {
+		p.fail(key, "应为true或false")
+	}
+	return b
+}
+
+// 非负整数
+func (p *parser) int(key string) int {
+	n, err := strconv.Atoi(p.str(key))
+	if err != nil || n < 0 {
+		p.fail(key, "应为非负整数")
+	}
+	return n
+}
+
+// 非负小数
+func (p *parser) float(key string) float64 {
+	f, err := strconv.ParseFloat(p.str(key), 64)
+	if err != nil || f < 0 {
+		p.fail(key, "应为非负数")
+	}
+	return f
+}
+
+// 正的时长，例如30s、5m
+func (p *parser) duration(key string) time.Duration {
+	d, err := time.ParseDuration(p.str(key))
+	if err != nil || d <= 0 {
+		p.fail(key, "应为正的时长，例如30s、5m")
+	}
+	return d
+}
+
+func (p *parser) logLevel(key string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(p.str(key))); err != nil {
+		p.fail(key, "应为debug、info、warn或error")
+	}
+	return level
+}
+
+func (p *parser) modelPrices(key string) map[string]ModelPrice {
+	prices, err := parseModelPrices(p.values[key])
+	if err != nil {
+		p.fail(key, err.Error())
+	}
+	return prices
+}
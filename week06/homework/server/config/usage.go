@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 模型单价，单位为元/千tokens
+type ModelPrice struct {
+	PromptPer1K     float64
+	CompletionPer1K float64
+}
+
+// 生成费用配额，单位为元，0表示不限制
+type QuotaConfig struct {
+	UserDaily     float64
+	UserMonthly   float64
+	GlobalDaily   float64
+	GlobalMonthly float64
+}
+
+// 内置的模型单价，可通过MODEL_PRICES或providers.prices覆盖
+var defaultModelPrices = map[string]ModelPrice{
+	"qwen-turbo":    {PromptPer1K: 0.0003, CompletionPer1K: 0.0006},
+	"deepseek-chat": {PromptPer1K: 0.002, CompletionPer1K: 0.008},
+}
+
+// 解析MODEL_PRICES，格式为"模型=输入单价:输出单价"，多个模型用逗号分隔，
+// 例如 qwen-turbo=0.0003:0.0006,deepseek-chat=0.002:0.008
+func parseModelPrices(value string) (map[string]ModelPrice, error) {
+	prices := make(map[string]ModelPrice, len(defaultModelPrices))
+	for model, price := range defaultModelPrices {
+		prices[model] = price
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		model, rates, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("无效的模型单价: %s", item)
+		}
+		promptRate, completionRate, ok := strings.Cut(rates, ":")
+		if !ok {
+			return nil, fmt.Errorf("无效的模型单价: %s", item)
+		}
+
+		promptPrice, err := strconv.ParseFloat(strings.TrimSpace(promptRate), 64)
+		if err != nil || promptPrice < 0 {
+			return nil, fmt.Errorf("无效的输入单价: %s", item)
+		}
+		completionPrice, err := strconv.ParseFloat(strings.TrimSpace(completionRate), 64)
+		if err != nil || completionPrice < 0 {
+			return nil, fmt.Errorf("无效的输出单价: %s", item)
+		}
+
+		prices[strings.TrimSpace(model)] = ModelPrice{PromptPer1K: promptPrice, CompletionPer1K: completionPrice}
+	}
+
+	return prices, nil
+}
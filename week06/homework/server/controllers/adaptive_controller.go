@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单次自适应测试允许的最多题数
+const maxAdaptiveQuestions = 100
+
+// 自适应测试控制器
+type AdaptiveController struct {
+	adaptive *services.AdaptiveService
+	courses  *services.CourseService
+}
+
+// 创建新的自适应测试控制器
+func NewAdaptiveController(adaptive *services.AdaptiveService, courses *services.CourseService) *AdaptiveController {
+	return &AdaptiveController{
+		adaptive: adaptive,
+		courses:  courses,
+	}
+}
+
+// 开始自适应测试，返回第一道题
+func (c *AdaptiveController) Start(ctx *gin.Context) {
+	var req models.AdaptiveStartRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	for _, t := range req.Types {
+		if spec, ok := models.LookupQuestionType(t); !ok || spec.Input != models.InputChoice {
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+				Code: -1,
+				Msg:  "自适应测试只支持单选题、多选题和判断题",
+			})
+			return
+		}
+	}
+	if req.MaxQuestions < 0 || req.MaxQuestions > maxAdaptiveQuestions {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "题数上限必须在1到100之间",
+		})
+		return
+	}
+	if req.TargetSE < 0 || req.TargetSE > 1 {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "目标标准误必须在0到1之间",
+		})
+		return
+	}
+
+	// 题库范围由权限决定，忽略客户端传入的bankIds
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	if req.BankIDs, ok = bankScope(ctx, perms, req.BankID, models.RoleStudent); !ok {
+		return
+	}
+
+	session, err := c.adaptive.Start(ClientUserID(ctx), req)
+	if err != nil {
+		c.fail(ctx, "开始测试失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"msg":     "",
+		"session": session,
+	})
+}
+
+// 查询测试进度，结束后返回最终报告
+func (c *AdaptiveController) Get(ctx *gin.Context) {
+	session, err := c.adaptive.Get(ClientUserID(ctx), ctx.Param("id"))
+	if err != nil {
+		c.fail(ctx, "查询测试失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"msg":     "",
+		"session": session,
+	})
+}
+
+// 提交当前题目的作答，返回下一道题或最终报告
+func (c *AdaptiveController) Answer(ctx *gin.Context) {
+	var req models.AdaptiveAnswerRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	session, err := c.adaptive.Answer(ClientUserID(ctx), ctx.Param("id"), req)
+	if err != nil {
+		c.fail(ctx, "提交作答失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"msg":     "",
+		"session": session,
+	})
+}
+
+// 按错误类型返回对应的状态码
+func (c *AdaptiveController) fail(ctx *gin.Context, prefix string, err error) {
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, services.ErrSessionNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrSessionFinished), errors.Is(err, services.ErrWrongQuestion):
+		status = http.StatusConflict
+	case errors.Is(err, services.ErrPoolEmpty):
+		status = http.StatusUnprocessableEntity
+	}
+	ctx.JSON(status, models.HTTPResponse{
+		Code: -1,
+		Msg:  prefix + err.Error(),
+	})
+}
@@ -0,0 +1,55 @@
+package controllers_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAdaptiveEndpoints(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, difficulty := range []int{1, 2} {
+		_, resp := s.do(t, http.MethodPost, "/api/questions/add", map[string]interface{}{
+			"aiReq":      map[string]interface{}{"type": 1},
+			"aiRes":      map[string]interface{}{"title": "题目", "answer": []string{"A", "B"}, "right": []int{0}},
+			"difficulty": difficulty,
+		})
+		if resp["code"] != float64(0) {
+			t.Fatalf("add: %v", resp)
+		}
+	}
+
+	if status, _ := s.do(t, http.MethodPost, "/api/adaptive/sessions", map[string]interface{}{"types": []int{3}}); status != http.StatusBadRequest {
+		t.Errorf("programming type: status %d", status)
+	}
+
+	_, resp := s.doAs(t, "alice", http.MethodPost, "/api/adaptive/sessions", map[string]interface{}{})
+	session := resp["session"].(map[string]interface{})
+	id := session["id"].(string)
+
+	// 两道题答完后题目用尽
+	for i := 0; i < 2; i++ {
+		next := session["next"].(map[string]interface{})
+		if _, ok := next["right"]; ok {
+			t.Fatalf("question leaks answer: %v", next)
+		}
+		status, resp := s.doAs(t, "alice", http.MethodPost, "/api/adaptive/sessions/"+id+"/answer", map[string]interface{}{
+			"questionId": next["id"], "selected": []int{1},
+		})
+		if status != http.StatusOK || resp["code"] != float64(0) {
+			t.Fatalf("answer: status %d, %v", status, resp)
+		}
+		session = resp["session"].(map[string]interface{})
+	}
+	if session["status"] != "finished" || session["stopReason"] != "pool_empty" || session["theta"].(float64) >= 0 {
+		t.Fatalf("final session: %v", session)
+	}
+
+	status, _ := s.doAs(t, "alice", http.MethodPost, "/api/adaptive/sessions/"+id+"/answer", map[string]interface{}{"questionId": 1})
+	if status != http.StatusConflict {
+		t.Errorf("answer after finish: status %d", status)
+	}
+	if status, _ := s.doAs(t, "bob", http.MethodGet, "/api/adaptive/sessions/"+id, nil); status != http.StatusNotFound {
+		t.Errorf("other user: status %d", status)
+	}
+}
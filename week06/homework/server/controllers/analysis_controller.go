@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 题目分析控制器，分析结果包含题干和选项，与题目列表一样只对助教以上可见
+type AnalysisController struct {
+	analysis *services.ItemAnalysisService
+	storage  *services.StorageService
+	courses  *services.CourseService
+}
+
+// 创建新的题目分析控制器
+func NewAnalysisController(analysis *services.ItemAnalysisService, storage *services.StorageService, courses *services.CourseService) *AnalysisController {
+	return &AnalysisController{
+		analysis: analysis,
+		storage:  storage,
+		courses:  courses,
+	}
+}
+
+// 能查看题目的题库中所有被作答过的题目的分析结果
+func (c *AnalysisController) Items(ctx *gin.Context) {
+	var req models.ItemAnalysisRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的查询参数: " + err.Error(),
+		})
+		return
+	}
+
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	bankIDs, ok := bankScope(ctx, perms, req.BankID, models.RoleTA)
+	if !ok {
+		return
+	}
+
+	report, err := c.analysis.Analyze(req.Flagged, bankIDs)
+	if err != nil {
+		ctx.JSON(http.StatusOK, models.HTTPResponse{
+			Code: -1,
+			Msg:  "题目分析失败: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"report": report,
+	})
+}
+
+// 单道题的分析结果
+func (c *AnalysisController) Item(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的题目ID",
+		})
+		return
+	}
+
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	canView := func(bankID int64) bool { return perms.Can(bankID, models.RoleTA) }
+	if !requireQuestionBanks(ctx, c.storage, []int64{id}, canView, "查看") {
+		return
+	}
+
+	item, err := c.analysis.AnalyzeQuestion(id)
+	if err != nil {
+		status := http.StatusOK
+		if errors.Is(err, services.ErrQuestionNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, models.HTTPResponse{
+			Code: -1,
+			Msg:  "题目分析失败: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "",
+		"item": item,
+	})
+}
+
+// 按实测难度重新标定题目难度
+func (c *AnalysisController) Recalibrate(ctx *gin.Context) {
+	var req models.RecalibrateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	result, err := c.analysis.Recalibrate(req.IDs, req.DryRun)
+	if err != nil {
+		ctx.JSON(http.StatusOK, models.HTTPResponse{
+			Code: -1,
+			Msg:  "重新标定难度失败: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"result": result,
+	})
+}
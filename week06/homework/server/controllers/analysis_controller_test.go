@@ -0,0 +1,57 @@
+package controllers_test
+
+import (
+	"net/http"
+	"question-generator/middleware"
+	"testing"
+)
+
+func TestItemAnalysisEndpoints(t *testing.T) {
+	s := newTestServer(t)
+	admin := http.Header{}
+	admin.Set(middleware.AdminTokenHeader, testAdminToken)
+
+	_, resp := s.do(t, http.MethodPost, "/api/questions/add", map[string]interface{}{
+		"aiReq":      map[string]interface{}{"type": 1},
+		"aiRes":      map[string]interface{}{"title": "人人都会", "answer": []string{"A", "B"}, "right": []int{0}},
+		"difficulty": 3,
+	})
+	id := int64(resp["id"].(float64))
+
+	// 测试服务器的最少作答次数为2
+	for _, user := range []string{"alice", "bob"} {
+		_, resp := s.doAs(t, user, http.MethodPost, "/api/exams/grade", map[string]interface{}{
+			"answers": []map[string]interface{}{{"questionId": id, "selected": []int{0}}},
+		})
+		if resp["code"] != float64(0) {
+			t.Fatalf("grade: %v", resp)
+		}
+	}
+
+	_, resp = s.do(t, http.MethodGet, "/api/stats/items?flagged=true", nil)
+	items := resp["report"].(map[string]interface{})["items"].([]interface{})
+	if len(items) != 1 {
+		t.Fatalf("flagged items: %v", resp)
+	}
+	item := items[0].(map[string]interface{})
+	if item["difficultyIndex"] != float64(1) || item["suggestedDifficulty"] != float64(1) || item["discrimination"] != nil {
+		t.Errorf("item: %v", item)
+	}
+
+	if status, _ := s.do(t, http.MethodGet, "/api/stats/items/"+jsonNumber(id+1), nil); status != http.StatusNotFound {
+		t.Errorf("missing item: status %d", status)
+	}
+
+	if status, _ := s.do(t, http.MethodPost, "/api/admin/items/recalibrate", map[string]interface{}{}); status != http.StatusUnauthorized {
+		t.Errorf("recalibrate without token: status %d", status)
+	}
+	_, resp = s.doWithHeader(t, admin, http.MethodPost, "/api/admin/items/recalibrate", map[string]interface{}{"ids": []int64{id}})
+	if changes := resp["result"].(map[string]interface{})["changes"].([]interface{}); len(changes) != 1 {
+		t.Fatalf("recalibrate: %v", resp)
+	}
+
+	_, resp = s.do(t, http.MethodGet, "/api/stats/items/"+jsonNumber(id), nil)
+	if item := resp["item"].(map[string]interface{}); item["difficulty"] != float64(1) || len(item["flags"].([]interface{})) != 0 {
+		t.Errorf("item after recalibrate: %v", item)
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipart请求中除文件以外的部分（边界、表单头）预留的大小
+const multipartOverhead = 64 << 10
+
+var attachmentIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// 题目图片控制器
+type AttachmentController struct {
+	attachments *services.AttachmentService
+	maxBytes    int64
+}
+
+// 创建图片控制器，maxBytes是单张图片的大小上限
+func NewAttachmentController(attachments *services.AttachmentService, maxBytes int64) *AttachmentController {
+	return &AttachmentController{attachments: attachments, maxBytes: maxBytes}
+}
+
+// 上传图片，表单字段名为file。返回的markdown可以直接插入题干或选项
+func (c *AttachmentController) Upload(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, c.maxBytes+multipartOverhead)
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.fail(ctx, "上传图片失败: ", fmt.Errorf("%w: 不能超过%d字节", services.ErrAttachmentTooLarge, c.maxBytes))
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "没有找到上传的图片，请使用file作为表单字段名",
+		})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.fail(ctx, "上传图片失败: ", err)
+		return
+	}
+	defer file.Close()
+
+	attachment, err := c.attachments.Upload(file, header.Filename)
+	if err != nil {
+		c.fail(ctx, "上传图片失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":       0,
+		"msg":        "",
+		"attachment": attachment,
+		"markdown":   fmt.Sprintf("![%s](%s)", attachment.Name, attachment.URL),
+	})
+}
+
+// 返回图片内容。地址由内容哈希决定，内容不会变化，可以长期缓存；
+// download=true时作为附件下载。浏览器用<img>加载时不带令牌，所以不检查题库权限，
+// 无法猜测的内容哈希就是访问凭证
+func (c *AttachmentController) Serve(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if !attachmentIDPattern.MatchString(id) {
+		c.fail(ctx, "", services.ErrAttachmentNotFound)
+		return
+	}
+
+	attachment, file, err := c.attachments.Open(id)
+	if err != nil {
+		c.fail(ctx, "读取图片失败: ", err)
+		return
+	}
+	defer file.Close()
+
+	disposition := "inline"
+	if ctx.Query("download") == "true" {
+		disposition = "attachment"
+	}
+	if attachment.Name != "" {
+		disposition = mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name})
+	}
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", attachment.MimeType)
+	header.Set("Content-Disposition", disposition)
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	header.Set("ETag", `"`+attachment.ID+`"`)
+	http.ServeContent(ctx.Writer, ctx.Request, "", attachment.CreatedAt, file)
+}
+
+// 按错误类型返回对应的状态码
+func (c *AttachmentController) fail(ctx *gin.Context, prefix string, err error) {
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, services.ErrAttachmentNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrAttachmentTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrUnsupportedAttachment):
+		status = http.StatusUnsupportedMediaType
+	}
+	ctx.JSON(status, models.HTTPResponse{
+		Code: -1,
+		Msg:  prefix + err.Error(),
+	})
+}
@@ -0,0 +1,100 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"question-generator/models"
+	"strings"
+	"testing"
+)
+
+// 以multipart表单上传文件
+func (s *testServer) upload(t *testing.T, name string, content []byte) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(content)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/attachments", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+	return w, resp
+}
+
+// 2x2的灰度PNG图片
+func testPNG() []byte {
+	var img bytes.Buffer
+	png.Encode(&img, image.NewGray(image.Rect(0, 0, 2, 2)))
+	return img.Bytes()
+}
+
+func TestAttachmentEndpoints(t *testing.T) {
+	s := newTestServer(t)
+
+	img := bytes.NewBuffer(testPNG())
+	w, resp := s.upload(t, "树.png", img.Bytes())
+	if w.Code != http.StatusOK || resp["code"].(float64) != 0 {
+		t.Fatalf("upload: %d %v", w.Code, resp)
+	}
+	attachment := resp["attachment"].(map[string]interface{})
+	url := attachment["url"].(string)
+	if !strings.HasPrefix(url, models.AttachmentURLPrefix) || resp["markdown"] != "![树.png]("+url+")" {
+		t.Fatalf("upload response = %v", resp)
+	}
+
+	// 读取图片，内容不变的地址可以用ETag协商缓存
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" || !bytes.Equal(w.Body.Bytes(), img.Bytes()) {
+		t.Fatalf("serve: %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" || !strings.HasPrefix(w.Header().Get("Content-Disposition"), "inline") {
+		t.Errorf("serve headers = %v", w.Header())
+	}
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("conditional get = %d, want 304", w.Code)
+	}
+
+	// 题目引用上传的图片
+	question := map[string]interface{}{
+		"aiReq": map[string]interface{}{"type": 1},
+		"aiRes": map[string]interface{}{"title": "看图 ![](" + url + ")", "answer": []string{"a", "b"}, "right": []int{0}},
+	}
+	if code, resp := s.do(t, http.MethodPost, "/api/questions/add", question); code != http.StatusOK || resp["code"].(float64) != 0 {
+		t.Fatalf("add question: %d %v", code, resp)
+	}
+	question["aiRes"].(map[string]interface{})["title"] = "![](" + models.AttachmentURL(strings.Repeat("0", 64)) + ")"
+	if code, resp := s.do(t, http.MethodPost, "/api/questions/add", question); code != http.StatusBadRequest {
+		t.Fatalf("add question with missing image: %d %v", code, resp)
+	}
+
+	if w, _ := s.upload(t, "x.png", []byte("<html></html>")); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("upload html = %d, want 415", w.Code)
+	}
+	if w, _ := s.upload(t, "big.png", append(img.Bytes(), make([]byte, 2048)...)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("upload large = %d, want 413", w.Code)
+	}
+	if code, _ := s.do(t, http.MethodGet, "/api/attachments/not-a-hash", nil); code != http.StatusNotFound {
+		t.Errorf("serve invalid id = %d, want 404", code)
+	}
+}
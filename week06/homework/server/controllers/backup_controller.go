@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 数据库备份控制器
+type BackupController struct {
+	backup *services.BackupService
+}
+
+// 创建新的备份控制器
+func NewBackupController(backup *services.BackupService) *BackupController {
+	return &BackupController{
+		backup: backup,
+	}
+}
+
+// 列出所有快照
+func (c *BackupController) ListBackups(ctx *gin.Context) {
+	backups, err := c.backup.List()
+	if err != nil {
+		ctx.JSON(http.StatusOK, models.HTTPResponse{
+			Code: -1,
+			Msg:  "查询快照失败: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"msg":     "",
+		"backups": backups,
+	})
+}
+
+// 立即创建一个快照
+func (c *BackupController) CreateBackup(ctx *gin.Context) {
+	backup, err := c.backup.Backup(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusOK, models.HTTPResponse{
+			Code: -1,
+			Msg:  "备份失败: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "备份成功",
+		"backup": backup,
+	})
+}
+
+// 检查当前数据库的完整性
+func (c *BackupController) IntegrityCheck(ctx *gin.Context) {
+	report, err := c.backup.IntegrityCheck(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusOK, models.HTTPResponse{
+			Code: -1,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"report": report,
+	})
+}
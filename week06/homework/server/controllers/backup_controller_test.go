@@ -0,0 +1,62 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"question-generator/middleware"
+	"strings"
+	"testing"
+)
+
+func TestBackupEndpoints(t *testing.T) {
+	s := newTestServer(t)
+	admin := http.Header{}
+	admin.Set(middleware.AdminTokenHeader, testAdminToken)
+
+	// 没有令牌或令牌错误时拒绝
+	if status, _ := s.do(t, http.MethodGet, "/api/admin/backups", nil); status != http.StatusUnauthorized {
+		t.Fatalf("without token: status %d", status)
+	}
+
+	addQuestion := func(title string) {
+		_, resp := s.do(t, http.MethodPost, "/api/questions/add", map[string]interface{}{
+			"aiReq": map[string]interface{}{"type": 1},
+			"aiRes": map[string]interface{}{"title": title, "answer": []string{"A", "B"}, "right": []int{0}},
+		})
+		if resp["code"] != float64(0) {
+			t.Fatalf("add: %v", resp)
+		}
+	}
+
+	addQuestion("备份前")
+
+	status, resp := s.doWithHeader(t, admin, http.MethodPost, "/api/admin/backups", nil)
+	if status != http.StatusOK || resp["code"] != float64(0) {
+		t.Fatalf("create backup: status %d, resp %v", status, resp)
+	}
+	name := resp["backup"].(map[string]interface{})["name"].(string)
+
+	_, resp = s.doWithHeader(t, admin, http.MethodGet, "/api/admin/backups", nil)
+	if backups := resp["backups"].([]interface{}); len(backups) != 1 {
+		t.Fatalf("got %d backups, want 1", len(backups))
+	}
+
+	addQuestion("备份后")
+
+	// 恢复只能在服务停止时用命令行执行，运行中的服务不提供恢复接口
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/backups/restore", strings.NewReader(`{"name":"`+name+`"}`))
+	req.Header.Set(middleware.AdminTokenHeader, testAdminToken)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("restore while running: status %d", w.Code)
+	}
+	if _, total, _ := s.storage.ListQuestions(1, 10, 0, 0, ""); total != 2 {
+		t.Errorf("got %d questions, want 2", total)
+	}
+
+	_, resp = s.doWithHeader(t, admin, http.MethodGet, "/api/admin/integrity", nil)
+	if report := resp["report"].(map[string]interface{}); report["ok"] != true {
+		t.Errorf("integrity report: %v", report)
+	}
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 当前用户的题库权限，查询失败时写入错误响应并返回false
+func bankPermissions(ctx *gin.Context, courses *services.CourseService) (services.BankPermissions, bool) {
+	perms, err := courses.Access(clientPrincipal(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusOK, models.HTTPResponse{
+			Code: -1,
+			Msg:  "查询题库权限失败: " + err.Error(),
+		})
+		return nil, false
+	}
+	return perms, true
+}
+
+func forbidden(ctx *gin.Context, msg string) {
+	ctx.JSON(http.StatusForbidden, models.HTTPResponse{
+		Code: -1,
+		Msg:  msg,
+	})
+}
+
+// 查询使用的题库范围。bankID为0时是角色不低于min的全部题库，否则只有该题库
+func bankScope(ctx *gin.Context, perms services.BankPermissions, bankID int64, min models.CourseRole) ([]int64, bool) {
+	if bankID == 0 {
+		return perms.IDs(min), true
+	}
+	if !perms.Can(bankID, min) {
+		forbidden(ctx, fmt.Sprintf("没有权限访问题库%d", bankID))
+		return nil, false
+	}
+	return []int64{bankID}, true
+}
+
+// 要求ids中的每道题所在的题库都满足allowed，不存在的题目留给后续处理报告
+func requireQuestionBanks(ctx *gin.Context, storage *services.StorageService, ids []int64, allowed func(bankID int64) bool, action string) bool {
+	banks, err := storage.QuestionBanks(ids)
+	if err != nil {
+		ctx.JSON(http.StatusOK, models.HTTPResponse{
+			Code: -1,
+			Msg:  err.Error(),
+		})
+		return false
+	}
+	for _, id := range ids {
+		if bankID, ok := banks[id]; ok && !allowed(bankID) {
+			forbidden(ctx, fmt.Sprintf("没有权限%s题目%d", action, id))
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"question-generator/middleware"
+	"question-generator/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 已通过令牌验证的用户ID，匿名请求返回空字符串
+func authenticatedUserID(ctx *gin.Context) string {
+	return ctx.GetString(middleware.UserContextKey)
+}
+
+// 获取当前请求的用户标识，用于用量记录、配额和答卷。
+// 只采用令牌中验证过的用户ID，匿名请求以客户端IP作为标识
+func ClientUserID(ctx *gin.Context) string {
+	if userID := authenticatedUserID(ctx); userID != "" {
+		return userID
+	}
+	return ctx.ClientIP()
+}
+
+// 限流时区分调用者的键：验证过的用户按用户ID计数，匿名请求按客户端IP计数。
+// 客户端自己设置的请求头不影响计数，换一个请求头值拿不到新的令牌桶
+func RateLimitKey(ctx *gin.Context) string {
+	if userID := authenticatedUserID(ctx); userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+// 当前请求的用户，用于课程和题库的权限检查。
+// 匿名用户没有用户ID，只能以开放课程的身份访问
+func clientPrincipal(ctx *gin.Context) models.Principal {
+	return models.Principal{
+		UserID: authenticatedUserID(ctx),
+		Admin:  ctx.GetBool(middleware.AdminContextKey),
+	}
+}
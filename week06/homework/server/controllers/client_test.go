@@ -0,0 +1,53 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"question-generator/config"
+	"question-generator/controllers"
+	"question-generator/middleware"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.SetTrustedProxies(nil)
+	limiter := middleware.NewRateLimiter(config.RateLimitConfig{
+		Default: config.RateLimit{Requests: 1, Period: time.Minute, Burst: 1},
+	})
+	r.Use(middleware.Authenticate(testUserSecret), middleware.RateLimit(limiter, controllers.RateLimitKey))
+	r.GET("/api/questions/list", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(header http.Header) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/questions/list", nil)
+		req.Header = header
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if status := send(http.Header{}); status != http.StatusOK {
+		t.Fatalf("first request: %d", status)
+	}
+	// 换用户请求头或伪造转发地址都还是同一个匿名客户端
+	for _, header := range []http.Header{
+		{"X-User-Id": {"someone-else"}},
+		{"X-Forwarded-For": {"203.0.113.9"}},
+	} {
+		if status := send(header); status != http.StatusTooManyRequests {
+			t.Errorf("%v: status %d", header, status)
+		}
+	}
+	// 验证过的用户各自计数
+	for _, user := range []string{"alice", "bob"} {
+		token := middleware.SignUserToken(testUserSecret, user, time.Now().Add(time.Hour))
+		if status := send(http.Header{middleware.AuthorizationHeader: {"Bearer " + token}}); status != http.StatusOK {
+			t.Errorf("%s: status %d", user, status)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 课程和题库控制器
+type CourseController struct {
+	courses *services.CourseService
+}
+
+// 创建新的课程控制器
+func NewCourseController(courses *services.CourseService) *CourseController {
+	return &CourseController{
+		courses: courses,
+	}
+}
+
+// 创建课程，创建者成为课程的教师
+func (c *CourseController) CreateCourse(ctx *gin.Context) {
+	var req models.CourseCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	course, err := c.courses.CreateCourse(clientPrincipal(ctx), req.Name)
+	if err != nil {
+		c.fail(ctx, "创建课程失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"course": course,
+	})
+}
+
+// 当前用户参加的课程
+func (c *CourseController) ListCourses(ctx *gin.Context) {
+	courses, err := c.courses.ListCourses(clientPrincipal(ctx))
+	if err != nil {
+		c.fail(ctx, "查询课程失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"msg":     "",
+		"courses": courses,
+	})
+}
+
+// 课程详情，包括成员和题库
+func (c *CourseController) GetCourse(ctx *gin.Context) {
+	id, ok := c.idParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	course, err := c.courses.GetCourse(clientPrincipal(ctx), id)
+	if err != nil {
+		c.fail(ctx, "查询课程失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"course": course,
+	})
+}
+
+// 修改课程名称或开放状态
+func (c *CourseController) UpdateCourse(ctx *gin.Context) {
+	id, ok := c.idParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var req models.CourseUpdateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	if err := c.courses.UpdateCourse(clientPrincipal(ctx), id, req); err != nil {
+		c.fail(ctx, "修改课程失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "修改课程成功",
+	})
+}
+
+// 添加课程成员或修改成员角色
+func (c *CourseController) SetMember(ctx *gin.Context) {
+	id, ok := c.idParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var req models.CourseMemberRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+	if !req.Role.Valid() {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的角色: " + string(req.Role),
+		})
+		return
+	}
+
+	if err := c.courses.SetMember(clientPrincipal(ctx), id, req.UserID, req.Role); err != nil {
+		c.fail(ctx, "保存课程成员失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "保存课程成员成功",
+	})
+}
+
+// 移除课程成员
+func (c *CourseController) RemoveMember(ctx *gin.Context) {
+	id, ok := c.idParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := c.courses.RemoveMember(clientPrincipal(ctx), id, ctx.Param("userId")); err != nil {
+		c.fail(ctx, "移除课程成员失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "移除课程成员成功",
+	})
+}
+
+// 在课程中创建题库
+func (c *CourseController) CreateBank(ctx *gin.Context) {
+	id, ok := c.idParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var req models.BankCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	bank, err := c.courses.CreateBank(clientPrincipal(ctx), id, req.Name)
+	if err != nil {
+		c.fail(ctx, "创建题库失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "",
+		"bank": bank,
+	})
+}
+
+// 当前用户能访问的题库，包括共享来的题库
+func (c *CourseController) ListBanks(ctx *gin.Context) {
+	banks, err := c.courses.ListBanks(clientPrincipal(ctx))
+	if err != nil {
+		c.fail(ctx, "查询题库失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":  0,
+		"msg":   "",
+		"banks": banks,
+	})
+}
+
+// 把题库共享给另一门课程
+func (c *CourseController) ShareBank(ctx *gin.Context) {
+	id, ok := c.idParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var req models.BankShareRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	if err := c.courses.ShareBank(clientPrincipal(ctx), id, req.CourseID); err != nil {
+		c.fail(ctx, "共享题库失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "共享题库成功",
+	})
+}
+
+// 取消题库共享
+func (c *CourseController) UnshareBank(ctx *gin.Context) {
+	id, ok := c.idParam(ctx, "id")
+	if !ok {
+		return
+	}
+	courseID, ok := c.idParam(ctx, "courseId")
+	if !ok {
+		return
+	}
+
+	if err := c.courses.UnshareBank(clientPrincipal(ctx), id, courseID); err != nil {
+		c.fail(ctx, "取消共享失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "取消共享成功",
+	})
+}
+
+// 解析路径中的ID参数，无效时返回400
+func (c *CourseController) idParam(ctx *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的ID: " + ctx.Param(name),
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// 按错误类型返回对应的状态码，其余错误属于参数问题
+func (c *CourseController) fail(ctx *gin.Context, prefix string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, services.ErrCourseNotFound), errors.Is(err, services.ErrBankNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrLastTeacher):
+		status = http.StatusConflict
+	}
+	ctx.JSON(status, models.HTTPResponse{
+		Code: -1,
+		Msg:  prefix + err.Error(),
+	})
+}
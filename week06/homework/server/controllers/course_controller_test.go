@@ -0,0 +1,223 @@
+package controllers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"question-generator/middleware"
+	"testing"
+	"time"
+)
+
+func TestCourseBankIsolation(t *testing.T) {
+	s := newTestServer(t)
+
+	// 两位教师各自创建课程和题库
+	newBank := func(teacher, course string) (int64, int64) {
+		t.Helper()
+		_, resp := s.doAs(t, teacher, http.MethodPost, "/api/courses", map[string]interface{}{"name": course})
+		if resp["code"] != float64(0) {
+			t.Fatalf("create course: %v", resp)
+		}
+		courseID := int64(resp["course"].(map[string]interface{})["id"].(float64))
+		_, resp = s.doAs(t, teacher, http.MethodPost, "/api/courses/"+jsonNumber(courseID)+"/banks", map[string]interface{}{"name": course + "题库"})
+		if resp["code"] != float64(0) {
+			t.Fatalf("create bank: %v", resp)
+		}
+		return courseID, int64(resp["bank"].(map[string]interface{})["id"].(float64))
+	}
+	courseA, bankA := newBank("alice", "数据结构")
+	courseB, bankB := newBank("bob", "算法")
+
+	question := map[string]interface{}{
+		"bankId": bankA,
+		"aiReq":  map[string]interface{}{"type": 1},
+		"aiRes":  map[string]interface{}{"title": "栈", "answer": []string{"LIFO", "FIFO"}, "right": []int{0}},
+	}
+	if status, _ := s.doAs(t, "bob", http.MethodPost, "/api/questions/add", question); status != http.StatusForbidden {
+		t.Fatalf("bob adds to alice's bank: status %d", status)
+	}
+	_, resp := s.doAs(t, "alice", http.MethodPost, "/api/questions/add", question)
+	if resp["code"] != float64(0) {
+		t.Fatalf("add: %v", resp)
+	}
+	id := int64(resp["id"].(float64))
+
+	// 其他课程的教师看不到、改不了这道题
+	_, resp = s.doAs(t, "bob", http.MethodGet, "/api/questions/list", nil)
+	if resp["total"] != float64(0) {
+		t.Errorf("bob lists alice's question: %v", resp)
+	}
+	if status, _ := s.doAs(t, "bob", http.MethodGet, "/api/questions/list?bankId="+jsonNumber(bankA), nil); status != http.StatusForbidden {
+		t.Errorf("bob lists alice's bank: status %d", status)
+	}
+	if status, _ := s.doAs(t, "bob", http.MethodPut, "/api/questions/edit/"+jsonNumber(id), question); status != http.StatusForbidden {
+		t.Errorf("bob edits: status %d", status)
+	}
+	if status, _ := s.doAs(t, "bob", http.MethodDelete, "/api/questions/delete", map[string]interface{}{"ids": []int64{id}}); status != http.StatusForbidden {
+		t.Errorf("bob deletes: status %d", status)
+	}
+	_, resp = s.doAs(t, "bob", http.MethodPost, "/api/questions/bulk", map[string]interface{}{
+		"operation": "set_status", "status": "archived", "filter": "title=栈",
+	})
+	if resp["result"].(map[string]interface{})["matched"] != float64(0) {
+		t.Errorf("bob bulk by filter: %v", resp)
+	}
+
+	// 冒充身份的请求头和伪造的令牌都拿不到教师权限
+	forged := http.Header{"X-User-Id": {"alice"}}
+	if status, _ := s.doWithHeader(t, forged, http.MethodPut, "/api/questions/edit/"+jsonNumber(id), question); status != http.StatusForbidden {
+		t.Errorf("forged X-User-ID edits: status %d", status)
+	}
+	forged = http.Header{middleware.AuthorizationHeader: {"Bearer " + middleware.SignUserToken("wrong-secret", "alice", time.Now().Add(time.Hour))}}
+	if status, _ := s.doWithHeader(t, forged, http.MethodPut, "/api/questions/edit/"+jsonNumber(id), question); status != http.StatusUnauthorized {
+		t.Errorf("forged token edits: status %d", status)
+	}
+	if status, _ := s.do(t, http.MethodPost, "/api/courses", map[string]interface{}{"name": "匿名课程"}); status != http.StatusForbidden {
+		t.Errorf("anonymous creates course: status %d", status)
+	}
+
+	// 学生只能作答，不能查看题目列表
+	if _, resp = s.doAs(t, "alice", http.MethodPut, "/api/courses/"+jsonNumber(courseA)+"/members", map[string]interface{}{
+		"userId": "carol", "role": "student",
+	}); resp["code"] != float64(0) {
+		t.Fatalf("add student: %v", resp)
+	}
+	if status, _ := s.doAs(t, "carol", http.MethodGet, "/api/questions/list?bankId="+jsonNumber(bankA), nil); status != http.StatusForbidden {
+		t.Errorf("student lists questions: status %d", status)
+	}
+	_, resp = s.doAs(t, "carol", http.MethodPost, "/api/exams/grade", map[string]interface{}{
+		"answers": []map[string]interface{}{{"questionId": id, "selected": []int{0}}},
+	})
+	if resp["code"] != float64(0) {
+		t.Errorf("student grade: %v", resp)
+	}
+	if status, _ := s.doAs(t, "bob", http.MethodPost, "/api/exams/grade", map[string]interface{}{
+		"answers": []map[string]interface{}{{"questionId": id, "selected": []int{0}}},
+	}); status != http.StatusForbidden {
+		t.Errorf("outsider grade: status %d", status)
+	}
+
+	// 统计、题目分析和题目中的图片同样按题库隔离
+	_, resp = s.doAs(t, "bob", http.MethodGet, "/api/stats/overview", nil)
+	if total := resp["stats"].(map[string]interface{})["total"]; total != float64(0) {
+		t.Errorf("bob's stats total = %v", total)
+	}
+	_, resp = s.doAs(t, "alice", http.MethodGet, "/api/stats/overview?bankId="+jsonNumber(bankA), nil)
+	if total := resp["stats"].(map[string]interface{})["total"]; total != float64(1) {
+		t.Errorf("alice's stats total = %v", total)
+	}
+	_, resp = s.doAs(t, "bob", http.MethodGet, "/api/stats/items", nil)
+	if items := resp["report"].(map[string]interface{})["items"].([]interface{}); len(items) != 0 {
+		t.Errorf("bob's item analysis = %v", items)
+	}
+	_, resp = s.doAs(t, "alice", http.MethodGet, "/api/stats/items", nil)
+	if items := resp["report"].(map[string]interface{})["items"].([]interface{}); len(items) != 1 {
+		t.Errorf("alice's item analysis = %v", items)
+	}
+	if status, _ := s.doAs(t, "bob", http.MethodGet, "/api/stats/items/"+jsonNumber(id), nil); status != http.StatusForbidden {
+		t.Errorf("bob analyzes alice's question: status %d", status)
+	}
+	if status, _ := s.doAs(t, "carol", http.MethodGet, "/api/stats/items/"+jsonNumber(id), nil); status != http.StatusForbidden {
+		t.Errorf("student analyzes question: status %d", status)
+	}
+
+	_, resp = s.upload(t, "图.png", testPNG())
+	image := resp["attachment"].(map[string]interface{})["url"].(string)
+	if _, resp = s.doAs(t, "alice", http.MethodPost, "/api/questions/add", map[string]interface{}{
+		"bankId": bankA,
+		"aiReq":  map[string]interface{}{"type": 1},
+		"aiRes":  map[string]interface{}{"title": "看图 ![图](" + image + ")", "answer": []string{"是", "否"}, "right": []int{0}},
+	}); resp["code"] != float64(0) {
+		t.Fatalf("add question with image: %v", resp)
+	}
+	// 浏览器用<img>加载图片时不带令牌，私有题库中的图片也只凭地址中的内容哈希访问
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, image, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("image in private bank without token: status %d", w.Code)
+	}
+
+	// 共享后对方只读，可以复制到自己的题库
+	if status, _ := s.doAs(t, "bob", http.MethodPost, "/api/banks/"+jsonNumber(bankA)+"/shares", map[string]interface{}{"courseId": 1}); status != http.StatusForbidden {
+		t.Errorf("bob shares alice's bank: status %d", status)
+	}
+	if _, resp = s.doAs(t, "alice", http.MethodPost, "/api/banks/"+jsonNumber(bankA)+"/shares", map[string]interface{}{
+		"courseId": courseB,
+	}); resp["code"] != float64(0) {
+		t.Fatalf("share: %v", resp)
+	}
+	_, resp = s.doAs(t, "bob", http.MethodGet, "/api/questions/list?bankId="+jsonNumber(bankA), nil)
+	if resp["total"] != float64(2) {
+		t.Errorf("bob lists shared bank: %v", resp)
+	}
+	if status, _ := s.doAs(t, "bob", http.MethodPut, "/api/questions/edit/"+jsonNumber(id), question); status != http.StatusForbidden {
+		t.Errorf("bob edits shared question: status %d", status)
+	}
+	_, resp = s.doAs(t, "bob", http.MethodPost, "/api/questions/copy", map[string]interface{}{"ids": []int64{id}, "bankId": bankB})
+	if resp["code"] != float64(0) || len(resp["ids"].([]interface{})) != 1 {
+		t.Fatalf("copy: %v", resp)
+	}
+	_, resp = s.doAs(t, "bob", http.MethodGet, "/api/questions/list?bankId="+jsonNumber(bankB), nil)
+	if resp["total"] != float64(1) {
+		t.Errorf("bob's bank after copy: %v", resp)
+	}
+
+	// 移动题目时目标题库也需要能修改
+	if status, _ := s.doAs(t, "alice", http.MethodPost, "/api/questions/bulk", map[string]interface{}{
+		"operation": "move_to_bank", "bankId": bankB, "ids": []int64{id},
+	}); status != http.StatusForbidden {
+		t.Errorf("move into foreign bank: status %d", status)
+	}
+	_, resp = s.doAs(t, "alice", http.MethodPost, "/api/questions/bulk", map[string]interface{}{
+		"operation": "move_to_bank", "bankId": 1, "ids": []int64{id},
+	})
+	if resp["result"].(map[string]interface{})["changed"] != float64(1) {
+		t.Errorf("move to default bank: %v", resp)
+	}
+	if q, _ := s.storage.GetQuestionByID(id); q.BankID != 1 {
+		t.Errorf("bank after move = %d", q.BankID)
+	}
+}
+
+func TestCourseManagement(t *testing.T) {
+	s := newTestServer(t)
+
+	_, resp := s.doAs(t, "alice", http.MethodPost, "/api/courses", map[string]interface{}{"name": "编译原理"})
+	courseID := jsonNumber(int64(resp["course"].(map[string]interface{})["id"].(float64)))
+
+	if status, _ := s.doAs(t, "bob", http.MethodGet, "/api/courses/"+courseID, nil); status != http.StatusForbidden {
+		t.Errorf("outsider get course: status %d", status)
+	}
+	if status, _ := s.doAs(t, "alice", http.MethodGet, "/api/courses/999", nil); status != http.StatusNotFound {
+		t.Errorf("missing course: status %d", status)
+	}
+	if status, _ := s.doAs(t, "alice", http.MethodPut, "/api/courses/"+courseID+"/members", map[string]interface{}{
+		"userId": "bob", "role": "owner",
+	}); status != http.StatusBadRequest {
+		t.Errorf("invalid role: status %d", status)
+	}
+	if status, _ := s.doAs(t, "alice", http.MethodDelete, "/api/courses/"+courseID+"/members/alice", nil); status != http.StatusConflict {
+		t.Errorf("remove last teacher: status %d", status)
+	}
+
+	// 只有管理员能管理开放的默认课程
+	if status, _ := s.doAs(t, "alice", http.MethodPut, "/api/courses/1", map[string]interface{}{"open": false}); status != http.StatusForbidden {
+		t.Errorf("member closes default course: status %d", status)
+	}
+	header := http.Header{}
+	header.Set(middleware.AdminTokenHeader, testAdminToken)
+	if _, resp = s.doWithHeader(t, header, http.MethodPut, "/api/courses/1", map[string]interface{}{"open": false}); resp["code"] != float64(0) {
+		t.Fatalf("admin closes default course: %v", resp)
+	}
+	if status, _ := s.doAs(t, "bob", http.MethodGet, "/api/questions/list", nil); status != http.StatusOK {
+		t.Errorf("list without banks: status %d", status)
+	}
+	if status, _ := s.doAs(t, "bob", http.MethodPost, "/api/questions/create", map[string]interface{}{"type": 1}); status != http.StatusForbidden {
+		t.Errorf("generate without writable bank: status %d", status)
+	}
+
+	_, resp = s.doAs(t, "alice", http.MethodGet, "/api/courses", nil)
+	if courses := resp["courses"].([]interface{}); len(courses) != 1 {
+		t.Errorf("alice's courses: %v", courses)
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 考试控制器
+type ExamController struct {
+	storage  *services.StorageService
+	analysis *services.ItemAnalysisService
+	practice *services.PracticeService
+	courses  *services.CourseService
+	webhooks *services.WebhookService
+}
+
+// 创建新的考试控制器
+func NewExamController(storage *services.StorageService, analysis *services.ItemAnalysisService, practice *services.PracticeService, courses *services.CourseService, webhooks *services.WebhookService) *ExamController {
+	return &ExamController{
+		storage:  storage,
+		analysis: analysis,
+		practice: practice,
+		courses:  courses,
+		webhooks: webhooks,
+	}
+}
+
+// 交卷并批改，返回每道题的解析和所选错误选项的错误原因
+func (c *ExamController) GradeExam(ctx *gin.Context) {
+	var req models.ExamSubmitRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	if len(req.Answers) == 0 {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "答卷不能为空",
+		})
+		return
+	}
+
+	// 只能作答自己所在课程能使用的题目
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	ids := make([]int64, len(req.Answers))
+	for i, answer := range req.Answers {
+		ids[i] = answer.QuestionID
+	}
+	canRead := func(bankID int64) bool { return perms.Can(bankID, models.RoleStudent) }
+	if !requireQuestionBanks(ctx, c.storage, ids, canRead, "作答") {
+		return
+	}
+
+	result, err := c.storage.GradeExam(ClientUserID(ctx), req.Answers)
+	if err != nil {
+		status := http.StatusOK
+		if errors.Is(err, services.ErrNotGradable) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, models.HTTPResponse{
+			Code: -1,
+			Msg:  "批改失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.analysis.AfterExam(result)
+	// 错题本只是附带功能，写入失败不影响交卷
+	if err := c.practice.RecordExam(ClientUserID(ctx), result); err != nil {
+		slog.Warn("记录错题失败", "error", err)
+	}
+	c.webhooks.Emit(models.EventExamSubmitted, models.ExamSubmittedData{UserID: ClientUserID(ctx), Result: result})
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"result": result,
+	})
+}
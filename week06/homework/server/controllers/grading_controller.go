@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 开放题批改控制器：评分标准、模型批改、教师改分和批量批改任务，
+// 都要求能修改题目所在的题库
+type GradingController struct {
+	grader  *services.GraderService
+	storage *services.StorageService
+	courses *services.CourseService
+}
+
+// 创建新的批改控制器
+func NewGradingController(grader *services.GraderService, storage *services.StorageService, courses *services.CourseService) *GradingController {
+	return &GradingController{
+		grader:  grader,
+		storage: storage,
+		courses: courses,
+	}
+}
+
+// 查询题目的评分标准
+func (c *GradingController) GetRubric(ctx *gin.Context) {
+	questionID, ok := c.idParam(ctx, "questionId")
+	if !ok || !c.requireWritable(ctx, questionID) {
+		return
+	}
+
+	rubric, err := c.grader.GetRubric(questionID)
+	if err != nil {
+		c.fail(ctx, "查询评分标准失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"rubric": rubric,
+	})
+}
+
+// 设置题目的评分标准
+func (c *GradingController) SetRubric(ctx *gin.Context) {
+	questionID, ok := c.idParam(ctx, "questionId")
+	if !ok {
+		return
+	}
+	var req models.RubricRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+	if !c.requireWritable(ctx, questionID) {
+		return
+	}
+
+	rubric, err := c.grader.SetRubric(questionID, req.Criteria)
+	if err != nil {
+		c.fail(ctx, "设置评分标准失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "设置评分标准成功",
+		"rubric": rubric,
+	})
+}
+
+// 查询一道题的作答及批改结果
+func (c *GradingController) ListResponses(ctx *gin.Context) {
+	var q models.ResponseGradeQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的查询参数: " + err.Error(),
+		})
+		return
+	}
+	if !c.requireWritable(ctx, q.QuestionID) {
+		return
+	}
+
+	list, err := c.grader.ListResponses(q)
+	if err != nil {
+		c.fail(ctx, "查询作答失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":      0,
+		"msg":       "",
+		"responses": list,
+	})
+}
+
+// 调用模型按评分标准批改一个作答
+func (c *GradingController) Grade(ctx *gin.Context) {
+	attemptID, questionID, ok := c.responseParams(ctx)
+	if !ok {
+		return
+	}
+	// 请求体可以省略，使用默认模型
+	var req models.GradeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+	if !c.requireWritable(ctx, questionID) {
+		return
+	}
+
+	grade, err := c.grader.Grade(ctx.Request.Context(), attemptID, questionID, req.Provider, ClientUserID(ctx))
+	if err != nil {
+		c.fail(ctx, "批改失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":     0,
+		"msg":      "批改成功",
+		"response": grade,
+	})
+}
+
+// 教师修改一个作答的分数
+func (c *GradingController) Override(ctx *gin.Context) {
+	attemptID, questionID, ok := c.responseParams(ctx)
+	if !ok {
+		return
+	}
+	var req models.GradeOverrideRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+	if !c.requireWritable(ctx, questionID) {
+		return
+	}
+
+	grade, err := c.grader.Override(ClientUserID(ctx), attemptID, questionID, req)
+	if err != nil {
+		c.fail(ctx, "修改分数失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":     0,
+		"msg":      "修改分数成功",
+		"response": grade,
+	})
+}
+
+// 提交批量批改任务，立即返回任务，批改在后台进行
+func (c *GradingController) StartJob(ctx *gin.Context) {
+	var req models.GradingJobRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+	if !c.requireAllWritable(ctx, req.QuestionIDs) {
+		return
+	}
+
+	job, err := c.grader.StartJob(ClientUserID(ctx), req)
+	if err != nil {
+		c.fail(ctx, "提交批改任务失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"code": 0,
+		"msg":  fmt.Sprintf("已提交批改任务，共%d份作答", job.Total),
+		"job":  job,
+	})
+}
+
+// 查询批量批改任务的进度
+func (c *GradingController) GetJob(ctx *gin.Context) {
+	id, ok := c.idParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	job, err := c.grader.GetJob(id)
+	if err != nil {
+		c.fail(ctx, "查询批改任务失败: ", err)
+		return
+	}
+	if !c.requireAllWritable(ctx, job.QuestionIDs) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "",
+		"job":  job,
+	})
+}
+
+// 模型与教师评分的一致程度
+func (c *GradingController) Calibration(ctx *gin.Context) {
+	var q models.CalibrationQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的查询参数: " + err.Error(),
+		})
+		return
+	}
+	if !c.requireWritable(ctx, q.QuestionID) {
+		return
+	}
+
+	calibration, err := c.grader.Calibration(q.QuestionID)
+	if err != nil {
+		c.fail(ctx, "查询校准情况失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":        0,
+		"msg":         "",
+		"calibration": calibration,
+	})
+}
+
+// 要求题目存在且能修改题目所在的题库
+func (c *GradingController) requireWritable(ctx *gin.Context, questionID int64) bool {
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return false
+	}
+	banks, err := c.storage.QuestionBanks([]int64{questionID})
+	if err != nil {
+		c.fail(ctx, "", err)
+		return false
+	}
+	bankID, ok := banks[questionID]
+	if !ok {
+		c.fail(ctx, "", fmt.Errorf("%w: ID=%d", services.ErrQuestionNotFound, questionID))
+		return false
+	}
+	if !perms.CanWrite(bankID) {
+		forbidden(ctx, fmt.Sprintf("没有权限批改题目%d", questionID))
+		return false
+	}
+	return true
+}
+
+// 要求能修改ids中每道题所在的题库
+func (c *GradingController) requireAllWritable(ctx *gin.Context, ids []int64) bool {
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return false
+	}
+	return requireQuestionBanks(ctx, c.storage, ids, perms.CanWrite, "批改")
+}
+
+// 解析路径中的答卷ID和题目ID
+func (c *GradingController) responseParams(ctx *gin.Context) (int64, int64, bool) {
+	attemptID, ok := c.idParam(ctx, "attemptId")
+	if !ok {
+		return 0, 0, false
+	}
+	questionID, ok := c.idParam(ctx, "questionId")
+	if !ok {
+		return 0, 0, false
+	}
+	return attemptID, questionID, true
+}
+
+// 解析路径中的ID参数，无效时返回400
+func (c *GradingController) idParam(ctx *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的ID: " + ctx.Param(name),
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// 按错误类型返回对应的状态码
+func (c *GradingController) fail(ctx *gin.Context, prefix string, err error) {
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, services.ErrQuestionNotFound), errors.Is(err, services.ErrResponseNotFound),
+		errors.Is(err, services.ErrGradingJobNotFound), errors.Is(err, services.ErrNoRubric):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrRubricNotSupported), errors.Is(err, services.ErrInvalidGrade):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrQuotaExceeded):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, services.ErrShuttingDown):
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, models.HTTPResponse{
+		Code: -1,
+		Msg:  prefix + err.Error(),
+	})
+}
@@ -0,0 +1,108 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestRubricGrading(t *testing.T) {
+	s := newTestServer(t)
+
+	_, resp := s.do(t, http.MethodPost, "/api/questions/add", map[string]interface{}{
+		"aiReq": map[string]interface{}{"type": 6},
+		"aiRes": map[string]interface{}{"title": "简述defer", "reference": "后进先出"},
+	})
+	id := int64(resp["id"].(float64))
+	rubricPath := "/api/grading/rubrics/" + jsonNumber(id)
+
+	if status, _ := s.do(t, http.MethodGet, rubricPath, nil); status != http.StatusNotFound {
+		t.Errorf("missing rubric: status %d", status)
+	}
+	if status, _ := s.do(t, http.MethodPut, rubricPath, map[string]interface{}{
+		"criteria": []map[string]interface{}{{"name": "概念准确", "points": 0}},
+	}); status != http.StatusBadRequest {
+		t.Errorf("invalid rubric: status %d", status)
+	}
+	_, resp = s.do(t, http.MethodPut, rubricPath, map[string]interface{}{
+		"criteria": []map[string]interface{}{{"name": "概念准确", "points": 6}, {"name": "表达清晰", "points": 4}},
+	})
+	if resp["code"] != float64(0) || resp["rubric"].(map[string]interface{})["maxScore"] != float64(10) {
+		t.Fatalf("set rubric: %v", resp)
+	}
+
+	var attempts []int64
+	for _, user := range []string{"alice", "bob"} {
+		_, resp = s.doAs(t, user, http.MethodPost, "/api/exams/grade", map[string]interface{}{
+			"answers": []map[string]interface{}{{"questionId": id, "text": "函数返回前按后进先出执行"}},
+		})
+		attempts = append(attempts, int64(resp["result"].(map[string]interface{})["attemptId"].(float64)))
+	}
+
+	// 不存在的作答
+	if status, _ := s.do(t, http.MethodPost, "/api/grading/responses/999/"+jsonNumber(id)+"/grade", nil); status != http.StatusNotFound {
+		t.Errorf("missing response: status %d", status)
+	}
+
+	_, resp = s.do(t, http.MethodPost, "/api/grading/responses/"+jsonNumber(attempts[0])+"/"+jsonNumber(id)+"/grade", nil)
+	response := resp["response"].(map[string]interface{})
+	if resp["code"] != float64(0) || response["status"] != "graded" || response["score"] != float64(6) {
+		t.Fatalf("grade: %v", resp)
+	}
+
+	status, resp := s.do(t, http.MethodPost, "/api/grading/jobs", map[string]interface{}{"questionIds": []int64{id}})
+	if status != http.StatusAccepted {
+		t.Fatalf("start job: status %d, resp %v", status, resp)
+	}
+	job := resp["job"].(map[string]interface{})
+	if job["total"] != float64(1) {
+		t.Errorf("job total: %v", job)
+	}
+	if err := s.jobs.Shutdown(context.Background()); err != nil {
+		t.Fatalf("wait for job: %v", err)
+	}
+	_, resp = s.do(t, http.MethodGet, "/api/grading/jobs/"+jsonNumber(int64(job["id"].(float64))), nil)
+	if job = resp["job"].(map[string]interface{}); job["status"] != "done" || job["graded"] != float64(1) {
+		t.Errorf("finished job: %v", job)
+	}
+
+	_, resp = s.do(t, http.MethodPut, "/api/grading/responses/"+jsonNumber(attempts[1])+"/"+jsonNumber(id)+"/override", map[string]interface{}{
+		"scores": []map[string]interface{}{{"criterion": "概念准确", "score": 5}, {"criterion": "表达清晰", "score": 4}},
+	})
+	if response = resp["response"].(map[string]interface{}); response["status"] != "overridden" || response["score"] != float64(9) {
+		t.Fatalf("override: %v", resp)
+	}
+
+	_, resp = s.do(t, http.MethodGet, "/api/grading/responses?questionId="+jsonNumber(id), nil)
+	if list := resp["responses"].([]interface{}); len(list) != 2 {
+		t.Errorf("responses: %v", list)
+	}
+	_, resp = s.do(t, http.MethodGet, "/api/grading/calibration?questionId="+jsonNumber(id), nil)
+	calibration := resp["calibration"].(map[string]interface{})
+	if calibration["samples"] != float64(1) || calibration["meanDiff"] != float64(-3) || calibration["agreement"] != float64(1) {
+		t.Errorf("calibration: %v", calibration)
+	}
+
+	// 学生不能批改
+	_, resp = s.doAs(t, "alice", http.MethodPost, "/api/courses", map[string]interface{}{"name": "Go语言"})
+	courseID := jsonNumber(int64(resp["course"].(map[string]interface{})["id"].(float64)))
+	_, resp = s.doAs(t, "alice", http.MethodPost, "/api/courses/"+courseID+"/banks", map[string]interface{}{"name": "期末"})
+	bankID := int64(resp["bank"].(map[string]interface{})["id"].(float64))
+	s.doAs(t, "alice", http.MethodPut, "/api/courses/"+courseID+"/members", map[string]interface{}{"userId": "bob", "role": "student"})
+	_, resp = s.doAs(t, "alice", http.MethodPost, "/api/questions/add", map[string]interface{}{
+		"bankId": bankID,
+		"aiReq":  map[string]interface{}{"type": 3},
+		"aiRes":  map[string]interface{}{"title": "实现LRU"},
+	})
+	private := jsonNumber(int64(resp["id"].(float64)))
+	if status, _ := s.doAs(t, "bob", http.MethodPut, "/api/grading/rubrics/"+private, map[string]interface{}{
+		"criteria": []map[string]interface{}{{"name": "思路", "points": 5}},
+	}); status != http.StatusForbidden {
+		t.Errorf("student sets rubric: status %d", status)
+	}
+	if _, resp = s.doAs(t, "alice", http.MethodPut, "/api/grading/rubrics/"+private, map[string]interface{}{
+		"criteria": []map[string]interface{}{{"name": "思路", "points": 5}},
+	}); resp["code"] != float64(0) {
+		t.Errorf("teacher sets programming rubric: %v", resp)
+	}
+}
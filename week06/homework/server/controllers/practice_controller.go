@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 今日复习单次最多返回的到期题目数和新题数
+const (
+	maxPracticeLimit = 100
+	maxPracticeNew   = 50
+)
+
+// 练习控制器
+type PracticeController struct {
+	practice *services.PracticeService
+	storage  *services.StorageService
+	courses  *services.CourseService
+}
+
+// 创建新的练习控制器
+func NewPracticeController(practice *services.PracticeService, storage *services.StorageService, courses *services.CourseService) *PracticeController {
+	return &PracticeController{
+		practice: practice,
+		storage:  storage,
+		courses:  courses,
+	}
+}
+
+// 提交一道题的练习作答，返回批改结果和下次复习时间
+func (c *PracticeController) Answer(ctx *gin.Context) {
+	var req models.PracticeAnswerRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Grade != 0 && (req.Grade < 3 || req.Grade > 5) {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "自评必须在3到5之间",
+		})
+		return
+	}
+
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	canRead := func(bankID int64) bool { return perms.Can(bankID, models.RoleStudent) }
+	if !requireQuestionBanks(ctx, c.storage, []int64{req.QuestionID}, canRead, "练习") {
+		return
+	}
+
+	result, err := c.practice.Answer(ClientUserID(ctx), req)
+	if err != nil {
+		c.fail(ctx, "提交练习失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"result": result,
+	})
+}
+
+// 今日复习
+func (c *PracticeController) Today(ctx *gin.Context) {
+	var req models.PracticeTodayRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的查询参数: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Limit < 0 || req.Limit > maxPracticeLimit {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "limit必须在1到100之间",
+		})
+		return
+	}
+	if req.New != nil && (*req.New < 0 || *req.New > maxPracticeNew) {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "new必须在0到50之间",
+		})
+		return
+	}
+
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	if req.BankIDs, ok = bankScope(ctx, perms, req.BankID, models.RoleStudent); !ok {
+		return
+	}
+
+	today, err := c.practice.Today(ClientUserID(ctx), req)
+	if err != nil {
+		c.fail(ctx, "查询今日复习失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":  0,
+		"msg":   "",
+		"today": today,
+	})
+}
+
+// 错题本
+func (c *PracticeController) Notebook(ctx *gin.Context) {
+	entries, err := c.practice.Notebook(ClientUserID(ctx))
+	if err != nil {
+		c.fail(ctx, "查询错题本失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"msg":     "",
+		"entries": entries,
+	})
+}
+
+// 把题目移出错题本
+func (c *PracticeController) RemoveFromNotebook(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的题目ID",
+		})
+		return
+	}
+
+	if err := c.practice.RemoveFromNotebook(ClientUserID(ctx), id); err != nil {
+		c.fail(ctx, "移出错题本失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "已移出错题本",
+	})
+}
+
+// 按标签统计的掌握情况
+func (c *PracticeController) Mastery(ctx *gin.Context) {
+	report, err := c.practice.Mastery(ClientUserID(ctx))
+	if err != nil {
+		c.fail(ctx, "统计掌握情况失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"report": report,
+	})
+}
+
+// 按错误类型返回对应的状态码
+func (c *PracticeController) fail(ctx *gin.Context, prefix string, err error) {
+	status := http.StatusOK
+	if errors.Is(err, services.ErrQuestionNotFound) || errors.Is(err, services.ErrNotInNotebook) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, services.ErrNotGradable) {
+		status = http.StatusBadRequest
+	}
+	ctx.JSON(status, models.HTTPResponse{
+		Code: -1,
+		Msg:  prefix + err.Error(),
+	})
+}
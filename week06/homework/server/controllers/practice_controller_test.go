@@ -0,0 +1,79 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestPracticeEndpoints(t *testing.T) {
+	s := newTestServer(t)
+
+	_, resp := s.do(t, http.MethodPost, "/api/questions/add", map[string]interface{}{
+		"aiReq": map[string]interface{}{"type": 1},
+		"aiRes": map[string]interface{}{"title": "题目", "answer": []string{"A", "B"}, "right": []int{0}},
+		"tags":  []string{"基础"},
+	})
+	if resp["code"] != float64(0) {
+		t.Fatalf("add: %v", resp)
+	}
+	id := int64(resp["id"].(float64))
+
+	// 考试答错后进入错题本
+	_, resp = s.doAs(t, "alice", http.MethodPost, "/api/exams/grade", map[string]interface{}{
+		"answers": []map[string]interface{}{{"questionId": id, "selected": []int{1}}},
+	})
+	if resp["code"] != float64(0) {
+		t.Fatalf("grade: %v", resp)
+	}
+	_, resp = s.doAs(t, "alice", http.MethodGet, "/api/practice/notebook", nil)
+	entries := resp["entries"].([]interface{})
+	if len(entries) != 1 {
+		t.Fatalf("notebook: %v", resp)
+	}
+	if _, ok := entries[0].(map[string]interface{})["question"].(map[string]interface{})["right"]; ok {
+		t.Errorf("notebook leaks answer: %v", entries[0])
+	}
+
+	status, resp := s.doAs(t, "alice", http.MethodPost, "/api/practice/answer", map[string]interface{}{
+		"questionId": id, "selected": []int{0}, "grade": 5,
+	})
+	if status != http.StatusOK || resp["code"] != float64(0) {
+		t.Fatalf("answer: status %d, %v", status, resp)
+	}
+	card := resp["result"].(map[string]interface{})["card"].(map[string]interface{})
+	if card["reviews"] != float64(2) || card["inNotebook"] != true {
+		t.Errorf("card: %v", card)
+	}
+
+	if status, _ := s.do(t, http.MethodPost, "/api/practice/answer", map[string]interface{}{"questionId": id, "grade": 7}); status != http.StatusBadRequest {
+		t.Errorf("invalid grade: status %d", status)
+	}
+	if status, _ := s.do(t, http.MethodPost, "/api/practice/answer", map[string]interface{}{"questionId": 9999}); status != http.StatusNotFound {
+		t.Errorf("missing question: status %d", status)
+	}
+	if status, _ := s.do(t, http.MethodGet, "/api/practice/today?limit=1000", nil); status != http.StatusBadRequest {
+		t.Errorf("invalid limit: status %d", status)
+	}
+
+	// 其他学生没有练习过，这道题作为新题
+	_, resp = s.doAs(t, "bob", http.MethodGet, "/api/practice/today?tag=基础", nil)
+	today := resp["today"].(map[string]interface{})
+	if today["due"] != float64(0) || len(today["items"].([]interface{})) != 1 {
+		t.Errorf("bob's today: %v", resp)
+	}
+
+	_, resp = s.doAs(t, "alice", http.MethodGet, "/api/practice/mastery", nil)
+	overall := resp["report"].(map[string]interface{})["overall"].(map[string]interface{})
+	if overall["questions"] != float64(1) || overall["practiced"] != float64(1) || overall["accuracy"] != 0.5 {
+		t.Errorf("mastery: %v", resp)
+	}
+
+	path := fmt.Sprintf("/api/practice/notebook/%d", id)
+	if status, _ := s.doAs(t, "alice", http.MethodDelete, path, nil); status != http.StatusOK {
+		t.Errorf("remove: status %d", status)
+	}
+	if status, _ := s.doAs(t, "alice", http.MethodDelete, path, nil); status != http.StatusNotFound {
+		t.Errorf("remove twice: status %d", status)
+	}
+}
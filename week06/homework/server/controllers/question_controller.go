@@ -2,31 +2,50 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"question-generator/models"
 	"question-generator/services"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // 问题控制器
 type QuestionController struct {
 	aiClient *services.AIClient
 	storage  *services.StorageService
+	courses  *services.CourseService
+	webhooks *services.WebhookService
 }
 
 // 创建新的问题控制器
-func NewQuestionController(aiClient *services.AIClient, storage *services.StorageService) *QuestionController {
+func NewQuestionController(aiClient *services.AIClient, storage *services.StorageService, courses *services.CourseService, webhooks *services.WebhookService) *QuestionController {
 	return &QuestionController{
 		aiClient: aiClient,
 		storage:  storage,
+		courses:  courses,
+		webhooks: webhooks,
 	}
 }
 
+// 发布携带题目完整内容的事件，题目读取失败时只记录日志
+func (c *QuestionController) emitQuestion(event models.WebhookEvent, id int64) {
+	question, err := c.storage.GetQuestionByID(id)
+	if err != nil {
+		slog.Warn("读取题目失败，未发布webhook事件", "event", event, "question_id", id, "error", err)
+		return
+	}
+	c.webhooks.Emit(event, question)
+}
+
 // 创建新问题的处理器
 func (c *QuestionController) CreateQuestion(ctx *gin.Context) {
 	body, err := io.ReadAll(ctx.Request.Body)
@@ -53,11 +72,13 @@ func (c *QuestionController) CreateQuestion(ctx *gin.Context) {
 
 	// 检查是否存在未知字段
 	validFields := map[string]bool{
-		"model":      true,
-		"language":   true,
-		"type":       true,
-		"difficulty": true,
-		"count":      true,
+		"model":       true,
+		"language":    true,
+		"type":        true,
+		"difficulty":  true,
+		"count":       true,
+		"review":      true,
+		"reviewModel": true,
 	}
 
 	for field := range rawRequest {
@@ -80,6 +101,24 @@ func (c *QuestionController) CreateQuestion(ctx *gin.Context) {
 		return
 	}
 
+	if req.Type != 0 && !req.Type.Valid() {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  fmt.Sprintf("不支持的题型: %d", req.Type),
+		})
+		return
+	}
+
+	// 出题消耗AI额度，只有能修改某个题库的用户可以出题
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	if len(perms.WritableIDs()) == 0 {
+		forbidden(ctx, "没有可以修改的题库，不能出题")
+		return
+	}
+
 	// 获取题目数量
 	count := req.GetCount()
 	if count <= 0 {
@@ -87,25 +126,51 @@ func (c *QuestionController) CreateQuestion(ctx *gin.Context) {
 	}
 
 	// 生成题目逻辑处理
-	questionsList, err := c.aiClient.BatchGenerateQuestions(&req, count)
+	userID := ClientUserID(ctx)
+	questionsList, err := c.aiClient.BatchGenerateQuestions(ctx.Request.Context(), &req, count, userID)
 	if err != nil {
-		ctx.JSON(http.StatusOK, models.HTTPResponse{
+		if ctx.Request.Context().Err() != nil {
+			slog.InfoContext(ctx.Request.Context(), "客户端已断开，取消生成题目", "error", err)
+			return
+		}
+		status := http.StatusOK
+		if errors.Is(err, services.ErrQuotaExceeded) {
+			status = http.StatusTooManyRequests
+		}
+		ctx.JSON(status, models.HTTPResponse{
 			Code: -2,
 			Msg:  "生成题目失败: " + err.Error(),
 		})
 		return
 	}
 
-	// 处理每个题目的类型一致性
-	for i := range questionsList {
-		requestedProgrammingQuestion := req.GetQuestionType() == models.Programming
-		generatedProgrammingQuestion := len(questionsList[i].AIRes.Answer) == 0 || questionsList[i].AIRes.Code != ""
-
-		if requestedProgrammingQuestion != generatedProgrammingQuestion {
-			if generatedProgrammingQuestion {
+	// 模型有时把选择类题目写成编程题：没有给出可用的选项，而是给出了代码。
+	// 这样的题目不符合所请求题型的校验，按编程题保存
+	if spec, ok := models.LookupQuestionType(req.GetQuestionType()); ok && spec.Input == models.InputChoice {
+		for i := range questionsList {
+			res := &questionsList[i].AIRes
+			if (len(res.Answer) == 0 || res.Code != "") && spec.Validate(res) != nil {
 				questionsList[i].AIReq.Type = models.Programming
-			} else {
-				questionsList[i].AIRes.Code = ""
+			}
+		}
+	}
+
+	msg := fmt.Sprintf("成功生成%d个题目", len(questionsList))
+
+	// 二次审核失败不影响出题结果，只在提示信息中说明
+	if req.Review {
+		if err := c.aiClient.ReviewQuestions(ctx.Request.Context(), req.ReviewModel, userID, questionsList); err != nil {
+			slog.WarnContext(ctx.Request.Context(), "题目审核失败", "error", err)
+			msg += "，审核失败: " + err.Error()
+		} else {
+			disagree := 0
+			for _, q := range questionsList {
+				if q.AIRes.Review != nil && q.AIRes.Review.Disagree {
+					disagree++
+				}
+			}
+			if disagree > 0 {
+				msg += fmt.Sprintf("，其中%d个题目的审核答案与标注答案不一致", disagree)
 			}
 		}
 	}
@@ -116,10 +181,16 @@ func (c *QuestionController) CreateQuestion(ctx *gin.Context) {
 		aiResList[i] = q.AIRes
 	}
 
+	c.webhooks.Emit(models.EventGenerationCompleted, models.GenerationCompletedData{
+		UserID:    userID,
+		Request:   req,
+		Questions: aiResList,
+	})
+
 	// 返回成功响应（直接返回aiRes数组，不保存到数据库，等客户端选择后再保存）
 	ctx.JSON(http.StatusOK, models.HTTPResponse{
 		Code:  0,
-		Msg:   fmt.Sprintf("成功生成%d个题目", len(aiResList)),
+		Msg:   msg,
 		AIRes: aiResList,
 	})
 }
@@ -135,31 +206,161 @@ func (c *QuestionController) ListQuestions(ctx *gin.Context) {
 		return
 	}
 
-	// 设置默认分页参数
-	if req.Page <= 0 {
-		req.Page = 1
+	filter, err := questionFilter(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的查询参数: " + err.Error(),
+		})
+		return
+	}
+
+	// 只查询助教以上角色能查看的题库，学生看不到题目答案
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
 	}
-	if req.PageSize <= 0 {
-		req.PageSize = 10
+	if filter.BankIDs, ok = bankScope(ctx, perms, req.BankID, models.RoleTA); !ok {
+		return
 	}
 
 	// 调用服务获取题目列表
-	questions, total, err := c.storage.ListQuestions(req.Page, req.PageSize, int(req.Type), int(req.Difficulty), req.Title)
+	result, err := c.storage.QueryQuestions(filter)
 	if err != nil {
-		ctx.JSON(http.StatusOK, models.HTTPResponse{
+		status := http.StatusOK
+		if errors.Is(err, services.ErrInvalidCursor) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, models.HTTPResponse{
 			Code: -1,
 			Msg:  "查询题目列表失败: " + err.Error(),
 		})
 		return
 	}
 
-	// 返回结果
-	ctx.JSON(http.StatusOK, gin.H{
-		"code":  0,
-		"msg":   "",
-		"total": total,
-		"list":  questions,
-	})
+	// 返回结果，游标分页时不统计总数
+	resp := gin.H{
+		"code":       0,
+		"msg":        "",
+		"list":       result.List,
+		"nextCursor": result.NextCursor,
+	}
+	if filter.Cursor == "" {
+		resp["total"] = result.Total
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 把查询参数转换为存储层的筛选条件
+func questionFilter(req *models.QuestionQueryRequest) (models.QuestionFilter, error) {
+	filter := models.QuestionFilter{
+		BankIDs:  bankIDs(req.BankID),
+		Language: req.Language,
+		Tag:      strings.TrimSpace(req.Tag),
+		Status:   req.Status,
+		Title:    req.Title,
+		Sort:     req.Sort,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Cursor:   req.Cursor,
+	}
+
+	// 设置默认分页参数
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = 10
+	}
+	if filter.PageSize > 100 {
+		filter.PageSize = 100
+	}
+
+	switch filter.Sort {
+	case "", models.SortByID, models.SortByCreated, models.SortByDifficulty, models.SortByUsage:
+	default:
+		return filter, fmt.Errorf("不支持的排序字段: %s", filter.Sort)
+	}
+
+	if filter.Status != "" && !filter.Status.Valid() {
+		return filter, fmt.Errorf("不支持的题目状态: %s", filter.Status)
+	}
+
+	switch strings.ToLower(req.Order) {
+	case "", "desc":
+	case "asc":
+		filter.Asc = true
+	default:
+		return filter, fmt.Errorf("不支持的排序方向: %s", req.Order)
+	}
+
+	// 兼容单值参数type和difficulty
+	types, err := splitInts(req.Types)
+	if err != nil {
+		return filter, fmt.Errorf("无效的题型: %w", err)
+	}
+	if req.Type > 0 {
+		types = append(types, int(req.Type))
+	}
+	for _, t := range types {
+		filter.Types = append(filter.Types, models.QuestionType(t))
+	}
+
+	difficulties, err := splitInts(req.Difficulties)
+	if err != nil {
+		return filter, fmt.Errorf("无效的难度: %w", err)
+	}
+	if req.Difficulty > 0 {
+		difficulties = append(difficulties, int(req.Difficulty))
+	}
+	for _, d := range difficulties {
+		filter.Difficulties = append(filter.Difficulties, models.QuestionDifficulty(d))
+	}
+
+	if req.CreatedFrom != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.CreatedFrom, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("无效的开始日期: %s", req.CreatedFrom)
+		}
+		filter.CreatedFrom = t
+	}
+	if req.CreatedTo != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.CreatedTo, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("无效的结束日期: %s", req.CreatedTo)
+		}
+		// 结束日期包含当天
+		filter.CreatedTo = t.AddDate(0, 0, 1)
+	}
+
+	return filter, nil
+}
+
+// 指定了题库时只查询该题库，否则不限制
+func bankIDs(bankID int64) []int64 {
+	if bankID == 0 {
+		return nil
+	}
+	return []int64{bankID}
+}
+
+// 解析重复传入或逗号分隔的整数参数
+func splitInts(values []string) ([]int, error) {
+	var result []int
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			n, err := strconv.Atoi(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s", item)
+			}
+			result = append(result, n)
+		}
+	}
+	return result, nil
 }
 
 // 手动添加题目
@@ -174,63 +375,39 @@ func (c *QuestionController) AddQuestion(ctx *gin.Context) {
 		return
 	}
 
-	// 验证基本参数
-	if data.AIReq.Type <= 0 {
+	// 验证题目内容
+	if err := data.Validate(); err != nil {
 		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
 			Code: -1,
-			Msg:  "题目类型不能为空",
+			Msg:  err.Error(),
 		})
 		return
 	}
 
-	if data.AIRes.Title == "" {
-		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-			Code: -1,
-			Msg:  "题目标题不能为空",
-		})
+	if data.BankID == 0 {
+		data.BankID = models.DefaultBankID
+	}
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
 		return
 	}
-
-	// 验证选择题的选项和答案
-	if data.AIReq.Type != models.Programming {
-		if len(data.AIRes.Answer) < 2 {
-			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-				Code: -1,
-				Msg:  "选择题至少需要2个选项",
-			})
-			return
-		}
-
-		if len(data.AIRes.Right) == 0 {
-			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-				Code: -1,
-				Msg:  "选择题必须指定正确答案",
-			})
-			return
-		}
-
-		// 验证答案索引是否有效
-		for _, idx := range data.AIRes.Right {
-			if idx < 0 || idx >= len(data.AIRes.Answer) {
-				ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-					Code: -1,
-					Msg:  fmt.Sprintf("无效的答案索引: %d", idx),
-				})
-				return
-			}
-		}
+	if !perms.CanWrite(data.BankID) {
+		forbidden(ctx, fmt.Sprintf("没有权限向题库%d添加题目", data.BankID))
+		return
 	}
 
 	// 保存题目
 	id, err := c.storage.AddQuestion(&data)
 	if err != nil {
-		ctx.JSON(http.StatusOK, models.HTTPResponse{
+		ctx.JSON(saveStatus(err), models.HTTPResponse{
 			Code: -1,
 			Msg:  "添加题目失败: " + err.Error(),
 		})
 		return
 	}
 
+	c.emitQuestion(models.EventQuestionCreated, id)
+
 	// 返回成功响应
 	ctx.JSON(http.StatusOK, gin.H{
 		"code": 0,
@@ -262,62 +439,34 @@ func (c *QuestionController) EditQuestion(ctx *gin.Context) {
 		return
 	}
 
-	// 验证基本参数
-	if data.AIReq.Type <= 0 {
+	// 验证题目内容
+	if err := data.Validate(); err != nil {
 		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
 			Code: -1,
-			Msg:  "题目类型不能为空",
+			Msg:  err.Error(),
 		})
 		return
 	}
 
-	if data.AIRes.Title == "" {
-		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-			Code: -1,
-			Msg:  "题目标题不能为空",
-		})
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
 		return
 	}
-
-	// 验证选择题的选项和答案
-	if data.AIReq.Type != models.Programming {
-		if len(data.AIRes.Answer) < 2 {
-			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-				Code: -1,
-				Msg:  "选择题至少需要2个选项",
-			})
-			return
-		}
-
-		if len(data.AIRes.Right) == 0 {
-			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-				Code: -1,
-				Msg:  "选择题必须指定正确答案",
-			})
-			return
-		}
-
-		// 验证答案索引是否有效
-		for _, idx := range data.AIRes.Right {
-			if idx < 0 || idx >= len(data.AIRes.Answer) {
-				ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
-					Code: -1,
-					Msg:  fmt.Sprintf("无效的答案索引: %d", idx),
-				})
-				return
-			}
-		}
+	if !requireQuestionBanks(ctx, c.storage, []int64{id}, perms.CanWrite, "编辑") {
+		return
 	}
 
 	// 更新题目
 	if err := c.storage.EditQuestion(id, &data); err != nil {
-		ctx.JSON(http.StatusOK, models.HTTPResponse{
+		ctx.JSON(saveStatus(err), models.HTTPResponse{
 			Code: -1,
 			Msg:  "编辑题目失败: " + err.Error(),
 		})
 		return
 	}
 
+	c.emitQuestion(models.EventQuestionUpdated, id)
+
 	// 返回成功响应
 	ctx.JSON(http.StatusOK, gin.H{
 		"code": 0,
@@ -325,6 +474,32 @@ func (c *QuestionController) EditQuestion(ctx *gin.Context) {
 	})
 }
 
+// 预览题目渲染后的HTML，不保存。用于编辑题目和AI出题结果的预览
+func (c *QuestionController) PreviewQuestion(ctx *gin.Context) {
+	var data models.QuestionData
+	if err := ctx.ShouldBindJSON(&data); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "",
+		"html": data.RenderHTML(),
+	})
+}
+
+// 保存题目失败时的状态码，引用了不存在的图片属于请求错误
+func saveStatus(err error) int {
+	if errors.Is(err, services.ErrAttachmentNotFound) {
+		return http.StatusBadRequest
+	}
+	return http.StatusOK
+}
+
 // 删除题目
 func (c *QuestionController) DeleteQuestions(ctx *gin.Context) {
 	var req models.QuestionDeleteRequest
@@ -344,6 +519,22 @@ func (c *QuestionController) DeleteQuestions(ctx *gin.Context) {
 		return
 	}
 
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	if !requireQuestionBanks(ctx, c.storage, req.IDs, perms.CanWrite, "删除") {
+		return
+	}
+
+	// 记下实际存在的题目，删除后只为它们发布事件
+	var existing []int64
+	for _, id := range req.IDs {
+		if _, err := c.storage.GetQuestionByID(id); err == nil {
+			existing = append(existing, id)
+		}
+	}
+
 	// 删除题目
 	if err := c.storage.DeleteQuestions(req.IDs); err != nil {
 		ctx.JSON(http.StatusOK, models.HTTPResponse{
@@ -353,9 +544,208 @@ func (c *QuestionController) DeleteQuestions(ctx *gin.Context) {
 		return
 	}
 
+	for _, id := range existing {
+		c.webhooks.Emit(models.EventQuestionDeleted, models.QuestionDeletedData{ID: id})
+	}
+
 	// 返回成功响应
 	ctx.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"msg":  "删除题目成功",
 	})
 }
+
+// 批量修改题目，按ID或按列表查询条件选择题目
+func (c *QuestionController) BulkUpdate(ctx *gin.Context) {
+	var req models.BulkRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	change, err := bulkChange(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	// IDs和Filter必须且只能指定一个
+	if (len(req.IDs) == 0) == (strings.TrimSpace(req.Filter) == "") {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "请指定题目ID或筛选条件之一",
+		})
+		return
+	}
+
+	var filter *models.QuestionFilter
+	if len(req.IDs) == 0 {
+		f, err := bulkFilter(req.Filter)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+				Code: -1,
+				Msg:  "无效的筛选条件: " + err.Error(),
+			})
+			return
+		}
+		filter = &f
+	}
+
+	// 只能修改自己能修改的题库中的题目，移动时目标题库也要能修改
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	if change.Operation == models.BulkMoveToBank && !perms.CanWrite(change.BankID) {
+		forbidden(ctx, fmt.Sprintf("没有权限向题库%d移入题目", change.BankID))
+		return
+	}
+	if filter == nil {
+		if !requireQuestionBanks(ctx, c.storage, req.IDs, perms.CanWrite, "修改") {
+			return
+		}
+	} else if filter.BankIDs == nil {
+		filter.BankIDs = perms.WritableIDs()
+	} else if !perms.CanWrite(filter.BankIDs[0]) {
+		forbidden(ctx, fmt.Sprintf("没有权限修改题库%d", filter.BankIDs[0]))
+		return
+	}
+
+	result, err := c.storage.BulkUpdate(req.IDs, filter, change, req.DryRun)
+	if err != nil {
+		status := http.StatusOK
+		if errors.Is(err, services.ErrBulkTooMany) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, models.HTTPResponse{
+			Code: -1,
+			Msg:  "批量修改失败: " + err.Error(),
+		})
+		return
+	}
+
+	if !req.DryRun {
+		slog.Info("批量修改题目", "operation", req.Operation, "matched", result.Matched, "changed", result.Changed)
+		for _, item := range result.Items {
+			if item.Changed {
+				c.emitQuestion(models.EventQuestionUpdated, item.ID)
+			}
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"result": result,
+	})
+}
+
+// 检查批量操作的参数
+func bulkChange(req *models.BulkRequest) (models.BulkChange, error) {
+	change := models.BulkChange{Operation: req.Operation}
+	switch req.Operation {
+	case models.BulkSetDifficulty:
+		if req.Difficulty < models.Easy || req.Difficulty > models.Hard {
+			return change, fmt.Errorf("无效的难度: %d", req.Difficulty)
+		}
+		change.Difficulty = req.Difficulty
+	case models.BulkAddTags, models.BulkRemoveTags:
+		for _, tag := range req.Tags {
+			if strings.TrimSpace(tag) != "" {
+				change.Tags = append(change.Tags, tag)
+			}
+		}
+		if len(change.Tags) == 0 {
+			return change, errors.New("请指定标签")
+		}
+	case models.BulkSetStatus:
+		if !req.Status.Valid() {
+			return change, fmt.Errorf("无效的题目状态: %s", req.Status)
+		}
+		change.Status = req.Status
+	case models.BulkMoveToBank:
+		if req.BankID <= 0 {
+			return change, errors.New("请指定目标题库")
+		}
+		change.BankID = req.BankID
+	default:
+		return change, fmt.Errorf("不支持的批量操作: %s", req.Operation)
+	}
+	return change, nil
+}
+
+// 按题目列表的查询参数解析筛选条件，分页和排序参数不起作用
+func bulkFilter(query string) (models.QuestionFilter, error) {
+	values, err := url.ParseQuery(strings.TrimPrefix(strings.TrimSpace(query), "?"))
+	if err != nil {
+		return models.QuestionFilter{}, err
+	}
+
+	var req models.QuestionQueryRequest
+	if err := binding.MapFormWithTag(&req, values, "form"); err != nil {
+		return models.QuestionFilter{}, err
+	}
+	req.Page, req.PageSize, req.Sort, req.Order, req.Cursor = 0, 0, "", "", ""
+	return questionFilter(&req)
+}
+
+// 把题目复制到另一个题库，原题库需要助教以上角色，目标题库需要能修改
+func (c *QuestionController) CopyQuestions(ctx *gin.Context) {
+	var req models.QuestionCopyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+	if len(req.IDs) > services.MaxBulkItems {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  services.ErrBulkTooMany.Error(),
+		})
+		return
+	}
+
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	if !perms.CanWrite(req.BankID) {
+		forbidden(ctx, fmt.Sprintf("没有权限向题库%d复制题目", req.BankID))
+		return
+	}
+	canRead := func(bankID int64) bool { return perms.Can(bankID, models.RoleTA) }
+	if !requireQuestionBanks(ctx, c.storage, req.IDs, canRead, "复制") {
+		return
+	}
+
+	ids, err := c.storage.CopyQuestions(req.IDs, req.BankID)
+	if err != nil {
+		status := http.StatusOK
+		if errors.Is(err, services.ErrQuestionNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, models.HTTPResponse{
+			Code: -1,
+			Msg:  "复制题目失败: " + err.Error(),
+		})
+		return
+	}
+
+	for _, id := range ids {
+		c.emitQuestion(models.EventQuestionCreated, id)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  fmt.Sprintf("成功复制%d道题目", len(ids)),
+		"ids":  ids,
+	})
+}
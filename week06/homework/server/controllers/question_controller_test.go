@@ -0,0 +1,587 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"question-generator/config"
+	"question-generator/controllers"
+	"question-generator/middleware"
+	"question-generator/mockllm"
+	"question-generator/models"
+	"question-generator/routes"
+	"question-generator/services"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testServer struct {
+	router   *gin.Engine
+	mock     *mockllm.Server
+	storage  *services.StorageService
+	webhooks *services.WebhookService
+	jobs     *services.BackgroundJobs
+}
+
+// 测试用的管理令牌和用户令牌密钥
+const (
+	testAdminToken = "secret"
+	testUserSecret = "test-user-token-secret"
+)
+
+// 用假模型服务和临时数据库搭建完整的路由
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	mock, err := mockllm.NewServer()
+	if err != nil {
+		t.Fatalf("start mock server: %v", err)
+	}
+	t.Cleanup(func() { mock.Close() })
+
+	storage, err := services.OpenStorageService(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenStorageService: %v", err)
+	}
+	t.Cleanup(func() { storage.DB.Close() })
+
+	cfg := &config.Configuration{
+		QwenAPIKey:  "mock",
+		QwenAPIURL:  mock.URL,
+		ReviewModel: models.Tongyi,
+		ModelPrices: map[string]config.ModelPrice{"qwen-turbo": {PromptPer1K: 1, CompletionPer1K: 1}},
+		Quotas:      config.QuotaConfig{UserDaily: 1},
+	}
+	usage, err := services.NewUsageService(storage.DB, cfg)
+	if err != nil {
+		t.Fatalf("NewUsageService: %v", err)
+	}
+	aiClient := services.NewAIClient(cfg, usage)
+
+	analysis := services.NewItemAnalysisService(storage, config.ItemAnalysisConfig{MinResponses: 2})
+	adaptive, err := services.NewAdaptiveService(storage, analysis, config.AdaptiveConfig{MaxQuestions: 3, TargetSE: 0.3})
+	if err != nil {
+		t.Fatalf("NewAdaptiveService: %v", err)
+	}
+	practice, err := services.NewPracticeService(storage)
+	if err != nil {
+		t.Fatalf("NewPracticeService: %v", err)
+	}
+	webhooks, err := services.NewWebhookService(storage.DB, config.WebhookConfig{MaxAttempts: 2, RetryBase: time.Minute, RetryMax: time.Hour})
+	if err != nil {
+		t.Fatalf("NewWebhookService: %v", err)
+	}
+
+	courses := services.NewCourseService(storage)
+	jobs := services.NewBackgroundJobs()
+	t.Cleanup(func() { jobs.Shutdown(context.Background()) })
+	grader, err := services.NewGraderService(storage, aiClient, jobs)
+	if err != nil {
+		t.Fatalf("NewGraderService: %v", err)
+	}
+
+	r := gin.New()
+	routes.SetupRoutes(r, middleware.NewRateLimiter(config.RateLimitConfig{}), routes.Auth{AdminToken: testAdminToken, UserSecret: testUserSecret}, routes.Controllers{
+		Question:   controllers.NewQuestionController(aiClient, storage, courses, webhooks),
+		Attachment: controllers.NewAttachmentController(services.NewAttachmentService(storage, config.AttachmentConfig{MaxBytes: 1024}), 1024),
+		Course:     controllers.NewCourseController(courses),
+		Exam:       controllers.NewExamController(storage, analysis, practice, courses, webhooks),
+		Grading:    controllers.NewGradingController(grader, storage, courses),
+		Practice:   controllers.NewPracticeController(practice, storage, courses),
+		Adaptive:   controllers.NewAdaptiveController(adaptive, courses),
+		Usage:      controllers.NewUsageController(usage),
+		Stats:      controllers.NewStatsController(services.NewStatsService(storage.DB, 0), courses),
+		Analysis:   controllers.NewAnalysisController(analysis, storage, courses),
+		Backup:     controllers.NewBackupController(services.NewBackupService(storage, config.BackupConfig{})),
+		Webhook:    controllers.NewWebhookController(webhooks),
+	})
+	return &testServer{router: r, mock: mock, storage: storage, webhooks: webhooks, jobs: jobs}
+}
+
+// 发送请求并把响应体解析为map
+func (s *testServer) do(t *testing.T, method, path string, body interface{}) (int, map[string]interface{}) {
+	return s.doAs(t, "", method, path, body)
+}
+
+// 以指定用户身份发送请求，携带为该用户签发的令牌
+func (s *testServer) doAs(t *testing.T, user, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	header := http.Header{}
+	if user != "" {
+		header.Set(middleware.AuthorizationHeader, "Bearer "+middleware.SignUserToken(testUserSecret, user, time.Now().Add(time.Hour)))
+	}
+	return s.doWithHeader(t, header, method, path, body)
+}
+
+// 带指定请求头发送请求
+func (s *testServer) doWithHeader(t *testing.T, header http.Header, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, resp
+}
+
+func TestCreateQuestion(t *testing.T) {
+	s := newTestServer(t)
+
+	status, resp := s.do(t, http.MethodPost, "/api/questions/create", map[string]interface{}{
+		"type": 1, "difficulty": 2, "language": "go", "count": 2,
+	})
+	if status != http.StatusOK || resp["code"] != float64(0) {
+		t.Fatalf("status %d, resp %v", status, resp)
+	}
+	list := resp["aiRes"].([]interface{})
+	if len(list) != 2 {
+		t.Fatalf("got %d questions, want 2", len(list))
+	}
+
+	// 生成的题目不直接入库
+	_, total, _ := s.storage.ListQuestions(1, 10, 0, 0, "")
+	if total != 0 {
+		t.Errorf("generated questions were saved: total = %d", total)
+	}
+}
+
+func TestCreateQuestionWithReview(t *testing.T) {
+	s := newTestServer(t)
+
+	_, resp := s.do(t, http.MethodPost, "/api/questions/create", map[string]interface{}{
+		"type": 1, "count": 2, "review": true,
+	})
+	if resp["code"] != float64(0) {
+		t.Fatalf("resp %v", resp)
+	}
+	list := resp["aiRes"].([]interface{})
+	second := list[1].(map[string]interface{})["review"].(map[string]interface{})
+	if second["disagree"] != true {
+		t.Errorf("second review = %v, want disagree", second)
+	}
+}
+
+// 模型把选择类题目写成编程题时按编程题处理，判断题不给选项仍是判断题
+func TestCreateQuestionConfusedType(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, tt := range []struct {
+		qType     int
+		recording string
+		want      string
+	}{
+		{1, "programming", "编程题"},
+		{4, "true_false", "判断题"},
+	} {
+		s.mock.Enqueue(tt.recording, "review")
+		// 每次换一个用户，不受用户配额影响
+		_, resp := s.doAs(t, tt.recording, http.MethodPost, "/api/questions/create", map[string]interface{}{
+			"type": tt.qType, "count": 1, "review": true,
+		})
+		if resp["code"] != float64(0) {
+			t.Fatalf("type %d: resp %v", tt.qType, resp)
+		}
+		requests := s.mock.Requests()
+		messages := requests[len(requests)-1].Messages
+		if prompt := messages[len(messages)-1].Content; !strings.Contains(prompt, "index=0，"+tt.want) {
+			t.Errorf("type %d: review prompt %q, want %s", tt.qType, prompt, tt.want)
+		}
+	}
+}
+
+func TestCreateQuestionErrors(t *testing.T) {
+	s := newTestServer(t)
+
+	status, resp := s.do(t, http.MethodPost, "/api/questions/create", map[string]interface{}{"unknown": 1})
+	if status != http.StatusBadRequest || resp["code"] != float64(-1) {
+		t.Errorf("unknown field: status %d, resp %v", status, resp)
+	}
+
+	status, _ = s.do(t, http.MethodPost, "/api/questions/create", "{not json")
+	if status != http.StatusBadRequest {
+		t.Errorf("invalid json: status %d", status)
+	}
+
+	status, _ = s.do(t, http.MethodPost, "/api/questions/create", map[string]interface{}{"type": 9})
+	if status != http.StatusBadRequest {
+		t.Errorf("unknown type: status %d", status)
+	}
+
+	s.mock.Enqueue("server_error")
+	status, resp = s.do(t, http.MethodPost, "/api/questions/create", map[string]interface{}{"type": 1})
+	if status != http.StatusOK || resp["code"] != float64(-2) {
+		t.Errorf("provider error: status %d, resp %v", status, resp)
+	}
+}
+
+func TestQuotaAndUsageReport(t *testing.T) {
+	s := newTestServer(t)
+
+	// 一次出题花费0.73元，第二次还未超过1元配额，第三次被拒绝
+	create := map[string]interface{}{"type": 1}
+	for i := 0; i < 2; i++ {
+		if _, resp := s.doAs(t, "alice", http.MethodPost, "/api/questions/create", create); resp["code"] != float64(0) {
+			t.Fatalf("call %d: %v", i, resp)
+		}
+	}
+	status, resp := s.doAs(t, "alice", http.MethodPost, "/api/questions/create", create)
+	if status != http.StatusTooManyRequests || resp["code"] != float64(-2) {
+		t.Fatalf("over quota: status %d, resp %v", status, resp)
+	}
+
+	// 其他用户不受影响
+	if _, resp := s.doAs(t, "bob", http.MethodPost, "/api/questions/create", create); resp["code"] != float64(0) {
+		t.Fatalf("bob: %v", resp)
+	}
+
+	// 匿名用户按客户端IP计算配额，换X-User-ID请求头不能重新获得配额
+	for i := 0; i < 2; i++ {
+		s.do(t, http.MethodPost, "/api/questions/create", create)
+	}
+	status, _ = s.doWithHeader(t, http.Header{"X-User-Id": {"someone-else"}}, http.MethodPost, "/api/questions/create", create)
+	if status != http.StatusTooManyRequests {
+		t.Errorf("forged X-User-ID over quota: status %d", status)
+	}
+
+	// 只有管理员可以查看其他用户的用量，普通用户只看到自己的
+	if status, _ := s.doAs(t, "bob", http.MethodGet, "/api/usage/report?userId=alice", nil); status != http.StatusForbidden {
+		t.Errorf("bob reads alice's usage: status %d", status)
+	}
+	_, resp = s.doWithHeader(t, http.Header{middleware.AdminTokenHeader: {testAdminToken}}, http.MethodGet, "/api/usage/report?userId=alice", nil)
+	if resp["code"] != float64(0) {
+		t.Fatalf("report: %v", resp)
+	}
+	total := resp["report"].(map[string]interface{})["total"].(map[string]interface{})
+	if total["requests"] != float64(2) || total["totalTokens"] != float64(1460) {
+		t.Errorf("alice total = %v", total)
+	}
+	scopes := func(report map[string]interface{}) []string {
+		var result []string
+		for _, q := range report["quotas"].([]interface{}) {
+			result = append(result, q.(map[string]interface{})["scope"].(string))
+		}
+		return result
+	}
+	if got := scopes(resp["report"].(map[string]interface{})); !reflect.DeepEqual(got, []string{"user_daily", "user_monthly", "global_daily", "global_monthly"}) {
+		t.Errorf("admin quota scopes = %v", got)
+	}
+	_, resp = s.doAs(t, "alice", http.MethodGet, "/api/usage/report", nil)
+	report := resp["report"].(map[string]interface{})
+	if report["userId"] != "alice" || report["total"].(map[string]interface{})["requests"] != float64(2) {
+		t.Errorf("alice's own report = %v", report)
+	}
+	// 普通用户看不到全站的费用
+	if got := scopes(report); !reflect.DeepEqual(got, []string{"user_daily", "user_monthly"}) {
+		t.Errorf("alice's quota scopes = %v", got)
+	}
+
+	status, _ = s.do(t, http.MethodGet, "/api/usage/report?from=2026-13-01", nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("invalid date: status %d", status)
+	}
+	status, _ = s.do(t, http.MethodGet, "/api/usage/report?from=2026-02-01&to=2026-01-01", nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("reversed range: status %d", status)
+	}
+}
+
+func TestQuestionCRUD(t *testing.T) {
+	s := newTestServer(t)
+
+	question := map[string]interface{}{
+		"aiReq": map[string]interface{}{"type": 1},
+		"aiRes": map[string]interface{}{
+			"title":       "Go的零值",
+			"answer":      []string{"0", "nil"},
+			"right":       []int{0},
+			"explanation": "int的零值是0",
+			"rationale":   []string{"", "int不能为nil"},
+		},
+		"difficulty": 1,
+	}
+	_, resp := s.do(t, http.MethodPost, "/api/questions/add", question)
+	if resp["code"] != float64(0) {
+		t.Fatalf("add: %v", resp)
+	}
+	id := int64(resp["id"].(float64))
+
+	_, resp = s.do(t, http.MethodGet, "/api/questions/list?type=1&title=零值", nil)
+	if resp["total"] != float64(1) {
+		t.Fatalf("list: %v", resp)
+	}
+	item := resp["list"].([]interface{})[0].(map[string]interface{})["aiRes"].(map[string]interface{})
+	if item["explanation"] != "int的零值是0" {
+		t.Errorf("listed question = %v", item)
+	}
+
+	question["aiRes"].(map[string]interface{})["title"] = "Go的零值（改）"
+	_, resp = s.do(t, http.MethodPut, "/api/questions/edit/"+jsonNumber(id), question)
+	if resp["code"] != float64(0) {
+		t.Fatalf("edit: %v", resp)
+	}
+	q, _ := s.storage.GetQuestionByID(id)
+	if q.AIRes.Title != "Go的零值（改）" {
+		t.Errorf("title after edit = %q", q.AIRes.Title)
+	}
+
+	_, resp = s.do(t, http.MethodPost, "/api/exams/grade", map[string]interface{}{
+		"answers": []map[string]interface{}{{"questionId": id, "selected": []int{1}}},
+	})
+	if resp["code"] != float64(0) {
+		t.Fatalf("grade: %v", resp)
+	}
+	result := resp["result"].(map[string]interface{})["results"].([]interface{})[0].(map[string]interface{})
+	if result["correct"] != false || result["rationale"].(map[string]interface{})["1"] != "int不能为nil" {
+		t.Errorf("grade result = %v", result)
+	}
+
+	_, resp = s.do(t, http.MethodDelete, "/api/questions/delete", map[string]interface{}{"ids": []int64{id}})
+	if resp["code"] != float64(0) {
+		t.Fatalf("delete: %v", resp)
+	}
+	_, resp = s.do(t, http.MethodGet, "/api/questions/list", nil)
+	if resp["total"] != float64(0) {
+		t.Errorf("list after delete: %v", resp)
+	}
+}
+
+func TestGradeExamQuestionTypes(t *testing.T) {
+	s := newTestServer(t)
+
+	add := func(aiReq, aiRes map[string]interface{}) int64 {
+		t.Helper()
+		_, resp := s.do(t, http.MethodPost, "/api/questions/add", map[string]interface{}{"aiReq": aiReq, "aiRes": aiRes})
+		if resp["code"] != float64(0) {
+			t.Fatalf("add: %v", resp)
+		}
+		return int64(resp["id"].(float64))
+	}
+	blank := add(map[string]interface{}{"type": 5}, map[string]interface{}{"title": "用____启动协程", "blanks": [][]string{{"go"}}})
+	short := add(map[string]interface{}{"type": 6}, map[string]interface{}{"title": "简述defer", "reference": "后进先出"})
+	programming := add(map[string]interface{}{"type": 3}, map[string]interface{}{"title": "实现LRU"})
+
+	// 练习只推送能自动批改的题目，考生拿到作答方式而不是答案
+	_, resp := s.do(t, http.MethodGet, "/api/practice/today?new=10", nil)
+	items := resp["today"].(map[string]interface{})["items"].([]interface{})
+	if len(items) != 1 {
+		t.Fatalf("practice items = %v", items)
+	}
+	if q := items[0].(map[string]interface{})["question"].(map[string]interface{}); q["input"] != "blanks" || q["blankCount"] != float64(1) || q["blanks"] != nil {
+		t.Errorf("student fill-in question = %v", q)
+	}
+
+	_, resp = s.do(t, http.MethodPost, "/api/exams/grade", map[string]interface{}{
+		"answers": []map[string]interface{}{
+			{"questionId": blank, "blanks": []string{"Go"}},
+			{"questionId": short, "text": "defer后进先出"},
+		},
+	})
+	result := resp["result"].(map[string]interface{})
+	if result["correct"] != float64(1) || result["pending"] != float64(1) || result["score"] != float64(100) {
+		t.Errorf("grade result = %v", result)
+	}
+
+	// 编程题提交思路说明，等待批改
+	_, resp = s.do(t, http.MethodPost, "/api/exams/grade", map[string]interface{}{
+		"answers": []map[string]interface{}{{"questionId": programming, "text": "哈希表加双向链表"}},
+	})
+	if result := resp["result"].(map[string]interface{}); result["pending"] != float64(1) {
+		t.Errorf("grade programming: %v", resp)
+	}
+}
+
+func TestQuestionHTML(t *testing.T) {
+	s := newTestServer(t)
+
+	question := map[string]interface{}{
+		"aiReq": map[string]interface{}{"type": 1},
+		"aiRes": map[string]interface{}{
+			"title":  "下面代码输出什么？<script>alert(1)</script>\n```go\nfmt.Println(len(\"ab\"))\n```",
+			"answer": []string{"`2`", "**编译错误**"},
+			"right":  []int{0},
+		},
+	}
+	_, resp := s.do(t, http.MethodPost, "/api/questions/add", question)
+	if resp["code"] != float64(0) {
+		t.Fatalf("add: %v", resp)
+	}
+
+	// 查询结果带有渲染后的HTML，原始HTML被去掉
+	_, resp = s.do(t, http.MethodGet, "/api/questions/list", nil)
+	html := resp["list"].([]interface{})[0].(map[string]interface{})["html"].(map[string]interface{})
+	want := "<p>下面代码输出什么？alert(1)</p>\n" +
+		`<pre><code class="language-go">fmt.Println(<span class="hl-type">len</span>(<span class="hl-string">&#34;ab&#34;</span>))</code></pre>` + "\n"
+	if html["title"] != want {
+		t.Errorf("html title = %q, want %q", html["title"], want)
+	}
+	if options := html["options"].([]interface{}); options[0] != "<p><code>2</code></p>\n" || options[1] != "<p><strong>编译错误</strong></p>\n" {
+		t.Errorf("html options = %v", options)
+	}
+
+	// 预览不保存
+	question["aiRes"].(map[string]interface{})["title"] = "*预览*"
+	_, resp = s.do(t, http.MethodPost, "/api/questions/preview", question)
+	if resp["code"] != float64(0) || resp["html"].(map[string]interface{})["title"] != "<p><em>预览</em></p>\n" {
+		t.Errorf("preview: %v", resp)
+	}
+	_, resp = s.do(t, http.MethodGet, "/api/questions/list", nil)
+	if resp["total"] != float64(1) {
+		t.Errorf("preview saved the question: %v", resp)
+	}
+}
+
+func TestListQuestionsFilters(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, q := range []struct {
+		title    string
+		qType    int
+		language string
+		right    []int
+	}{{"Go单选", 1, "go", []int{0}}, {"Python多选", 2, "python", []int{0, 1}}, {"Go编程", 3, "go", nil}} {
+		_, resp := s.do(t, http.MethodPost, "/api/questions/add", map[string]interface{}{
+			"aiReq": map[string]interface{}{"type": q.qType, "language": q.language},
+			"aiRes": map[string]interface{}{"title": q.title, "answer": []string{"A", "B"}, "right": q.right},
+		})
+		if resp["code"] != float64(0) {
+			t.Fatalf("add: %v", resp)
+		}
+	}
+
+	_, resp := s.do(t, http.MethodGet, "/api/questions/list?types=1,3&language=go&order=asc", nil)
+	list := resp["list"].([]interface{})
+	if resp["total"] != float64(2) || len(list) != 2 || list[0].(map[string]interface{})["aiRes"].(map[string]interface{})["title"] != "Go单选" {
+		t.Fatalf("filtered list: %v", resp)
+	}
+
+	// 第一页返回游标，用游标翻页时不返回总数
+	_, resp = s.do(t, http.MethodGet, "/api/questions/list?pageSize=2", nil)
+	cursor, _ := resp["nextCursor"].(string)
+	if cursor == "" {
+		t.Fatalf("first page: %v", resp)
+	}
+	_, resp = s.do(t, http.MethodGet, "/api/questions/list?pageSize=2&cursor="+cursor, nil)
+	if _, ok := resp["total"]; ok || len(resp["list"].([]interface{})) != 1 || resp["nextCursor"] != "" {
+		t.Errorf("cursor page: %v", resp)
+	}
+
+	for _, query := range []string{"sort=title", "order=up", "types=a", "createdFrom=2026-13-01", "cursor=bad"} {
+		if status, _ := s.do(t, http.MethodGet, "/api/questions/list?"+query, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, status)
+		}
+	}
+}
+
+func TestAddQuestionValidation(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name  string
+		aiReq map[string]interface{}
+		aiRes map[string]interface{}
+	}{
+		{name: "缺少类型", aiReq: map[string]interface{}{}, aiRes: map[string]interface{}{"title": "t"}},
+		{name: "缺少标题", aiReq: map[string]interface{}{"type": 3}, aiRes: map[string]interface{}{}},
+		{name: "选项不足", aiReq: map[string]interface{}{"type": 1}, aiRes: map[string]interface{}{"title": "t", "answer": []string{"a"}, "right": []int{0}}},
+		{name: "缺少答案", aiReq: map[string]interface{}{"type": 1}, aiRes: map[string]interface{}{"title": "t", "answer": []string{"a", "b"}}},
+		{name: "答案越界", aiReq: map[string]interface{}{"type": 1}, aiRes: map[string]interface{}{"title": "t", "answer": []string{"a", "b"}, "right": []int{2}}},
+		{name: "错误原因过多", aiReq: map[string]interface{}{"type": 1}, aiRes: map[string]interface{}{"title": "t", "answer": []string{"a", "b"}, "right": []int{0}, "rationale": []string{"", "x", "y"}}},
+		{name: "未知题型", aiReq: map[string]interface{}{"type": 9}, aiRes: map[string]interface{}{"title": "t"}},
+		{name: "判断题答案无效", aiReq: map[string]interface{}{"type": 4}, aiRes: map[string]interface{}{"title": "t", "right": []int{2}}},
+		{name: "填空题缺少空", aiReq: map[string]interface{}{"type": 5}, aiRes: map[string]interface{}{"title": "t"}},
+		{name: "简答题缺少参考答案", aiReq: map[string]interface{}{"type": 6}, aiRes: map[string]interface{}{"title": "t"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := s.do(t, http.MethodPost, "/api/questions/add", map[string]interface{}{"aiReq": tt.aiReq, "aiRes": tt.aiRes})
+			if status != http.StatusBadRequest || resp["code"] != float64(-1) {
+				t.Errorf("status %d, resp %v", status, resp)
+			}
+		})
+	}
+
+	status, _ := s.do(t, http.MethodPut, "/api/questions/edit/abc", map[string]interface{}{})
+	if status != http.StatusBadRequest {
+		t.Errorf("edit with invalid id: status %d", status)
+	}
+}
+
+func jsonNumber(id int64) string {
+	data, _ := json.Marshal(id)
+	return string(data)
+}
+
+func TestBulkUpdate(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, language := range []string{"go", "go", "python"} {
+		_, resp := s.do(t, http.MethodPost, "/api/questions/add", map[string]interface{}{
+			"aiReq": map[string]interface{}{"type": 1, "language": language},
+			"aiRes": map[string]interface{}{"title": language, "answer": []string{"A", "B"}, "right": []int{0}},
+		})
+		if resp["code"] != float64(0) {
+			t.Fatalf("add: %v", resp)
+		}
+	}
+
+	body := map[string]interface{}{"filter": "language=go", "operation": "add_tags", "tags": []string{"入门"}, "dryRun": true}
+	_, resp := s.do(t, http.MethodPost, "/api/questions/bulk", body)
+	result, _ := resp["result"].(map[string]interface{})
+	if resp["code"] != float64(0) || result["matched"] != float64(2) || result["changed"] != float64(2) || result["dryRun"] != true {
+		t.Fatalf("dry run: %v", resp)
+	}
+	if _, resp := s.do(t, http.MethodGet, "/api/questions/list?tag=入门", nil); resp["total"] != float64(0) {
+		t.Fatalf("dry run applied changes: %v", resp)
+	}
+
+	body["dryRun"] = false
+	s.do(t, http.MethodPost, "/api/questions/bulk", body)
+	if _, resp := s.do(t, http.MethodGet, "/api/questions/list?tag=入门", nil); resp["total"] != float64(2) {
+		t.Fatalf("tagged questions: %v", resp)
+	}
+
+	for _, body := range []map[string]interface{}{
+		{"ids": []int64{1}, "filter": "language=go", "operation": "set_status", "status": "draft"},
+		{"operation": "set_status", "status": "draft"},
+		{"ids": []int64{1}, "operation": "set_status", "status": "deleted"},
+		{"ids": []int64{1}, "operation": "set_difficulty", "difficulty": 4},
+		{"ids": []int64{1}, "operation": "add_tags", "tags": []string{" "}},
+		{"ids": []int64{1}, "operation": "move"},
+		{"filter": "types=a", "operation": "set_status", "status": "draft"},
+	} {
+		if status, resp := s.do(t, http.MethodPost, "/api/questions/bulk", body); status != http.StatusBadRequest {
+			t.Errorf("%v: status %d, %v", body, status, resp)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 题库统计控制器，题目数量只统计能查看题目的题库
+type StatsController struct {
+	stats   *services.StatsService
+	courses *services.CourseService
+}
+
+// 创建新的统计控制器
+func NewStatsController(stats *services.StatsService, courses *services.CourseService) *StatsController {
+	return &StatsController{
+		stats:   stats,
+		courses: courses,
+	}
+}
+
+// 查询题库统计，默认统计最近30天
+func (c *StatsController) Overview(ctx *gin.Context) {
+	var req models.StatsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的查询参数: " + err.Error(),
+		})
+		return
+	}
+
+	days := req.Days
+	if days == 0 {
+		days = 30
+	}
+	if days < 1 || days > 365 {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "统计天数必须在1到365之间",
+		})
+		return
+	}
+
+	perms, ok := bankPermissions(ctx, c.courses)
+	if !ok {
+		return
+	}
+	bankIDs, ok := bankScope(ctx, perms, req.BankID, models.RoleTA)
+	if !ok {
+		return
+	}
+
+	stats, err := c.stats.Stats(days, bankIDs)
+	if err != nil {
+		ctx.JSON(http.StatusOK, models.HTTPResponse{
+			Code: -1,
+			Msg:  "查询统计失败: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":  0,
+		"msg":   "",
+		"stats": stats,
+	})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"question-generator/middleware"
+	"question-generator/models"
+	"question-generator/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 用量控制器。普通用户只能查看自己的用量，管理员可以查看任意用户和全站的用量
+type UsageController struct {
+	usage *services.UsageService
+}
+
+// 创建新的用量控制器
+func NewUsageController(usage *services.UsageService) *UsageController {
+	return &UsageController{
+		usage: usage,
+	}
+}
+
+// 查询用量报表，默认统计本月
+func (c *UsageController) Report(ctx *gin.Context) {
+	var req models.UsageReportRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的查询参数: " + err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	if req.From != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.From, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+				Code: -1,
+				Msg:  "无效的开始日期: " + req.From,
+			})
+			return
+		}
+		from = t
+	}
+	if req.To != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.To, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+				Code: -1,
+				Msg:  "无效的结束日期: " + req.To,
+			})
+			return
+		}
+		to = t
+	}
+	// 结束日期包含当天
+	to = to.AddDate(0, 0, 1)
+
+	if !from.Before(to) {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "开始日期不能晚于结束日期",
+		})
+		return
+	}
+
+	userID := req.UserID
+	admin := ctx.GetBool(middleware.AdminContextKey)
+	if !admin {
+		self := ClientUserID(ctx)
+		if userID != "" && userID != self {
+			forbidden(ctx, "只有管理员可以查看其他用户的用量")
+			return
+		}
+		userID = self
+	}
+
+	// 全站配额反映整个站点的模型费用，只有管理员能看到
+	report, err := c.usage.Report(from, to, userID, admin)
+	if err != nil {
+		ctx.JSON(http.StatusOK, models.HTTPResponse{
+			Code: -1,
+			Msg:  "查询用量失败: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":   0,
+		"msg":    "",
+		"report": report,
+	})
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"question-generator/models"
+	"question-generator/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 投递记录单次最多返回的条数
+const maxDeliveryLimit = 1000
+
+// webhook管理控制器
+type WebhookController struct {
+	webhooks *services.WebhookService
+}
+
+// 创建新的webhook管理控制器
+func NewWebhookController(webhooks *services.WebhookService) *WebhookController {
+	return &WebhookController{
+		webhooks: webhooks,
+	}
+}
+
+// 订阅列表
+func (c *WebhookController) List(ctx *gin.Context) {
+	hooks, err := c.webhooks.List()
+	if err != nil {
+		c.fail(ctx, "查询webhook失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":     0,
+		"msg":      "",
+		"webhooks": hooks,
+	})
+}
+
+// 创建订阅，返回的签名密钥只在此时可见
+func (c *WebhookController) Create(ctx *gin.Context) {
+	var req models.WebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	hook, err := c.webhooks.Create(req)
+	if err != nil {
+		c.fail(ctx, "创建webhook失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"msg":     "",
+		"webhook": hook,
+	})
+}
+
+// 修改订阅
+func (c *WebhookController) Update(ctx *gin.Context) {
+	id, ok := c.id(ctx)
+	if !ok {
+		return
+	}
+
+	var req models.WebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的请求格式: " + err.Error(),
+		})
+		return
+	}
+
+	hook, err := c.webhooks.Update(id, req)
+	if err != nil {
+		c.fail(ctx, "修改webhook失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"msg":     "",
+		"webhook": hook,
+	})
+}
+
+// 删除订阅
+func (c *WebhookController) Delete(ctx *gin.Context) {
+	id, ok := c.id(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.webhooks.Delete(id); err != nil {
+		c.fail(ctx, "删除webhook失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "删除webhook成功",
+	})
+}
+
+// 投递记录，?status=dead查看死信列表
+func (c *WebhookController) Deliveries(ctx *gin.Context) {
+	var req models.DeliveryListRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的查询参数: " + err.Error(),
+		})
+		return
+	}
+
+	switch req.Status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的投递状态: " + string(req.Status),
+		})
+		return
+	}
+	if req.Limit < 0 || req.Limit > maxDeliveryLimit {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "limit必须在1到1000之间",
+		})
+		return
+	}
+
+	deliveries, err := c.webhooks.Deliveries(req)
+	if err != nil {
+		c.fail(ctx, "查询投递记录失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":       0,
+		"msg":        "",
+		"deliveries": deliveries,
+	})
+}
+
+// 重新投递一条记录
+func (c *WebhookController) Redeliver(ctx *gin.Context) {
+	id, ok := c.id(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.webhooks.Redeliver(id); err != nil {
+		c.fail(ctx, "重新投递失败: ", err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "已加入投递队列",
+	})
+}
+
+// 解析路径中的ID，无效时直接返回400
+func (c *WebhookController) id(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.HTTPResponse{
+			Code: -1,
+			Msg:  "无效的ID",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// 按错误类型返回对应的状态码，订阅参数无效时返回400
+func (c *WebhookController) fail(ctx *gin.Context, prefix string, err error) {
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound), errors.Is(err, services.ErrDeliveryNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidWebhook):
+		status = http.StatusBadRequest
+	}
+	ctx.JSON(status, models.HTTPResponse{
+		Code: -1,
+		Msg:  prefix + err.Error(),
+	})
+}
@@ -0,0 +1,142 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"question-generator/middleware"
+	"question-generator/services"
+	"testing"
+	"time"
+)
+
+func TestWebhookEndpoints(t *testing.T) {
+	s := newTestServer(t)
+	admin := http.Header{}
+	admin.Set(middleware.AdminTokenHeader, testAdminToken)
+
+	received := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer receiver.Close()
+
+	if status, _ := s.do(t, http.MethodGet, "/api/admin/webhooks", nil); status != http.StatusUnauthorized {
+		t.Errorf("without token: status %d", status)
+	}
+	status, _ := s.doWithHeader(t, admin, http.MethodPost, "/api/admin/webhooks", map[string]interface{}{
+		"url": receiver.URL, "events": []string{"question.unknown"},
+	})
+	if status != http.StatusBadRequest {
+		t.Errorf("invalid event: status %d", status)
+	}
+
+	_, resp := s.doWithHeader(t, admin, http.MethodPost, "/api/admin/webhooks", map[string]interface{}{
+		"url": receiver.URL, "events": []string{"question.created", "question.deleted"},
+	})
+	hook := resp["webhook"].(map[string]interface{})
+	secret, _ := hook["secret"].(string)
+	if secret == "" {
+		t.Fatalf("create: %v", resp)
+	}
+	_, resp = s.doWithHeader(t, admin, http.MethodGet, "/api/admin/webhooks", nil)
+	if hooks := resp["webhooks"].([]interface{}); len(hooks) != 1 || hooks[0].(map[string]interface{})["secret"] != nil {
+		t.Errorf("list: %v", resp)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.webhooks.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	_, resp = s.do(t, http.MethodPost, "/api/questions/add", map[string]interface{}{
+		"aiReq": map[string]interface{}{"type": 1},
+		"aiRes": map[string]interface{}{"title": "新题目", "answer": []string{"A", "B"}, "right": []int{0}},
+	})
+	id := resp["id"]
+
+	select {
+	case r := <-received:
+		body := <-bodies
+		if r.Header.Get(services.WebhookSignatureHeader) != services.SignWebhook(secret, r.Header.Get(services.WebhookTimestampHeader), body) {
+			t.Errorf("signature mismatch")
+		}
+		var payload struct {
+			Event string `json:"event"`
+			Data  struct {
+				ID    float64 `json:"id"`
+				AIRes struct {
+					Title string `json:"title"`
+				} `json:"aiRes"`
+			} `json:"data"`
+		}
+		json.Unmarshal(body, &payload)
+		if payload.Event != "question.created" || payload.Data.ID != id || payload.Data.AIRes.Title != "新题目" {
+			t.Errorf("payload: %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+
+	// 没有订阅的事件不投递
+	s.do(t, http.MethodPut, "/api/questions/edit/"+jsonNumber(int64(id.(float64))), map[string]interface{}{
+		"aiReq": map[string]interface{}{"type": 1},
+		"aiRes": map[string]interface{}{"title": "改过的题目", "answer": []string{"A", "B"}, "right": []int{1}},
+	})
+	s.do(t, http.MethodDelete, "/api/questions/delete", map[string]interface{}{"ids": []interface{}{id, 9999}})
+	select {
+	case r := <-received:
+		body := <-bodies
+		if r.Header.Get(services.WebhookEventHeader) != "question.deleted" {
+			t.Errorf("unexpected event: %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("delete event not delivered")
+	}
+
+	// 接收方收到请求后投递结果才写入数据库
+	var deliveries []interface{}
+	for deadline := time.Now().Add(5 * time.Second); len(deliveries) != 2; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries: %v", deliveries)
+		}
+		_, resp = s.doWithHeader(t, admin, http.MethodGet, "/api/admin/webhooks/deliveries?status=delivered", nil)
+		deliveries = resp["deliveries"].([]interface{})
+	}
+	if status, _ := s.doWithHeader(t, admin, http.MethodGet, "/api/admin/webhooks/deliveries?status=lost", nil); status != http.StatusBadRequest {
+		t.Errorf("invalid status: status %d", status)
+	}
+
+	deliveryID := jsonNumber(int64(deliveries[0].(map[string]interface{})["id"].(float64)))
+	if status, _ := s.doWithHeader(t, admin, http.MethodPost, "/api/admin/webhooks/deliveries/"+deliveryID+"/redeliver", nil); status != http.StatusOK {
+		t.Errorf("redeliver: status %d", status)
+	}
+	select {
+	case <-received:
+		<-bodies
+	case <-time.After(5 * time.Second):
+		t.Fatal("redelivery not sent")
+	}
+	if status, _ := s.doWithHeader(t, admin, http.MethodPost, "/api/admin/webhooks/deliveries/9999/redeliver", nil); status != http.StatusNotFound {
+		t.Errorf("redeliver missing: status %d", status)
+	}
+
+	hookID := jsonNumber(int64(hook["id"].(float64)))
+	if status, _ := s.doWithHeader(t, admin, http.MethodDelete, "/api/admin/webhooks/"+hookID, nil); status != http.StatusOK {
+		t.Errorf("delete: status %d", status)
+	}
+	if status, _ := s.doWithHeader(t, admin, http.MethodDelete, "/api/admin/webhooks/"+hookID, nil); status != http.StatusNotFound {
+		t.Errorf("delete twice: status %d", status)
+	}
+}
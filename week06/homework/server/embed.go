@@ -0,0 +1,31 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// README.md是../readme.md的副本，go:embed不能引用模块目录之外的文件
+//go:generate cp ../readme.md README.md
+
+// 构建前端，修改前端代码后需要重新构建并把static目录一起提交，否则内嵌的还是旧页面。
+// 构建后在frontend.sha256中记录源码哈希，源码改动而没有重新构建时测试会失败
+//go:generate npm --prefix ../client run build
+//go:generate go test -run TestEmbeddedFrontendUpToDate -update .
+
+// 前端构建产物（client执行npm run build后输出到static目录）
+//
+//go:embed all:static
+var embeddedStatic embed.FS
+
+//go:embed README.md
+var embeddedReadme []byte
+
+// 内嵌的前端文件，根目录即static目录
+func staticFiles() fs.FS {
+	files, err := fs.Sub(embeddedStatic, "static")
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "重新记录构建static目录时使用的前端源码哈希")
+
+// 记录构建static目录时前端源码的哈希，go generate构建前端后更新
+const frontendHashFile = "frontend.sha256"
+
+// README.md由go generate从../readme.md复制，只修改其中一个时内嵌的说明会与仓库中的不一致
+func TestEmbeddedReadmeUpToDate(t *testing.T) {
+	source, err := os.ReadFile("../readme.md")
+	if err != nil {
+		t.Skipf("找不到../readme.md: %v", err)
+	}
+	if !bytes.Equal(source, embeddedReadme) {
+		t.Fatal("README.md与../readme.md不一致，请修改../readme.md后执行go generate")
+	}
+}
+
+// 修改了前端源码却没有重新构建时，内嵌的还是旧页面
+func TestEmbeddedFrontendUpToDate(t *testing.T) {
+	if _, err := os.Stat("../client/src"); err != nil {
+		t.Skipf("找不到前端源码: %v", err)
+	}
+	hash, err := frontendSourceHash("../client")
+	if err != nil {
+		t.Fatalf("计算前端源码哈希失败: %v", err)
+	}
+
+	if *update {
+		if err := os.WriteFile(frontendHashFile, []byte(hash+"\n"), 0644); err != nil {
+			t.Fatalf("写入%s失败: %v", frontendHashFile, err)
+		}
+		return
+	}
+
+	recorded, err := os.ReadFile(frontendHashFile)
+	if err != nil {
+		t.Fatalf("读取%s失败: %v", frontendHashFile, err)
+	}
+	if strings.TrimSpace(string(recorded)) != hash {
+		t.Fatal("static目录不是由当前的前端源码构建的，请在client执行npm ci后在server执行go generate，并提交static目录")
+	}
+}
+
+// 计算影响构建结果的前端文件的哈希：源码、公共资源、入口页面和构建配置，不包括node_modules
+func frontendSourceHash(dir string) (string, error) {
+	var files []string
+	for _, sub := range []string{"src", "public"} {
+		err := filepath.WalkDir(filepath.Join(dir, sub), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, name := range []string{"index.html", "package.json", "package-lock.json", "vite.config.ts", "tsconfig.json", "tsconfig.node.json"} {
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(filepath.ToSlash(rel)))
+		h.Write([]byte{0})
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
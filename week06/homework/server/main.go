@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"question-generator/config"
 	"question-generator/controllers"
+	"question-generator/logging"
+	"question-generator/metrics"
+	"question-generator/middleware"
+	"question-generator/mockllm"
 	"question-generator/routes"
 	"question-generator/services"
 	"syscall"
@@ -17,71 +24,216 @@ import (
 )
 
 func main() {
-	// 记录静态资源目录的绝对路径
-	absPath, _ := filepath.Abs("./static")
-	log.Printf("静态资源绝对路径: %s", absPath)
+	staticDir := flag.String("static-dir", "", "从磁盘目录提供前端文件，用于前端开发；留空使用编译时内嵌的文件")
+	configFile := flag.String("config", "", "YAML或TOML配置文件，也可以通过CONFIG_FILE设置")
+	flag.String("db", "", "题库数据库文件，覆盖DATABASE_PATH")
+	flag.String("host", "", "监听地址，覆盖HOST")
+	flag.String("port", "", "监听端口，覆盖PORT")
+	flag.String("log-level", "", "日志级别，覆盖LOG_LEVEL")
+	flag.Usage = printUsage
+	flag.Parse()
+
+	// 所有日志以JSON格式输出到标准输出，加载配置后再调整级别。
+	// 运维命令的结果输出到标准输出，日志改为输出到标准错误
+	if flag.NArg() > 0 {
+		logging.Setup(os.Stderr)
+	} else {
+		logging.Setup(os.Stdout)
+	}
+
+	// 默认使用内嵌的前端文件，可执行文件可以在任意目录启动
+	frontend := staticFiles()
+	if *staticDir != "" {
+		frontend = os.DirFS(*staticDir)
+		slog.Info("从磁盘目录提供前端文件", "path", *staticDir)
+	}
 
-	// 加载配置
-	cfg := config.LoadConfig()
+	// 加载配置，有无效的配置项时直接退出
+	configOptions := config.Options{File: *configFile, Flags: configFlags()}
+	cfg, err := config.Load(configOptions)
+	if err != nil {
+		slog.Error("无法加载配置", "error", err)
+		os.Exit(1)
+	}
+	logging.SetLevel(cfg.LogLevel)
+	// 热加载时与最初加载的配置比较，不受下面假模型服务地址的影响
+	loaded := *cfg
+
+	// 带命令启动时只执行运维命令
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(cfg, flag.Args()))
+	}
+
+	// 离线模式下所有模型请求都发往内置的假模型服务
+	if cfg.MockLLM {
+		mock, err := mockllm.NewServer()
+		if err != nil {
+			slog.Error("无法启动假模型服务", "error", err)
+			os.Exit(1)
+		}
+		defer mock.Close()
+
+		slog.Info("使用内置假模型服务", "url", mock.URL)
+		cfg.QwenAPIURL, cfg.QwenAPIKey = mock.URL, "mock"
+		cfg.DeepSeekAPIURL, cfg.DeepSeekAPIKey = mock.URL, "mock"
+	}
 
 	// 初始化服务
-	aiClient := services.NewAIClient(cfg)
-	storage := services.NewStorageService()
+	storage, err := services.OpenStorageFile(cfg.DatabasePath)
+	if err != nil {
+		slog.Error("无法打开题库", "error", err)
+		os.Exit(1)
+	}
+	usage, err := services.NewUsageService(storage.DB, cfg)
+	if err != nil {
+		slog.Error("无法初始化用量服务", "error", err)
+		os.Exit(1)
+	}
+	aiClient := services.NewAIClient(cfg, usage)
+	jobs := services.NewBackgroundJobs()
 
 	defer storage.DB.Close()
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		log.Println("正在关闭服务器...")
-		storage.DB.Close()
-		os.Exit(0)
-	}()
+	webhooks, err := services.NewWebhookService(storage.DB, cfg.Webhook)
+	if err != nil {
+		slog.Error("无法初始化webhook服务", "error", err)
+		os.Exit(1)
+	}
 
 	// 初始化控制器
-	questionController := controllers.NewQuestionController(aiClient, storage)
-
-	// 设置Gin路由
-	r := gin.Default()
+	courses := services.NewCourseService(storage)
+	questionController := controllers.NewQuestionController(aiClient, storage, courses, webhooks)
+	analysis := services.NewItemAnalysisService(storage, cfg.ItemAnalysis)
+	practice, err := services.NewPracticeService(storage)
+	if err != nil {
+		slog.Error("无法初始化练习服务", "error", err)
+		os.Exit(1)
+	}
+	examController := controllers.NewExamController(storage, analysis, practice, courses, webhooks)
+	grader, err := services.NewGraderService(storage, aiClient, jobs)
+	if err != nil {
+		slog.Error("无法初始化批改服务", "error", err)
+		os.Exit(1)
+	}
+	gradingController := controllers.NewGradingController(grader, storage, courses)
+	practiceController := controllers.NewPracticeController(practice, storage, courses)
+	adaptive, err := services.NewAdaptiveService(storage, analysis, cfg.Adaptive)
+	if err != nil {
+		slog.Error("无法初始化自适应测试服务", "error", err)
+		os.Exit(1)
+	}
+	adaptiveController := controllers.NewAdaptiveController(adaptive, courses)
+	usageController := controllers.NewUsageController(usage)
+	statsController := controllers.NewStatsController(services.NewStatsService(storage.DB, cfg.StatsCacheTTL), courses)
+	analysisController := controllers.NewAnalysisController(analysis, storage, courses)
+	backupController := controllers.NewBackupController(services.NewBackupService(storage, cfg.Backup))
+	webhookController := controllers.NewWebhookController(webhooks)
+	attachments := services.NewAttachmentService(storage, cfg.Attachment)
+	attachmentController := controllers.NewAttachmentController(attachments, cfg.Attachment.MaxBytes)
+	courseController := controllers.NewCourseController(courses)
+
+	// 设置Gin路由，访问日志和指标由自己的中间件负责
+	r := gin.New()
+	// 只信任配置的反向代理转发的客户端IP，限流和匿名用户的配额都按客户端IP计算
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		slog.Error("无效的可信代理", "error", err)
+		os.Exit(1)
+	}
+	r.Use(gin.Recovery(), middleware.RequestID(), middleware.AccessLog(), middleware.CORS(cfg.CORS))
 
-	r.Static("/assets", "./static/assets")
-	r.StaticFile("/vite.svg", "./static/vite.svg")
-	r.StaticFile("/favicon.ico", "./static/favicon.ico")
-	r.StaticFile("/README.md", "../README.md")
+	// Prometheus指标
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
 
-	r.GET("/", func(c *gin.Context) {
-		log.Println("访问根路径")
-		c.File("./static/index.html")
+	// 配置API路由
+	limiter := middleware.NewRateLimiter(cfg.RateLimits)
+	routes.SetupRoutes(r, limiter, routes.Auth{AdminToken: cfg.AdminToken, UserSecret: cfg.Auth.Secret}, routes.Controllers{
+		Question:   questionController,
+		Attachment: attachmentController,
+		Course:     courseController,
+		Exam:       examController,
+		Grading:    gradingController,
+		Practice:   practiceController,
+		Adaptive:   adaptiveController,
+		Usage:      usageController,
+		Stats:      statsController,
+		Analysis:   analysisController,
+		Backup:     backupController,
+		Webhook:    webhookController,
 	})
 
-	// 配置API路由
-	routes.SetupRoutes(r, questionController)
+	// 前端页面、静态资源和README
+	routes.SetupStatic(r, frontend, embeddedReadme)
 
-	// 处理前端路由
-	r.NoRoute(func(c *gin.Context) {
-		path := c.Request.URL.Path
-		log.Printf("处理未匹配路由: %s", path)
+	// 所有请求的上下文都派生自requestsCtx，关闭时等待超时后取消它，
+	// 使仍在进行的模型调用和数据库操作停止，不会在数据库关闭后继续运行
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 
-		if len(path) >= 4 && path[:4] == "/api" {
-			c.JSON(http.StatusNotFound, gin.H{"code": -1, "msg": "API not found"})
-			return
-		}
+	serverAddr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	server := &http.Server{
+		Addr:         serverAddr,
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		BaseContext:  func(net.Listener) context.Context { return requestsCtx },
+	}
+	if cfg.Server.ShutdownTimeout < services.ModelCallTimeout {
+		slog.Warn("关闭等待时间短于模型调用超时，关闭时进行中的出题可能被取消",
+			"shutdownTimeout", cfg.Server.ShutdownTimeout, "modelTimeout", services.ModelCallTimeout)
+	}
 
-		// 检查是否请求的是静态资源
-		if filepath.Ext(path) != "" {
-			log.Printf("找不到静态资源: %s", path)
-			c.Status(http.StatusNotFound)
-			return
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		log.Println("返回index.html用于前端路由处理")
-		c.File("./static/index.html")
-	})
+	// webhook投递在服务关闭时停止，未完成的投递下次启动后继续
+	webhooksDone := make(chan struct{})
+	go func() {
+		defer close(webhooksDone)
+		webhooks.Run(ctx)
+	}()
 
-	serverAddr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-	log.Printf("服务器启动于 http://%s", serverAddr)
-	if err := r.Run(serverAddr); err != nil {
-		log.Fatalf("无法启动服务器: %v", err)
+	// SIGHUP时重新加载配置
+	go watchReload(ctx, configOptions, &loaded, limiter, aiClient, usage)
+
+	// 定期清理不再被题目引用的图片
+	attachmentsDone := make(chan struct{})
+	go func() {
+		defer close(attachmentsDone)
+		attachments.Run(ctx)
+	}()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("服务器启动", "addr", "http://"+serverAddr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("无法启动服务器", "error", err)
+			os.Exit(1)
+		}
+	case <-ctx.Done():
+	}
+	stop()
+
+	// 先停止接收新请求并等待进行中的请求完成，再等待后台任务，最后关闭数据库。
+	// 等待时间以SERVER_SHUTDOWN_TIMEOUT为准：到期后取消请求的上下文，进行中的模型调用随之中止
+	slog.Info("正在关闭服务器")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	stopRequests := context.AfterFunc(shutdownCtx, cancelRequests)
+	defer stopRequests()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("等待请求完成超时，已取消进行中的请求", "error", err)
+	}
+	if err := jobs.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("等待后台任务完成超时", "error", err)
 	}
+	<-webhooksDone
+	<-attachmentsDone
+	slog.Info("服务器已关闭")
 }
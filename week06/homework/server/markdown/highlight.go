@@ -0,0 +1,229 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+)
+
+// 一种编程语言的词法规则，只用于给代码加上高亮的class，不做完整的语法分析
+type syntax struct {
+	keywords     map[string]bool
+	types        map[string]bool
+	literals     map[string]bool
+	lineComment  string
+	blockComment [2]string // 为空时不支持块注释
+	rawQuote     byte      // 可以跨行的字符串，Go和JavaScript中是反引号
+	tripleQuote  bool      // Python的'''和"""
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+// 题库支持的编程语言，键与models.ProgrammingLanguage一致
+var languages = map[string]*syntax{
+	"go": {
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto
+			if import interface map package range return select struct switch type var`),
+		types: words(`bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune
+			string uint uint8 uint16 uint32 uint64 uintptr any comparable
+			append cap clear close complex copy delete imag len make max min new panic print println real recover`),
+		literals:     words(`true false nil iota`),
+		lineComment:  "//",
+		blockComment: [2]string{"/*", "*/"},
+		rawQuote:     '`',
+	},
+	"java": {
+		keywords: words(`abstract assert break case catch class const continue default do else enum extends
+			final finally for goto if implements import instanceof interface native new package private
+			protected public return static strictfp super switch synchronized this throw throws transient
+			try var void volatile while record yield`),
+		types:        words(`boolean byte char double float int long short String Object Integer List Map`),
+		literals:     words(`true false null`),
+		lineComment:  "//",
+		blockComment: [2]string{"/*", "*/"},
+	},
+	"python": {
+		keywords: words(`and as assert async await break class continue def del elif else except finally
+			for from global if import in is lambda nonlocal not or pass raise return try while with yield match case`),
+		types:       words(`int float str bool list dict set tuple bytes object len range print isinstance type super`),
+		literals:    words(`True False None self`),
+		lineComment: "#",
+		tripleQuote: true,
+	},
+	"c++": {
+		keywords: words(`alignas alignof auto break case catch class const constexpr const_cast continue
+			decltype default delete do dynamic_cast else enum explicit export extern for friend goto if
+			inline mutable namespace new noexcept operator private protected public register
+			reinterpret_cast return sizeof static static_assert static_cast struct switch template this
+			thread_local throw try typedef typeid typename union using virtual volatile while`),
+		types: words(`bool char char16_t char32_t double float int long short signed unsigned void wchar_t
+			size_t string vector map set std`),
+		literals:     words(`true false nullptr NULL`),
+		lineComment:  "//",
+		blockComment: [2]string{"/*", "*/"},
+	},
+	"javascript": {
+		keywords: words(`async await break case catch class const continue debugger default delete do else
+			export extends finally for function if import in instanceof let new of return static super
+			switch this throw try typeof var void while with yield`),
+		types:        words(`Array Boolean Date Error JSON Map Math Number Object Promise Set String Symbol console`),
+		literals:     words(`true false null undefined NaN Infinity`),
+		lineComment:  "//",
+		blockComment: [2]string{"/*", "*/"},
+		rawQuote:     '`',
+	},
+}
+
+// 代码块上常见的语言别名
+var languageAliases = map[string]string{
+	"golang": "go",
+	"py":     "python",
+	"cpp":    "c++",
+	"cc":     "c++",
+	"c":      "c++",
+	"js":     "javascript",
+}
+
+// 渲染代码块。language是已知语言时按词法加上hl-keyword、hl-type、hl-literal、
+// hl-string、hl-comment和hl-number这几种class，其余语言只转义
+func Code(code, language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if alias, ok := languageAliases[language]; ok {
+		language = alias
+	}
+
+	var b strings.Builder
+	b.WriteString("<pre><code")
+	if class := languageClass(language); class != "" {
+		b.WriteString(` class="language-` + class + `"`)
+	}
+	b.WriteString(">")
+	if lang, ok := languages[language]; ok {
+		highlight(&b, code, lang)
+	} else {
+		b.WriteString(html.EscapeString(code))
+	}
+	b.WriteString("</code></pre>\n")
+	return b.String()
+}
+
+// 代码块语言对应的class后缀，只保留字母、数字和连字符
+func languageClass(language string) string {
+	if language == "c++" {
+		return "cpp"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return -1
+	}, language)
+}
+
+func highlight(b *strings.Builder, code string, lang *syntax) {
+	span := func(class, text string) {
+		b.WriteString(`<span class="hl-` + class + `">` + html.EscapeString(text) + "</span>")
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		c := code[i]
+		switch {
+		case strings.HasPrefix(rest, lang.lineComment):
+			n := strings.IndexByte(rest, '\n')
+			if n < 0 {
+				n = len(rest)
+			}
+			span("comment", rest[:n])
+			i += n
+
+		case lang.blockComment[0] != "" && strings.HasPrefix(rest, lang.blockComment[0]):
+			n := strings.Index(rest[2:], lang.blockComment[1])
+			if n < 0 {
+				n = len(rest)
+			} else {
+				n += 2 + len(lang.blockComment[1])
+			}
+			span("comment", rest[:n])
+			i += n
+
+		case lang.tripleQuote && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`)):
+			n := strings.Index(rest[3:], rest[:3])
+			if n < 0 {
+				n = len(rest)
+			} else {
+				n += 6
+			}
+			span("string", rest[:n])
+			i += n
+
+		case c == '"' || c == '\'' || (lang.rawQuote != 0 && c == lang.rawQuote):
+			n := stringLength(rest, c == lang.rawQuote)
+			span("string", rest[:n])
+			i += n
+
+		case isDigit(c) && (i == 0 || !isIdent(code[i-1])):
+			n := 1
+			for n < len(rest) && (isIdent(rest[n]) || rest[n] == '.' && n+1 < len(rest) && isDigit(rest[n+1])) {
+				n++
+			}
+			span("number", rest[:n])
+			i += n
+
+		case isIdentStart(c):
+			n := 1
+			for n < len(rest) && isIdent(rest[n]) {
+				n++
+			}
+			word := rest[:n]
+			switch {
+			case lang.keywords[word]:
+				span("keyword", word)
+			case lang.literals[word]:
+				span("literal", word)
+			case lang.types[word]:
+				span("type", word)
+			default:
+				b.WriteString(word)
+			}
+			i += n
+
+		default:
+			b.WriteString(html.EscapeString(rest[:1]))
+			i++
+		}
+	}
+}
+
+// 以引号开头的字符串的长度。普通字符串遇到换行结束，raw字符串不处理转义
+func stringLength(text string, raw bool) int {
+	quote := text[0]
+	for j := 1; j < len(text); j++ {
+		switch {
+		case text[j] == quote:
+			return j + 1
+		case text[j] == '\\' && !raw:
+			j++
+		case text[j] == '\n' && !raw:
+			return j
+		}
+	}
+	return len(text)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isIdent(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
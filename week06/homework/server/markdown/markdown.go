@@ -0,0 +1,432 @@
+// Package markdown 把题目文本中使用的受限Markdown渲染为HTML。
+//
+// 支持的语法：段落（单个换行保留为<br>）、#到###标题、无序和有序列表、
+// ``` 代码块、`行内代码`、**粗体**、*斜体*、[链接](url) 和 ![图片](url)。
+// 文本中的原始HTML标签会被去掉，其余内容全部转义，链接只允许http(s)和站内地址，
+// 因此输出可以直接插入页面而不会带来存储型XSS
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 文本中的HTML标签和注释，渲染时整体去掉
+var htmlTagPattern = regexp.MustCompile(`^(?:<!--[\s\S]*?-->|</?[A-Za-z][A-Za-z0-9-]*(?:\s[^<>]*)?/?>)`)
+
+// 有序列表项的前缀，例如 "1. "
+var orderedItemPattern = regexp.MustCompile(`^(\d{1,9})[.)]\s+`)
+
+// 渲染Markdown文本，返回安全的HTML。空文本返回空字符串
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	if strings.TrimSpace(src) == "" {
+		return ""
+	}
+
+	r := renderer{}
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if fence, lang, ok := openFence(line); ok {
+			r.flush()
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			var code []string
+			for i++; i < len(lines); i++ {
+				if closesFence(lines[i], fence) {
+					break
+				}
+				code = append(code, trimIndent(lines[i], indent))
+			}
+			r.out.WriteString(Code(strings.Join(code, "\n"), lang))
+			continue
+		}
+
+		if trimmed == "" {
+			r.flush()
+			continue
+		}
+
+		if level, text, ok := heading(trimmed); ok {
+			r.flush()
+			tag := "h" + strconv.Itoa(level)
+			r.out.WriteString("<" + tag + ">" + inline(text) + "</" + tag + ">\n")
+			continue
+		}
+
+		if text, ordered, start, ok := listItem(trimmed); ok {
+			r.flushParagraph()
+			if r.list != nil && r.list.ordered != ordered {
+				r.flushList()
+			}
+			if r.list == nil {
+				r.list = &list{ordered: ordered, start: start}
+			}
+			r.list.items = append(r.list.items, text)
+			continue
+		}
+
+		// 缩进的行接在上一个列表项后面
+		if r.list != nil && (line[0] == ' ' || line[0] == '\t') {
+			last := len(r.list.items) - 1
+			r.list.items[last] += "\n" + trimmed
+			continue
+		}
+
+		r.flushList()
+		r.paragraph = append(r.paragraph, trimmed)
+	}
+	r.flush()
+	return r.out.String()
+}
+
+type list struct {
+	ordered bool
+	start   int
+	items   []string
+}
+
+// 按行渲染时尚未输出的段落和列表
+type renderer struct {
+	out       strings.Builder
+	paragraph []string
+	list      *list
+}
+
+func (r *renderer) flush() {
+	r.flushParagraph()
+	r.flushList()
+}
+
+func (r *renderer) flushParagraph() {
+	if len(r.paragraph) == 0 {
+		return
+	}
+	r.out.WriteString("<p>" + inline(strings.Join(r.paragraph, "\n")) + "</p>\n")
+	r.paragraph = nil
+}
+
+func (r *renderer) flushList() {
+	if r.list == nil {
+		return
+	}
+	tag := "ul"
+	open := "<ul>"
+	if r.list.ordered {
+		tag = "ol"
+		open = "<ol>"
+		if r.list.start != 1 {
+			open = `<ol start="` + strconv.Itoa(r.list.start) + `">`
+		}
+	}
+	r.out.WriteString(open + "\n")
+	for _, item := range r.list.items {
+		r.out.WriteString("<li>" + inline(item) + "</li>\n")
+	}
+	r.out.WriteString("</" + tag + ">\n")
+	r.list = nil
+}
+
+// 代码块的起始行，返回围栏（``` 或 ~~~，可以更长）和语言
+func openFence(line string) (fence, lang string, ok bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 {
+		return "", "", false
+	}
+	c := trimmed[0]
+	if c != '`' && c != '~' {
+		return "", "", false
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == c {
+		n++
+	}
+	if n < 3 {
+		return "", "", false
+	}
+	info := strings.TrimSpace(trimmed[n:])
+	// ``` 后面的说明中不能再有反引号，否则是行内代码
+	if c == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+	if fields := strings.Fields(info); len(fields) > 0 {
+		lang = fields[0]
+	}
+	return trimmed[:n], lang, true
+}
+
+// 是否为与fence对应的结束行：同一种字符，长度不少于起始围栏
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// 去掉代码行开头最多n个与起始围栏相同的缩进
+func trimIndent(line string, n int) string {
+	for i := 0; i < n && len(line) > 0 && (line[0] == ' ' || line[0] == '\t'); i++ {
+		line = line[1:]
+	}
+	return line
+}
+
+// #到###开头的标题
+func heading(line string) (level int, text string, ok bool) {
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 3 || level == len(line) || line[level] != ' ' {
+		return 0, "", false
+	}
+	return level, strings.TrimSpace(line[level:]), true
+}
+
+// 以 -、*、+ 或 "1." 开头的列表项
+func listItem(line string) (text string, ordered bool, start int, ok bool) {
+	if len(line) >= 2 && strings.ContainsRune("-*+", rune(line[0])) && line[1] == ' ' {
+		return strings.TrimSpace(line[2:]), false, 0, true
+	}
+	if m := orderedItemPattern.FindStringSubmatch(line); m != nil {
+		start, _ = strconv.Atoi(m[1])
+		return line[len(m[0]):], true, start, true
+	}
+	return "", false, 0, false
+}
+
+// 渲染段落内的行内语法
+func inline(text string) string {
+	var b strings.Builder
+	renderInline(&b, text, true)
+	return b.String()
+}
+
+func renderInline(b *strings.Builder, text string, links bool) {
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && isPunct(text[i+1]):
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '\n':
+			b.WriteString("<br>\n")
+			i++
+			continue
+
+		case c == '<':
+			if m := htmlTagPattern.FindString(text[i:]); m != "" {
+				i += len(m)
+				continue
+			}
+
+		case c == '`':
+			if n := codeSpan(b, text[i:]); n > 0 {
+				i += n
+				continue
+			}
+			// 没有配对的反引号原样输出整串，避免后面的反引号被错误配对
+			n := countRun(text[i:], '`')
+			b.WriteString(text[i : i+n])
+			i += n
+			continue
+
+		case c == '*':
+			if n := emphasis(b, text[i:], links); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == '!' && links && strings.HasPrefix(text[i+1:], "["):
+			if n := image(b, text[i:]); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == '[' && links:
+			if n := link(b, text[i:]); n > 0 {
+				i += n
+				continue
+			}
+		}
+		b.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+}
+
+// 行内代码，返回消耗的长度，不是行内代码时返回0
+func codeSpan(b *strings.Builder, text string) int {
+	n := countRun(text, '`')
+	for j := n; j < len(text); {
+		if text[j] != '`' {
+			j++
+			continue
+		}
+		m := countRun(text[j:], '`')
+		if m == n {
+			code := text[n:j]
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+				code = code[1 : len(code)-1]
+			}
+			b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			return j + m
+		}
+		j += m
+	}
+	return 0
+}
+
+// **粗体** 和 *斜体*。开始标记后和结束标记前不能是空白，
+// 避免 *int 和 *string 这样的指针类型被当作斜体
+func emphasis(b *strings.Builder, text string, links bool) int {
+	marker, tag := "*", "em"
+	if strings.HasPrefix(text, "**") {
+		marker, tag = "**", "strong"
+	}
+	rest := text[len(marker):]
+	if rest == "" || isSpace(rest[0]) {
+		return 0
+	}
+	for j := 1; j < len(rest); j++ {
+		if strings.HasPrefix(rest[j:], marker) && !isSpace(rest[j-1]) {
+			if marker == "*" && strings.HasPrefix(rest[j:], "**") {
+				j++
+				continue
+			}
+			b.WriteString("<" + tag + ">")
+			renderInline(b, rest[:j], links)
+			b.WriteString("</" + tag + ">")
+			return len(marker) + j + len(marker)
+		}
+	}
+	return 0
+}
+
+// ![说明](地址)
+func image(b *strings.Builder, text string) int {
+	alt, target, n := linkParts(text[1:])
+	if n == 0 {
+		return 0
+	}
+	if u, ok := safeURL(target, true); ok {
+		b.WriteString(`<img src="` + html.EscapeString(u) + `" alt="` + html.EscapeString(plainText(alt)) + `" loading="lazy">`)
+	} else {
+		b.WriteString(html.EscapeString(plainText(alt)))
+	}
+	return n + 1
+}
+
+// [文字](地址)，地址不安全时只输出文字
+func link(b *strings.Builder, text string) int {
+	label, target, n := linkParts(text)
+	if n == 0 {
+		return 0
+	}
+	u, ok := safeURL(target, false)
+	if ok {
+		b.WriteString(`<a href="` + html.EscapeString(u) + `" rel="nofollow noopener noreferrer">`)
+	}
+	renderInline(b, label, false)
+	if ok {
+		b.WriteString("</a>")
+	}
+	return n
+}
+
+// 解析 [label](target)，返回消耗的长度，格式不对时返回0
+func linkParts(text string) (label, target string, n int) {
+	if !strings.HasPrefix(text, "[") {
+		return "", "", 0
+	}
+	depth := 0
+	end := -1
+	for j := 0; j < len(text) && end < 0; j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				end = j
+			}
+		case '\n':
+			return "", "", 0
+		}
+	}
+	if end < 0 || !strings.HasPrefix(text[end+1:], "(") {
+		return "", "", 0
+	}
+	close := strings.IndexByte(text[end+2:], ')')
+	if close < 0 {
+		return "", "", 0
+	}
+	target = strings.TrimSpace(text[end+2 : end+2+close])
+	if target == "" || strings.ContainsAny(target, " \t\n") {
+		return "", "", 0
+	}
+	return text[1:end], target, end + 2 + close + 1
+}
+
+// 只允许http(s)、站内路径和页内锚点，链接还允许mailto
+func safeURL(target string, image bool) (string, bool) {
+	for _, r := range target {
+		if r < 0x20 || r == 0x7f || r == '\\' {
+			return "", false
+		}
+	}
+	if strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") {
+		return target, true
+	}
+	if strings.HasPrefix(target, "#") && !image {
+		return target, true
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return target, u.Host != ""
+	case "mailto":
+		return target, !image
+	}
+	return "", false
+}
+
+// 图片说明中的行内标记只保留文字
+func plainText(text string) string {
+	text = strings.NewReplacer("**", "", "*", "", "`", "").Replace(text)
+	for {
+		loc := strings.IndexByte(text, '<')
+		if loc < 0 {
+			return text
+		}
+		m := htmlTagPattern.FindString(text[loc:])
+		if m == "" {
+			return text[:loc+1] + plainText(text[loc+1:])
+		}
+		text = text[:loc] + text[loc+len(m):]
+	}
+}
+
+func countRun(text string, c byte) int {
+	n := 0
+	for n < len(text) && text[n] == c {
+		n++
+	}
+	return n
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("\\`*_{}[]()#+-.!<>|~", c) >= 0
+}
@@ -0,0 +1,83 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"empty", "  \n", ""},
+		{"paragraph", "第一行\n第二行\n\n第二段", "<p>第一行<br>\n第二行</p>\n<p>第二段</p>\n"},
+		{"inline", "**粗体** *斜体* `a < b` 和 \\*星号", "<p><strong>粗体</strong> <em>斜体</em> <code>a &lt; b</code> 和 *星号</p>\n"},
+		{"pointer types", "返回 *int 和 *string", "<p>返回 *int 和 *string</p>\n"},
+		{"heading", "### 解析", "<h3>解析</h3>\n"},
+		{"lists", "- a\n- b\n  续行\n\n3. c\n4. d", "<ul>\n<li>a</li>\n<li>b<br>\n续行</li>\n</ul>\n<ol start=\"3\">\n<li>c</li>\n<li>d</li>\n</ol>\n"},
+		{"link", "[文档](https://go.dev/doc)", `<p><a href="https://go.dev/doc" rel="nofollow noopener noreferrer">文档</a></p>` + "\n"},
+		{"image", "![树](/api/attachments/abc)", `<p><img src="/api/attachments/abc" alt="树" loading="lazy"></p>` + "\n"},
+		{"unclosed code", "`a", "<p>`a</p>\n"},
+		{"fence", "```\n<b>x</b>\n```\n之后", "<pre><code>&lt;b&gt;x&lt;/b&gt;</code></pre>\n<p>之后</p>\n"},
+		{"unknown language", "```shell\nls\n```", "<pre><code class=\"language-shell\">ls</code></pre>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.src); got != tt.want {
+				t.Errorf("Render(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+// 原始HTML和危险链接不能出现在输出中
+func TestRenderSanitizes(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"<script>alert(1)</script>题干", "<p>alert(1)题干</p>\n"},
+		{"<img src=x\nonerror=alert(1)>图", "<p>图</p>\n"},
+		{"a <!-- 注释 --> b", "<p>a  b</p>\n"},
+		{"x < y && y > z", "<p>x &lt; y &amp;&amp; y &gt; z</p>\n"},
+		{"v := <-ch", "<p>v := &lt;-ch</p>\n"},
+		{"[点击](javascript:alert(1))", "<p>点击)</p>\n"},
+		{"[点击](//evil.example.com)", "<p>点击</p>\n"},
+		{"[点击](/\\evil.example.com)", "<p>点击</p>\n"},
+		{`![x" onerror="alert(1)](data:image/png;base64,AAAA)`, "<p>x&#34; onerror=&#34;alert(1)</p>\n"},
+		{"```go\" onmouseover=\"alert(1)\nx\n```", "<pre><code class=\"language-go\">x</code></pre>\n"},
+	}
+	for _, tt := range tests {
+		got := Render(tt.src)
+		if got != tt.want {
+			t.Errorf("Render(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+		}
+		for _, bad := range []string{"<script", "<img src=x", "javascript:", "onerror=\"", "//evil"} {
+			if strings.Contains(got, bad) {
+				t.Errorf("Render(%q) = %q contains %q", tt.src, got, bad)
+			}
+		}
+	}
+}
+
+func TestCodeHighlight(t *testing.T) {
+	src := "```go\nfunc f() int { // 返回\n\treturn len(\"a<b\") + 0x1F\n}\n```"
+	want := `<pre><code class="language-go"><span class="hl-keyword">func</span> f() <span class="hl-type">int</span> { <span class="hl-comment">// 返回</span>` + "\n" +
+		"\t" + `<span class="hl-keyword">return</span> <span class="hl-type">len</span>(<span class="hl-string">&#34;a&lt;b&#34;</span>) + <span class="hl-number">0x1F</span>` + "\n" +
+		"}</code></pre>\n"
+	if got := Render(src); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	got := Code("s = '''多行\n字符串''' # 注释\nx = None", "py")
+	for _, want := range []string{`class="language-python"`, `<span class="hl-string">&#39;&#39;&#39;多行` + "\n" + `字符串&#39;&#39;&#39;</span>`, `<span class="hl-comment"># 注释</span>`, `<span class="hl-literal">None</span>`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("python code %q missing %q", got, want)
+		}
+	}
+	if got := Code("a<b", "c++"); got != `<pre><code class="language-cpp">a&lt;b</code></pre>`+"\n" {
+		t.Errorf("c++ code = %q", got)
+	}
+}
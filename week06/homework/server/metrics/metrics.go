@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "question_generator"
+
+// 独立的注册表，避免引入其他库注册到默认注册表中的指标
+var registry = prometheus.NewRegistry()
+
+var (
+	// HTTP请求耗时，route为路由模板，未匹配的请求统一记为unmatched
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP请求耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// 模型调用耗时，模型调用通常需要数秒到数分钟
+	LLMRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "模型调用耗时",
+		Buckets:   []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 180},
+	}, []string{"provider", "purpose", "outcome"})
+
+	// 模型调用消耗的tokens，type为prompt或completion
+	LLMTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "模型调用消耗的tokens",
+	}, []string{"provider", "purpose", "type"})
+
+	// 模型输出解析失败次数。stage为structured时表示结构化解析失败后改用文本解析，
+	// salvaged表示严格解析失败但宽松解析恢复了部分题目，failed表示完全无法解析
+	LLMParseFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_parse_failures_total",
+		Help:      "模型输出解析失败次数",
+	}, []string{"provider", "stage"})
+
+	// 数据库操作耗时
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "数据库操作耗时",
+		Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 1},
+	}, []string{"operation"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		LLMRequestDuration,
+		LLMTokens,
+		LLMParseFailures,
+		DBQueryDuration,
+	)
+}
+
+// 以Prometheus文本格式输出所有指标
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// 记录一次数据库操作的耗时，用法: defer metrics.ObserveDB("list_questions")()
+func ObserveDB(operation string) func() {
+	start := time.Now()
+	return func() {
+		DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"question-generator/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 管理接口的令牌请求头
+const AdminTokenHeader = "X-Admin-Token"
+
+// 校验管理接口的访问令牌，未配置令牌时拒绝所有管理请求
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.HTTPResponse{
+				Code: -1,
+				Msg:  "未配置ADMIN_TOKEN，管理接口不可用",
+			})
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader(AdminTokenHeader)), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.HTTPResponse{
+				Code: -1,
+				Msg:  "管理令牌无效",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// 请求上下文中标记管理员身份的键
+const AdminContextKey = "admin"
+
+// 请求带有有效的管理令牌时标记为管理员，其他请求照常处理。
+// 管理员访问课程和题库时不受成员身份限制
+func IdentifyAdmin(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader(AdminTokenHeader)), []byte(token)) == 1 {
+			c.Set(AdminContextKey, true)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"question-generator/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 用户令牌放在Authorization请求头中，格式为"Bearer <令牌>"
+const AuthorizationHeader = "Authorization"
+
+// 请求上下文中保存已验证的用户ID的键
+const UserContextKey = "userID"
+
+// 令牌格式错误、签名不符或已过期
+var ErrInvalidToken = errors.New("用户令牌无效")
+
+// 签发用户令牌。令牌由用户ID、过期时间和用AUTH_SECRET计算的HMAC-SHA256签名组成，
+// 服务端不保存令牌，更换密钥后之前签发的令牌全部失效
+func SignUserToken(secret, userID string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(tokenSignature(secret, payload))
+}
+
+// 校验用户令牌，返回其中的用户ID
+func VerifyUserToken(secret, token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if secret == "" || len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, tokenSignature(secret, parts[0]+"."+parts[1])) {
+		return "", ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return "", ErrInvalidToken
+	}
+	userID, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(userID) == 0 {
+		return "", ErrInvalidToken
+	}
+	return string(userID), nil
+}
+
+func tokenSignature(secret, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// 验证请求携带的用户令牌，通过后把用户ID保存在上下文中。
+// 没有携带令牌的请求作为匿名用户继续处理，令牌无效时返回401，
+// 不会退回匿名身份，以免客户端误以为自己已经登录
+func Authenticate(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader(AuthorizationHeader)
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.HTTPResponse{
+				Code: -1,
+				Msg:  "Authorization请求头应为Bearer <令牌>",
+			})
+			return
+		}
+		userID, err := VerifyUserToken(secret, strings.TrimSpace(token), time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.HTTPResponse{
+				Code: -1,
+				Msg:  err.Error(),
+			})
+			return
+		}
+
+		c.Set(UserContextKey, userID)
+		c.Next()
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUserToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	token := SignUserToken("secret", "alice", now.Add(time.Hour))
+
+	if userID, err := VerifyUserToken("secret", token, now); err != nil || userID != "alice" {
+		t.Fatalf("VerifyUserToken = %q, %v", userID, err)
+	}
+
+	forged := SignUserToken("secret", "bob", now.Add(time.Hour))
+	tests := map[string]struct {
+		secret string
+		token  string
+		now    time.Time
+	}{
+		"过期":    {"secret", token, now.Add(time.Hour)},
+		"密钥不同":  {"other", token, now},
+		"未配置密钥": {"", token, now},
+		"篡改用户":  {"secret", forged[:len(forged)-43] + token[len(token)-43:], now},
+		"格式错误":  {"secret", "alice", now},
+	}
+	for name, tt := range tests {
+		if _, err := VerifyUserToken(tt.secret, tt.token, tt.now); err != ErrInvalidToken {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(Authenticate("secret"))
+	r.GET("/api/me", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(UserContextKey))
+	})
+
+	request := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+		if authorization != "" {
+			req.Header.Set(AuthorizationHeader, authorization)
+		}
+		// 未经验证的用户请求头不起作用
+		req.Header.Set("X-User-ID", "teacher")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request(""); w.Code != http.StatusOK || w.Body.String() != "" {
+		t.Errorf("anonymous: %d %q", w.Code, w.Body.String())
+	}
+	if w := request("Bearer " + SignUserToken("secret", "alice", time.Now().Add(time.Hour))); w.Code != http.StatusOK || w.Body.String() != "alice" {
+		t.Errorf("valid token: %d %q", w.Code, w.Body.String())
+	}
+	for _, header := range []string{"alice", "Bearer " + SignUserToken("other", "teacher", time.Now().Add(time.Hour))} {
+		if w := request(header); w.Code != http.StatusUnauthorized {
+			t.Errorf("%q: status %d", header, w.Code)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"question-generator/config"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 跨域请求允许的方法，与路由中用到的方法一致
+const corsAllowMethods = "GET, POST, PUT, DELETE, OPTIONS"
+
+// 跨域访问中间件，只处理配置中允许的来源。没有配置来源时不做任何处理，
+// 浏览器按同源策略拒绝跨域请求
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool, len(cfg.AllowOrigins))
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[origin] = true
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if len(allowed) == 0 || origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if !allowAll && !allowed[origin] {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Access-Control-Expose-Headers", RequestIDHeader+", Retry-After")
+
+		// 预检请求直接返回，不进入路由
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			if headers := c.GetHeader("Access-Control-Request-Headers"); headers != "" {
+				c.Header("Access-Control-Allow-Headers", headers)
+			}
+			c.Header("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
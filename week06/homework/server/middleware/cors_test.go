@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"question-generator/config"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCORSRouter(cfg config.CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORS(cfg))
+	r.GET("/api/questions/list", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func corsRequest(r *gin.Engine, method, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/questions/list", nil)
+	req.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		req.Header.Set("Access-Control-Request-Headers", "X-User-ID")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS(t *testing.T) {
+	r := newCORSRouter(config.CORSConfig{AllowOrigins: []string{"https://a.example.com"}, MaxAge: time.Hour})
+
+	w := corsRequest(r, http.MethodGet, "https://a.example.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://a.example.com" {
+		t.Fatalf("allowed origin: code=%d headers=%v", w.Code, w.Header())
+	}
+
+	w = corsRequest(r, http.MethodOptions, "https://a.example.com")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Headers") != "X-User-ID" || w.Header().Get("Access-Control-Max-Age") != "3600" {
+		t.Fatalf("preflight: code=%d headers=%v", w.Code, w.Header())
+	}
+
+	w = corsRequest(r, http.MethodGet, "https://evil.example.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("other origin allowed: %v", w.Header())
+	}
+}
+
+func TestCORSDisabled(t *testing.T) {
+	r := newCORSRouter(config.CORSConfig{})
+	w := corsRequest(r, http.MethodGet, "https://a.example.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("code=%d headers=%v", w.Code, w.Header())
+	}
+}
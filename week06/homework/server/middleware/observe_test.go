@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"question-generator/logging"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(logging.New(&buf, slog.LevelInfo))
+	defer slog.SetDefault(defaultLogger)
+
+	var seen string
+	r := gin.New()
+	r.Use(RequestID(), AccessLog())
+	r.GET("/api/questions/list", func(c *gin.Context) {
+		seen = logging.RequestID(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	// 沿用客户端传入的ID
+	req := httptest.NewRequest(http.MethodGet, "/api/questions/list", nil)
+	req.Header.Set(RequestIDHeader, "abc123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if seen != "abc123" || w.Header().Get(RequestIDHeader) != "abc123" {
+		t.Fatalf("request id: ctx=%q header=%q", seen, w.Header().Get(RequestIDHeader))
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("access log is not JSON: %v\n%s", err, buf.String())
+	}
+	if entry["request_id"] != "abc123" || entry["route"] != "/api/questions/list" || entry["status"] != float64(200) {
+		t.Errorf("unexpected access log: %v", entry)
+	}
+
+	// 没有传入时生成新的ID
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/questions/list", nil))
+	if id := w.Header().Get(RequestIDHeader); id == "" || id != seen {
+		t.Errorf("generated id: header=%q ctx=%q", id, seen)
+	}
+}
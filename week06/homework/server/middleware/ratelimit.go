@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"question-generator/config"
+	"question-generator/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 清理空闲令牌桶的间隔
+const evictInterval = time.Minute
+
+// 单个客户端在单个接口上的令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  config.RateLimit
+}
+
+// 按"接口+客户端"维护令牌桶的内存限流器
+type RateLimiter struct {
+	limits    config.RateLimitConfig
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastEvict time.Time
+	now       func() time.Time
+}
+
+// 创建限流器
+func NewRateLimiter(limits config.RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		limits:    limits,
+		buckets:   make(map[string]*bucket),
+		lastEvict: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// 替换限流规则，用于热加载配置。已有的令牌桶全部丢弃，按新规则重新计数
+func (l *RateLimiter) SetLimits(limits config.RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+	l.buckets = make(map[string]*bucket)
+}
+
+// 返回接口对应的限流规则，调用时需持有锁
+func (l *RateLimiter) limitFor(route string) config.RateLimit {
+	if limit, ok := l.limits.Routes[route]; ok {
+		return limit
+	}
+	return l.limits.Default
+}
+
+// 尝试从令牌桶中取一个令牌，失败时返回需要等待的时间
+func (l *RateLimiter) Allow(route, client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.limitFor(route)
+	if !limit.Enabled() {
+		return true, 0
+	}
+
+	// 每秒补充的令牌数
+	rate := float64(limit.Requests) / limit.Period.Seconds()
+	burst := float64(limit.Burst)
+
+	now := l.now()
+	l.evictLocked(now)
+
+	key := route + "|" + client
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now, limit: limit}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// 定期删除已经补满的令牌桶，补满的桶与新建的桶等价，删除不影响限流结果
+func (l *RateLimiter) evictLocked(now time.Time) {
+	if now.Sub(l.lastEvict) < evictInterval {
+		return
+	}
+	l.lastEvict = now
+
+	for key, b := range l.buckets {
+		// 补满所需的时间
+		refill := float64(b.limit.Burst) * b.limit.Period.Seconds() / float64(b.limit.Requests)
+		if now.Sub(b.last).Seconds() >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// 当前内存中的令牌桶数量
+func (l *RateLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// 限流中间件，clientKey用于区分调用者（用户标识或IP）。
+// 超出限制时返回429并在Retry-After中给出需要等待的秒数
+func RateLimit(limiter *RateLimiter, clientKey func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		allowed, wait := limiter.Allow(route, clientKey(c))
+		if allowed {
+			c.Next()
+			return
+		}
+
+		seconds := int(math.Ceil(wait.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, models.HTTPResponse{
+			Code: -1,
+			Msg:  fmt.Sprintf("请求过于频繁，请%d秒后重试", seconds),
+		})
+	}
+}
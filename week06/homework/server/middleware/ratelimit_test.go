@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"question-generator/config"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 创建使用可控时钟的限流器
+func newTestLimiter(limits config.RateLimitConfig) (*RateLimiter, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(limits)
+	limiter.now = func() time.Time { return now }
+	limiter.lastEvict = now
+	return limiter, &now
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter, now := newTestLimiter(config.RateLimitConfig{
+		Default: config.RateLimit{Requests: 60, Period: time.Minute, Burst: 60},
+		Routes: map[string]config.RateLimit{
+			config.GenerateRoute: {Requests: 2, Period: time.Minute, Burst: 2},
+			config.ListRoute:     {},
+		},
+	})
+
+	// 突发容量用完后被拒绝
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow(config.GenerateRoute, "alice"); !ok {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	ok, wait := limiter.Allow(config.GenerateRoute, "alice")
+	if ok || wait != 30*time.Second {
+		t.Fatalf("third request: ok=%v wait=%v, want rejected with 30s", ok, wait)
+	}
+
+	// 其他客户端和其他接口不受影响
+	if ok, _ := limiter.Allow(config.GenerateRoute, "bob"); !ok {
+		t.Error("bob rejected")
+	}
+	if ok, _ := limiter.Allow("DELETE /api/questions/delete", "alice"); !ok {
+		t.Error("default route rejected")
+	}
+
+	// 未开启限流的接口不创建令牌桶
+	for i := 0; i < 1000; i++ {
+		if ok, _ := limiter.Allow(config.ListRoute, "alice"); !ok {
+			t.Fatal("unlimited route rejected")
+		}
+	}
+
+	// 等待补充一个令牌
+	*now = now.Add(20 * time.Second)
+	if ok, wait := limiter.Allow(config.GenerateRoute, "alice"); ok || wait != 10*time.Second {
+		t.Fatalf("after 20s: ok=%v wait=%v", ok, wait)
+	}
+	*now = now.Add(10 * time.Second)
+	if ok, _ := limiter.Allow(config.GenerateRoute, "alice"); !ok {
+		t.Fatal("rejected after refill")
+	}
+}
+
+func TestRateLimiterEviction(t *testing.T) {
+	limiter, now := newTestLimiter(config.RateLimitConfig{
+		Default: config.RateLimit{Requests: 10, Period: time.Minute, Burst: 10},
+		Routes: map[string]config.RateLimit{
+			config.GenerateRoute: {Requests: 1, Period: time.Hour, Burst: 1},
+		},
+	})
+
+	limiter.Allow(config.GenerateRoute, "alice")
+	limiter.Allow("GET /api/usage/report", "alice")
+	limiter.Allow("GET /api/usage/report", "bob")
+	if limiter.Len() != 3 {
+		t.Fatalf("len = %d, want 3", limiter.Len())
+	}
+
+	// 两分钟后默认规则的桶已补满被清理，生成接口的桶还需要等待
+	*now = now.Add(2 * time.Minute)
+	limiter.Allow(config.ListRoute, "carol")
+	if limiter.Len() != 2 {
+		t.Fatalf("len after eviction = %d, want 2", limiter.Len())
+	}
+	if ok, _ := limiter.Allow(config.GenerateRoute, "alice"); ok {
+		t.Error("eviction reset a bucket that was still refilling")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter, _ := newTestLimiter(config.RateLimitConfig{
+		Routes: map[string]config.RateLimit{
+			config.GenerateRoute: {Requests: 1, Period: 90 * time.Second, Burst: 1},
+		},
+	})
+
+	r := gin.New()
+	api := r.Group("/api")
+	api.Use(RateLimit(limiter, func(c *gin.Context) string { return c.GetHeader("X-User-ID") }))
+	api.POST("/questions/create", func(c *gin.Context) { c.Status(http.StatusOK) })
+	api.GET("/questions/list", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(method, path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-User-ID", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send(http.MethodPost, "/api/questions/create", "alice"); w.Code != http.StatusOK {
+		t.Fatalf("first request: %d", w.Code)
+	}
+	w := send(http.MethodPost, "/api/questions/create", "alice")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "90" {
+		t.Fatalf("second request: %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := send(http.MethodPost, "/api/questions/create", "bob"); w.Code != http.StatusOK {
+		t.Errorf("other user: %d", w.Code)
+	}
+	if w := send(http.MethodGet, "/api/questions/list", "alice"); w.Code != http.StatusOK {
+		t.Errorf("list: %d", w.Code)
+	}
+}
+
+func TestRateLimiterSetLimits(t *testing.T) {
+	limiter, _ := newTestLimiter(config.RateLimitConfig{
+		Routes: map[string]config.RateLimit{config.GenerateRoute: {Requests: 1, Period: time.Minute, Burst: 1}},
+	})
+	limiter.Allow(config.GenerateRoute, "alice")
+	if ok, _ := limiter.Allow(config.GenerateRoute, "alice"); ok {
+		t.Fatal("second request allowed")
+	}
+
+	// 热加载后按新规则重新计数
+	limiter.SetLimits(config.RateLimitConfig{
+		Routes: map[string]config.RateLimit{config.GenerateRoute: {Requests: 2, Period: time.Minute, Burst: 2}},
+	})
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow(config.GenerateRoute, "alice"); !ok {
+			t.Fatalf("request %d after reload rejected", i)
+		}
+	}
+	if ok, _ := limiter.Allow(config.GenerateRoute, "alice"); ok {
+		t.Fatal("request over new limit allowed")
+	}
+}
@@ -0,0 +1,252 @@
+// mockllm 提供一个进程内的假OpenAI兼容服务，回放预先录制好的模型响应，
+// 用于离线开发和测试，不需要真实的API密钥
+package mockllm
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+//go:embed recordings/*.json
+var recordingFS embed.FS
+
+// 一条录制好的响应
+type Recording struct {
+	Name                 string `json:"-"`
+	Status               int    `json:"status"`                         // HTTP状态码，默认200
+	Content              string `json:"content,omitempty"`              // 模型返回的消息内容
+	Body                 string `json:"body,omitempty"`                 // 非200时原样返回的响应体
+	FinishReason         string `json:"finishReason,omitempty"`         // 默认stop，截断的响应为length
+	RejectResponseFormat bool   `json:"rejectResponseFormat,omitempty"` // 请求带response_format时返回400，模拟不支持结构化输出的服务商
+	PromptTokens         int    `json:"promptTokens,omitempty"`
+	CompletionTokens     int    `json:"completionTokens,omitempty"`
+}
+
+// 假服务收到的请求，只保留断言需要的字段；
+// openai.ChatCompletionRequest中的Schema是接口类型，无法直接反序列化
+type Request struct {
+	Model          string                         `json:"model"`
+	Messages       []openai.ChatCompletionMessage `json:"messages"`
+	MaxTokens      int                            `json:"max_tokens"`
+	ResponseFormat *ResponseFormat                `json:"response_format,omitempty"`
+}
+
+// 请求中的response_format参数
+type ResponseFormat struct {
+	Type       openai.ChatCompletionResponseFormatType `json:"type"`
+	JSONSchema *struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+	} `json:"json_schema,omitempty"`
+}
+
+// 假模型服务
+type Server struct {
+	URL string // 作为openai客户端的BaseURL使用
+
+	listener   net.Listener
+	httpServer *http.Server
+	recordings map[string]Recording
+
+	mu       sync.Mutex
+	queue    []string
+	requests []Request
+}
+
+// 加载所有录制的响应，按名称索引
+func LoadRecordings() (map[string]Recording, error) {
+	entries, err := recordingFS.ReadDir("recordings")
+	if err != nil {
+		return nil, err
+	}
+
+	recordings := make(map[string]Recording, len(entries))
+	for _, entry := range entries {
+		data, err := recordingFS.ReadFile(path.Join("recordings", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var rec Recording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("解析录制文件%s失败: %w", entry.Name(), err)
+		}
+		rec.Name = strings.TrimSuffix(entry.Name(), ".json")
+		if rec.Status == 0 {
+			rec.Status = http.StatusOK
+		}
+		if rec.FinishReason == "" {
+			rec.FinishReason = string(openai.FinishReasonStop)
+		}
+		recordings[rec.Name] = rec
+	}
+
+	return recordings, nil
+}
+
+// 在本机随机端口上启动假模型服务
+func NewServer() (*Server, error) {
+	recordings, err := LoadRecordings()
+	if err != nil {
+		return nil, fmt.Errorf("加载录制响应失败: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("监听端口失败: %w", err)
+	}
+
+	s := &Server{
+		URL:        "http://" + listener.Addr().String() + "/v1",
+		listener:   listener,
+		recordings: recordings,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", s.handleChatCompletion)
+	s.httpServer = &http.Server{Handler: mux}
+
+	go s.httpServer.Serve(listener)
+
+	return s, nil
+}
+
+// 关闭服务
+func (s *Server) Close() error {
+	return s.httpServer.Close()
+}
+
+// 指定接下来依次回放的录制响应；队列为空时按提示语内容自动选择
+func (s *Server) Enqueue(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, names...)
+}
+
+// 返回收到的所有请求，便于断言请求参数
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// 返回所有录制响应的名称
+func (s *Server) Names() []string {
+	names := make([]string, 0, len(s.recordings))
+	for name := range s.recordings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 根据提示语选择默认的录制响应
+func routeByPrompt(prompt string) string {
+	switch {
+	case strings.Contains(prompt, "审核员"):
+		return "review"
+	case strings.Contains(prompt, "阅卷老师"):
+		return "grade"
+	case strings.Contains(prompt, "编程题不需要提供代码"):
+		return "programming"
+	case strings.Contains(prompt, "这是多选题"):
+		return "multi_choice"
+	case strings.Contains(prompt, "options固定为"):
+		return "true_false"
+	case strings.Contains(prompt, "四个下划线"):
+		return "fill_blank"
+	case strings.Contains(prompt, "得分要点"):
+		return "short_answer"
+	default:
+		return "single_choice"
+	}
+}
+
+func (s *Server) handleChatCompletion(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "无法解析请求: "+err.Error())
+		return
+	}
+
+	var prompt string
+	if len(req.Messages) > 0 {
+		prompt = req.Messages[len(req.Messages)-1].Content
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	name := routeByPrompt(prompt)
+	if len(s.queue) > 0 {
+		name = s.queue[0]
+		s.queue = s.queue[1:]
+	}
+	s.mu.Unlock()
+
+	rec, ok := s.recordings[name]
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "server_error", "未找到录制响应: "+name)
+		return
+	}
+
+	if rec.RejectResponseFormat && req.ResponseFormat != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "response_format is not supported")
+		// 重试的请求仍然使用同一条录制响应
+		s.mu.Lock()
+		s.queue = append([]string{name}, s.queue...)
+		s.mu.Unlock()
+		return
+	}
+
+	if rec.Status != http.StatusOK {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(rec.Status)
+		w.Write([]byte(rec.Body))
+		return
+	}
+
+	resp := openai.ChatCompletionResponse{
+		ID:      "mock-" + rec.Name,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []openai.ChatCompletionChoice{{
+			Index: 0,
+			Message: openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: rec.Content,
+			},
+			FinishReason: openai.FinishReason(rec.FinishReason),
+		}},
+		Usage: openai.Usage{
+			PromptTokens:     rec.PromptTokens,
+			CompletionTokens: rec.CompletionTokens,
+			TotalTokens:      rec.PromptTokens + rec.CompletionTokens,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 按OpenAI的错误格式返回
+func writeError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errType,
+		},
+	})
+}
@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// 自适应测试会话状态
+type AdaptiveStatus string
+
+const (
+	AdaptiveActive   AdaptiveStatus = "active"
+	AdaptiveFinished AdaptiveStatus = "finished"
+)
+
+// 自适应测试结束的原因
+type AdaptiveStopReason string
+
+const (
+	StopPrecision    AdaptiveStopReason = "precision"     // 能力估计的标准误低于目标值
+	StopMaxQuestions AdaptiveStopReason = "max_questions" // 达到题数上限
+	StopPoolEmpty    AdaptiveStopReason = "pool_empty"    // 没有可用的题目
+)
+
+// 项目反应理论双参数模型的题目参数
+type ItemParams struct {
+	A          float64 `json:"a"`          // 区分度
+	B          float64 `json:"b"`          // 难度，与能力值在同一量表上
+	Calibrated bool    `json:"calibrated"` // 由作答记录估计；为false时由标注难度推算
+}
+
+// 开始自适应测试的请求，筛选条件决定题目范围
+type AdaptiveStartRequest struct {
+	Types        []QuestionType      `json:"types"` // 只支持单选和多选，为空时两者都出
+	Language     ProgrammingLanguage `json:"language"`
+	Tag          string              `json:"tag"`
+	MaxQuestions int                 `json:"maxQuestions"` // 为0时使用配置的默认值
+	TargetSE     float64             `json:"targetSE"`     // 为0时使用配置的默认值
+	BankID       int64               `json:"bankId"`       // 只从该题库出题，为0时使用能访问的全部题库
+	BankIDs      []int64             `json:"bankIds"`      // 按权限确定的题库范围，由服务端填写
+}
+
+// 提交一道题的作答
+type AdaptiveAnswerRequest struct {
+	QuestionID int64 `json:"questionId" binding:"required"`
+	Selected   []int `json:"selected"`
+}
+
+// 会话中已作答的一道题
+type AdaptiveItem struct {
+	QuestionID int64      `json:"questionId"`
+	Title      string     `json:"title"`
+	Params     ItemParams `json:"params"` // 出题时使用的参数
+	Correct    bool       `json:"correct"`
+	Theta      float64    `json:"theta"` // 作答后的能力估计
+	SE         float64    `json:"se"`
+}
+
+// 自适应测试会话的当前状态，结束后即为最终报告
+type AdaptiveSession struct {
+	ID           string             `json:"id"`
+	Status       AdaptiveStatus     `json:"status"`
+	StopReason   AdaptiveStopReason `json:"stopReason,omitempty"`
+	MaxQuestions int                `json:"maxQuestions"`
+	TargetSE     float64            `json:"targetSE"`
+	Theta        float64            `json:"theta"` // 能力估计，0为平均水平
+	SE           float64            `json:"se"`
+	Lower        float64            `json:"lower"` // 95%置信区间
+	Upper        float64            `json:"upper"`
+	Answered     int                `json:"answered"`
+	Correct      int                `json:"correct"`
+	Items        []AdaptiveItem     `json:"items"`
+	Next         *StudentQuestion   `json:"next,omitempty"` // 进行中时为下一道题
+	CreatedAt    time.Time          `json:"createdAt"`
+	FinishedAt   *time.Time         `json:"finishedAt,omitempty"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// 题目分析发现的问题
+type ItemFlag string
+
+const (
+	FlagDifficultyMismatch     ItemFlag = "difficulty_mismatch"     // 实测难度与标注难度不符
+	FlagNegativeDiscrimination ItemFlag = "negative_discrimination" // 总分高的考生反而更容易答错
+)
+
+// 单道题的经典测量理论分析结果
+type ItemAnalysis struct {
+	QuestionID      int64              `json:"questionId"`
+	Title           string             `json:"title"`
+	Type            QuestionType       `json:"type"`
+	Difficulty      QuestionDifficulty `json:"difficulty"` // 标注难度
+	Responses       int                `json:"responses"`
+	Reliable        bool               `json:"reliable"`        // 作答次数达到最少次数，分析结果和标记可信
+	DifficultyIndex float64            `json:"difficultyIndex"` // 答对比例
+	// 点二列相关系数，以考生在其余题目上的得分率作为总分；
+	// 全部答对、全部答错或总分没有差异时无法计算，为null
+	Discrimination      *float64           `json:"discrimination"`
+	SuggestedDifficulty QuestionDifficulty `json:"suggestedDifficulty"` // 按答对比例推算的难度
+	Options             []OptionStats      `json:"options"`
+	Flags               []ItemFlag         `json:"flags"`
+}
+
+// 单个选项被选中的情况
+type OptionStats struct {
+	Index int     `json:"index"`
+	Right bool    `json:"right"`
+	Count int     `json:"count"`
+	Rate  float64 `json:"rate"` // 选中该选项的作答占比
+}
+
+// 题目分析报告
+type ItemAnalysisReport struct {
+	GeneratedAt  time.Time      `json:"generatedAt"`
+	MinResponses int            `json:"minResponses"`
+	Items        []ItemAnalysis `json:"items"`
+}
+
+// 题目分析查询参数
+type ItemAnalysisRequest struct {
+	Flagged bool  `form:"flagged"` // 只返回有问题的题目
+	BankID  int64 `form:"bankId"`  // 只分析一个题库，默认是能查看题目的全部题库
+}
+
+// 重新标定难度的请求
+type RecalibrateRequest struct {
+	IDs    []int64 `json:"ids"` // 为空时处理所有题目
+	DryRun bool    `json:"dryRun"`
+}
+
+// 单道题的难度调整
+type DifficultyChange struct {
+	QuestionID      int64              `json:"questionId"`
+	From            QuestionDifficulty `json:"from"`
+	To              QuestionDifficulty `json:"to"`
+	DifficultyIndex float64            `json:"difficultyIndex"`
+	Responses       int                `json:"responses"`
+}
+
+// 重新标定难度的结果
+type RecalibrateResult struct {
+	DryRun  bool               `json:"dryRun"`
+	Changes []DifficultyChange `json:"changes"`
+}
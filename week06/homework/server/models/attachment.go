@@ -0,0 +1,48 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"time"
+)
+
+// 图片地址的前缀，后面是图片内容的SHA-256
+const AttachmentURLPrefix = "/api/attachments/"
+
+// 题干、选项和解析中用图片地址引用图片，例如 ![二叉树](/api/attachments/<sha256>)
+var attachmentRefPattern = regexp.MustCompile(regexp.QuoteMeta(AttachmentURLPrefix) + `([0-9a-f]{64})`)
+
+// 上传的图片，ID是内容的SHA-256，相同的图片只保存一份
+type Attachment struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"` // 第一次上传时的文件名
+	MimeType  string    `json:"mimeType"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+	URL       string    `json:"url"`
+}
+
+// 图片的访问地址
+func AttachmentURL(id string) string {
+	return AttachmentURLPrefix + id
+}
+
+// 题目中引用的图片ID，去重并排序
+func (d *QuestionData) AttachmentRefs() []string {
+	texts := []string{d.AIRes.Title, d.AIRes.Code, d.AIRes.Explanation}
+	texts = append(texts, d.AIRes.Answer...)
+	texts = append(texts, d.AIRes.Rationale...)
+
+	seen := make(map[string]bool)
+	var refs []string
+	for _, text := range texts {
+		for _, match := range attachmentRefPattern.FindAllStringSubmatch(text, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				refs = append(refs, match[1])
+			}
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
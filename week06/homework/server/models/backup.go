@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// 一个数据库快照文件
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 数据库完整性检查结果
+type IntegrityReport struct {
+	OK       bool     `json:"ok"`
+	Messages []string `json:"messages"` // PRAGMA integrity_check的输出，正常时只有"ok"
+}
+
+// 恢复结果
+type RestoreResult struct {
+	Restored   string     `json:"restored"`   // 恢复所用的快照
+	PreRestore BackupInfo `json:"preRestore"` // 恢复前自动备份的当前数据库
+}
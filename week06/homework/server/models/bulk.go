@@ -0,0 +1,51 @@
+package models
+
+// 批量操作类型
+type BulkOperation string
+
+const (
+	BulkSetDifficulty BulkOperation = "set_difficulty"
+	BulkAddTags       BulkOperation = "add_tags"
+	BulkRemoveTags    BulkOperation = "remove_tags"
+	BulkSetStatus     BulkOperation = "set_status"
+	BulkMoveToBank    BulkOperation = "move_to_bank"
+)
+
+// 批量操作请求，IDs和Filter二选一。Filter的写法与题目列表的查询参数相同，
+// 例如"types=1,2&language=go"
+type BulkRequest struct {
+	IDs        []int64            `json:"ids"`
+	Filter     string             `json:"filter"`
+	Operation  BulkOperation      `json:"operation" binding:"required"`
+	Difficulty QuestionDifficulty `json:"difficulty,omitempty"`
+	Tags       []string           `json:"tags,omitempty"`
+	Status     QuestionStatus     `json:"status,omitempty"`
+	BankID     int64              `json:"bankId,omitempty"` // move_to_bank的目标题库
+	DryRun     bool               `json:"dryRun"`           // 只统计会受影响的题目，不修改数据
+}
+
+// 存储层执行的批量修改
+type BulkChange struct {
+	Operation  BulkOperation
+	Difficulty QuestionDifficulty
+	Tags       []string
+	Status     QuestionStatus
+	BankID     int64
+}
+
+// 单个题目的处理结果
+type BulkItemResult struct {
+	ID      int64  `json:"id"`
+	OK      bool   `json:"ok"`
+	Changed bool   `json:"changed"` // 题目原本已满足要求时为false
+	Error   string `json:"error,omitempty"`
+}
+
+// 批量操作结果
+type BulkResult struct {
+	DryRun  bool             `json:"dryRun"`
+	Matched int              `json:"matched"` // 找到的题目数
+	Changed int              `json:"changed"` // 实际（或预演时将会）修改的题目数
+	Failed  int              `json:"failed"`
+	Items   []BulkItemResult `json:"items"`
+}
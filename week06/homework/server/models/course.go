@@ -0,0 +1,116 @@
+package models
+
+import "time"
+
+// 课程成员的角色
+type CourseRole string
+
+const (
+	RoleStudent CourseRole = "student" // 只能考试和练习，看不到题目答案
+	RoleTA      CourseRole = "ta"      // 助教，可以查看和修改课程题库中的题目
+	RoleTeacher CourseRole = "teacher" // 教师，另外可以管理成员、题库和共享
+)
+
+// 角色的权限等级，数值越大权限越多
+var courseRoleRanks = map[CourseRole]int{
+	RoleStudent: 1,
+	RoleTA:      2,
+	RoleTeacher: 3,
+}
+
+// 是否为支持的角色
+func (r CourseRole) Valid() bool {
+	return courseRoleRanks[r] > 0
+}
+
+// 权限是否不低于min
+func (r CourseRole) AtLeast(min CourseRole) bool {
+	return courseRoleRanks[r] >= courseRoleRanks[min]
+}
+
+// 升级前的题目所在的默认课程和默认题库
+const (
+	DefaultCourseID int64 = 1
+	DefaultBankID   int64 = 1
+)
+
+// 发起请求的用户。管理员不受课程成员身份限制
+type Principal struct {
+	UserID string
+	Admin  bool
+}
+
+// 课程，课程成员即为上课的班级
+type Course struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Open      bool       `json:"open"` // 开放课程中所有用户都以助教身份访问，用于兼容升级前的使用方式
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Role      CourseRole `json:"role,omitempty"` // 当前用户在课程中的角色
+}
+
+// 课程成员
+type CourseMember struct {
+	UserID  string     `json:"userId"`
+	Role    CourseRole `json:"role"`
+	AddedAt time.Time  `json:"addedAt"`
+}
+
+// 题库，属于一门课程，可以共享给其他课程只读使用
+type Bank struct {
+	ID            int64      `json:"id"`
+	CourseID      int64      `json:"courseId"`
+	Name          string     `json:"name"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	QuestionCount int        `json:"questionCount"`
+	SharedWith    []int64    `json:"sharedWith"`         // 共享给的课程
+	Role          CourseRole `json:"role,omitempty"`     // 当前用户对题库的角色
+	Writable      bool       `json:"writable,omitempty"` // 当前用户能否修改题库中的题目
+}
+
+// 课程详情，包括成员和课程自己的题库
+type CourseDetail struct {
+	Course
+	Members []CourseMember `json:"members"`
+	Banks   []Bank         `json:"banks"`
+}
+
+// 用户对一个题库的权限
+type BankAccess struct {
+	Role     CourseRole
+	Writable bool // 只有题库所属课程的助教和教师可以修改，共享来的题库只读
+}
+
+// 创建课程请求
+type CourseCreateRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// 修改课程请求
+type CourseUpdateRequest struct {
+	Name string `json:"name"`
+	Open *bool  `json:"open"`
+}
+
+// 添加或修改成员请求
+type CourseMemberRequest struct {
+	UserID string     `json:"userId" binding:"required"`
+	Role   CourseRole `json:"role" binding:"required"`
+}
+
+// 创建题库请求
+type BankCreateRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// 共享题库请求
+type BankShareRequest struct {
+	CourseID int64 `json:"courseId" binding:"required"`
+}
+
+// 把题目复制到另一个题库的请求
+type QuestionCopyRequest struct {
+	IDs    []int64 `json:"ids" binding:"required"`
+	BankID int64   `json:"bankId" binding:"required"`
+}
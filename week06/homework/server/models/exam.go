@@ -0,0 +1,41 @@
+package models
+
+// 考生对单道题的作答
+type ExamAnswer struct {
+	QuestionID int64    `json:"questionId"`
+	Selected   []int    `json:"selected"`
+	Blanks     []string `json:"blanks,omitempty"` // 填空题按顺序填写的答案
+	Text       string   `json:"text,omitempty"`   // 简答题的作答
+}
+
+// 交卷请求
+type ExamSubmitRequest struct {
+	Answers []ExamAnswer `json:"answers" binding:"required"`
+}
+
+// 单道题的批改结果
+type ExamQuestionResult struct {
+	QuestionID   int64          `json:"questionId"`
+	Title        string         `json:"title"`
+	Selected     []int          `json:"selected"`
+	Right        []int          `json:"right"`
+	Correct      bool           `json:"correct"`
+	Explanation  string         `json:"explanation,omitempty"`
+	Rationale    map[int]string `json:"rationale,omitempty"`    // 考生选中的错误选项 -> 错误原因
+	Blanks       []string       `json:"blanks,omitempty"`       // 填空题的作答
+	BlankResults []bool         `json:"blankResults,omitempty"` // 填空题每个空是否正确
+	Accepted     [][]string     `json:"accepted,omitempty"`     // 填空题每个空可以接受的答案
+	Text         string         `json:"text,omitempty"`         // 简答题的作答
+	Reference    string         `json:"reference,omitempty"`    // 简答题的参考答案
+	Pending      bool           `json:"pending,omitempty"`      // 不能自动批改，等待批改
+}
+
+// 整张试卷的批改结果
+type ExamResult struct {
+	AttemptID int64                `json:"attemptId"` // 保存的答卷ID
+	Total     int                  `json:"total"`
+	Correct   int                  `json:"correct"`
+	Pending   int                  `json:"pending,omitempty"` // 等待批改的题数
+	Score     float64              `json:"score"`             // 已批改题目的得分率
+	Results   []ExamQuestionResult `json:"results"`
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 评分标准的限制
+const (
+	MaxRubricCriteria  = 10
+	MaxCriterionPoints = 100
+)
+
+// 开放题得分率达到该比例的作答记为答对，用于题目分析
+const PassRatio = 0.6
+
+// 评分标准中的一项
+type RubricCriterion struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"` // 给批改者的说明，例如得满分需要答出的要点
+	Points      int    `json:"points"`                // 该项满分
+}
+
+// 一道题的评分标准，由教师设置
+type Rubric struct {
+	QuestionID int64             `json:"questionId"`
+	Criteria   []RubricCriterion `json:"criteria"`
+	MaxScore   int               `json:"maxScore"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+}
+
+// 设置评分标准的请求
+type RubricRequest struct {
+	Criteria []RubricCriterion `json:"criteria" binding:"required"`
+}
+
+// 检查评分标准：1到10项，名称不能为空或重复，满分在1到100之间
+func ValidateRubric(criteria []RubricCriterion) error {
+	if len(criteria) == 0 || len(criteria) > MaxRubricCriteria {
+		return fmt.Errorf("评分标准需要1到%d项", MaxRubricCriteria)
+	}
+	seen := make(map[string]bool, len(criteria))
+	for _, c := range criteria {
+		name := strings.TrimSpace(c.Name)
+		if name == "" {
+			return errors.New("评分项名称不能为空")
+		}
+		if seen[name] {
+			return fmt.Errorf("评分项重复: %s", name)
+		}
+		seen[name] = true
+		if c.Points < 1 || c.Points > MaxCriterionPoints {
+			return fmt.Errorf("评分项%s的满分必须在1到%d之间", name, MaxCriterionPoints)
+		}
+	}
+	return nil
+}
+
+// 评分标准的总分
+func RubricMaxScore(criteria []RubricCriterion) int {
+	total := 0
+	for _, c := range criteria {
+		total += c.Points
+	}
+	return total
+}
+
+// 单个评分项的得分
+type CriterionScore struct {
+	Criterion     string `json:"criterion"`
+	Score         int    `json:"score"`
+	Points        int    `json:"points,omitempty"` // 该项满分
+	Justification string `json:"justification,omitempty"`
+}
+
+// 批改模型返回的结果
+type AIGradeResponse struct {
+	Scores []AIGradeScore `json:"scores"`
+}
+
+// 批改模型对一个评分项的打分
+type AIGradeScore struct {
+	Criterion     string `json:"criterion"`
+	Score         int    `json:"score"`
+	Justification string `json:"justification"`
+}
+
+// 作答的批改状态
+type GradeStatus string
+
+const (
+	GradePending    GradeStatus = "pending"    // 等待批改
+	GradeAI         GradeStatus = "graded"     // 已由模型批改
+	GradeOverridden GradeStatus = "overridden" // 教师修改过分数
+)
+
+// 模型的批改结果
+type AIGrade struct {
+	Grader   ModelProvider    `json:"grader"`
+	Scores   []CriterionScore `json:"scores"`
+	Score    int              `json:"score"`
+	GradedAt time.Time        `json:"gradedAt"`
+}
+
+// 教师修改后的分数，与模型的结果分开保存，用于校准模型
+type GradeOverride struct {
+	Scores    []CriterionScore `json:"scores"`
+	Score     int              `json:"score"`
+	Comment   string           `json:"comment,omitempty"`
+	TeacherID string           `json:"teacherId"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+// 一次开放题作答及其批改结果
+type ResponseGrade struct {
+	AttemptID  int64          `json:"attemptId"`
+	QuestionID int64          `json:"questionId"`
+	UserID     string         `json:"userId"` // 作答的考生
+	Answer     string         `json:"answer"`
+	Status     GradeStatus    `json:"status"`
+	MaxScore   int            `json:"maxScore,omitempty"`
+	Score      *int           `json:"score"` // 最终得分，教师修改过时以教师为准，未批改时为null
+	AI         *AIGrade       `json:"ai,omitempty"`
+	Override   *GradeOverride `json:"override,omitempty"`
+}
+
+// 作答列表的查询参数
+type ResponseGradeQuery struct {
+	QuestionID int64       `form:"questionId" binding:"required"`
+	AttemptID  int64       `form:"attemptId"`
+	Status     GradeStatus `form:"status"`
+}
+
+// 调用模型批改单个作答的请求
+type GradeRequest struct {
+	Provider ModelProvider `json:"provider"` // 为空时使用默认审核模型
+}
+
+// 教师修改分数的请求，需要给出每个评分项的得分
+type GradeOverrideRequest struct {
+	Scores  []CriterionScore `json:"scores" binding:"required"`
+	Comment string           `json:"comment"`
+}
+
+// 批量批改任务的状态
+type GradingJobStatus string
+
+const (
+	JobRunning  GradingJobStatus = "running"
+	JobDone     GradingJobStatus = "done"
+	JobCanceled GradingJobStatus = "canceled" // 服务关闭时中止，重新提交会继续批改剩下的作答
+)
+
+// 批量批改一场考试的请求：指定考试中的开放题，attemptIds不为空时只批改这些答卷
+type GradingJobRequest struct {
+	QuestionIDs []int64       `json:"questionIds" binding:"required"`
+	AttemptIDs  []int64       `json:"attemptIds"`
+	Provider    ModelProvider `json:"provider"`
+}
+
+// 批量批改任务
+type GradingJob struct {
+	ID          int64            `json:"id"`
+	UserID      string           `json:"userId"`
+	QuestionIDs []int64          `json:"questionIds"`
+	AttemptIDs  []int64          `json:"attemptIds,omitempty"`
+	Provider    ModelProvider    `json:"provider"`
+	Status      GradingJobStatus `json:"status"`
+	Total       int              `json:"total"`  // 开始时等待批改的作答数
+	Graded      int              `json:"graded"` // 已批改
+	Failed      int              `json:"failed"` // 批改失败，仍然等待批改
+	LastError   string           `json:"lastError,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	FinishedAt  *time.Time       `json:"finishedAt,omitempty"`
+}
+
+// 模型与教师评分的一致程度，只统计教师修改过的作答
+type GradingCalibration struct {
+	QuestionID int64 `json:"questionId,omitempty"`
+	Samples    int   `json:"samples"`
+	// 模型总分与教师总分之差的平均值，为正表示模型给分偏高
+	MeanDiff float64 `json:"meanDiff"`
+	// 模型总分与教师总分之差的绝对值的平均值
+	MeanAbsDiff float64 `json:"meanAbsDiff"`
+	// 模型和教师对是否答对的判断一致的比例
+	Agreement float64                `json:"agreement"`
+	Criteria  []CriterionCalibration `json:"criteria"`
+}
+
+// 单个评分项上的一致程度
+type CriterionCalibration struct {
+	Criterion   string  `json:"criterion"`
+	Samples     int     `json:"samples"`
+	MeanDiff    float64 `json:"meanDiff"`
+	MeanAbsDiff float64 `json:"meanAbsDiff"`
+}
+
+// 查询校准情况的参数
+type CalibrationQuery struct {
+	QuestionID int64 `form:"questionId" binding:"required"`
+}
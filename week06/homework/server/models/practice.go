@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// 学生对单道题的复习安排，按SM-2算法计算
+type ReviewCard struct {
+	QuestionID     int64      `json:"questionId"`
+	Repetitions    int        `json:"repetitions"` // 连续答对的次数，答错时清零
+	Interval       int        `json:"interval"`    // 复习间隔（天）
+	Ease           float64    `json:"ease"`        // 难易系数，不低于1.3
+	DueAt          time.Time  `json:"dueAt"`
+	Reviews        int        `json:"reviews"`
+	CorrectReviews int        `json:"correctReviews"`
+	Lapses         int        `json:"lapses"` // 答错的次数
+	LastReviewedAt time.Time  `json:"lastReviewedAt"`
+	LastWrongAt    *time.Time `json:"lastWrongAt,omitempty"`
+	InNotebook     bool       `json:"inNotebook"` // 是否在错题本中
+}
+
+// 提交一次练习作答
+type PracticeAnswerRequest struct {
+	QuestionID int64    `json:"questionId" binding:"required"`
+	Selected   []int    `json:"selected"`
+	Blanks     []string `json:"blanks,omitempty"` // 填空题按顺序填写的答案
+	// 答对时的自评：3=勉强想起, 4=想起, 5=轻松想起，为0时按4处理；答错时忽略
+	Grade int `json:"grade"`
+}
+
+// 练习作答的批改结果和新的复习安排
+type PracticeAnswerResult struct {
+	Result ExamQuestionResult `json:"result"`
+	Card   ReviewCard         `json:"card"`
+}
+
+// 今日复习的查询参数
+type PracticeTodayRequest struct {
+	Limit  int    `form:"limit"`  // 最多返回的到期题目数，默认20
+	New    *int   `form:"new"`    // 附带的新题数量，默认5
+	Tag    string `form:"tag"`    // 只复习带该标签的题目
+	BankID int64  `form:"bankId"` // 只练习该题库的题目，为0时使用能访问的全部题库
+
+	BankIDs []int64 `form:"-"` // 按权限确定的题库范围
+}
+
+// 一道待练习的题目，新题没有复习安排
+type PracticeItem struct {
+	Question StudentQuestion `json:"question"`
+	Card     *ReviewCard     `json:"card"`
+}
+
+// 今日复习
+type PracticeToday struct {
+	Due   int            `json:"due"` // 今天到期的题目总数
+	Items []PracticeItem `json:"items"`
+}
+
+// 错题本中的一道题
+type NotebookEntry struct {
+	Question StudentQuestion `json:"question"`
+	Card     ReviewCard      `json:"card"`
+}
+
+// 某个标签下的掌握情况，Tag为空表示全部题目
+type TagMastery struct {
+	Tag       string  `json:"tag"`
+	Questions int     `json:"questions"` // 可练习的题目数
+	Practiced int     `json:"practiced"` // 练习过的题目数
+	Mastered  int     `json:"mastered"`  // 复习间隔达到掌握标准的题目数
+	Due       int     `json:"due"`       // 今天到期的题目数
+	Accuracy  float64 `json:"accuracy"`  // 练习的答对率
+	Mastery   float64 `json:"mastery"`   // 已掌握题目占可练习题目的比例
+}
+
+// 掌握情况统计
+type MasteryReport struct {
+	Overall TagMastery   `json:"overall"`
+	ByTag   []TagMastery `json:"byTag"`
+}
@@ -1,16 +1,22 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"question-generator/markdown"
 	"time"
 )
 
-// 题目类型
+// 题目类型，各题型的定义见question_type.go
 type QuestionType int
 
 const (
 	SingleChoice QuestionType = 1
 	MultiChoice  QuestionType = 2
 	Programming  QuestionType = 3
+	TrueFalse    QuestionType = 4
+	FillBlank    QuestionType = 5
+	ShortAnswer  QuestionType = 6
 )
 
 // 题目难度
@@ -26,9 +32,37 @@ const (
 type ModelProvider string
 
 const (
-	Tongyi ModelProvider = "tongyi"
+	Tongyi   ModelProvider = "tongyi"
+	DeepSeek ModelProvider = "deepseek"
 )
 
+// 结构化输出模式，决定调用模型时如何约束返回格式
+type OutputMode string
+
+const (
+	OutputText       OutputMode = "text"        // 只通过提示词约束，按文本解析
+	OutputJSONObject OutputMode = "json_object" // 保证返回合法JSON，但不约束结构
+	OutputJSONSchema OutputMode = "json_schema" // 按AIBatchResponse生成的JSON Schema约束结构
+)
+
+// 题目状态
+type QuestionStatus string
+
+const (
+	StatusActive   QuestionStatus = "active"   // 正常使用
+	StatusDraft    QuestionStatus = "draft"    // 草稿，尚未审核
+	StatusArchived QuestionStatus = "archived" // 已归档，不再出现在考试中
+)
+
+// 是否为支持的题目状态
+func (s QuestionStatus) Valid() bool {
+	switch s {
+	case StatusActive, StatusDraft, StatusArchived:
+		return true
+	}
+	return false
+}
+
 // 编程语言参数
 type ProgrammingLanguage string
 
@@ -42,19 +76,25 @@ const (
 
 // 题目生成请求
 type QuestionRequest struct {
-	Model      ModelProvider       `json:"model,omitempty"`
-	Language   ProgrammingLanguage `json:"language,omitempty"`
-	Type       QuestionType        `json:"type,omitempty"`
-	Difficulty QuestionDifficulty  `json:"difficulty,omitempty"`
-	Count      int                 `json:"count,omitempty"`
+	Model       ModelProvider       `json:"model,omitempty"`
+	Language    ProgrammingLanguage `json:"language,omitempty"`
+	Type        QuestionType        `json:"type,omitempty"`
+	Difficulty  QuestionDifficulty  `json:"difficulty,omitempty"`
+	Count       int                 `json:"count,omitempty"`
+	Review      bool                `json:"review,omitempty"`      // 生成后是否进行二次审核
+	ReviewModel ModelProvider       `json:"reviewModel,omitempty"` // 审核使用的模型，为空时使用配置的默认审核模型
 }
 
 // 生成的题目
 type AIQuestion struct {
-	Title   string   `json:"title"`
-	Options []string `json:"options"`
-	Right   []int    `json:"right"`
-	Code    string   `json:"code,omitempty"`
+	Title       string     `json:"title"`
+	Options     []string   `json:"options"`
+	Right       []int      `json:"right"`
+	Code        string     `json:"code,omitempty"`
+	Explanation string     `json:"explanation,omitempty"`
+	Rationale   []string   `json:"rationale,omitempty"`
+	Blanks      [][]string `json:"blanks,omitempty"`
+	Reference   string     `json:"reference,omitempty"`
 }
 
 // 批量生成题目响应
@@ -63,16 +103,32 @@ type AIBatchResponse struct {
 }
 
 // 请求的整体响应
+// Explanation为整体解析，Rationale与Answer按下标一一对应，说明每个错误选项错在哪里，正确选项对应位置留空
 type AIResponse struct {
-	Title  string   `json:"title"`
-	Answer []string `json:"answer"`
-	Right  []int    `json:"right"`
-	Code   string   `json:"code,omitempty"`
+	Title       string          `json:"title"`
+	Answer      []string        `json:"answer"`
+	Right       []int           `json:"right"`
+	Code        string          `json:"code,omitempty"`
+	Explanation string          `json:"explanation,omitempty"`
+	Rationale   []string        `json:"rationale,omitempty"`
+	Blanks      [][]string      `json:"blanks,omitempty"`    // 填空题每个空可以接受的答案
+	Reference   string          `json:"reference,omitempty"` // 简答题的参考答案
+	Review      *QuestionReview `json:"review,omitempty"`
+}
+
+// 二次审核结果，只随AI出题结果返回给预览页，不入库
+type QuestionReview struct {
+	Reviewer       ModelProvider `json:"reviewer"`
+	Score          int           `json:"score"`          // 质量评分，1-10
+	Comments       string        `json:"comments"`       // 对题干歧义、选项设置等问题的点评
+	ReviewerAnswer []int         `json:"reviewerAnswer"` // 审核模型独立作答的答案，编程题为空
+	Disagree       bool          `json:"disagree"`       // 审核答案与题目标注的正确答案不一致
 }
 
 // 存储在数据库中的完整问题数据
 type QuestionData struct {
 	ID          int64              `json:"id,omitempty"`
+	BankID      int64              `json:"bankId,omitempty"` // 所在题库，添加时为0表示默认题库，编辑时忽略
 	AIStartTime time.Time          `json:"aiStartTime"`
 	AIEndTime   time.Time          `json:"aiEndTime"`
 	AICostTime  int                `json:"aiCostTime"`
@@ -81,6 +137,66 @@ type QuestionData struct {
 	AIRes       AIResponse         `json:"aiRes"`
 	Difficulty  QuestionDifficulty `json:"difficulty"`
 	CreatedAt   time.Time          `json:"createdAt"`
+	UsageCount  int                `json:"usageCount"`     // 在考试中被作答的次数
+	Status      QuestionStatus     `json:"status"`         // 为空时入库为active
+	Tags        []string           `json:"tags"`           // 编辑时为nil表示不修改标签
+	HTML        *QuestionHTML      `json:"html,omitempty"` // 只读，查询时由服务端渲染，保存时忽略
+}
+
+// 题目文本按Markdown渲染后的HTML，原始HTML已被去掉，可以直接插入页面
+type QuestionHTML struct {
+	Title       string   `json:"title"`
+	Options     []string `json:"options,omitempty"`
+	Code        string   `json:"code,omitempty"`
+	Explanation string   `json:"explanation,omitempty"`
+	Rationale   []string `json:"rationale,omitempty"`
+	Reference   string   `json:"reference,omitempty"`
+}
+
+// 渲染题干、选项、代码、解析、错误原因和参考答案
+func (d *QuestionData) RenderHTML() *QuestionHTML {
+	h := &QuestionHTML{
+		Title:       markdown.Render(d.AIRes.Title),
+		Options:     renderAll(d.AIRes.Answer),
+		Explanation: markdown.Render(d.AIRes.Explanation),
+		Rationale:   renderAll(d.AIRes.Rationale),
+		Reference:   markdown.Render(d.AIRes.Reference),
+	}
+	if d.AIRes.Code != "" {
+		h.Code = markdown.Code(d.AIRes.Code, string(d.AIReq.GetLanguage()))
+	}
+	return h
+}
+
+func renderAll(texts []string) []string {
+	if len(texts) == 0 {
+		return nil
+	}
+	out := make([]string, len(texts))
+	for i, text := range texts {
+		out[i] = markdown.Render(text)
+	}
+	return out
+}
+
+// 检查手动添加或编辑的题目，错误信息可以直接展示给用户
+func (d *QuestionData) Validate() error {
+	if d.AIReq.Type <= 0 {
+		return errors.New("题目类型不能为空")
+	}
+	spec, ok := LookupQuestionType(d.AIReq.Type)
+	if !ok {
+		return fmt.Errorf("不支持的题目类型: %d", d.AIReq.Type)
+	}
+	if d.AIRes.Title == "" {
+		return errors.New("题目标题不能为空")
+	}
+	if d.Status != "" && !d.Status.Valid() {
+		return fmt.Errorf("无效的题目状态: %s", d.Status)
+	}
+
+	// 各题型的选项和答案
+	return spec.Validate(&d.AIRes)
 }
 
 // 接口返回的响应结构
@@ -90,19 +206,59 @@ type HTTPResponse struct {
 	AIRes interface{} `json:"aiRes,omitempty"`
 }
 
-// 题目查询请求
+// 题目列表的排序字段
+type QuestionSort string
+
+const (
+	SortByID         QuestionSort = "id"
+	SortByCreated    QuestionSort = "created"
+	SortByDifficulty QuestionSort = "difficulty"
+	SortByUsage      QuestionSort = "usage"
+)
+
+// 题目查询请求。types和difficulties可以重复传入或用逗号分隔，例如types=1,2
 type QuestionQueryRequest struct {
-	Page       int                `json:"page" form:"page"`
-	PageSize   int                `json:"pageSize" form:"pageSize"`
-	Type       QuestionType       `json:"type" form:"type"`
-	Difficulty QuestionDifficulty `json:"difficulty" form:"difficulty"`
-	Title      string             `json:"title" form:"title"`
+	Page         int                 `json:"page" form:"page"`
+	PageSize     int                 `json:"pageSize" form:"pageSize"`
+	Type         QuestionType        `json:"type" form:"type"`
+	Difficulty   QuestionDifficulty  `json:"difficulty" form:"difficulty"`
+	Title        string              `json:"title" form:"title"`
+	Types        []string            `json:"types" form:"types"`
+	Difficulties []string            `json:"difficulties" form:"difficulties"`
+	Language     ProgrammingLanguage `json:"language" form:"language"`
+	Tag          string              `json:"tag" form:"tag"`
+	Status       QuestionStatus      `json:"status" form:"status"`
+	CreatedFrom  string              `json:"createdFrom" form:"createdFrom"` // 2006-01-02
+	CreatedTo    string              `json:"createdTo" form:"createdTo"`     // 2006-01-02，包含当天
+	Sort         QuestionSort        `json:"sort" form:"sort"`               // 默认按id
+	Order        string              `json:"order" form:"order"`             // asc / desc，默认desc
+	Cursor       string              `json:"cursor" form:"cursor"`           // 上一页返回的nextCursor，传入时忽略page
+	BankID       int64               `json:"bankId" form:"bankId"`           // 为0时查询当前用户能查看的全部题库
 }
 
-// 题目查询响应
+// 存储层使用的题目筛选条件
+type QuestionFilter struct {
+	Types        []QuestionType
+	Difficulties []QuestionDifficulty
+	Language     ProgrammingLanguage
+	Tag          string
+	Status       QuestionStatus
+	Title        string
+	CreatedFrom  time.Time // 为零值时不限制
+	CreatedTo    time.Time // 不包含，为零值时不限制
+	Sort         QuestionSort
+	Asc          bool
+	Page         int
+	PageSize     int
+	Cursor       string  // 不为空时使用游标分页，不统计总数
+	BankIDs      []int64 // 为nil时不限制题库，为空时不匹配任何题目
+}
+
+// 题目查询响应，游标分页时不返回总数
 type QuestionListResponse struct {
-	Total int            `json:"total"`
-	List  []QuestionData `json:"list"`
+	Total      int            `json:"total"`
+	List       []QuestionData `json:"list"`
+	NextCursor string         `json:"nextCursor,omitempty"` // 还有下一页时返回
 }
 
 // 题目删除请求
@@ -149,3 +305,16 @@ func (r *QuestionRequest) GetCount() int {
 	}
 	return r.Count
 }
+
+// 发给考生的题目，不包含答案和解析
+type StudentQuestion struct {
+	ID         int64               `json:"id"`
+	Type       QuestionType        `json:"type"`
+	Input      AnswerInput         `json:"input"` // 作答方式
+	Language   ProgrammingLanguage `json:"language"`
+	Title      string              `json:"title"`
+	Options    []string            `json:"options"`
+	BlankCount int                 `json:"blankCount,omitempty"` // 填空题需要填写的空数
+	Tags       []string            `json:"tags"`
+	HTML       *QuestionHTML       `json:"html,omitempty"` // 只包含题干和选项
+}
@@ -0,0 +1,476 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 考生的作答方式
+type AnswerInput string
+
+const (
+	InputChoice AnswerInput = "choice" // 选择选项，作答放在selected
+	InputBlanks AnswerInput = "blanks" // 按顺序填写每个空，作答放在blanks
+	InputText   AnswerInput = "text"   // 文字作答，作答放在text
+	InputNone   AnswerInput = "none"   // 不在线作答
+)
+
+// 判断题的固定选项，正确答案为[0]表示题目说法正确
+var TrueFalseOptions = []string{"正确", "错误"}
+
+// 题目答案部分在questions表中的存储形式，依次对应answer、right_answer、rationale和payload列，
+// 空字符串存为NULL
+type StoredAnswer struct {
+	Options   string
+	Right     string
+	Rationale string
+	Payload   string
+}
+
+// 题型定义。每种题型自己决定答案的校验、出题提示、存储方式和批改方法，
+// 存储和批改代码只通过这里区分题型
+type QuestionTypeSpec struct {
+	Type  QuestionType
+	Name  string
+	Input AnswerInput
+	// 出题提示词中的格式要求和输出示例
+	Prompt string
+	// 检查题目的答案部分，题干和状态等公共字段由QuestionData.Validate检查
+	Validate func(res *AIResponse) error
+	Encode   func(res *AIResponse) (StoredAnswer, error)
+	Decode   func(stored StoredAnswer, res *AIResponse)
+	// 批改一道题，结果写入item；为nil时不能在线作答
+	Grade func(res *AIResponse, answer ExamAnswer, item *ExamQuestionResult)
+}
+
+var questionTypes = map[QuestionType]*QuestionTypeSpec{
+	SingleChoice: {
+		Type:     SingleChoice,
+		Name:     "单选题",
+		Input:    InputChoice,
+		Prompt:   choicePrompt + "这是单选题！每个题目只能输出一个答案索引。\n",
+		Validate: validateSingleChoice,
+		Encode:   encodeChoice,
+		Decode:   decodeChoice,
+		Grade:    gradeChoice,
+	},
+	MultiChoice: {
+		Type:     MultiChoice,
+		Name:     "多选题",
+		Input:    InputChoice,
+		Prompt:   choicePrompt + "这是多选题！每个题目必须输出多个答案索引。\n",
+		Validate: validateMultiChoice,
+		Encode:   encodeChoice,
+		Decode:   decodeChoice,
+		Grade:    gradeChoice,
+	},
+	Programming: {
+		Type:     Programming,
+		Name:     "编程题",
+		Input:    InputText,
+		Prompt:   programmingPrompt,
+		Validate: func(res *AIResponse) error { return nil },
+		Encode:   func(res *AIResponse) (StoredAnswer, error) { return StoredAnswer{}, nil },
+		Decode:   func(stored StoredAnswer, res *AIResponse) {},
+		Grade:    gradeText,
+	},
+	TrueFalse: {
+		Type:     TrueFalse,
+		Name:     "判断题",
+		Input:    InputChoice,
+		Prompt:   trueFalsePrompt,
+		Validate: validateTrueFalse,
+		Encode: func(res *AIResponse) (StoredAnswer, error) {
+			fixed := *res
+			fixed.Answer = TrueFalseOptions
+			return encodeChoice(&fixed)
+		},
+		Decode: decodeChoice,
+		Grade:  gradeChoice,
+	},
+	FillBlank: {
+		Type:     FillBlank,
+		Name:     "填空题",
+		Input:    InputBlanks,
+		Prompt:   fillBlankPrompt,
+		Validate: validateFillBlank,
+		Encode:   func(res *AIResponse) (StoredAnswer, error) { return encodePayload(questionPayload{Blanks: res.Blanks}) },
+		Decode: func(stored StoredAnswer, res *AIResponse) {
+			res.Blanks = decodePayload(stored).Blanks
+		},
+		Grade: gradeFillBlank,
+	},
+	ShortAnswer: {
+		Type:     ShortAnswer,
+		Name:     "简答题",
+		Input:    InputText,
+		Prompt:   shortAnswerPrompt,
+		Validate: validateShortAnswer,
+		Encode: func(res *AIResponse) (StoredAnswer, error) {
+			return encodePayload(questionPayload{Reference: res.Reference})
+		},
+		Decode: func(stored StoredAnswer, res *AIResponse) {
+			res.Reference = decodePayload(stored).Reference
+		},
+		Grade: gradeText,
+	},
+}
+
+// 查找题型定义
+func LookupQuestionType(t QuestionType) (*QuestionTypeSpec, bool) {
+	spec, ok := questionTypes[t]
+	return spec, ok
+}
+
+// 全部题型，按类型值排序
+func QuestionTypes() []*QuestionTypeSpec {
+	specs := make([]*QuestionTypeSpec, 0, len(questionTypes))
+	for _, spec := range questionTypes {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Type < specs[j].Type })
+	return specs
+}
+
+// 是否为支持的题型
+func (t QuestionType) Valid() bool {
+	_, ok := questionTypes[t]
+	return ok
+}
+
+// 作答方式为inputs之一的题型
+func QuestionTypesWithInput(inputs ...AnswerInput) []QuestionType {
+	var types []QuestionType
+	for _, spec := range QuestionTypes() {
+		for _, in := range inputs {
+			if spec.Input == in {
+				types = append(types, spec.Type)
+			}
+		}
+	}
+	return types
+}
+
+const choicePrompt = `1. 每个题目必须包含一个题干和四个选项(A, B, C, D)
+2. 题目要符合编程语言特性和实际应用场景
+3. 必须明确标明正确答案
+4. 你的回答必须是一个有效的JSON对象，不包含任何额外文字
+5. 输出格式必须严格遵循：
+{
+  "questions": [
+    {
+      "title": "题目内容",
+      "options": ["选项A内容", "选项B内容", "选项C内容", "选项D内容"],
+      "right": [答案索引],
+      "explanation": "整体解析，说明正确答案为什么正确",
+      "rationale": ["选项A错误的原因", "", "选项C错误的原因", "选项D错误的原因"]
+    },
+    // 更多题目...
+  ]
+}
+
+说明：right数组中的数字是正确答案的索引，0代表A，1代表B，2代表C，3代表D。单选题只有一个答案，如[1]表示B是正确答案；多选题要求必须有多个答案，如[0,2]表示A和C是正确答案。
+explanation是对本题的整体解析。rationale数组与options一一对应，长度必须相同，每个错误选项写一句简短的错误原因，正确选项对应位置填空字符串。
+
+`
+
+const programmingPrompt = `1. 只需提供题目要求描述，不要提供任何代码或解答
+2. 题目要符合编程语言特性和实际应用场景
+3. 题目要求要清晰、明确且具体
+4. 你的回答必须是一个有效的JSON对象，不包含任何额外文字
+5. 输出格式必须严格遵循：
+{
+  "questions": [
+    {
+      "title": "详细描述编程题目要求，包括输入、输出要求和约束条件",
+      "options": [],
+      "right": [],
+      "code": "",
+      "explanation": "解题思路和需要注意的要点"
+    },
+    // 更多题目...
+  ]
+}
+
+注意：编程题不需要提供代码，code字段留空。explanation字段给出解题思路，但不要给出完整代码。
+`
+
+const trueFalsePrompt = `1. 每个题目是一句可以判断对错的陈述，不要出现"以下哪个"之类的问法
+2. 题目要符合编程语言特性和实际应用场景，正确和错误的陈述数量大致相当
+3. 你的回答必须是一个有效的JSON对象，不包含任何额外文字
+4. 输出格式必须严格遵循：
+{
+  "questions": [
+    {
+      "title": "需要判断对错的陈述",
+      "options": ["正确", "错误"],
+      "right": [0],
+      "explanation": "说明这句陈述为什么正确或错误"
+    },
+    // 更多题目...
+  ]
+}
+
+说明：options固定为["正确", "错误"]，陈述正确时right为[0]，错误时为[1]。
+`
+
+const fillBlankPrompt = `1. 题干中每个需要填写的位置用____（四个下划线）表示，每题1到3个空
+2. 每个空的答案应当是一个确定的关键字、函数名、数值或简短的词语
+3. 你的回答必须是一个有效的JSON对象，不包含任何额外文字
+4. 输出格式必须严格遵循：
+{
+  "questions": [
+    {
+      "title": "Go语言中用____关键字启动一个协程，用____关键字声明通道",
+      "options": [],
+      "right": [],
+      "blanks": [["go"], ["chan"]],
+      "explanation": "整体解析"
+    },
+    // 更多题目...
+  ]
+}
+
+说明：blanks数组按顺序与题干中的空一一对应，每个空给出所有可以接受的答案，例如[["nil", "null"]]。判分时忽略大小写和多余的空白。
+`
+
+const shortAnswerPrompt = `1. 题目要求考生用几句话解释概念、比较差异或分析原因，不要求编写完整代码
+2. 题目要符合编程语言特性和实际应用场景
+3. 你的回答必须是一个有效的JSON对象，不包含任何额外文字
+4. 输出格式必须严格遵循：
+{
+  "questions": [
+    {
+      "title": "简答题题干",
+      "options": [],
+      "right": [],
+      "reference": "参考答案，列出得分要点",
+      "explanation": "补充说明和常见错误"
+    },
+    // 更多题目...
+  ]
+}
+`
+
+func validateChoice(res *AIResponse) error {
+	if len(res.Answer) < 2 {
+		return errors.New("选择题至少需要2个选项")
+	}
+	if len(res.Right) == 0 {
+		return errors.New("选择题必须指定正确答案")
+	}
+	seen := make(map[int]bool, len(res.Right))
+	for _, idx := range res.Right {
+		if idx < 0 || idx >= len(res.Answer) {
+			return fmt.Errorf("无效的答案索引: %d", idx)
+		}
+		if seen[idx] {
+			return fmt.Errorf("重复的答案索引: %d", idx)
+		}
+		seen[idx] = true
+	}
+	// 错误原因与选项按下标对应，数量不能超过选项数
+	if len(res.Rationale) > len(res.Answer) {
+		return errors.New("错误原因数量不能超过选项数量")
+	}
+	return nil
+}
+
+func validateSingleChoice(res *AIResponse) error {
+	if err := validateChoice(res); err != nil {
+		return err
+	}
+	if len(res.Right) != 1 {
+		return errors.New("单选题必须有且只有一个正确答案")
+	}
+	return nil
+}
+
+func validateMultiChoice(res *AIResponse) error {
+	if err := validateChoice(res); err != nil {
+		return err
+	}
+	if len(res.Right) < 2 {
+		return errors.New("多选题至少需要2个正确答案")
+	}
+	return nil
+}
+
+// 判断题的选项固定，可以不传
+func validateTrueFalse(res *AIResponse) error {
+	if len(res.Right) != 1 || (res.Right[0] != 0 && res.Right[0] != 1) {
+		return errors.New("判断题的答案必须是[0]（正确）或[1]（错误）")
+	}
+	if len(res.Rationale) > len(TrueFalseOptions) {
+		return errors.New("错误原因数量不能超过选项数量")
+	}
+	return nil
+}
+
+func validateFillBlank(res *AIResponse) error {
+	if len(res.Blanks) == 0 {
+		return errors.New("填空题至少需要1个空")
+	}
+	for i, accepted := range res.Blanks {
+		ok := false
+		for _, answer := range accepted {
+			if NormalizeBlank(answer) != "" {
+				ok = true
+			}
+		}
+		if !ok {
+			return fmt.Errorf("第%d个空没有指定答案", i+1)
+		}
+	}
+	return nil
+}
+
+func validateShortAnswer(res *AIResponse) error {
+	if strings.TrimSpace(res.Reference) == "" {
+		return errors.New("简答题必须提供参考答案")
+	}
+	return nil
+}
+
+// 选择题的选项、排好序的正确答案和错误原因分别存为JSON
+func encodeChoice(res *AIResponse) (StoredAnswer, error) {
+	answerJSON, err := json.Marshal(res.Answer)
+	if err != nil {
+		return StoredAnswer{}, fmt.Errorf("序列化选项失败: %w", err)
+	}
+
+	sortedRight := make([]int, len(res.Right))
+	copy(sortedRight, res.Right)
+	sort.Ints(sortedRight)
+
+	rightJSON, err := json.Marshal(sortedRight)
+	if err != nil {
+		return StoredAnswer{}, fmt.Errorf("序列化正确答案失败: %w", err)
+	}
+
+	rationaleJSON, err := json.Marshal(res.Rationale)
+	if err != nil {
+		return StoredAnswer{}, fmt.Errorf("序列化错误原因失败: %w", err)
+	}
+
+	return StoredAnswer{Options: string(answerJSON), Right: string(rightJSON), Rationale: string(rationaleJSON)}, nil
+}
+
+// 解析选项、正确答案和错误原因，格式错误的列保持为空
+func decodeChoice(stored StoredAnswer, res *AIResponse) {
+	if stored.Options != "" {
+		json.Unmarshal([]byte(stored.Options), &res.Answer)
+	}
+	if stored.Right != "" {
+		json.Unmarshal([]byte(stored.Right), &res.Right)
+	}
+	if stored.Rationale != "" {
+		json.Unmarshal([]byte(stored.Rationale), &res.Rationale)
+	}
+}
+
+// 选择题以外的题型的答案，存在payload列中
+type questionPayload struct {
+	Blanks    [][]string `json:"blanks,omitempty"`
+	Reference string     `json:"reference,omitempty"`
+}
+
+func encodePayload(p questionPayload) (StoredAnswer, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return StoredAnswer{}, fmt.Errorf("序列化答案失败: %w", err)
+	}
+	return StoredAnswer{Payload: string(data)}, nil
+}
+
+func decodePayload(stored StoredAnswer) questionPayload {
+	var p questionPayload
+	if stored.Payload != "" {
+		json.Unmarshal([]byte(stored.Payload), &p)
+	}
+	return p
+}
+
+// 批改选择题和判断题，只返回考生选中的错误选项的错误原因
+func gradeChoice(res *AIResponse, answer ExamAnswer, item *ExamQuestionResult) {
+	item.Selected = answer.Selected
+	item.Right = res.Right
+	item.Correct = SameChoices(answer.Selected, res.Right)
+	if item.Correct {
+		return
+	}
+	for _, idx := range answer.Selected {
+		if idx < 0 || idx >= len(res.Rationale) || containsInt(res.Right, idx) {
+			continue
+		}
+		if reason := res.Rationale[idx]; reason != "" {
+			if item.Rationale == nil {
+				item.Rationale = make(map[int]string)
+			}
+			item.Rationale[idx] = reason
+		}
+	}
+}
+
+// 批改填空题，每个空与任意一个可接受的答案相同即为正确，全部正确才算答对
+func gradeFillBlank(res *AIResponse, answer ExamAnswer, item *ExamQuestionResult) {
+	item.Blanks = answer.Blanks
+	item.Accepted = res.Blanks
+	item.BlankResults = make([]bool, len(res.Blanks))
+	item.Correct = true
+	for i, accepted := range res.Blanks {
+		if i < len(answer.Blanks) {
+			given := NormalizeBlank(answer.Blanks[i])
+			for _, a := range accepted {
+				if given != "" && given == NormalizeBlank(a) {
+					item.BlankResults[i] = true
+					break
+				}
+			}
+		}
+		item.Correct = item.Correct && item.BlankResults[i]
+	}
+}
+
+// 简答题和编程题不能自动判断对错，记录作答等待按评分标准批改
+func gradeText(res *AIResponse, answer ExamAnswer, item *ExamQuestionResult) {
+	item.Text = answer.Text
+	item.Reference = res.Reference
+	item.Pending = true
+}
+
+// 填空题答案的规范形式：去掉首尾空白，连续空白合并为一个空格，忽略大小写
+func NormalizeBlank(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// 判断两个答案索引集合是否相同，与顺序无关
+func SameChoices(selected, right []int) bool {
+	if len(selected) != len(right) {
+		return false
+	}
+
+	a := append([]int(nil), selected...)
+	b := append([]int(nil), right...)
+	sort.Ints(a)
+	sort.Ints(b)
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// 题库统计
+type QuestionStats struct {
+	GeneratedAt time.Time       `json:"generatedAt"` // 统计时间，结果会被短暂缓存
+	Days        int             `json:"days"`        // 时间序列和出题统计覆盖的天数
+	Total       int             `json:"total"`
+	Breakdown   []QuestionCount `json:"breakdown"`
+	AddedPerDay []DailyCount    `json:"addedPerDay"`
+	Generation  GenerationStats `json:"generation"`
+}
+
+// 按题型、难度、编程语言和标签分组的题目数量，没有记录语言的旧题目Language为空，没有标签的题目Tag为空。
+// 有多个标签的题目在每个标签下各计一次，各组数量之和可能大于题目总数
+type QuestionCount struct {
+	Type       QuestionType        `json:"type"`
+	Difficulty QuestionDifficulty  `json:"difficulty"`
+	Language   ProgrammingLanguage `json:"language"`
+	Tag        string              `json:"tag"`
+	Count      int                 `json:"count"`
+}
+
+// 某一天新增的题目数量
+type DailyCount struct {
+	Date  string `json:"date"` // 2006-01-02
+	Count int    `json:"count"`
+}
+
+// AI出题调用的成功率和耗时
+type GenerationStats struct {
+	GenerationSummary
+	ByProvider []ProviderGeneration `json:"byProvider"`
+}
+
+// 出题调用汇总
+type GenerationSummary struct {
+	Requests     int     `json:"requests"`
+	Failures     int     `json:"failures"`
+	SuccessRate  float64 `json:"successRate"`  // 0-1，没有调用时为0
+	AvgLatencyMs float64 `json:"avgLatencyMs"` // 只统计成功的调用
+}
+
+// 单个模型服务商的出题调用汇总
+type ProviderGeneration struct {
+	Provider ModelProvider `json:"provider"`
+	GenerationSummary
+}
+
+// 统计查询参数
+type StatsRequest struct {
+	Days   int   `form:"days"`   // 默认30天，最多365天
+	BankID int64 `form:"bankId"` // 只统计一个题库，默认是能查看题目的全部题库
+}
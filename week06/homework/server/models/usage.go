@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// 调用模型的用途
+type UsagePurpose string
+
+const (
+	PurposeGenerate UsagePurpose = "generate"
+	PurposeReview   UsagePurpose = "review"
+	PurposeGrade    UsagePurpose = "grade"
+)
+
+// 一次模型调用的用量记录
+type UsageRecord struct {
+	UserID           string        `json:"userId"`
+	Provider         ModelProvider `json:"provider"`
+	Model            string        `json:"model"`
+	Purpose          UsagePurpose  `json:"purpose"`
+	PromptTokens     int           `json:"promptTokens"`
+	CompletionTokens int           `json:"completionTokens"`
+	Cost             float64       `json:"cost"`
+	LatencyMs        int64         `json:"latencyMs"`
+	Success          bool          `json:"success"`
+	CreatedAt        time.Time     `json:"createdAt"`
+}
+
+// 用量汇总
+type UsageSummary struct {
+	Requests         int     `json:"requests"`
+	Failures         int     `json:"failures"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// 按某一维度分组的用量汇总
+type UsageGroup struct {
+	Key string `json:"key"`
+	UsageSummary
+}
+
+// 配额使用情况，Limit为0表示不限制
+type QuotaStatus struct {
+	Scope  string  `json:"scope"` // user_daily / user_monthly / global_daily / global_monthly
+	Used   float64 `json:"used"`
+	Limit  float64 `json:"limit"`
+	Remain float64 `json:"remain"`
+}
+
+// 用量报表
+type UsageReport struct {
+	From    time.Time     `json:"from"`
+	To      time.Time     `json:"to"`
+	UserID  string        `json:"userId,omitempty"`
+	Total   UsageSummary  `json:"total"`
+	ByModel []UsageGroup  `json:"byModel"`
+	ByUser  []UsageGroup  `json:"byUser"`
+	ByDay   []UsageGroup  `json:"byDay"`
+	Quotas  []QuotaStatus `json:"quotas"`
+}
+
+// 用量报表查询参数
+type UsageReportRequest struct {
+	From   string `form:"from"` // 2006-01-02，默认本月第一天
+	To     string `form:"to"`   // 2006-01-02，包含当天，默认今天
+	UserID string `form:"userId"`
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 可以订阅的事件
+type WebhookEvent string
+
+const (
+	EventQuestionCreated     WebhookEvent = "question.created"
+	EventQuestionUpdated     WebhookEvent = "question.updated"
+	EventQuestionDeleted     WebhookEvent = "question.deleted"
+	EventGenerationCompleted WebhookEvent = "generation.completed"
+	EventExamSubmitted       WebhookEvent = "exam.submitted"
+)
+
+// 所有可以订阅的事件
+var WebhookEvents = []WebhookEvent{
+	EventQuestionCreated,
+	EventQuestionUpdated,
+	EventQuestionDeleted,
+	EventGenerationCompleted,
+	EventExamSubmitted,
+}
+
+// 是否是可以订阅的事件
+func (e WebhookEvent) Valid() bool {
+	for _, event := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// 一个webhook订阅
+type Webhook struct {
+	ID        int64          `json:"id"`
+	URL       string         `json:"url"`
+	Events    []WebhookEvent `json:"events"`
+	Active    bool           `json:"active"`
+	CreatedAt time.Time      `json:"createdAt"`
+	// 签名密钥只在创建时返回一次
+	Secret string `json:"secret,omitempty"`
+}
+
+// 创建或修改webhook订阅的请求
+type WebhookRequest struct {
+	URL    string         `json:"url" binding:"required"`
+	Events []WebhookEvent `json:"events" binding:"required"`
+	Active *bool          `json:"active"` // 为nil时创建为启用，修改时保持不变
+	// 签名密钥，创建时为空则随机生成，修改时为空表示不修改
+	Secret string `json:"secret"`
+}
+
+// 投递状态
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // 等待投递或重试
+	DeliveryDelivered DeliveryStatus = "delivered" // 接收方返回了2xx
+	DeliveryDead      DeliveryStatus = "dead"      // 重试次数用尽，进入死信列表
+)
+
+// 一次事件投递，同一个事件投递给每个订阅各有一条记录
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     int64           `json:"webhookId"`
+	EventID       string          `json:"eventId"`
+	Event         WebhookEvent    `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        DeliveryStatus  `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	LastStatus    int             `json:"lastStatus,omitempty"` // 最近一次投递的HTTP状态码
+	LastError     string          `json:"lastError,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	DeliveredAt   *time.Time      `json:"deliveredAt,omitempty"`
+}
+
+// 投递记录的查询参数
+type DeliveryListRequest struct {
+	Status    DeliveryStatus `form:"status"`
+	WebhookID int64          `form:"webhookId"`
+	Limit     int            `form:"limit"` // 默认100
+}
+
+// 发送给接收方的请求体
+type WebhookPayload struct {
+	ID        string       `json:"id"` // 事件ID，重试时不变，接收方可以据此去重
+	Event     WebhookEvent `json:"event"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Data      interface{}  `json:"data"`
+}
+
+// question.deleted事件的数据
+type QuestionDeletedData struct {
+	ID int64 `json:"id"`
+}
+
+// generation.completed事件的数据
+type GenerationCompletedData struct {
+	UserID    string          `json:"userId"`
+	Request   QuestionRequest `json:"request"`
+	Questions []AIResponse    `json:"questions"`
+}
+
+// exam.submitted事件的数据
+type ExamSubmittedData struct {
+	UserID string      `json:"userId"`
+	Result *ExamResult `json:"result"`
+}
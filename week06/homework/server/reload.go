@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"question-generator/config"
+	"question-generator/logging"
+	"question-generator/middleware"
+	"question-generator/services"
+	"syscall"
+)
+
+// 可以覆盖配置的命令行参数，对应的环境变量名
+var configFlagKeys = map[string]string{
+	"db":        "DATABASE_PATH",
+	"host":      "HOST",
+	"port":      "PORT",
+	"log-level": "LOG_LEVEL",
+}
+
+// 收集命令行中实际指定的配置参数，未指定的参数不覆盖其他来源
+func configFlags() map[string]string {
+	flags := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		if key, ok := configFlagKeys[f.Name]; ok {
+			flags[key] = f.Value.String()
+		}
+	})
+	return flags
+}
+
+// 收到SIGHUP时重新加载配置，只应用限流、配额、模型参数和日志级别；
+// 新配置无效时继续使用当前配置
+func watchReload(ctx context.Context, opts config.Options, current *config.Configuration, limiter *middleware.RateLimiter, aiClient *services.AIClient, usage *services.UsageService) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		cfg, err := config.Load(opts)
+		if err != nil {
+			slog.Error("重新加载配置失败，继续使用当前配置", "error", err)
+			continue
+		}
+
+		limiter.SetLimits(cfg.RateLimits)
+		usage.SetQuotas(cfg.Quotas)
+		aiClient.SetPrompts(cfg.Prompts)
+		logging.SetLevel(cfg.LogLevel)
+		if fields := config.RestartRequired(current, cfg); len(fields) > 0 {
+			slog.Warn("部分配置需要重启服务才能生效", "fields", fields)
+		}
+		slog.Info("已重新加载配置")
+	}
+}
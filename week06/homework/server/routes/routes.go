@@ -2,21 +2,144 @@ package routes
 
 import (
 	"question-generator/controllers"
+	"question-generator/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// API路由用到的控制器，按字段名传入，新增控制器时不会因为参数顺序错位
+type Controllers struct {
+	Question   *controllers.QuestionController
+	Attachment *controllers.AttachmentController
+	Course     *controllers.CourseController
+	Exam       *controllers.ExamController
+	Grading    *controllers.GradingController
+	Practice   *controllers.PracticeController
+	Adaptive   *controllers.AdaptiveController
+	Usage      *controllers.UsageController
+	Stats      *controllers.StatsController
+	Analysis   *controllers.AnalysisController
+	Backup     *controllers.BackupController
+	Webhook    *controllers.WebhookController
+}
+
+// 接口的身份验证设置
+type Auth struct {
+	AdminToken string // 管理令牌，为空时管理接口不可用
+	UserSecret string // 验证用户令牌的密钥，为空时所有请求都是匿名用户
+}
+
 // 配置API路由
-func SetupRoutes(r *gin.Engine, questionController *controllers.QuestionController) {
+func SetupRoutes(r *gin.Engine, limiter *middleware.RateLimiter, auth Auth, c Controllers) {
+	// 图片地址写在题目内容中，一页题目会同时请求多张图片，不参与限流
+	r.GET("/api/attachments/:id", c.Attachment.Serve)
+
 	api := r.Group("/api")
+	// 用户身份只来自验证过的令牌，限流和权限检查都在验证之后
+	api.Use(middleware.Authenticate(auth.UserSecret))
+	api.Use(middleware.RateLimit(limiter, controllers.RateLimitKey))
+	// 带管理令牌的请求不受课程成员身份限制
+	api.Use(middleware.IdentifyAdmin(auth.AdminToken))
 
 	// 问题相关路由
 	questions := api.Group("/questions")
 	{
-		questions.POST("/create", questionController.CreateQuestion)    // 生成题目
-		questions.GET("/list", questionController.ListQuestions)        // 查询题目列表
-		questions.POST("/add", questionController.AddQuestion)          // 手动添加题目
-		questions.PUT("/edit/:id", questionController.EditQuestion)     // 编辑题目
-		questions.DELETE("/delete", questionController.DeleteQuestions) // 删除题目
+		questions.POST("/create", c.Question.CreateQuestion)    // 生成题目
+		questions.GET("/list", c.Question.ListQuestions)        // 查询题目列表
+		questions.POST("/add", c.Question.AddQuestion)          // 手动添加题目
+		questions.PUT("/edit/:id", c.Question.EditQuestion)     // 编辑题目
+		questions.DELETE("/delete", c.Question.DeleteQuestions) // 删除题目
+		questions.POST("/bulk", c.Question.BulkUpdate)          // 批量修改题目
+		questions.POST("/preview", c.Question.PreviewQuestion)  // 预览渲染后的题目
+		questions.POST("/copy", c.Question.CopyQuestions)       // 复制题目到其他题库
+	}
+
+	// 课程相关路由
+	courses := api.Group("/courses")
+	{
+		courses.POST("", c.Course.CreateCourse)                       // 创建课程
+		courses.GET("", c.Course.ListCourses)                         // 我的课程
+		courses.GET("/:id", c.Course.GetCourse)                       // 课程详情
+		courses.PUT("/:id", c.Course.UpdateCourse)                    // 修改课程
+		courses.PUT("/:id/members", c.Course.SetMember)               // 添加或修改成员
+		courses.DELETE("/:id/members/:userId", c.Course.RemoveMember) // 移除成员
+		courses.POST("/:id/banks", c.Course.CreateBank)               // 创建题库
+	}
+
+	// 题库相关路由
+	banks := api.Group("/banks")
+	{
+		banks.GET("", c.Course.ListBanks)                           // 能访问的题库
+		banks.POST("/:id/shares", c.Course.ShareBank)               // 共享给其他课程
+		banks.DELETE("/:id/shares/:courseId", c.Course.UnshareBank) // 取消共享
+	}
+
+	api.POST("/attachments", c.Attachment.Upload) // 上传题目图片
+
+	// 考试相关路由
+	exams := api.Group("/exams")
+	{
+		exams.POST("/grade", c.Exam.GradeExam) // 交卷批改
+	}
+
+	// 开放题批改相关路由
+	grading := api.Group("/grading")
+	{
+		grading.GET("/rubrics/:questionId", c.Grading.GetRubric)                      // 评分标准
+		grading.PUT("/rubrics/:questionId", c.Grading.SetRubric)                      // 设置评分标准
+		grading.GET("/responses", c.Grading.ListResponses)                            // 作答及批改结果
+		grading.POST("/responses/:attemptId/:questionId/grade", c.Grading.Grade)      // 模型批改
+		grading.PUT("/responses/:attemptId/:questionId/override", c.Grading.Override) // 教师改分
+		grading.POST("/jobs", c.Grading.StartJob)                                     // 批量批改
+		grading.GET("/jobs/:id", c.Grading.GetJob)                                    // 批改任务进度
+		grading.GET("/calibration", c.Grading.Calibration)                            // 模型与教师评分的一致程度
+	}
+
+	// 练习和错题本相关路由
+	practice := api.Group("/practice")
+	{
+		practice.POST("/answer", c.Practice.Answer)                     // 提交练习作答
+		practice.GET("/today", c.Practice.Today)                        // 今日复习
+		practice.GET("/notebook", c.Practice.Notebook)                  // 错题本
+		practice.DELETE("/notebook/:id", c.Practice.RemoveFromNotebook) // 移出错题本
+		practice.GET("/mastery", c.Practice.Mastery)                    // 按标签的掌握情况
+	}
+
+	// 自适应测试相关路由
+	adaptive := api.Group("/adaptive")
+	{
+		adaptive.POST("/sessions", c.Adaptive.Start)             // 开始测试
+		adaptive.GET("/sessions/:id", c.Adaptive.Get)            // 测试进度和报告
+		adaptive.POST("/sessions/:id/answer", c.Adaptive.Answer) // 提交作答
+	}
+
+	// 用量相关路由
+	usage := api.Group("/usage")
+	{
+		usage.GET("/report", c.Usage.Report) // 用量和费用报表
+	}
+
+	// 统计相关路由
+	stats := api.Group("/stats")
+	{
+		stats.GET("/overview", c.Stats.Overview) // 题库概况和出题统计
+		stats.GET("/items", c.Analysis.Items)    // 题目分析
+		stats.GET("/items/:id", c.Analysis.Item) // 单道题的分析
+	}
+
+	// 管理相关路由，需要管理令牌
+	admin := api.Group("/admin", middleware.AdminAuth(auth.AdminToken))
+	{
+		admin.GET("/backups", c.Backup.ListBackups)              // 快照列表
+		admin.POST("/backups", c.Backup.CreateBackup)            // 立即备份
+		admin.GET("/integrity", c.Backup.IntegrityCheck)         // 完整性检查
+		admin.POST("/items/recalibrate", c.Analysis.Recalibrate) // 按实测难度重新标定
+
+		admin.GET("/webhooks", c.Webhook.List)                                // 订阅列表
+		admin.POST("/webhooks", c.Webhook.Create)                             // 创建订阅
+		admin.PUT("/webhooks/:id", c.Webhook.Update)                          // 修改订阅
+		admin.DELETE("/webhooks/:id", c.Webhook.Delete)                       // 删除订阅
+		admin.GET("/webhooks/deliveries", c.Webhook.Deliveries)               // 投递记录和死信列表
+		admin.POST("/webhooks/deliveries/:id/redeliver", c.Webhook.Redeliver) // 重新投递
 	}
 }
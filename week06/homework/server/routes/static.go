@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 前端页面的内容安全策略：只执行本站的脚本文件，题目内容中混入的内联脚本、事件属性和javascript:链接都不会执行。
+// antd在运行时插入样式，所以允许内联样式；题目和README中可以引用外部图片
+const contentSecurityPolicy = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: blob: https:; object-src 'none'; base-uri 'self'; frame-ancestors 'none'"
+
+// 配置前端页面、静态资源和README路由，files的根目录对应前端构建输出的static目录
+func SetupStatic(r *gin.Engine, files fs.FS, readme []byte) {
+	fileServer := http.FileServer(http.FS(files))
+
+	// 把请求路径对应的文件交给fileServer，文件不存在或是目录时返回false
+	serveFile := func(c *gin.Context, cacheControl string) bool {
+		name := strings.TrimPrefix(path.Clean(c.Request.URL.Path), "/")
+		info, err := fs.Stat(files, name)
+		if err != nil || info.IsDir() {
+			return false
+		}
+		c.Header("Cache-Control", cacheControl)
+		fileServer.ServeHTTP(c.Writer, c.Request)
+		return true
+	}
+
+	// index.html不能缓存，否则发布新版本后浏览器仍会引用旧的资源文件名
+	serveIndex := func(c *gin.Context) {
+		index, err := fs.ReadFile(files, "index.html")
+		if err != nil {
+			c.String(http.StatusNotFound, "前端尚未构建")
+			return
+		}
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Content-Security-Policy", contentSecurityPolicy)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", index)
+	}
+
+	// vite构建的资源文件名带内容哈希，可以长期缓存
+	r.GET("/assets/*filepath", func(c *gin.Context) {
+		if !serveFile(c, "public, max-age=31536000, immutable") {
+			c.Status(http.StatusNotFound)
+		}
+	})
+
+	r.GET("/README.md", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-cache")
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", readme)
+	})
+
+	r.GET("/", serveIndex)
+
+	// 处理前端路由
+	r.NoRoute(func(c *gin.Context) {
+		p := c.Request.URL.Path
+
+		if strings.HasPrefix(p, "/api") {
+			c.JSON(http.StatusNotFound, gin.H{"code": -1, "msg": "API not found"})
+			return
+		}
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		// 根目录下的其他文件，例如vite.svg
+		if serveFile(c, "no-cache") {
+			return
+		}
+
+		// 检查是否请求的是静态资源
+		if path.Ext(p) != "" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		// 返回index.html用于前端路由处理
+		serveIndex(c)
+	})
+}
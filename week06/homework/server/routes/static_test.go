@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSetupStatic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	files := fstest.MapFS{
+		"index.html":          {Data: []byte("<html>index</html>")},
+		"vite.svg":            {Data: []byte("<svg/>")},
+		"assets/index-abc.js": {Data: []byte("console.log(1)")},
+	}
+	r := gin.New()
+	SetupStatic(r, files, []byte("# 学习心得"))
+
+	tests := []struct {
+		path   string
+		status int
+		cache  string
+		body   string
+	}{
+		{"/", http.StatusOK, "no-cache", "<html>index</html>"},
+		{"/questions/list", http.StatusOK, "no-cache", "<html>index</html>"},
+		{"/assets/index-abc.js", http.StatusOK, "public, max-age=31536000, immutable", "console.log(1)"},
+		{"/assets/missing.js", http.StatusNotFound, "", ""},
+		{"/assets/", http.StatusNotFound, "", ""},
+		{"/vite.svg", http.StatusOK, "no-cache", "<svg/>"},
+		{"/favicon.ico", http.StatusNotFound, "", ""},
+		{"/README.md", http.StatusOK, "no-cache", "# 学习心得"},
+		{"/api/unknown", http.StatusNotFound, "", `{"code":-1,"msg":"API not found"}`},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.path, w.Code, tt.status)
+			continue
+		}
+		if got := w.Header().Get("Cache-Control"); got != tt.cache {
+			t.Errorf("%s: Cache-Control %q, want %q", tt.path, got, tt.cache)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s: body %q, want %q", tt.path, w.Body.String(), tt.body)
+		}
+	}
+
+	// 前端页面不允许执行内联脚本，题目内容中混入的脚本不会执行
+	for _, p := range []string{"/", "/questions/list"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'self';") {
+			t.Errorf("%s: Content-Security-Policy %q", p, csp)
+		}
+	}
+}
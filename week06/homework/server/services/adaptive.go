@@ -0,0 +1,477 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"question-generator/config"
+	"question-generator/metrics"
+	"question-generator/models"
+	"sync"
+	"time"
+)
+
+// 自适应测试相关的错误，可用errors.Is判断
+var (
+	ErrSessionNotFound = errors.New("测试会话不存在")
+	ErrSessionFinished = errors.New("测试已结束")
+	ErrWrongQuestion   = errors.New("作答的不是当前题目")
+	ErrPoolEmpty       = errors.New("没有符合条件的题目")
+)
+
+// 95%置信区间对应的正态分位数
+const confidenceZ = 1.96
+
+// 按项目反应理论逐题选题的自适应测试
+type AdaptiveService struct {
+	storage  *StorageService
+	analysis *ItemAnalysisService
+	cfg      config.AdaptiveConfig
+	now      func() time.Time
+	mu       sync.Mutex // 同一时间只处理一次作答，避免重复提交同一道题
+}
+
+// 创建自适应测试服务，会话表与题库放在同一个数据库中
+func NewAdaptiveService(storage *StorageService, analysis *ItemAnalysisService, cfg config.AdaptiveConfig) (*AdaptiveService, error) {
+	_, err := storage.DB.Exec(`CREATE TABLE IF NOT EXISTS adaptive_sessions (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		filter TEXT NOT NULL, -- 开始测试时的筛选条件，JSON
+		max_questions INTEGER NOT NULL,
+		target_se REAL NOT NULL,
+		status TEXT NOT NULL, -- active=进行中, finished=已结束
+		stop_reason TEXT,
+		theta REAL NOT NULL,
+		se REAL NOT NULL,
+		next_question_id INTEGER, -- 进行中时为当前待答的题目
+		next_a REAL,
+		next_b REAL,
+		next_calibrated INTEGER,
+		created_at INTEGER NOT NULL,
+		finished_at INTEGER
+	);
+	CREATE TABLE IF NOT EXISTS adaptive_responses (
+		session_id TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		question_id INTEGER NOT NULL,
+		a REAL NOT NULL, -- 出题时使用的题目参数
+		b REAL NOT NULL,
+		calibrated INTEGER NOT NULL,
+		selected TEXT NOT NULL,
+		correct INTEGER NOT NULL,
+		theta REAL NOT NULL, -- 作答后的能力估计
+		se REAL NOT NULL,
+		PRIMARY KEY (session_id, seq)
+	);
+	CREATE INDEX IF NOT EXISTS idx_adaptive_sessions_user ON adaptive_sessions(user_id, created_at);`)
+	if err != nil {
+		return nil, fmt.Errorf("无法创建自适应测试表: %w", err)
+	}
+
+	return &AdaptiveService{
+		storage:  storage,
+		analysis: analysis,
+		cfg:      cfg,
+		now:      time.Now,
+	}, nil
+}
+
+// 数据库中的一个会话
+type adaptiveSession struct {
+	id           string
+	userID       string
+	filter       models.AdaptiveStartRequest
+	maxQuestions int
+	targetSE     float64
+	status       models.AdaptiveStatus
+	stopReason   models.AdaptiveStopReason
+	theta, se    float64
+	nextID       int64
+	nextParams   models.ItemParams
+	createdAt    time.Time
+	finishedAt   *time.Time
+}
+
+// 开始一次自适应测试，返回会话和第一道题
+func (s *AdaptiveService) Start(userID string, req models.AdaptiveStartRequest) (*models.AdaptiveSession, error) {
+	if req.MaxQuestions <= 0 {
+		req.MaxQuestions = s.cfg.MaxQuestions
+	}
+	if req.TargetSE <= 0 {
+		req.TargetSE = s.cfg.TargetSE
+	}
+
+	// 先验为标准正态分布，开始时能力估计为0，标准误为1
+	session := &adaptiveSession{
+		id:           newSessionID(),
+		userID:       userID,
+		filter:       req,
+		maxQuestions: req.MaxQuestions,
+		targetSE:     req.TargetSE,
+		status:       models.AdaptiveActive,
+		theta:        0,
+		se:           1,
+		createdAt:    s.now(),
+	}
+
+	next, params, err := s.selectNext(session, nil)
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		return nil, ErrPoolEmpty
+	}
+	session.nextID, session.nextParams = next.ID, params
+
+	if err := s.insertSession(session); err != nil {
+		return nil, err
+	}
+	return s.view(session, nil)
+}
+
+// 查询会话的当前状态，结束后即为最终报告
+func (s *AdaptiveService) Get(userID, id string) (*models.AdaptiveSession, error) {
+	session, err := s.loadSession(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	responses, err := s.loadResponses(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.view(session, responses)
+}
+
+// 提交当前题目的作答，更新能力估计，并选出下一道题或结束测试
+func (s *AdaptiveService) Answer(userID, id string, req models.AdaptiveAnswerRequest) (*models.AdaptiveSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, err := s.loadSession(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.status != models.AdaptiveActive {
+		return nil, ErrSessionFinished
+	}
+	if req.QuestionID != session.nextID {
+		return nil, ErrWrongQuestion
+	}
+
+	question, err := s.storage.GetQuestionByID(req.QuestionID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := s.loadResponses(id)
+	if err != nil {
+		return nil, err
+	}
+
+	current := adaptiveResponse{
+		questionID: question.ID,
+		params:     session.nextParams,
+		selected:   nonNilInts(req.Selected),
+		correct:    models.SameChoices(req.Selected, question.AIRes.Right),
+	}
+	irt := make([]irtResponse, 0, len(responses)+1)
+	for _, r := range responses {
+		irt = append(irt, irtResponse{params: r.params, correct: r.correct})
+	}
+	irt = append(irt, irtResponse{params: current.params, correct: current.correct})
+	current.theta, current.se = estimateAbility(irt)
+	responses = append(responses, current)
+
+	session.theta, session.se = current.theta, current.se
+	session.nextID, session.nextParams = 0, models.ItemParams{}
+
+	switch {
+	case session.se < session.targetSE:
+		session.stopReason = models.StopPrecision
+	case len(responses) >= session.maxQuestions:
+		session.stopReason = models.StopMaxQuestions
+	default:
+		next, params, err := s.selectNext(session, responses)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			session.stopReason = models.StopPoolEmpty
+		} else {
+			session.nextID, session.nextParams = next.ID, params
+		}
+	}
+	if session.stopReason != "" {
+		now := s.now()
+		session.status = models.AdaptiveFinished
+		session.finishedAt = &now
+	}
+
+	if err := s.saveAnswer(session, len(responses), current); err != nil {
+		return nil, err
+	}
+	return s.view(session, responses)
+}
+
+// 从题目范围中选出在当前能力估计处信息量最大的题目，没有可用题目时返回nil
+func (s *AdaptiveService) selectNext(session *adaptiveSession, answered []adaptiveResponse) (*models.QuestionData, models.ItemParams, error) {
+	pool, err := s.candidates(session.filter, answered)
+	if err != nil || len(pool) == 0 {
+		return nil, models.ItemParams{}, err
+	}
+
+	params, err := s.analysis.ItemParams(pool)
+	if err != nil {
+		return nil, models.ItemParams{}, err
+	}
+
+	// 候选题按ID升序，信息量相同时选ID较小的题目
+	best := -1
+	bestInfo := 0.0
+	for i, q := range pool {
+		if info := irtInformation(params[q.ID], session.theta); best < 0 || info > bestInfo {
+			best, bestInfo = i, info
+		}
+	}
+	return &pool[best], params[pool[best].ID], nil
+}
+
+// 查出符合筛选条件、可以自动批改且未作答过的题目
+func (s *AdaptiveService) candidates(req models.AdaptiveStartRequest, answered []adaptiveResponse) ([]models.QuestionData, error) {
+	defer metrics.ObserveDB("adaptive_candidates")()
+
+	filter := models.QuestionFilter{
+		Types:    req.Types,
+		Language: req.Language,
+		Tag:      req.Tag,
+		Status:   models.StatusActive,
+		BankIDs:  req.BankIDs,
+	}
+	if len(filter.Types) == 0 {
+		filter.Types = models.QuestionTypesWithInput(models.InputChoice)
+	}
+	conditions, args := questionConditions(filter)
+
+	rows, err := s.storage.DB.Query("SELECT "+questionColumns+" FROM questions "+whereClause(conditions)+" ORDER BY id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询题目失败: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int64]bool, len(answered))
+	for _, r := range answered {
+		done[r.questionID] = true
+	}
+
+	var pool []models.QuestionData
+	for rows.Next() {
+		q, err := scanQuestion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描数据库行失败: %w", err)
+		}
+		if spec, ok := models.LookupQuestionType(q.AIReq.Type); !ok || spec.Input != models.InputChoice || done[q.ID] {
+			continue
+		}
+		pool = append(pool, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询题目失败: %w", err)
+	}
+	return pool, nil
+}
+
+func (s *AdaptiveService) insertSession(session *adaptiveSession) error {
+	defer metrics.ObserveDB("adaptive_start")()
+
+	filter, _ := json.Marshal(session.filter)
+	_, err := s.storage.DB.Exec(`INSERT INTO adaptive_sessions (
+		id, user_id, filter, max_questions, target_se, status, theta, se,
+		next_question_id, next_a, next_b, next_calibrated, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.id, session.userID, string(filter), session.maxQuestions, session.targetSE, string(session.status),
+		session.theta, session.se, session.nextID, session.nextParams.A, session.nextParams.B, session.nextParams.Calibrated,
+		session.createdAt.Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("保存测试会话失败: %w", err)
+	}
+	return nil
+}
+
+// 在一个事务中保存作答、更新会话和题目的使用次数
+func (s *AdaptiveService) saveAnswer(session *adaptiveSession, seq int, r adaptiveResponse) error {
+	defer metrics.ObserveDB("adaptive_answer")()
+
+	tx, err := s.storage.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	selected, _ := json.Marshal(r.selected)
+	_, err = tx.Exec(`INSERT INTO adaptive_responses (
+		session_id, seq, question_id, a, b, calibrated, selected, correct, theta, se
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.id, seq, r.questionID, r.params.A, r.params.B, r.params.Calibrated, string(selected), r.correct, r.theta, r.se,
+	)
+	if err != nil {
+		return fmt.Errorf("保存作答失败: %w", err)
+	}
+
+	var nextID interface{}
+	if session.nextID != 0 {
+		nextID = session.nextID
+	}
+	var finishedAt interface{}
+	if session.finishedAt != nil {
+		finishedAt = session.finishedAt.Unix()
+	}
+	_, err = tx.Exec(`UPDATE adaptive_sessions SET
+		status = ?, stop_reason = ?, theta = ?, se = ?,
+		next_question_id = ?, next_a = ?, next_b = ?, next_calibrated = ?, finished_at = ?
+	WHERE id = ?`,
+		string(session.status), string(session.stopReason), session.theta, session.se,
+		nextID, session.nextParams.A, session.nextParams.B, session.nextParams.Calibrated, finishedAt,
+		session.id,
+	)
+	if err != nil {
+		return fmt.Errorf("更新测试会话失败: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE questions SET usage_count = usage_count + 1 WHERE id = ?", r.questionID); err != nil {
+		return fmt.Errorf("更新题目使用次数失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// 读取会话，会话不属于该用户时视为不存在
+func (s *AdaptiveService) loadSession(userID, id string) (*adaptiveSession, error) {
+	defer metrics.ObserveDB("adaptive_session")()
+
+	session := &adaptiveSession{id: id}
+	var filter string
+	var stopReason sql.NullString
+	var nextID sql.NullInt64
+	var nextA, nextB sql.NullFloat64
+	var nextCalibrated sql.NullBool
+	var createdAt int64
+	var finishedAt sql.NullInt64
+
+	err := s.storage.DB.QueryRow(`SELECT user_id, filter, max_questions, target_se, status, stop_reason, theta, se,
+		next_question_id, next_a, next_b, next_calibrated, created_at, finished_at
+	FROM adaptive_sessions WHERE id = ?`, id).Scan(
+		&session.userID, &filter, &session.maxQuestions, &session.targetSE, &session.status, &stopReason,
+		&session.theta, &session.se, &nextID, &nextA, &nextB, &nextCalibrated, &createdAt, &finishedAt,
+	)
+	if err == sql.ErrNoRows || (err == nil && session.userID != userID) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询测试会话失败: %w", err)
+	}
+
+	json.Unmarshal([]byte(filter), &session.filter)
+	session.stopReason = models.AdaptiveStopReason(stopReason.String)
+	session.nextID = nextID.Int64
+	session.nextParams = models.ItemParams{A: nextA.Float64, B: nextB.Float64, Calibrated: nextCalibrated.Bool}
+	session.createdAt = time.Unix(createdAt, 0)
+	if finishedAt.Valid {
+		t := time.Unix(finishedAt.Int64, 0)
+		session.finishedAt = &t
+	}
+	return session, nil
+}
+
+// 会话中已保存的一次作答
+type adaptiveResponse struct {
+	questionID int64
+	params     models.ItemParams
+	selected   []int
+	correct    bool
+	theta, se  float64
+}
+
+func (s *AdaptiveService) loadResponses(id string) ([]adaptiveResponse, error) {
+	rows, err := s.storage.DB.Query(`SELECT question_id, a, b, calibrated, selected, correct, theta, se
+	FROM adaptive_responses WHERE session_id = ? ORDER BY seq`, id)
+	if err != nil {
+		return nil, fmt.Errorf("查询作答记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var responses []adaptiveResponse
+	for rows.Next() {
+		var r adaptiveResponse
+		var selected string
+		if err := rows.Scan(&r.questionID, &r.params.A, &r.params.B, &r.params.Calibrated, &selected, &r.correct, &r.theta, &r.se); err != nil {
+			return nil, fmt.Errorf("扫描作答记录失败: %w", err)
+		}
+		json.Unmarshal([]byte(selected), &r.selected)
+		responses = append(responses, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询作答记录失败: %w", err)
+	}
+	return responses, nil
+}
+
+// 生成返回给调用者的会话状态，进行中时附带下一道题（不含答案）
+func (s *AdaptiveService) view(session *adaptiveSession, responses []adaptiveResponse) (*models.AdaptiveSession, error) {
+	result := &models.AdaptiveSession{
+		ID:           session.id,
+		Status:       session.status,
+		StopReason:   session.stopReason,
+		MaxQuestions: session.maxQuestions,
+		TargetSE:     session.targetSE,
+		Theta:        session.theta,
+		SE:           session.se,
+		Lower:        session.theta - confidenceZ*session.se,
+		Upper:        session.theta + confidenceZ*session.se,
+		Answered:     len(responses),
+		Items:        make([]models.AdaptiveItem, 0, len(responses)),
+		CreatedAt:    session.createdAt,
+		FinishedAt:   session.finishedAt,
+	}
+
+	for _, r := range responses {
+		item := models.AdaptiveItem{
+			QuestionID: r.questionID,
+			Params:     r.params,
+			Correct:    r.correct,
+			Theta:      r.theta,
+			SE:         r.se,
+		}
+		// 题目可能已被删除，报告中只缺少标题
+		if q, err := s.storage.GetQuestionByID(r.questionID); err == nil {
+			item.Title = q.AIRes.Title
+		} else if !errors.Is(err, ErrQuestionNotFound) {
+			return nil, err
+		}
+		if r.correct {
+			result.Correct++
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	if session.status == models.AdaptiveActive && session.nextID != 0 {
+		q, err := s.storage.GetQuestionByID(session.nextID)
+		if err != nil {
+			return nil, err
+		}
+		result.Next = studentQuestion(q)
+	}
+	return result, nil
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"question-generator/config"
+	"question-generator/models"
+	"testing"
+)
+
+func TestEstimateAbility(t *testing.T) {
+	theta, se := estimateAbility(nil)
+	if math.Abs(theta) > 1e-9 || math.Abs(se-1) > 0.01 {
+		t.Errorf("prior: theta=%v se=%v", theta, se)
+	}
+
+	medium := paramsFromLabel(models.Medium)
+	right, _ := estimateAbility([]irtResponse{{params: medium, correct: true}})
+	wrong, _ := estimateAbility([]irtResponse{{params: medium, correct: false}})
+	if right <= 0 || math.Abs(right+wrong) > 1e-9 {
+		t.Errorf("one response: right=%v wrong=%v", right, wrong)
+	}
+
+	// 作答越多标准误越小
+	var responses []irtResponse
+	for i := 0; i < 10; i++ {
+		responses = append(responses, irtResponse{params: medium, correct: i%2 == 0})
+	}
+	if _, se := estimateAbility(responses); se >= 0.6 {
+		t.Errorf("se after 10 responses = %v", se)
+	}
+}
+
+func TestParamsFromStats(t *testing.T) {
+	easy := paramsFromStats(0.84, nil)
+	if !easy.Calibrated || easy.A != 1 || math.Abs(easy.B+1) > 0.01 {
+		t.Errorf("easy: %+v", easy)
+	}
+
+	r := 0.4
+	p := paramsFromStats(0.5, &r)
+	if p.A <= 0.5 || p.A >= 1 || math.Abs(p.B) > 1e-9 {
+		t.Errorf("discriminating item: %+v", p)
+	}
+
+	// 全对时难度有限
+	if all := paramsFromStats(1, nil); all.B < minTheta || all.B > -2 {
+		t.Errorf("all correct: %+v", all)
+	}
+}
+
+func TestAdaptiveSession(t *testing.T) {
+	storage := newTestStorage(t)
+	analysis := NewItemAnalysisService(storage, config.ItemAnalysisConfig{MinResponses: 30})
+	adaptive, err := NewAdaptiveService(storage, analysis, config.AdaptiveConfig{MaxQuestions: 4, TargetSE: 0.1})
+	if err != nil {
+		t.Fatalf("NewAdaptiveService: %v", err)
+	}
+
+	difficulty := make(map[int64]models.QuestionDifficulty)
+	for _, d := range []models.QuestionDifficulty{models.Easy, models.Medium, models.Hard} {
+		for i := 0; i < 3; i++ {
+			q := choiceQuestion("题目", models.SingleChoice, 0)
+			q.Difficulty = d
+			id, err := storage.AddQuestion(q)
+			if err != nil {
+				t.Fatalf("AddQuestion: %v", err)
+			}
+			difficulty[id] = d
+		}
+	}
+	storage.AddQuestion(programmingQuestion("编程题不参与自适应测试"))
+
+	session, err := adaptive.Start("alice", models.AdaptiveStartRequest{})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// 初始能力为0，中等难度的题目信息量最大
+	if session.Next == nil || difficulty[session.Next.ID] != models.Medium || len(session.Next.Options) != 4 {
+		t.Fatalf("first question: %+v", session.Next)
+	}
+
+	if _, err := adaptive.Get("bob", session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("other user: %v", err)
+	}
+	if _, err := adaptive.Answer("alice", session.ID, models.AdaptiveAnswerRequest{QuestionID: session.Next.ID + 1}); !errors.Is(err, ErrWrongQuestion) {
+		t.Errorf("wrong question: %v", err)
+	}
+
+	// 一直答对时能力估计上升，选到困难的题目
+	seen := make(map[int64]bool)
+	prevTheta := session.Theta
+	for session.Status == models.AdaptiveActive {
+		seen[session.Next.ID] = true
+		session, err = adaptive.Answer("alice", session.ID, models.AdaptiveAnswerRequest{QuestionID: session.Next.ID, Selected: []int{0}})
+		if err != nil {
+			t.Fatalf("Answer: %v", err)
+		}
+		if session.Theta <= prevTheta {
+			t.Errorf("theta did not increase: %v -> %v", prevTheta, session.Theta)
+		}
+		prevTheta = session.Theta
+		if session.Next != nil && seen[session.Next.ID] {
+			t.Fatalf("question %d asked twice", session.Next.ID)
+		}
+	}
+
+	if session.StopReason != models.StopMaxQuestions || session.Answered != 4 || session.Correct != 4 {
+		t.Fatalf("final session: %+v", session)
+	}
+	if difficulty[session.Items[1].QuestionID] != models.Hard {
+		t.Errorf("second question should be hard: %+v", session.Items[1])
+	}
+	if session.Lower >= session.Theta || session.Upper <= session.Theta || session.SE >= 1 {
+		t.Errorf("confidence band: %+v", session)
+	}
+
+	report, err := adaptive.Get("alice", session.ID)
+	if err != nil || report.Theta != session.Theta || len(report.Items) != 4 || report.Next != nil {
+		t.Errorf("report: %+v, %v", report, err)
+	}
+	if _, err := adaptive.Answer("alice", session.ID, models.AdaptiveAnswerRequest{QuestionID: session.Items[0].QuestionID}); !errors.Is(err, ErrSessionFinished) {
+		t.Errorf("answer after finish: %v", err)
+	}
+
+	if _, err := adaptive.Start("alice", models.AdaptiveStartRequest{Tag: "不存在"}); !errors.Is(err, ErrPoolEmpty) {
+		t.Errorf("empty pool: %v", err)
+	}
+}
@@ -3,36 +3,131 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"question-generator/config"
+	"question-generator/metrics"
 	"question-generator/models"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
+// 单次出题或审核调用模型的最长时间。服务关闭时的等待时间应不短于它，否则进行中的调用会被取消
+const ModelCallTimeout = 180 * time.Second
+
+// 各模型服务商默认支持的结构化输出能力，可通过配置覆盖
+var providerOutputModes = map[models.ModelProvider]models.OutputMode{
+	models.Tongyi:   models.OutputJSONObject,
+	models.DeepSeek: models.OutputJSONObject,
+}
+
+// 各模型服务商调用时使用的模型名称
+var providerModelNames = map[models.ModelProvider]string{
+	models.Tongyi:   "qwen-turbo",
+	models.DeepSeek: "deepseek-chat",
+}
+
+// 各模型服务商的显示名称，用于错误信息
+var providerDisplayNames = map[models.ModelProvider]string{
+	models.Tongyi:   "通义",
+	models.DeepSeek: "DeepSeek",
+}
+
+// 由AIBatchResponse生成的JSON Schema，json_schema模式下随请求发送
+var batchResponseSchema = mustBatchResponseSchema()
+
+func mustBatchResponseSchema() *jsonschema.Definition {
+	schema, err := jsonschema.GenerateSchemaForType(models.AIBatchResponse{})
+	if err != nil {
+		panic(fmt.Sprintf("生成题目JSON Schema失败: %v", err))
+	}
+	return schema
+}
+
 // 负责与模型官网通信
 type AIClient struct {
-	config       *config.Configuration
-	tongyiClient *openai.Client
+	config         *config.Configuration
+	tongyiClient   *openai.Client
+	deepseekClient *openai.Client
+	outputModes    map[models.ModelProvider]models.OutputMode
+	usage          *UsageService // 为空时不记录用量、不检查配额
+	mu             sync.RWMutex
+	prompts        config.PromptConfig // 可以热加载，读写时需持有mu
 }
 
 // 创建新的模型客户端
-func NewAIClient(config *config.Configuration) *AIClient {
+func NewAIClient(config *config.Configuration, usage *UsageService) *AIClient {
 	tongyiConfig := openai.DefaultConfig(config.QwenAPIKey)
 	tongyiConfig.BaseURL = config.QwenAPIURL
 
 	tongyiClient := openai.NewClientWithConfig(tongyiConfig)
 
+	deepseekConfig := openai.DefaultConfig(config.DeepSeekAPIKey)
+	deepseekConfig.BaseURL = config.DeepSeekAPIURL
+
+	deepseekClient := openai.NewClientWithConfig(deepseekConfig)
+
+	outputModes := make(map[models.ModelProvider]models.OutputMode, len(providerOutputModes))
+	for provider, mode := range providerOutputModes {
+		outputModes[provider] = mode
+	}
+	if config.QwenOutputMode != "" {
+		outputModes[models.Tongyi] = config.QwenOutputMode
+	}
+	if config.DeepSeekOutputMode != "" {
+		outputModes[models.DeepSeek] = config.DeepSeekOutputMode
+	}
+
 	return &AIClient{
-		config:       config,
-		tongyiClient: tongyiClient,
+		config:         config,
+		tongyiClient:   tongyiClient,
+		deepseekClient: deepseekClient,
+		outputModes:    outputModes,
+		usage:          usage,
+		prompts:        config.Prompts,
+	}
+}
+
+// 替换调用模型的参数，用于热加载配置，之后的请求使用新参数
+func (c *AIClient) SetPrompts(prompts config.PromptConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prompts = prompts
+}
+
+func (c *AIClient) promptConfig() config.PromptConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.prompts
+}
+
+// 获取指定服务商的客户端，未配置密钥时返回错误
+func (c *AIClient) clientFor(provider models.ModelProvider) (*openai.Client, error) {
+	switch provider {
+	case models.Tongyi:
+		if c.config.QwenAPIKey == "" {
+			return nil, fmt.Errorf("Qwen API密钥未配置")
+		}
+		return c.tongyiClient, nil
+	case models.DeepSeek:
+		if c.config.DeepSeekAPIKey == "" {
+			return nil, fmt.Errorf("DeepSeek API密钥未配置")
+		}
+		return c.deepseekClient, nil
+	default:
+		return nil, fmt.Errorf("不支持的模型: %s", provider)
 	}
 }
 
-// 批量生成问题
-func (c *AIClient) BatchGenerateQuestions(req *models.QuestionRequest, count int) ([]models.QuestionData, error) {
+// 批量生成问题，userID用于用量记录和配额检查。
+// ctx取消（例如客户端断开连接）时模型调用随之中止
+func (c *AIClient) BatchGenerateQuestions(ctx context.Context, req *models.QuestionRequest, count int, userID string) ([]models.QuestionData, error) {
 	if count <= 0 {
 		count = 1
 	}
@@ -43,12 +138,9 @@ func (c *AIClient) BatchGenerateQuestions(req *models.QuestionRequest, count int
 
 	startTime := time.Now()
 
-	prompt := buildBatchPrompt(req, count)
+	prompt := buildBatchPrompt(req, count, c.promptConfig().Instructions)
 
-	if c.config.QwenAPIKey == "" {
-		return nil, fmt.Errorf("Qwen API密钥未配置")
-	}
-	response, err := c.callTongyiAPIBatch(prompt)
+	response, err := c.callTongyiAPIBatch(ctx, prompt, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -56,6 +148,10 @@ func (c *AIClient) BatchGenerateQuestions(req *models.QuestionRequest, count int
 	endTime := time.Now()
 	costTime := int(endTime.Sub(startTime).Seconds())
 
+	// 记录实际使用的编程语言，入库后可以按语言筛选
+	aiReq := *req
+	aiReq.Language = req.GetLanguage()
+
 	results := make([]models.QuestionData, 0, len(response.Questions))
 	for _, question := range response.Questions {
 		questionData := models.QuestionData{
@@ -63,32 +159,36 @@ func (c *AIClient) BatchGenerateQuestions(req *models.QuestionRequest, count int
 			AIEndTime:   endTime,
 			AICostTime:  costTime,
 			AIStatus:    string(models.Tongyi),
-			AIReq:       *req,
+			AIReq:       aiReq,
 			AIRes: models.AIResponse{
-				Title:  question.Title,
-				Answer: question.Options,
-				Right:  question.Right,
-				Code:   question.Code,
+				Title:       question.Title,
+				Answer:      question.Options,
+				Right:       question.Right,
+				Code:        question.Code,
+				Explanation: question.Explanation,
+				Rationale:   alignRationale(question.Rationale, len(question.Options)),
+				Blanks:      question.Blanks,
+				Reference:   question.Reference,
 			},
 			Difficulty: req.GetDifficulty(),
 			CreatedAt:  time.Now(),
 		}
+		// 判断题的选项固定，不依赖模型的输出
+		if aiReq.GetQuestionType() == models.TrueFalse {
+			questionData.AIRes.Answer = models.TrueFalseOptions
+			questionData.AIRes.Rationale = alignRationale(question.Rationale, len(models.TrueFalseOptions))
+		}
 		results = append(results, questionData)
 	}
 
 	return results, nil
 }
 
-// 构建提示语
-func buildBatchPrompt(req *models.QuestionRequest, count int) string {
-	var questionType string
-	switch req.GetQuestionType() {
-	case models.SingleChoice:
-		questionType = "单选题"
-	case models.MultiChoice:
-		questionType = "多选题"
-	case models.Programming:
-		questionType = "编程题"
+// 构建提示语，instructions是配置中附加的出题要求
+func buildBatchPrompt(req *models.QuestionRequest, count int, instructions string) string {
+	spec, ok := models.LookupQuestionType(req.GetQuestionType())
+	if !ok {
+		spec, _ = models.LookupQuestionType(models.SingleChoice)
 	}
 
 	var difficultyLevel string
@@ -104,96 +204,224 @@ func buildBatchPrompt(req *models.QuestionRequest, count int) string {
 	language := string(req.GetLanguage())
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("生成%d道%s难度，关于%s编程语言的%s", count, difficultyLevel, language, questionType))
+	sb.WriteString(fmt.Sprintf("生成%d道%s难度，关于%s编程语言的%s", count, difficultyLevel, language, spec.Name))
 	sb.WriteString("。\n\n")
 	sb.WriteString("要求严格按照以下格式：\n")
 
-	if req.GetQuestionType() == models.Programming {
-		sb.WriteString("1. 只需提供题目要求描述，不要提供任何代码或解答\n")
-		sb.WriteString("2. 题目要符合编程语言特性和实际应用场景\n")
-		sb.WriteString("3. 题目要求要清晰、明确且具体\n")
-		sb.WriteString("4. 你的回答必须是一个有效的JSON对象，不包含任何额外文字\n")
-		sb.WriteString("5. 输出格式必须严格遵循：\n")
-		sb.WriteString(`{
-  "questions": [
-    {
-      "title": "详细描述编程题目要求，包括输入、输出要求和约束条件",
-      "options": [],
-      "right": [],
-      "code": ""
-    },
-    // 更多题目...
-  ]
-}`)
-		sb.WriteString("\n\n注意：编程题不需要提供代码，code字段留空。\n")
-	} else {
-		sb.WriteString("1. 每个题目必须包含一个题干和四个选项(A, B, C, D)\n")
-		sb.WriteString("2. 题目要符合编程语言特性和实际应用场景\n")
-		sb.WriteString("3. 必须明确标明正确答案\n")
-		sb.WriteString("4. 你的回答必须是一个有效的JSON对象，不包含任何额外文字\n")
-		sb.WriteString("5. 输出格式必须严格遵循：\n")
-		sb.WriteString(`{
-  "questions": [
-    {
-      "title": "题目内容",
-      "options": ["选项A内容", "选项B内容", "选项C内容", "选项D内容"],
-      "right": [答案索引]
-    },
-    // 更多题目...
-  ]
-}`)
-		sb.WriteString("\n\n说明：right数组中的数字是正确答案的索引，0代表A，1代表B，2代表C，3代表D。单选题只有一个答案，如[1]表示B是正确答案；多选题要求必须有多个答案，如[0,2]表示A和C是正确答案。\n\n")
-		if req.GetQuestionType() == models.MultiChoice {
-			sb.WriteString("这是多选题！每个题目必须输出多个答案索引。\n")
-		} else {
-			sb.WriteString("这是单选题！每个题目只能输出一个答案索引。\n")
-		}
-	}
+	sb.WriteString(spec.Prompt)
 
-	sb.WriteString(fmt.Sprintf("请一次性返回包含%d个题目的JSON数组，不要有任何额外的文字说明，不要使用markdown格式。\n", count))
+	if instructions != "" {
+		sb.WriteString("\n其他要求：\n")
+		sb.WriteString(instructions)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString(fmt.Sprintf("请一次性返回一个JSON对象，其中的questions数组包含%d个题目，不要有任何额外的文字说明，不要使用markdown格式。\n", count))
 
 	return sb.String()
 }
 
+// 将错误原因数组对齐到选项数量，多余的截断，缺少的补空字符串
+func alignRationale(rationale []string, optionCount int) []string {
+	if len(rationale) == 0 || optionCount == 0 {
+		return nil
+	}
+
+	aligned := make([]string, optionCount)
+	copy(aligned, rationale)
+	return aligned
+}
+
 // 调用tongyi API
-func (c *AIClient) callTongyiAPIBatch(prompt string) (*models.AIBatchResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+func (c *AIClient) callTongyiAPIBatch(ctx context.Context, prompt string, userID string) (*models.AIBatchResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, ModelCallTimeout)
 	defer cancel()
 
+	call := chatCall{provider: models.Tongyi, purpose: models.PurposeGenerate, userID: userID}
+	content, mode, err := c.chatCompletion(ctx, call, prompt, batchResponseSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	// 结构化输出模式下先按Schema严格解析，失败再走文本解析
+	if mode != models.OutputText {
+		batchResponse, err := parseStructuredContent(content, mode)
+		if err == nil {
+			return batchResponse, nil
+		}
+		metrics.LLMParseFailures.WithLabelValues(string(models.Tongyi), "structured").Inc()
+		slog.WarnContext(ctx, "结构化输出解析失败，改用文本解析", "provider", models.Tongyi, "mode", mode, "error", err)
+	}
+
+	// 解析内容为题目对象数组
+	return parseBatchQuestionContent(ctx, models.Tongyi, content)
+}
+
+// 一次模型调用的来源信息，用于用量记录
+type chatCall struct {
+	provider models.ModelProvider
+	purpose  models.UsagePurpose
+	userID   string
+}
+
+// 向指定服务商发送一次对话请求，返回文本内容和实际使用的输出模式；
+// schema为空时json_schema模式降级为json_object。调用前检查配额，调用后记录用量
+func (c *AIClient) chatCompletion(ctx context.Context, call chatCall, prompt string, schema *jsonschema.Definition) (string, models.OutputMode, error) {
+	provider := call.provider
+	client, err := c.clientFor(provider)
+	if err != nil {
+		return "", "", err
+	}
+	name := providerDisplayNames[provider]
+
+	if c.usage != nil {
+		if err := c.usage.CheckQuota(call.userID); err != nil {
+			return "", "", err
+		}
+	}
+
+	prompts := c.promptConfig()
 	chatReq := openai.ChatCompletionRequest{
-		Model: "qwen-turbo",
+		Model: providerModelNames[provider],
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleUser,
 				Content: prompt,
 			},
 		},
-		Temperature: 0.1,
-		MaxTokens:   8000,
-		TopP:        0.95,
+		Temperature: float32(prompts.Temperature),
+		MaxTokens:   prompts.MaxTokens,
+		TopP:        float32(prompts.TopP),
 	}
 
-	resp, err := c.tongyiClient.CreateChatCompletion(ctx, chatReq)
+	mode := c.outputModes[provider]
+	if mode == models.OutputJSONSchema && schema == nil {
+		mode = models.OutputJSONObject
+	}
+	chatReq.ResponseFormat = buildResponseFormat(mode, schema)
+
+	startTime := time.Now()
+	resp, err := client.CreateChatCompletion(ctx, chatReq)
+	if err != nil && mode != models.OutputText && isBadRequest(err) {
+		// 服务商不认识response_format时退回纯文本模式重试一次
+		slog.WarnContext(ctx, "服务商不支持该输出模式，退回文本模式", "provider", provider, "mode", mode, "error", err)
+		mode = models.OutputText
+		chatReq.ResponseFormat = nil
+		resp, err = client.CreateChatCompletion(ctx, chatReq)
+	}
+	c.recordUsage(ctx, call, chatReq.Model, resp.Usage, time.Since(startTime), err == nil && len(resp.Choices) > 0)
 	if err != nil {
-		return nil, fmt.Errorf("发送批量请求到通义API失败: %w", err)
+		return "", mode, fmt.Errorf("发送请求到%sAPI失败: %w", name, err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("通义API响应没有包含结果")
+		return "", mode, fmt.Errorf("%sAPI响应没有包含结果", name)
 	}
 
 	// 提取内容
 	content := resp.Choices[0].Message.Content
 	if content == "" {
-		return nil, fmt.Errorf("通义API返回的内容为空")
+		return "", mode, fmt.Errorf("%sAPI返回的内容为空", name)
 	}
 
-	// 解析内容为题目对象数组
-	return parseBatchQuestionContent(content)
+	return content, mode, nil
 }
 
-// 解析批量模型返回的内容为题目数据数组
-func parseBatchQuestionContent(content string) (*models.AIBatchResponse, error) {
+// 记录一次调用的指标和用量，记录失败只打印日志，不影响出题
+func (c *AIClient) recordUsage(ctx context.Context, call chatCall, model string, usage openai.Usage, latency time.Duration, success bool) {
+	provider, purpose := string(call.provider), string(call.purpose)
+	outcome := "success"
+	if !success {
+		outcome = "error"
+	}
+	metrics.LLMRequestDuration.WithLabelValues(provider, purpose, outcome).Observe(latency.Seconds())
+	metrics.LLMTokens.WithLabelValues(provider, purpose, "prompt").Add(float64(usage.PromptTokens))
+	metrics.LLMTokens.WithLabelValues(provider, purpose, "completion").Add(float64(usage.CompletionTokens))
+
+	slog.InfoContext(ctx, "模型调用",
+		"provider", provider,
+		"model", model,
+		"purpose", purpose,
+		"user_id", call.userID,
+		"prompt_tokens", usage.PromptTokens,
+		"completion_tokens", usage.CompletionTokens,
+		"latency_ms", latency.Milliseconds(),
+		"success", success,
+	)
+
+	if c.usage == nil {
+		return
+	}
+
+	err := c.usage.Record(models.UsageRecord{
+		UserID:           call.userID,
+		Provider:         call.provider,
+		Model:            model,
+		Purpose:          call.purpose,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		LatencyMs:        latency.Milliseconds(),
+		Success:          success,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "记录用量失败", "error", err)
+	}
+}
+
+// 根据输出模式构建response_format参数，文本模式不发送
+func buildResponseFormat(mode models.OutputMode, schema *jsonschema.Definition) *openai.ChatCompletionResponseFormat {
+	switch mode {
+	case models.OutputJSONObject:
+		return &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	case models.OutputJSONSchema:
+		return &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "response",
+				Schema: schema,
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+// 判断是否为请求参数错误（如服务商不支持response_format）
+func isBadRequest(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusBadRequest
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode == http.StatusBadRequest
+	}
+	return false
+}
+
+// 严格解析结构化输出，json_schema模式下额外校验结构
+func parseStructuredContent(content string, mode models.OutputMode) (*models.AIBatchResponse, error) {
+	var batchResponse models.AIBatchResponse
+	var err error
+	if mode == models.OutputJSONSchema {
+		err = jsonschema.VerifySchemaAndUnmarshal(*batchResponseSchema, []byte(content), &batchResponse)
+	} else {
+		err = json.Unmarshal([]byte(content), &batchResponse)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(batchResponse.Questions) == 0 {
+		return nil, fmt.Errorf("API返回的题目数组为空")
+	}
+
+	return &batchResponse, nil
+}
+
+// 解析批量模型返回的内容为题目数据数组，provider仅用于日志和指标
+func parseBatchQuestionContent(ctx context.Context, provider models.ModelProvider, content string) (*models.AIBatchResponse, error) {
 	content = strings.TrimSpace(content)
 	if strings.HasPrefix(content, "```json") {
 		content = strings.TrimPrefix(content, "```json")
@@ -207,22 +435,21 @@ func parseBatchQuestionContent(content string) (*models.AIBatchResponse, error)
 
 	var batchResponse models.AIBatchResponse
 	err := json.Unmarshal([]byte(content), &batchResponse)
-	if err != nil {
-		questionsStart := strings.Index(content, "\"questions\"")
-		if questionsStart >= 0 {
-			fixedContent := "{" + content[questionsStart:] + "}"
-			err = json.Unmarshal([]byte(fixedContent), &batchResponse)
-			if err != nil {
-				return nil, fmt.Errorf("无法解析API返回的JSON内容: %w", err)
-			}
-		} else {
-			return nil, fmt.Errorf("无法解析API返回的内容，未找到questions字段: %w", err)
-		}
+	if err == nil && len(batchResponse.Questions) > 0 {
+		return &batchResponse, nil
 	}
 
-	if len(batchResponse.Questions) == 0 {
+	// 严格解析失败时宽松解析，尽量恢复被截断或格式不规范的输出
+	salvaged, report, salvageErr := salvageBatchQuestions(content)
+	if salvageErr != nil {
+		metrics.LLMParseFailures.WithLabelValues(string(provider), "failed").Inc()
+		if err != nil {
+			return nil, fmt.Errorf("无法解析API返回的JSON内容: %v; %w", err, salvageErr)
+		}
 		return nil, fmt.Errorf("API返回的题目数组为空")
 	}
 
-	return &batchResponse, nil
+	metrics.LLMParseFailures.WithLabelValues(string(provider), "salvaged").Inc()
+	slog.WarnContext(ctx, "宽松解析恢复部分题目", "provider", provider, "salvaged", report.Salvaged, "dropped", report.Dropped)
+	return salvaged, nil
 }
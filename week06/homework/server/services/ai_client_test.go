@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"question-generator/config"
+	"question-generator/mockllm"
+	"question-generator/models"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 创建指向假模型服务的客户端
+func newMockAIClient(t *testing.T, mode models.OutputMode) (*AIClient, *mockllm.Server) {
+	t.Helper()
+
+	mock, err := mockllm.NewServer()
+	if err != nil {
+		t.Fatalf("start mock server: %v", err)
+	}
+	t.Cleanup(func() { mock.Close() })
+
+	cfg := &config.Configuration{
+		QwenAPIKey:     "mock",
+		QwenAPIURL:     mock.URL,
+		QwenOutputMode: mode,
+		DeepSeekAPIKey: "mock",
+		DeepSeekAPIURL: mock.URL,
+		ReviewModel:    models.Tongyi,
+	}
+	return NewAIClient(cfg, nil), mock
+}
+
+func TestBatchGenerateQuestions(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording string // 为空时由假服务按提示语自动选择
+		qType     models.QuestionType
+		wantCount int
+		wantErr   string
+	}{
+		{name: "单选题", qType: models.SingleChoice, wantCount: 2},
+		{name: "多选题", qType: models.MultiChoice, wantCount: 1},
+		{name: "编程题", qType: models.Programming, wantCount: 1},
+		{name: "判断题", qType: models.TrueFalse, wantCount: 2},
+		{name: "填空题", qType: models.FillBlank, wantCount: 2},
+		{name: "简答题", qType: models.ShortAnswer, wantCount: 1},
+		{name: "markdown代码块", recording: "fenced", wantCount: 2},
+		{name: "回显注释和尾逗号", recording: "commented", wantCount: 1},
+		{name: "被截断的输出", recording: "truncated", wantCount: 1},
+		{name: "不是JSON", recording: "malformed", wantErr: "无法解析"},
+		{name: "空题目数组", recording: "empty_questions", wantErr: "题目数组为空"},
+		{name: "服务端错误", recording: "server_error", wantErr: "发送请求到通义API失败"},
+		{name: "限流", recording: "rate_limited", wantErr: "发送请求到通义API失败"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mock := newMockAIClient(t, "")
+			if tt.recording != "" {
+				mock.Enqueue(tt.recording)
+			}
+
+			req := &models.QuestionRequest{Type: tt.qType, Difficulty: models.Hard, Language: models.Python}
+			questions, err := client.BatchGenerateQuestions(context.Background(), req, 2, "tester")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BatchGenerateQuestions: %v", err)
+			}
+			if len(questions) != tt.wantCount {
+				t.Fatalf("got %d questions, want %d", len(questions), tt.wantCount)
+			}
+			for _, q := range questions {
+				if q.AIRes.Title == "" {
+					t.Errorf("empty title in %+v", q)
+				}
+				if q.Difficulty != models.Hard {
+					t.Errorf("difficulty = %d, want %d", q.Difficulty, models.Hard)
+				}
+				if q.AIStatus != string(models.Tongyi) {
+					t.Errorf("AIStatus = %q", q.AIStatus)
+				}
+				if err := q.Validate(); tt.qType != 0 && err != nil {
+					t.Errorf("generated question invalid: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchGenerateQuestionsExplanation(t *testing.T) {
+	client, _ := newMockAIClient(t, "")
+
+	questions, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 2, "tester")
+	if err != nil {
+		t.Fatalf("BatchGenerateQuestions: %v", err)
+	}
+
+	q := questions[0].AIRes
+	if q.Explanation == "" {
+		t.Error("missing explanation")
+	}
+	if len(q.Rationale) != len(q.Answer) {
+		t.Errorf("rationale has %d entries, want %d", len(q.Rationale), len(q.Answer))
+	}
+	if q.Rationale[q.Right[0]] != "" {
+		t.Errorf("correct option should have empty rationale, got %q", q.Rationale[q.Right[0]])
+	}
+}
+
+func TestBatchGenerateQuestionsPrompt(t *testing.T) {
+	client, mock := newMockAIClient(t, "")
+
+	req := &models.QuestionRequest{Type: models.MultiChoice, Difficulty: models.Easy, Language: models.Java}
+	if _, err := client.BatchGenerateQuestions(context.Background(), req, 50, "tester"); err != nil {
+		t.Fatalf("BatchGenerateQuestions: %v", err)
+	}
+
+	requests := mock.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	prompt := requests[0].Messages[0].Content
+	for _, want := range []string{"生成10道简单难度", "java", "多选题", "rationale"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q", want)
+		}
+	}
+	// 结尾的要求与解析器期望的对象格式一致
+	if !strings.Contains(prompt, "JSON对象，其中的questions数组包含10个题目") || strings.Contains(prompt, "JSON数组") {
+		t.Errorf("prompt trailer does not ask for the questions object: %q", prompt)
+	}
+	if requests[0].Model != "qwen-turbo" {
+		t.Errorf("model = %q", requests[0].Model)
+	}
+}
+
+func TestOutputModes(t *testing.T) {
+	tests := []struct {
+		mode       models.OutputMode
+		wantFormat openai.ChatCompletionResponseFormatType
+	}{
+		{mode: "", wantFormat: openai.ChatCompletionResponseFormatTypeJSONObject},
+		{mode: models.OutputText},
+		{mode: models.OutputJSONObject, wantFormat: openai.ChatCompletionResponseFormatTypeJSONObject},
+		{mode: models.OutputJSONSchema, wantFormat: openai.ChatCompletionResponseFormatTypeJSONSchema},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			client, mock := newMockAIClient(t, tt.mode)
+			if _, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 2, "tester"); err != nil {
+				t.Fatalf("BatchGenerateQuestions: %v", err)
+			}
+
+			format := mock.Requests()[0].ResponseFormat
+			if tt.wantFormat == "" {
+				if format != nil {
+					t.Fatalf("response_format = %+v, want none", format)
+				}
+				return
+			}
+			if format == nil || format.Type != tt.wantFormat {
+				t.Fatalf("response_format = %+v, want %s", format, tt.wantFormat)
+			}
+			if tt.wantFormat == openai.ChatCompletionResponseFormatTypeJSONSchema && format.JSONSchema == nil {
+				t.Fatal("json_schema mode without schema")
+			}
+		})
+	}
+}
+
+// DeepSeek的输出模式单独配置，不影响通义千问
+func TestDeepSeekOutputMode(t *testing.T) {
+	mock, err := mockllm.NewServer()
+	if err != nil {
+		t.Fatalf("start mock server: %v", err)
+	}
+	t.Cleanup(func() { mock.Close() })
+
+	client := NewAIClient(&config.Configuration{
+		QwenAPIKey:         "mock",
+		QwenAPIURL:         mock.URL,
+		DeepSeekAPIKey:     "mock",
+		DeepSeekAPIURL:     mock.URL,
+		DeepSeekOutputMode: models.OutputText,
+		ReviewModel:        models.Tongyi,
+	}, nil)
+
+	questions, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 2, "tester")
+	if err != nil {
+		t.Fatalf("BatchGenerateQuestions: %v", err)
+	}
+	if err := client.ReviewQuestions(context.Background(), models.DeepSeek, "tester", questions); err != nil {
+		t.Fatalf("ReviewQuestions: %v", err)
+	}
+
+	requests := mock.Requests()
+	if format := requests[0].ResponseFormat; format == nil || format.Type != openai.ChatCompletionResponseFormatTypeJSONObject {
+		t.Errorf("qwen response_format = %+v, want json_object", format)
+	}
+	if format := requests[1].ResponseFormat; requests[1].Model != "deepseek-chat" || format != nil {
+		t.Errorf("deepseek model = %q, response_format = %+v, want none", requests[1].Model, format)
+	}
+}
+
+func TestResponseFormatFallback(t *testing.T) {
+	client, mock := newMockAIClient(t, models.OutputJSONSchema)
+	mock.Enqueue("reject_response_format")
+
+	questions, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 2, "tester")
+	if err != nil {
+		t.Fatalf("BatchGenerateQuestions: %v", err)
+	}
+	if len(questions) != 2 {
+		t.Fatalf("got %d questions, want 2", len(questions))
+	}
+
+	requests := mock.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(requests))
+	}
+	if requests[0].ResponseFormat == nil || requests[1].ResponseFormat != nil {
+		t.Errorf("want retry without response_format, got %+v then %+v", requests[0].ResponseFormat, requests[1].ResponseFormat)
+	}
+}
+
+func TestMissingAPIKey(t *testing.T) {
+	client := NewAIClient(&config.Configuration{}, nil)
+	if _, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 1, "tester"); err == nil {
+		t.Fatal("want error without API key")
+	}
+}
+
+func TestReviewQuestions(t *testing.T) {
+	client, mock := newMockAIClient(t, "")
+
+	questions, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 2, "tester")
+	if err != nil {
+		t.Fatalf("BatchGenerateQuestions: %v", err)
+	}
+	if err := client.ReviewQuestions(context.Background(), models.DeepSeek, "tester", questions); err != nil {
+		t.Fatalf("ReviewQuestions: %v", err)
+	}
+
+	reviewReq := mock.Requests()[1]
+	if reviewReq.Model != "deepseek-chat" {
+		t.Errorf("review model = %q", reviewReq.Model)
+	}
+	prompt := reviewReq.Messages[0].Content
+	if strings.Contains(prompt, questions[0].AIRes.Explanation) {
+		t.Error("review prompt leaks the explanation")
+	}
+
+	first, second := questions[0].AIRes.Review, questions[1].AIRes.Review
+	if first == nil || second == nil {
+		t.Fatalf("missing reviews: %+v %+v", first, second)
+	}
+	if first.Disagree || first.Score != 9 {
+		t.Errorf("first review = %+v", first)
+	}
+	if !second.Disagree || second.Score != 4 || second.Reviewer != models.DeepSeek {
+		t.Errorf("second review = %+v", second)
+	}
+}
+
+func TestReviewQuestionsMalformed(t *testing.T) {
+	client, mock := newMockAIClient(t, "")
+
+	questions, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 2, "tester")
+	if err != nil {
+		t.Fatalf("BatchGenerateQuestions: %v", err)
+	}
+
+	mock.Enqueue("malformed")
+	if err := client.ReviewQuestions(context.Background(), "", "tester", questions); err == nil {
+		t.Fatal("want error for malformed review")
+	}
+	if questions[0].AIRes.Review != nil {
+		t.Error("review attached despite failure")
+	}
+}
+
+func TestBatchGenerateQuestionsCanceled(t *testing.T) {
+	client, mock := newMockAIClient(t, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.BatchGenerateQuestions(ctx, &models.QuestionRequest{}, 2, "tester")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if len(mock.Requests()) != 0 {
+		t.Errorf("canceled request reached the provider")
+	}
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"question-generator/config"
+	"question-generator/metrics"
+	"question-generator/models"
+	"sort"
+	"time"
+)
+
+// 按答对比例划分难度：不低于easyThreshold为简单，不高于hardThreshold为困难
+const (
+	easyThreshold = 0.7
+	hardThreshold = 0.3
+)
+
+// 根据保存的答卷对题目做经典测量理论分析
+type ItemAnalysisService struct {
+	storage *StorageService
+	cfg     config.ItemAnalysisConfig
+	now     func() time.Time
+}
+
+// 创建题目分析服务
+func NewItemAnalysisService(storage *StorageService, cfg config.ItemAnalysisConfig) *ItemAnalysisService {
+	return &ItemAnalysisService{
+		storage: storage,
+		cfg:     cfg,
+		now:     time.Now,
+	}
+}
+
+// 一次作答，以及考生在同一份试卷其余题目上的得分情况
+type itemResponse struct {
+	selected  []int
+	correct   bool
+	restTotal int // 试卷中其余题目的数量
+	restRight int // 其余题目答对的数量
+}
+
+// 分析所有被作答过的题目，flagged为true时只返回有问题的题目。
+// bankIDs为nil时不限制题库，为空时没有可分析的题目
+func (s *ItemAnalysisService) Analyze(flagged bool, bankIDs []int64) (*models.ItemAnalysisReport, error) {
+	responses, err := s.loadResponses(nil, bankIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ItemAnalysisReport{
+		GeneratedAt:  s.now(),
+		MinResponses: s.cfg.MinResponses,
+		Items:        []models.ItemAnalysis{},
+	}
+	for _, id := range sortedIDs(responses) {
+		item, err := s.analyzeQuestion(id, responses[id])
+		if err != nil {
+			return nil, err
+		}
+		if item == nil || (flagged && len(item.Flags) == 0) {
+			continue
+		}
+		report.Items = append(report.Items, *item)
+	}
+	return report, nil
+}
+
+// 分析单道题，题目没有被作答过时各项指标为零
+func (s *ItemAnalysisService) AnalyzeQuestion(id int64) (*models.ItemAnalysis, error) {
+	responses, err := s.loadResponses([]int64{id}, nil)
+	if err != nil {
+		return nil, err
+	}
+	item, err := s.analyzeQuestion(id, responses[id])
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, fmt.Errorf("%w: ID=%d", ErrQuestionNotFound, id)
+	}
+	return item, nil
+}
+
+// 把作答次数足够且实测难度与标注不符的题目改为推算的难度，ids为空时处理所有题目
+func (s *ItemAnalysisService) Recalibrate(ids []int64, dryRun bool) (*models.RecalibrateResult, error) {
+	responses, err := s.loadResponses(ids, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.RecalibrateResult{DryRun: dryRun, Changes: []models.DifficultyChange{}}
+	for _, id := range sortedIDs(responses) {
+		item, err := s.analyzeQuestion(id, responses[id])
+		if err != nil {
+			return nil, err
+		}
+		if item == nil || !hasFlag(item.Flags, models.FlagDifficultyMismatch) {
+			continue
+		}
+		result.Changes = append(result.Changes, models.DifficultyChange{
+			QuestionID:      id,
+			From:            item.Difficulty,
+			To:              item.SuggestedDifficulty,
+			DifficultyIndex: item.DifficultyIndex,
+			Responses:       item.Responses,
+		})
+	}
+
+	if dryRun || len(result.Changes) == 0 {
+		return result, nil
+	}
+	if err := s.applyDifficulty(result.Changes); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// 估计题目的项目反应理论参数。作答次数达到最少次数的题目由作答记录估计，
+// 其余题目由标注难度推算
+func (s *ItemAnalysisService) ItemParams(questions []models.QuestionData) (map[int64]models.ItemParams, error) {
+	params := make(map[int64]models.ItemParams, len(questions))
+	if len(questions) == 0 {
+		return params, nil
+	}
+
+	// 题目较多时直接读取全部作答记录，避免超出SQLite的参数个数限制
+	var ids []int64
+	if len(questions) <= tagQueryBatch {
+		for _, q := range questions {
+			ids = append(ids, q.ID)
+		}
+	}
+	responses, err := s.loadResponses(ids, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, q := range questions {
+		rs := responses[q.ID]
+		if len(rs) == 0 || len(rs) < s.cfg.MinResponses {
+			params[q.ID] = paramsFromLabel(q.Difficulty)
+			continue
+		}
+		right := 0
+		for _, r := range rs {
+			if r.correct {
+				right++
+			}
+		}
+		params[q.ID] = paramsFromStats(float64(right)/float64(len(rs)), pointBiserial(rs))
+	}
+	return params, nil
+}
+
+// 交卷后按配置自动重新标定这份试卷中的题目，失败时只记录日志
+func (s *ItemAnalysisService) AfterExam(result *models.ExamResult) {
+	if !s.cfg.AutoRecalibrate || len(result.Results) == 0 {
+		return
+	}
+
+	ids := make([]int64, 0, len(result.Results))
+	for _, item := range result.Results {
+		ids = append(ids, item.QuestionID)
+	}
+	recalibrated, err := s.Recalibrate(ids, false)
+	if err != nil {
+		slog.Warn("自动重新标定难度失败", "error", err)
+		return
+	}
+	for _, change := range recalibrated.Changes {
+		slog.Info("自动重新标定难度", "question_id", change.QuestionID, "from", change.From, "to", change.To, "difficulty_index", change.DifficultyIndex)
+	}
+}
+
+func (s *ItemAnalysisService) applyDifficulty(changes []models.DifficultyChange) error {
+	defer metrics.ObserveDB("recalibrate")()
+
+	tx, err := s.storage.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, change := range changes {
+		if _, err := tx.Exec("UPDATE questions SET difficulty = ? WHERE id = ?", int(change.To), change.QuestionID); err != nil {
+			return fmt.Errorf("更新题目难度失败: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// 读取作答记录，按题目分组。ids为空时读取所有题目的作答，
+// bankIDs不为nil时只读取这些题库中的题目
+func (s *ItemAnalysisService) loadResponses(ids, bankIDs []int64) (map[int64][]itemResponse, error) {
+	defer metrics.ObserveDB("load_responses")()
+
+	query := `SELECT r.question_id, r.selected, r.correct, a.total, a.correct
+	FROM exam_responses r
+	JOIN exam_attempts a ON a.id = r.attempt_id
+	WHERE r.pending = 0`
+	var args []interface{}
+	if len(ids) > 0 {
+		query += " AND r.question_id IN (" + sqlPlaceholders(len(ids)) + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	if bankIDs != nil {
+		if len(bankIDs) == 0 {
+			return map[int64][]itemResponse{}, nil
+		}
+		query += " AND r.question_id IN (SELECT id FROM questions WHERE bank_id IN (" + sqlPlaceholders(len(bankIDs)) + "))"
+		for _, id := range bankIDs {
+			args = append(args, id)
+		}
+	}
+
+	rows, err := s.storage.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询作答记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	responses := make(map[int64][]itemResponse)
+	for rows.Next() {
+		var id int64
+		var selected string
+		var r itemResponse
+		var total, right int
+		if err := rows.Scan(&id, &selected, &r.correct, &total, &right); err != nil {
+			return nil, fmt.Errorf("扫描作答记录失败: %w", err)
+		}
+		json.Unmarshal([]byte(selected), &r.selected)
+
+		r.restTotal, r.restRight = total-1, right
+		if r.correct {
+			r.restRight--
+		}
+		responses[id] = append(responses[id], r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询作答记录失败: %w", err)
+	}
+	return responses, nil
+}
+
+// 计算单道题的各项指标，题目已被删除时返回nil
+func (s *ItemAnalysisService) analyzeQuestion(id int64, responses []itemResponse) (*models.ItemAnalysis, error) {
+	question, err := s.storage.GetQuestionByID(id)
+	if errors.Is(err, ErrQuestionNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item := &models.ItemAnalysis{
+		QuestionID: id,
+		Title:      question.AIRes.Title,
+		Type:       question.AIReq.Type,
+		Difficulty: question.Difficulty,
+		Responses:  len(responses),
+		Options:    make([]models.OptionStats, len(question.AIRes.Answer)),
+		Flags:      []models.ItemFlag{},
+	}
+	for i := range item.Options {
+		item.Options[i] = models.OptionStats{Index: i, Right: containsInt(question.AIRes.Right, i)}
+	}
+
+	if len(responses) == 0 {
+		item.SuggestedDifficulty = item.Difficulty
+		return item, nil
+	}
+
+	right := 0
+	for _, r := range responses {
+		if r.correct {
+			right++
+		}
+		for _, idx := range r.selected {
+			if idx >= 0 && idx < len(item.Options) {
+				item.Options[idx].Count++
+			}
+		}
+	}
+	for i := range item.Options {
+		item.Options[i].Rate = float64(item.Options[i].Count) / float64(len(responses))
+	}
+
+	item.DifficultyIndex = float64(right) / float64(len(responses))
+	item.SuggestedDifficulty = difficultyFromIndex(item.DifficultyIndex)
+	item.Discrimination = pointBiserial(responses)
+	item.Reliable = len(responses) >= s.cfg.MinResponses
+
+	if item.Reliable {
+		if item.SuggestedDifficulty != item.Difficulty {
+			item.Flags = append(item.Flags, models.FlagDifficultyMismatch)
+		}
+		if item.Discrimination != nil && *item.Discrimination < 0 {
+			item.Flags = append(item.Flags, models.FlagNegativeDiscrimination)
+		}
+	}
+	return item, nil
+}
+
+// 由答对比例推算难度
+func difficultyFromIndex(p float64) models.QuestionDifficulty {
+	switch {
+	case p >= easyThreshold:
+		return models.Easy
+	case p <= hardThreshold:
+		return models.Hard
+	default:
+		return models.Medium
+	}
+}
+
+// 计算题目得分与其余题目得分率之间的点二列相关系数。
+// 只有一道题的试卷没有其余得分，不参与计算
+func pointBiserial(responses []itemResponse) *float64 {
+	var scores []float64
+	var right []bool
+	for _, r := range responses {
+		if r.restTotal <= 0 {
+			continue
+		}
+		scores = append(scores, float64(r.restRight)/float64(r.restTotal))
+		right = append(right, r.correct)
+	}
+
+	n := float64(len(scores))
+	if n == 0 {
+		return nil
+	}
+
+	var sum, sumRight float64
+	var nRight float64
+	for i, score := range scores {
+		sum += score
+		if right[i] {
+			sumRight += score
+			nRight++
+		}
+	}
+	nWrong := n - nRight
+	if nRight == 0 || nWrong == 0 {
+		return nil
+	}
+
+	mean := sum / n
+	var variance float64
+	for _, score := range scores {
+		variance += (score - mean) * (score - mean)
+	}
+	sd := math.Sqrt(variance / n)
+	if sd == 0 {
+		return nil
+	}
+
+	meanRight := sumRight / nRight
+	meanWrong := (sum - sumRight) / nWrong
+	p := nRight / n
+	r := (meanRight - meanWrong) / sd * math.Sqrt(p*(1-p))
+	return &r
+}
+
+func sortedIDs(responses map[int64][]itemResponse) []int64 {
+	ids := make([]int64, 0, len(responses))
+	for id := range responses {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func hasFlag(flags []models.ItemFlag, flag models.ItemFlag) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
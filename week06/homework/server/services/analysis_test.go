@@ -0,0 +1,138 @@
+package services
+
+import (
+	"question-generator/config"
+	"question-generator/models"
+	"reflect"
+	"testing"
+)
+
+func TestItemAnalysis(t *testing.T) {
+	storage := newTestStorage(t)
+	analysis := NewItemAnalysisService(storage, config.ItemAnalysisConfig{MinResponses: 4})
+
+	add := func(title string, right int) int64 {
+		t.Helper()
+		id, err := storage.AddQuestion(choiceQuestion(title, models.SingleChoice, right))
+		if err != nil {
+			t.Fatalf("AddQuestion: %v", err)
+		}
+		return id
+	}
+	anchor1, anchor2 := add("锚题1", 0), add("锚题2", 0)
+	easy := add("所有人都答对", 0)
+	good := add("区分度正常", 1)
+	bad := add("区分度为负", 3)
+
+	// 两名考生答对锚题，两名答错。good只有前者答对，bad只有后者答对
+	submit := func(user string, strong bool) {
+		t.Helper()
+		pick := func(ok bool, right, wrong int) []int {
+			if ok {
+				return []int{right}
+			}
+			return []int{wrong}
+		}
+		answers := []models.ExamAnswer{
+			{QuestionID: anchor1, Selected: pick(strong, 0, 1)},
+			{QuestionID: anchor2, Selected: pick(strong, 0, 1)},
+			{QuestionID: easy, Selected: []int{0}},
+			{QuestionID: good, Selected: pick(strong, 1, 2)},
+			{QuestionID: bad, Selected: pick(!strong, 3, 0)},
+		}
+		if _, err := storage.GradeExam(user, answers); err != nil {
+			t.Fatalf("GradeExam: %v", err)
+		}
+	}
+	submit("s1", true)
+	submit("s2", true)
+	submit("w1", false)
+
+	// 作答次数不足时不标记问题
+	item, err := analysis.AnalyzeQuestion(easy)
+	if err != nil {
+		t.Fatalf("AnalyzeQuestion: %v", err)
+	}
+	if item.Reliable || len(item.Flags) != 0 || item.SuggestedDifficulty != models.Easy {
+		t.Errorf("unreliable item: %+v", item)
+	}
+
+	submit("w2", false)
+
+	item, _ = analysis.AnalyzeQuestion(good)
+	if item.Responses != 4 || item.DifficultyIndex != 0.5 || item.Discrimination == nil || *item.Discrimination <= 0 || len(item.Flags) != 0 {
+		t.Errorf("good item: %+v", item)
+	}
+	wantOptions := []models.OptionStats{
+		{Index: 0},
+		{Index: 1, Right: true, Count: 2, Rate: 0.5},
+		{Index: 2, Count: 2, Rate: 0.5},
+		{Index: 3},
+	}
+	if !reflect.DeepEqual(item.Options, wantOptions) {
+		t.Errorf("options = %+v", item.Options)
+	}
+
+	// 全部答对时无法计算区分度
+	item, _ = analysis.AnalyzeQuestion(easy)
+	if item.Discrimination != nil || !reflect.DeepEqual(item.Flags, []models.ItemFlag{models.FlagDifficultyMismatch}) {
+		t.Errorf("easy item: %+v", item)
+	}
+
+	report, err := analysis.Analyze(true, nil)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	var flagged []int64
+	for _, item := range report.Items {
+		flagged = append(flagged, item.QuestionID)
+	}
+	// 锚题一半答对，难度与标注的中等一致
+	if !reflect.DeepEqual(flagged, []int64{easy, bad}) {
+		t.Errorf("flagged = %v", flagged)
+	}
+	if flags := report.Items[1].Flags; !reflect.DeepEqual(flags, []models.ItemFlag{models.FlagNegativeDiscrimination}) {
+		t.Errorf("bad item flags = %v", flags)
+	}
+
+	// 预演不修改难度
+	result, err := analysis.Recalibrate(nil, true)
+	if err != nil {
+		t.Fatalf("Recalibrate: %v", err)
+	}
+	want := []models.DifficultyChange{{QuestionID: easy, From: models.Medium, To: models.Easy, DifficultyIndex: 1, Responses: 4}}
+	if !reflect.DeepEqual(result.Changes, want) {
+		t.Fatalf("changes = %+v", result.Changes)
+	}
+	if q, _ := storage.GetQuestionByID(easy); q.Difficulty != models.Medium {
+		t.Fatalf("dry run changed difficulty to %d", q.Difficulty)
+	}
+
+	if _, err := analysis.Recalibrate(nil, false); err != nil {
+		t.Fatalf("Recalibrate: %v", err)
+	}
+	if q, _ := storage.GetQuestionByID(easy); q.Difficulty != models.Easy {
+		t.Errorf("difficulty = %d, want easy", q.Difficulty)
+	}
+	if result, _ := analysis.Recalibrate(nil, false); len(result.Changes) != 0 {
+		t.Errorf("second recalibration: %+v", result.Changes)
+	}
+}
+
+func TestItemAnalysisAutoRecalibrate(t *testing.T) {
+	storage := newTestStorage(t)
+	analysis := NewItemAnalysisService(storage, config.ItemAnalysisConfig{MinResponses: 2, AutoRecalibrate: true})
+
+	id, _ := storage.AddQuestion(choiceQuestion("太难", models.SingleChoice, 0))
+	for i := 0; i < 2; i++ {
+		result, err := storage.GradeExam("alice", []models.ExamAnswer{{QuestionID: id, Selected: []int{1}}})
+		if err != nil {
+			t.Fatalf("GradeExam: %v", err)
+		}
+		analysis.AfterExam(result)
+	}
+
+	if q, _ := storage.GetQuestionByID(id); q.Difficulty != models.Hard {
+		t.Errorf("difficulty = %d, want hard", q.Difficulty)
+	}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"question-generator/config"
+	"question-generator/metrics"
+	"question-generator/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAttachmentNotFound    = errors.New("图片不存在")
+	ErrAttachmentTooLarge    = errors.New("图片太大")
+	ErrUnsupportedAttachment = errors.New("不支持的图片格式")
+)
+
+// 允许上传的图片类型，按文件内容判断而不是扩展名。
+// SVG可以携带脚本，不在允许范围内
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// 清理未被引用图片的间隔
+const attachmentSweepInterval = time.Hour
+
+// 管理题目中引用的图片。图片按内容的SHA-256保存，相同的图片只存一份；
+// 题目与图片的关联在保存题目时由StorageService维护
+type AttachmentService struct {
+	db        *sql.DB
+	dir       string
+	maxBytes  int64
+	orphanTTL time.Duration
+	mu        sync.Mutex // 上传与清理互斥，避免清理掉刚上传的同一张图片
+	now       func() time.Time
+}
+
+// 创建图片服务，图片目录默认在数据目录下
+func NewAttachmentService(storage *StorageService, cfg config.AttachmentConfig) *AttachmentService {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join(storage.DataDir, "attachments")
+	}
+	return &AttachmentService{
+		db:        storage.DB,
+		dir:       dir,
+		maxBytes:  cfg.MaxBytes,
+		orphanTTL: cfg.OrphanTTL,
+		now:       time.Now,
+	}
+}
+
+// 图片文件的路径，用哈希的前两级分目录，避免单个目录下文件过多
+func (s *AttachmentService) path(id string) string {
+	return filepath.Join(s.dir, id[:2], id[2:4], id)
+}
+
+// 保存上传的图片，类型由内容判断。内容相同的图片返回已有的记录，
+// 并刷新上传时间，使其重新获得保留期
+func (s *AttachmentService) Upload(r io.Reader, name string) (*models.Attachment, error) {
+	defer metrics.ObserveDB("upload_attachment")()
+
+	data, err := io.ReadAll(io.LimitReader(r, s.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %w", err)
+	}
+	if int64(len(data)) > s.maxBytes {
+		return nil, fmt.Errorf("%w: 不能超过%d字节", ErrAttachmentTooLarge, s.maxBytes)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: 文件为空", ErrUnsupportedAttachment)
+	}
+
+	mimeType := http.DetectContentType(data)
+	if !allowedAttachmentTypes[mimeType] {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAttachment, mimeType)
+	}
+
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+	name = filepath.Base(strings.TrimSpace(name))
+	if name == "." || name == string(filepath.Separator) {
+		name = ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.writeFile(id, data); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	_, err = s.db.Exec(`INSERT INTO attachments (id, name, mime_type, size, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET created_at = excluded.created_at`,
+		id, name, mimeType, len(data), now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("保存图片记录失败: %w", err)
+	}
+	return s.Get(id)
+}
+
+// 先写临时文件再改名，中途失败不会留下不完整的图片。文件已存在时不重复写入
+func (s *AttachmentService) writeFile(id string, data []byte) error {
+	path := s.path(id)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("无法创建图片目录: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), id+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("无法保存图片: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, bytes.NewReader(data)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("无法保存图片: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("无法保存图片: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("无法保存图片: %w", err)
+	}
+	return nil
+}
+
+// 查询图片信息
+func (s *AttachmentService) Get(id string) (*models.Attachment, error) {
+	var a models.Attachment
+	var createdAt int64
+	err := s.db.QueryRow("SELECT id, name, mime_type, size, created_at FROM attachments WHERE id = ?", id).
+		Scan(&a.ID, &a.Name, &a.MimeType, &a.Size, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询图片失败: %w", err)
+	}
+	a.CreatedAt = time.Unix(createdAt, 0)
+	a.URL = models.AttachmentURL(a.ID)
+	return &a, nil
+}
+
+// 打开图片文件，调用方负责关闭
+func (s *AttachmentService) Open(id string) (*models.Attachment, *os.File, error) {
+	a, err := s.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(s.path(a.ID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w: 文件已丢失", ErrAttachmentNotFound)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开图片失败: %w", err)
+	}
+	return a, f, nil
+}
+
+// 删除没有被任何题目引用、且超过保留期的图片，返回删除的数量。
+// 刚上传还没保存到题目中的图片在保留期内不会被删除
+func (s *AttachmentService) Sweep() (int, error) {
+	defer metrics.ObserveDB("sweep_attachments")()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-s.orphanTTL).Unix()
+	rows, err := s.db.Query(`DELETE FROM attachments
+		WHERE created_at < ?
+		AND id NOT IN (SELECT attachment_id FROM question_attachments)
+		RETURNING id`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("清理图片记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return 0, fmt.Errorf("清理图片记录失败: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("清理图片记录失败: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("删除图片文件失败", "attachment_id", id, "error", err)
+		}
+	}
+	return len(ids), nil
+}
+
+// 定期清理未被引用的图片，直到ctx取消
+func (s *AttachmentService) Run(ctx context.Context) {
+	ticker := time.NewTicker(attachmentSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.Sweep(); err != nil {
+			slog.Warn("清理图片失败", "error", err)
+		} else if n > 0 {
+			slog.Info("已清理未被引用的图片", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 用refs替换题目引用的全部图片，引用了不存在的图片时返回ErrAttachmentNotFound
+func linkAttachments(tx *sql.Tx, questionID int64, refs []string) error {
+	if _, err := tx.Exec("DELETE FROM question_attachments WHERE question_id = ?", questionID); err != nil {
+		return fmt.Errorf("删除图片引用失败: %w", err)
+	}
+
+	for _, id := range refs {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM attachments WHERE id = ?)", id).Scan(&exists); err != nil {
+			return fmt.Errorf("查询图片失败: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrAttachmentNotFound, id)
+		}
+		if _, err := tx.Exec("INSERT INTO question_attachments (question_id, attachment_id) VALUES (?, ?)", questionID, id); err != nil {
+			return fmt.Errorf("保存图片引用失败: %w", err)
+		}
+	}
+	return nil
+}
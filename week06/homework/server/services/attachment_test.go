@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"question-generator/config"
+	"question-generator/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 生成一张边长为size的PNG图片
+func pngImage(t *testing.T, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, size, size))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newTestAttachments(t *testing.T) (*AttachmentService, *StorageService, *time.Time) {
+	t.Helper()
+	storage := newTestStorage(t)
+	attachments := NewAttachmentService(storage, config.AttachmentConfig{MaxBytes: 4096, OrphanTTL: time.Hour})
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	attachments.now = func() time.Time { return now }
+	return attachments, storage, &now
+}
+
+func TestAttachmentUpload(t *testing.T) {
+	attachments, _, _ := newTestAttachments(t)
+	data := pngImage(t, 4)
+
+	a, err := attachments.Upload(bytes.NewReader(data), "../tree.png")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if len(a.ID) != 64 || a.MimeType != "image/png" || a.Size != int64(len(data)) || a.Name != "tree.png" {
+		t.Fatalf("attachment = %+v", a)
+	}
+	if a.URL != models.AttachmentURLPrefix+a.ID {
+		t.Errorf("url = %q", a.URL)
+	}
+
+	// 相同内容只保存一份，保留第一次的文件名
+	again, err := attachments.Upload(bytes.NewReader(data), "copy.png")
+	if err != nil || again.ID != a.ID || again.Name != "tree.png" {
+		t.Fatalf("second upload = %+v, %v", again, err)
+	}
+
+	_, f, err := attachments.Open(a.ID)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	stored, _ := os.ReadFile(f.Name())
+	if !bytes.Equal(stored, data) {
+		t.Error("stored content differs")
+	}
+}
+
+func TestAttachmentUploadRejected(t *testing.T) {
+	attachments, _, _ := newTestAttachments(t)
+
+	// 扩展名是png，内容是HTML
+	_, err := attachments.Upload(strings.NewReader("<html><script>alert(1)</script></html>"), "x.png")
+	if !errors.Is(err, ErrUnsupportedAttachment) {
+		t.Errorf("html: err = %v", err)
+	}
+	_, err = attachments.Upload(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "x.svg")
+	if !errors.Is(err, ErrUnsupportedAttachment) {
+		t.Errorf("svg: err = %v", err)
+	}
+	_, err = attachments.Upload(bytes.NewReader(append(pngImage(t, 1), make([]byte, 4096)...)), "big.png")
+	if !errors.Is(err, ErrAttachmentTooLarge) {
+		t.Errorf("large: err = %v", err)
+	}
+	if _, err := attachments.Get(strings.Repeat("0", 64)); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("Get missing: err = %v", err)
+	}
+}
+
+func TestAttachmentLinksAndSweep(t *testing.T) {
+	attachments, storage, now := newTestAttachments(t)
+	used, err := attachments.Upload(bytes.NewReader(pngImage(t, 2)), "used.png")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	orphan, err := attachments.Upload(bytes.NewReader(pngImage(t, 3)), "orphan.png")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	q := choiceQuestion("看图回答", models.SingleChoice, 0)
+	q.AIRes.Answer[1] = "![选项](" + used.URL + ")"
+	id, err := storage.AddQuestion(q)
+	if err != nil {
+		t.Fatalf("AddQuestion: %v", err)
+	}
+
+	// 引用不存在的图片时整道题都不保存
+	missing := choiceQuestion("缺图", models.SingleChoice, 0)
+	missing.AIRes.Title += " ![](" + models.AttachmentURL(strings.Repeat("a", 64)) + ")"
+	if _, err := storage.AddQuestion(missing); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Fatalf("AddQuestion with missing image: err = %v", err)
+	}
+	if err := storage.EditQuestion(id, missing); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Fatalf("EditQuestion with missing image: err = %v", err)
+	}
+
+	// 保留期内不清理
+	if n, err := attachments.Sweep(); err != nil || n != 0 {
+		t.Fatalf("Sweep within ttl = %d, %v", n, err)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	if n, err := attachments.Sweep(); err != nil || n != 1 {
+		t.Fatalf("Sweep = %d, %v, want 1", n, err)
+	}
+	if _, _, err := attachments.Open(orphan.ID); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("orphan still exists: %v", err)
+	}
+	if _, err := os.Stat(attachments.path(orphan.ID)); !os.IsNotExist(err) {
+		t.Errorf("orphan file still exists: %v", err)
+	}
+
+	// 删除题目后图片不再被引用，随下次清理删除
+	if err := storage.DeleteQuestions([]int64{id}); err != nil {
+		t.Fatalf("DeleteQuestions: %v", err)
+	}
+	if n, err := attachments.Sweep(); err != nil || n != 1 {
+		t.Fatalf("Sweep after delete = %d, %v, want 1", n, err)
+	}
+	if _, err := attachments.Get(used.ID); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("used attachment not swept: %v", err)
+	}
+}
+
+func TestAttachmentRefs(t *testing.T) {
+	a, b := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	q := models.QuestionData{AIRes: models.AIResponse{
+		Title:       "![图](" + models.AttachmentURL(b) + ") 和 ![图](" + models.AttachmentURL(a) + ")",
+		Answer:      []string{"![](" + models.AttachmentURL(a) + ")", "/api/attachments/short"},
+		Explanation: "见" + models.AttachmentURL(b),
+	}}
+	refs := q.AttachmentRefs()
+	if len(refs) != 2 || refs[0] != a || refs[1] != b {
+		t.Fatalf("refs = %v", refs)
+	}
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"question-generator/config"
+	"question-generator/metrics"
+	"question-generator/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// 快照文件名格式: questions-20060102-150405.000.db，恢复前的自动备份带-pre-restore后缀
+const (
+	backupPrefix     = "questions-"
+	backupSuffix     = ".db"
+	backupTimeLayout = "20060102-150405.000"
+)
+
+// 快照不存在或文件名无效
+var ErrBackupNotFound = errors.New("快照不存在")
+
+// 题库数据库的备份服务
+type BackupService struct {
+	db   *sql.DB
+	dir  string
+	keep int
+	now  func() time.Time
+	mu   sync.Mutex // 备份、轮转和恢复互斥执行
+}
+
+// 创建备份服务，快照目录默认放在数据目录下
+func NewBackupService(storage *StorageService, cfg config.BackupConfig) *BackupService {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join(storage.DataDir, "backups")
+	}
+	return &BackupService{
+		db:   storage.DB,
+		dir:  dir,
+		keep: cfg.Keep,
+		now:  time.Now,
+	}
+}
+
+// 对正在使用的数据库做一致性快照，并按保留数量删除旧快照
+func (b *BackupService) Backup(ctx context.Context) (*models.BackupInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.backup(ctx, "", true)
+}
+
+// 创建快照，rotate为true时随后删除超出保留数量的旧快照
+func (b *BackupService) backup(ctx context.Context, label string, rotate bool) (*models.BackupInfo, error) {
+	defer metrics.ObserveDB("backup")()
+
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return nil, fmt.Errorf("无法创建快照目录: %w", err)
+	}
+
+	name := backupPrefix + b.now().Format(backupTimeLayout) + label + backupSuffix
+	path := filepath.Join(b.dir, name)
+
+	// VACUUM INTO在一个读事务中写出完整的数据库副本，备份期间不阻塞其他读写
+	if _, err := b.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return nil, fmt.Errorf("创建快照失败: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取快照信息失败: %w", err)
+	}
+
+	if rotate {
+		if err := b.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.BackupInfo{Name: name, Size: info.Size(), CreatedAt: info.ModTime()}, nil
+}
+
+// 只保留最新的keep个快照
+func (b *BackupService) rotate() error {
+	if b.keep <= 0 {
+		return nil
+	}
+
+	backups, err := b.list()
+	if err != nil {
+		return err
+	}
+	for i := b.keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(b.dir, backups[i].Name)); err != nil {
+			return fmt.Errorf("删除旧快照失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// 列出所有快照，最新的在前
+func (b *BackupService) List() ([]models.BackupInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.list()
+}
+
+func (b *BackupService) list() ([]models.BackupInfo, error) {
+	entries, err := os.ReadDir(b.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []models.BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取快照目录失败: %w", err)
+	}
+
+	backups := make([]models.BackupInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !isBackupName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, models.BackupInfo{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+
+	// 文件名中的时间戳按字典序即按时间排序
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) && filepath.Base(name) == name
+}
+
+// 检查正在使用的数据库
+func (b *BackupService) IntegrityCheck(ctx context.Context) (*models.IntegrityReport, error) {
+	defer metrics.ObserveDB("integrity_check")()
+
+	return integrityCheck(ctx, b.db)
+}
+
+// 运行PRAGMA integrity_check，没有问题时只返回一行"ok"
+func integrityCheck(ctx context.Context, db *sql.DB) (*models.IntegrityReport, error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("完整性检查失败: %w", err)
+	}
+	defer rows.Close()
+
+	report := &models.IntegrityReport{Messages: []string{}}
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, fmt.Errorf("读取检查结果失败: %w", err)
+		}
+		report.Messages = append(report.Messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("完整性检查失败: %w", err)
+	}
+
+	report.OK = len(report.Messages) == 1 && report.Messages[0] == "ok"
+	return report, nil
+}
+
+// 用指定快照替换当前数据库。恢复前先检查快照的完整性，并自动备份当前数据库。
+// 只能在服务停止时执行：快照可能来自升级前的版本，缺少的表和列要在服务启动时重新迁移，
+// 运行中的服务也不会清理内存中的缓存
+func (b *BackupService) Restore(ctx context.Context, name string) (*models.RestoreResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isBackupName(name) {
+		return nil, ErrBackupNotFound
+	}
+	path := filepath.Join(b.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return nil, ErrBackupNotFound
+	}
+
+	report, err := checkBackupFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if !report.OK {
+		return nil, fmt.Errorf("快照已损坏: %s", strings.Join(report.Messages, "; "))
+	}
+
+	// 恢复前的备份不删除旧快照，否则快照数量已达到保留上限时会删掉正要恢复的最旧快照。
+	// 多出的快照在下一次备份时清理
+	pre, err := b.backup(ctx, "-pre-restore", false)
+	if err != nil {
+		return nil, fmt.Errorf("恢复前备份失败: %w", err)
+	}
+
+	if err := b.restore(ctx, path); err != nil {
+		return nil, err
+	}
+
+	return &models.RestoreResult{Restored: name, PreRestore: *pre}, nil
+}
+
+// 以只读方式打开文件的SQLite URI。路径需要转义，否则其中的?和#会被当作查询参数和片段
+func readOnlyURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows的盘符路径
+	}
+	u := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}
+	return u.String()
+}
+
+// 以只读方式打开快照并检查完整性
+func checkBackupFile(ctx context.Context, path string) (*models.IntegrityReport, error) {
+	db, err := sql.Open("sqlite", readOnlyURI(path))
+	if err != nil {
+		return nil, fmt.Errorf("无法打开快照: %w", err)
+	}
+	defer db.Close()
+
+	return integrityCheck(ctx, db)
+}
+
+// 通过SQLite的备份接口把快照逐页写回当前数据库
+func (b *BackupService) restore(ctx context.Context, path string) error {
+	defer metrics.ObserveDB("restore")()
+
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn interface{}) error {
+		restorer, ok := driverConn.(interface {
+			NewRestore(srcURI string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return errors.New("数据库驱动不支持在线恢复")
+		}
+
+		bk, err := restorer.NewRestore(readOnlyURI(path))
+		if err != nil {
+			return err
+		}
+		for {
+			more, err := bk.Step(-1)
+			if err != nil {
+				bk.Finish()
+				return err
+			}
+			if !more {
+				break
+			}
+		}
+		return bk.Finish()
+	})
+	if err != nil {
+		return fmt.Errorf("恢复快照失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"question-generator/config"
+	"question-generator/models"
+	"testing"
+	"time"
+)
+
+// 创建使用可控时钟的备份服务，每次备份时钟前进一秒
+func newTestBackup(t *testing.T, keep int) (*BackupService, *StorageService) {
+	t.Helper()
+
+	storage := newTestStorage(t)
+	backup := NewBackupService(storage, config.BackupConfig{Keep: keep})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	backup.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return backup, storage
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	backup, storage := newTestBackup(t, 0)
+
+	if _, err := storage.AddQuestion(choiceQuestion("备份前", 1, 0)); err != nil {
+		t.Fatalf("AddQuestion: %v", err)
+	}
+	snapshot, err := backup.Backup(ctx)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if snapshot.Name != "questions-20260101-000001.000.db" || snapshot.Size == 0 {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	if _, err := storage.AddQuestion(choiceQuestion("备份后", 1, 1)); err != nil {
+		t.Fatalf("AddQuestion: %v", err)
+	}
+
+	result, err := backup.Restore(ctx, snapshot.Name)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if result.PreRestore.Name != "questions-20260101-000002.000-pre-restore.db" {
+		t.Errorf("pre-restore backup: %+v", result.PreRestore)
+	}
+
+	questions, err := storage.GetAllQuestions()
+	if err != nil {
+		t.Fatalf("GetAllQuestions: %v", err)
+	}
+	if len(questions) != 1 || questions[0].AIRes.Title != "备份前" {
+		t.Fatalf("restored questions: %+v", questions)
+	}
+
+	// 恢复前的自动备份包含恢复前的数据
+	if _, err := backup.Restore(ctx, result.PreRestore.Name); err != nil {
+		t.Fatalf("Restore pre-restore: %v", err)
+	}
+	questions, _ = storage.GetAllQuestions()
+	if len(questions) != 2 {
+		t.Fatalf("got %d questions after undo, want 2", len(questions))
+	}
+
+	report, err := backup.IntegrityCheck(ctx)
+	if err != nil || !report.OK {
+		t.Fatalf("IntegrityCheck: %+v, %v", report, err)
+	}
+}
+
+func TestBackupRotation(t *testing.T) {
+	ctx := context.Background()
+	backup, _ := newTestBackup(t, 2)
+
+	for i := 0; i < 4; i++ {
+		if _, err := backup.Backup(ctx); err != nil {
+			t.Fatalf("Backup %d: %v", i, err)
+		}
+	}
+
+	backups, err := backup.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Name != "questions-20260101-000004.000.db" || backups[1].Name != "questions-20260101-000003.000.db" {
+		t.Fatalf("unexpected backups: %+v", backups)
+	}
+}
+
+func TestRestoreOldestAtKeepLimit(t *testing.T) {
+	ctx := context.Background()
+	backup, storage := newTestBackup(t, 2)
+
+	oldest, err := backup.Backup(ctx)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if _, err := storage.AddQuestion(choiceQuestion("第二个快照", 1, 0)); err != nil {
+		t.Fatalf("AddQuestion: %v", err)
+	}
+	if _, err := backup.Backup(ctx); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	// 快照数量已达到上限，恢复前的备份不能把要恢复的快照删掉
+	result, err := backup.Restore(ctx, oldest.Name)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if result.Restored != oldest.Name {
+		t.Errorf("restored %q", result.Restored)
+	}
+	questions, _ := storage.GetAllQuestions()
+	if len(questions) != 0 {
+		t.Fatalf("got %d questions after restoring the oldest snapshot, want 0", len(questions))
+	}
+
+	backups, err := backup.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(backups) != 3 || backups[2].Name != oldest.Name {
+		t.Fatalf("backups after restore: %+v", backups)
+	}
+
+	// 下一次备份时恢复回到保留数量
+	if _, err := backup.Backup(ctx); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if backups, _ = backup.List(); len(backups) != 2 {
+		t.Fatalf("backups after rotation: %+v", backups)
+	}
+}
+
+// 恢复升级前的快照后重新打开数据库，缺少的表和列会补齐；快照目录中的?和#不影响打开快照
+func TestRestoreOldSnapshotThenReopen(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	storage, err := OpenStorageService(dataDir)
+	if err != nil {
+		t.Fatalf("OpenStorageService: %v", err)
+	}
+	defer func() { storage.DB.Close() }()
+	dir := filepath.Join(t.TempDir(), "快照?v=1#旧")
+	backup := NewBackupService(storage, config.BackupConfig{Dir: dir})
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	// 旧版本的数据库只有最初的questions表
+	name := "questions-20250101-000000.000.db"
+	oldPath := filepath.Join(t.TempDir(), name)
+	old, err := sql.Open("sqlite", oldPath)
+	if err != nil {
+		t.Fatalf("open old snapshot: %v", err)
+	}
+	_, err = old.Exec(`CREATE TABLE questions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		question_type INTEGER NOT NULL,
+		difficulty INTEGER DEFAULT 2,
+		answer TEXT,
+		right_answer TEXT
+	);
+	INSERT INTO questions (title, question_type, answer, right_answer) VALUES ('旧题目', 1, '["A","B"]', '[0]');`)
+	old.Close()
+	if err != nil {
+		t.Fatalf("create old snapshot: %v", err)
+	}
+	if err := os.Rename(oldPath, filepath.Join(dir, name)); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	if _, err := backup.Restore(ctx, name); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	// 恢复后重新启动服务
+	storage.DB.Close()
+	if storage, err = OpenStorageService(dataDir); err != nil {
+		t.Fatalf("reopen after restore: %v", err)
+	}
+	questions, err := storage.GetAllQuestions()
+	if err != nil {
+		t.Fatalf("GetAllQuestions: %v", err)
+	}
+	if len(questions) != 1 || questions[0].AIRes.Title != "旧题目" || questions[0].BankID != models.DefaultBankID {
+		t.Fatalf("questions after reopen: %+v", questions)
+	}
+}
+
+func TestRestoreRejectsInvalidName(t *testing.T) {
+	backup, _ := newTestBackup(t, 0)
+
+	for _, name := range []string{"../questions.db", "questions-missing.db", "other.db"} {
+		if _, err := backup.Restore(context.Background(), name); !errors.Is(err, ErrBackupNotFound) {
+			t.Errorf("Restore(%q) = %v, want ErrBackupNotFound", name, err)
+		}
+	}
+}
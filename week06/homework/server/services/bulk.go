@@ -0,0 +1,204 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"question-generator/metrics"
+	"question-generator/models"
+)
+
+// 一次批量操作最多涉及的题目数
+const MaxBulkItems = 1000
+
+// 批量操作涉及的题目过多
+var ErrBulkTooMany = fmt.Errorf("一次最多批量修改%d道题目", MaxBulkItems)
+
+// 对指定ID的题目批量执行修改，ids为nil时修改filter匹配的全部题目。
+// 所有修改在一个事务中完成，任何一条语句出错时整体回滚；
+// 预演时同样在事务中执行修改以得到准确的结果，最后回滚不保存
+func (s *StorageService) BulkUpdate(ids []int64, filter *models.QuestionFilter, change models.BulkChange, dryRun bool) (*models.BulkResult, error) {
+	defer metrics.ObserveDB("bulk_update")()
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	var existing map[int64]bool
+	if ids == nil {
+		if ids, err = matchQuestionIDs(tx, filter); err != nil {
+			return nil, err
+		}
+		existing = make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			existing[id] = true
+		}
+	} else {
+		ids = uniqueIDs(ids)
+		if len(ids) > MaxBulkItems {
+			return nil, ErrBulkTooMany
+		}
+		if existing, err = existingQuestionIDs(tx, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &models.BulkResult{DryRun: dryRun, Items: make([]models.BulkItemResult, 0, len(ids))}
+	for _, id := range ids {
+		item := models.BulkItemResult{ID: id}
+		if !existing[id] {
+			item.Error = "题目不存在"
+			result.Failed++
+			result.Items = append(result.Items, item)
+			continue
+		}
+
+		result.Matched++
+		changed, err := applyBulkChange(tx, id, change)
+		if err != nil {
+			return nil, fmt.Errorf("修改题目%d失败: %w", id, err)
+		}
+		item.OK = true
+		item.Changed = changed
+		if changed {
+			result.Changed++
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return result, nil
+}
+
+// 在事务中查出筛选条件匹配的题目ID
+func matchQuestionIDs(tx *sql.Tx, filter *models.QuestionFilter) ([]int64, error) {
+	if filter == nil {
+		return nil, errors.New("没有指定题目ID或筛选条件")
+	}
+	conditions, args := questionConditions(*filter)
+
+	// 多取一条用于判断是否超出上限
+	query := "SELECT id FROM questions " + whereClause(conditions) + " ORDER BY id LIMIT ?"
+	rows, err := tx.Query(query, append(args, MaxBulkItems+1)...)
+	if err != nil {
+		return nil, fmt.Errorf("查询题目失败: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("扫描题目ID失败: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询题目失败: %w", err)
+	}
+	if len(ids) > MaxBulkItems {
+		return nil, ErrBulkTooMany
+	}
+	return ids, nil
+}
+
+// 查出ids中存在的题目
+func existingQuestionIDs(tx *sql.Tx, ids []int64) (map[int64]bool, error) {
+	existing := make(map[int64]bool, len(ids))
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := tx.Query("SELECT id FROM questions WHERE id IN ("+sqlPlaceholders(len(ids))+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询题目失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("扫描题目ID失败: %w", err)
+		}
+		existing[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询题目失败: %w", err)
+	}
+	return existing, nil
+}
+
+// 去掉重复的ID，保持原有顺序
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	result := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// 修改单个题目，返回题目是否发生了变化
+func applyBulkChange(tx *sql.Tx, id int64, change models.BulkChange) (bool, error) {
+	var affected int64
+	switch change.Operation {
+	case models.BulkSetDifficulty:
+		result, err := tx.Exec("UPDATE questions SET difficulty = ? WHERE id = ? AND difficulty IS NOT ?", int(change.Difficulty), id, int(change.Difficulty))
+		if err != nil {
+			return false, err
+		}
+		affected, _ = result.RowsAffected()
+
+	case models.BulkSetStatus:
+		result, err := tx.Exec("UPDATE questions SET status = ? WHERE id = ? AND status != ?", string(change.Status), id, string(change.Status))
+		if err != nil {
+			return false, err
+		}
+		affected, _ = result.RowsAffected()
+
+	case models.BulkMoveToBank:
+		result, err := tx.Exec("UPDATE questions SET bank_id = ? WHERE id = ? AND bank_id != ?", change.BankID, id, change.BankID)
+		if err != nil {
+			return false, err
+		}
+		affected, _ = result.RowsAffected()
+
+	case models.BulkAddTags:
+		for _, tag := range normalizeTags(change.Tags) {
+			result, err := tx.Exec("INSERT OR IGNORE INTO question_tags (question_id, tag) VALUES (?, ?)", id, tag)
+			if err != nil {
+				return false, err
+			}
+			n, _ := result.RowsAffected()
+			affected += n
+		}
+
+	case models.BulkRemoveTags:
+		for _, tag := range normalizeTags(change.Tags) {
+			result, err := tx.Exec("DELETE FROM question_tags WHERE question_id = ? AND tag = ?", id, tag)
+			if err != nil {
+				return false, err
+			}
+			n, _ := result.RowsAffected()
+			affected += n
+		}
+
+	default:
+		return false, fmt.Errorf("不支持的批量操作: %s", change.Operation)
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"question-generator/models"
+	"reflect"
+	"testing"
+)
+
+func TestBulkUpdate(t *testing.T) {
+	storage := newTestStorage(t)
+
+	var ids []int64
+	for i, title := range []string{"一", "二", "三"} {
+		q := choiceQuestion(title, models.SingleChoice, 0)
+		if i == 0 {
+			q.Tags = []string{"基础", " 基础 ", ""}
+		}
+		id, err := storage.AddQuestion(q)
+		if err != nil {
+			t.Fatalf("AddQuestion: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// 预演只返回结果，不修改数据
+	change := models.BulkChange{Operation: models.BulkAddTags, Tags: []string{"基础", "并发"}}
+	result, err := storage.BulkUpdate([]int64{ids[0], ids[1], 999}, nil, change, true)
+	if err != nil {
+		t.Fatalf("BulkUpdate dry run: %v", err)
+	}
+	want := []models.BulkItemResult{
+		{ID: ids[0], OK: true, Changed: true},
+		{ID: ids[1], OK: true, Changed: true},
+		{ID: 999, Error: "题目不存在"},
+	}
+	if !result.DryRun || result.Matched != 2 || result.Changed != 2 || result.Failed != 1 || !reflect.DeepEqual(result.Items, want) {
+		t.Fatalf("dry run result: %+v", result)
+	}
+	q, _ := storage.GetQuestionByID(ids[1])
+	if len(q.Tags) != 0 {
+		t.Fatalf("dry run modified tags: %v", q.Tags)
+	}
+
+	if _, err := storage.BulkUpdate([]int64{ids[0], ids[1]}, nil, change, false); err != nil {
+		t.Fatalf("BulkUpdate: %v", err)
+	}
+	q, _ = storage.GetQuestionByID(ids[0])
+	if !reflect.DeepEqual(q.Tags, []string{"基础", "并发"}) {
+		t.Errorf("tags = %v", q.Tags)
+	}
+
+	// 按标签筛选后批量归档，已归档的题目不算修改
+	filter := &models.QuestionFilter{Tag: "并发"}
+	archive := models.BulkChange{Operation: models.BulkSetStatus, Status: models.StatusArchived}
+	result, err = storage.BulkUpdate(nil, filter, archive, false)
+	if err != nil || result.Matched != 2 || result.Changed != 2 {
+		t.Fatalf("archive by filter: %+v, %v", result, err)
+	}
+	result, _ = storage.BulkUpdate(nil, filter, archive, false)
+	if result.Matched != 2 || result.Changed != 0 {
+		t.Errorf("archive again: %+v", result)
+	}
+
+	list, err := storage.QueryQuestions(models.QuestionFilter{Status: models.StatusActive})
+	if err != nil || len(list.List) != 1 || list.List[0].ID != ids[2] {
+		t.Errorf("active questions: %+v, %v", list, err)
+	}
+
+	result, _ = storage.BulkUpdate(ids, nil, models.BulkChange{Operation: models.BulkSetDifficulty, Difficulty: models.Hard}, false)
+	if result.Changed != 3 {
+		t.Errorf("set difficulty: %+v", result)
+	}
+	result, _ = storage.BulkUpdate(ids, nil, models.BulkChange{Operation: models.BulkRemoveTags, Tags: []string{"基础"}}, false)
+	if result.Changed != 2 {
+		t.Errorf("remove tags: %+v", result)
+	}
+	q, _ = storage.GetQuestionByID(ids[0])
+	if q.Difficulty != models.Hard || !reflect.DeepEqual(q.Tags, []string{"并发"}) {
+		t.Errorf("question after bulk: %+v", q)
+	}
+
+	// 删除题目时一并删除标签
+	if err := storage.DeleteQuestions(ids[:1]); err != nil {
+		t.Fatalf("DeleteQuestions: %v", err)
+	}
+	var tags int
+	storage.DB.QueryRow("SELECT COUNT(*) FROM question_tags WHERE question_id = ?", ids[0]).Scan(&tags)
+	if tags != 0 {
+		t.Errorf("tags left after delete: %d", tags)
+	}
+}
+
+func TestBulkUpdateTooMany(t *testing.T) {
+	storage := newTestStorage(t)
+
+	ids := make([]int64, MaxBulkItems+1)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	_, err := storage.BulkUpdate(ids, nil, models.BulkChange{Operation: models.BulkSetStatus, Status: models.StatusDraft}, false)
+	if !errors.Is(err, ErrBulkTooMany) {
+		t.Errorf("err = %v, want ErrBulkTooMany", err)
+	}
+}
@@ -0,0 +1,600 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"question-generator/metrics"
+	"question-generator/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCourseNotFound = errors.New("课程不存在")
+	ErrBankNotFound   = errors.New("题库不存在")
+	ErrForbidden      = errors.New("没有权限")
+	ErrLastTeacher    = errors.New("课程至少需要保留一名教师")
+)
+
+// 创建课程相关的表，并确保默认课程和默认题库存在
+func createCourseTables(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS courses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		open INTEGER NOT NULL DEFAULT 0, -- 1=所有用户都以助教身份访问
+		created_by TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS course_members (
+		course_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		role TEXT NOT NULL, -- teacher / ta / student
+		added_at INTEGER NOT NULL,
+		PRIMARY KEY (course_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_course_members_user ON course_members(user_id);
+	CREATE TABLE IF NOT EXISTS banks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		course_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_banks_course ON banks(course_id);
+	CREATE TABLE IF NOT EXISTS bank_shares (
+		bank_id INTEGER NOT NULL,
+		course_id INTEGER NOT NULL, -- 共享给的课程，成员只读使用
+		PRIMARY KEY (bank_id, course_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_bank_shares_course ON bank_shares(course_id)`)
+	if err != nil {
+		return err
+	}
+
+	// 升级前的题目都在默认题库中，默认课程是开放的，原有的使用方式不受影响
+	now := time.Now().Unix()
+	if _, err := db.Exec("INSERT OR IGNORE INTO courses (id, name, open, created_at) VALUES (?, '默认课程', 1, ?)", models.DefaultCourseID, now); err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT OR IGNORE INTO banks (id, course_id, name, created_at) VALUES (?, ?, '默认题库', ?)", models.DefaultBankID, models.DefaultCourseID, now)
+	return err
+}
+
+// 用户能访问的题库及权限
+type BankPermissions map[int64]models.BankAccess
+
+// 能否以不低于min的角色访问题库
+func (p BankPermissions) Can(bankID int64, min models.CourseRole) bool {
+	access, ok := p[bankID]
+	return ok && access.Role.AtLeast(min)
+}
+
+// 能否修改题库中的题目
+func (p BankPermissions) CanWrite(bankID int64) bool {
+	return p[bankID].Writable
+}
+
+// 能以不低于min的角色访问的题库，按ID排序
+func (p BankPermissions) IDs(min models.CourseRole) []int64 {
+	ids := []int64{}
+	for id, access := range p {
+		if access.Role.AtLeast(min) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// 能修改的题库，按ID排序
+func (p BankPermissions) WritableIDs() []int64 {
+	ids := []int64{}
+	for id, access := range p {
+		if access.Writable {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// 管理课程、成员、题库和题库共享，并按成员身份计算题库权限
+type CourseService struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// 创建课程服务，表在打开数据库时已经创建
+func NewCourseService(storage *StorageService) *CourseService {
+	return &CourseService{db: storage.DB, now: time.Now}
+}
+
+// 开放课程中的用户至少是助教
+func openCourseRole(role models.CourseRole, open bool) models.CourseRole {
+	if open && !role.AtLeast(models.RoleTA) {
+		return models.RoleTA
+	}
+	return role
+}
+
+// 计算用户能访问的题库。课程自己的题库按成员角色访问，助教以上可以修改；
+// 共享来的题库按在目标课程中的角色只读访问
+func (s *CourseService) Access(p models.Principal) (BankPermissions, error) {
+	defer metrics.ObserveDB("bank_access")()
+
+	perms := make(BankPermissions)
+	if p.Admin {
+		rows, err := s.db.Query("SELECT id FROM banks")
+		if err != nil {
+			return nil, fmt.Errorf("查询题库失败: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return nil, fmt.Errorf("查询题库失败: %w", err)
+			}
+			perms[id] = models.BankAccess{Role: models.RoleTeacher, Writable: true}
+		}
+		return perms, rows.Err()
+	}
+
+	rows, err := s.db.Query(`SELECT b.id, c.open, COALESCE(m.role, '')
+		FROM banks b
+		JOIN courses c ON c.id = b.course_id
+		LEFT JOIN course_members m ON m.course_id = c.id AND m.user_id = ?
+		WHERE c.open = 1 OR m.user_id IS NOT NULL`, p.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("查询题库权限失败: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var open bool
+		var role models.CourseRole
+		if err := rows.Scan(&id, &open, &role); err != nil {
+			return nil, fmt.Errorf("查询题库权限失败: %w", err)
+		}
+		role = openCourseRole(role, open)
+		perms[id] = models.BankAccess{Role: role, Writable: role.AtLeast(models.RoleTA)}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询题库权限失败: %w", err)
+	}
+	rows.Close()
+
+	shared, err := s.db.Query(`SELECT s.bank_id, c.open, COALESCE(m.role, '')
+		FROM bank_shares s
+		JOIN courses c ON c.id = s.course_id
+		LEFT JOIN course_members m ON m.course_id = c.id AND m.user_id = ?
+		WHERE c.open = 1 OR m.user_id IS NOT NULL`, p.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("查询共享题库失败: %w", err)
+	}
+	defer shared.Close()
+	for shared.Next() {
+		var id int64
+		var open bool
+		var role models.CourseRole
+		if err := shared.Scan(&id, &open, &role); err != nil {
+			return nil, fmt.Errorf("查询共享题库失败: %w", err)
+		}
+		role = openCourseRole(role, open)
+		if existing, ok := perms[id]; ok && (existing.Writable || existing.Role.AtLeast(role)) {
+			continue
+		}
+		perms[id] = models.BankAccess{Role: role}
+	}
+	return perms, shared.Err()
+}
+
+// 用户在课程中的角色，没有关系时返回空字符串
+func (s *CourseService) courseRole(p models.Principal, courseID int64) (models.CourseRole, error) {
+	var open bool
+	var role models.CourseRole
+	err := s.db.QueryRow(`SELECT c.open, COALESCE(m.role, '')
+		FROM courses c
+		LEFT JOIN course_members m ON m.course_id = c.id AND m.user_id = ?
+		WHERE c.id = ?`, p.UserID, courseID).Scan(&open, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrCourseNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询课程失败: %w", err)
+	}
+	if p.Admin {
+		return models.RoleTeacher, nil
+	}
+	return openCourseRole(role, open), nil
+}
+
+// 要求用户在课程中的角色不低于min
+func (s *CourseService) requireRole(p models.Principal, courseID int64, min models.CourseRole) (models.CourseRole, error) {
+	role, err := s.courseRole(p, courseID)
+	if err != nil {
+		return "", err
+	}
+	if !role.AtLeast(min) {
+		return "", fmt.Errorf("%w: 需要课程的%s角色", ErrForbidden, min)
+	}
+	return role, nil
+}
+
+// 创建课程，创建者成为教师
+func (s *CourseService) CreateCourse(p models.Principal, name string) (*models.Course, error) {
+	defer metrics.ObserveDB("create_course")()
+
+	// 匿名用户没有用户ID，无法成为课程的教师
+	if p.UserID == "" {
+		return nil, fmt.Errorf("%w: 创建课程需要用户令牌", ErrForbidden)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("课程名称不能为空")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := s.now()
+	result, err := tx.Exec("INSERT INTO courses (name, created_by, created_at) VALUES (?, ?, ?)", name, p.UserID, now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("创建课程失败: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("创建课程失败: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO course_members (course_id, user_id, role, added_at) VALUES (?, ?, ?, ?)",
+		id, p.UserID, string(models.RoleTeacher), now.Unix()); err != nil {
+		return nil, fmt.Errorf("添加教师失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+
+	return &models.Course{
+		ID:        id,
+		Name:      name,
+		CreatedBy: p.UserID,
+		CreatedAt: time.Unix(now.Unix(), 0),
+		Role:      models.RoleTeacher,
+	}, nil
+}
+
+// 用户参加的课程和开放课程，管理员返回全部课程
+func (s *CourseService) ListCourses(p models.Principal) ([]models.Course, error) {
+	defer metrics.ObserveDB("list_courses")()
+
+	rows, err := s.db.Query(`SELECT c.id, c.name, c.open, c.created_by, c.created_at, COALESCE(m.role, '')
+		FROM courses c
+		LEFT JOIN course_members m ON m.course_id = c.id AND m.user_id = ?
+		WHERE ? OR c.open = 1 OR m.user_id IS NOT NULL
+		ORDER BY c.id`, p.UserID, p.Admin)
+	if err != nil {
+		return nil, fmt.Errorf("查询课程失败: %w", err)
+	}
+	defer rows.Close()
+
+	courses := []models.Course{}
+	for rows.Next() {
+		var c models.Course
+		var createdAt int64
+		if err := rows.Scan(&c.ID, &c.Name, &c.Open, &c.CreatedBy, &createdAt, &c.Role); err != nil {
+			return nil, fmt.Errorf("查询课程失败: %w", err)
+		}
+		c.CreatedAt = time.Unix(createdAt, 0)
+		c.Role = openCourseRole(c.Role, c.Open)
+		if p.Admin {
+			c.Role = models.RoleTeacher
+		}
+		courses = append(courses, c)
+	}
+	return courses, rows.Err()
+}
+
+// 课程详情。学生看不到成员列表
+func (s *CourseService) GetCourse(p models.Principal, id int64) (*models.CourseDetail, error) {
+	defer metrics.ObserveDB("get_course")()
+
+	role, err := s.requireRole(p, id, models.RoleStudent)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &models.CourseDetail{Members: []models.CourseMember{}}
+	var createdAt int64
+	err = s.db.QueryRow("SELECT id, name, open, created_by, created_at FROM courses WHERE id = ?", id).
+		Scan(&detail.ID, &detail.Name, &detail.Open, &detail.CreatedBy, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("查询课程失败: %w", err)
+	}
+	detail.CreatedAt = time.Unix(createdAt, 0)
+	detail.Role = role
+
+	if role.AtLeast(models.RoleTA) {
+		rows, err := s.db.Query("SELECT user_id, role, added_at FROM course_members WHERE course_id = ? ORDER BY added_at, user_id", id)
+		if err != nil {
+			return nil, fmt.Errorf("查询课程成员失败: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var m models.CourseMember
+			var addedAt int64
+			if err := rows.Scan(&m.UserID, &m.Role, &addedAt); err != nil {
+				return nil, fmt.Errorf("查询课程成员失败: %w", err)
+			}
+			m.AddedAt = time.Unix(addedAt, 0)
+			detail.Members = append(detail.Members, m)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("查询课程成员失败: %w", err)
+		}
+		rows.Close()
+	}
+
+	banks, err := s.queryBanks("WHERE course_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range banks {
+		banks[i].Role = role
+		banks[i].Writable = role.AtLeast(models.RoleTA)
+	}
+	detail.Banks = banks
+	return detail, nil
+}
+
+// 修改课程名称或开放状态，需要教师角色
+func (s *CourseService) UpdateCourse(p models.Principal, id int64, req models.CourseUpdateRequest) error {
+	defer metrics.ObserveDB("update_course")()
+
+	if _, err := s.requireRole(p, id, models.RoleTeacher); err != nil {
+		return err
+	}
+	name := strings.TrimSpace(req.Name)
+	_, err := s.db.Exec("UPDATE courses SET name = COALESCE(NULLIF(?, ''), name), open = COALESCE(?, open) WHERE id = ?", name, req.Open, id)
+	if err != nil {
+		return fmt.Errorf("修改课程失败: %w", err)
+	}
+	return nil
+}
+
+// 添加成员或修改成员角色，需要教师角色
+func (s *CourseService) SetMember(p models.Principal, courseID int64, userID string, role models.CourseRole) error {
+	defer metrics.ObserveDB("set_course_member")()
+
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return errors.New("用户不能为空")
+	}
+	if !role.Valid() {
+		return fmt.Errorf("无效的角色: %s", role)
+	}
+	if _, err := s.requireRole(p, courseID, models.RoleTeacher); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO course_members (course_id, user_id, role, added_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(course_id, user_id) DO UPDATE SET role = excluded.role`,
+		courseID, userID, string(role), s.now().Unix()); err != nil {
+		return fmt.Errorf("保存课程成员失败: %w", err)
+	}
+	if err := checkTeachers(tx, courseID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// 移除成员，需要教师角色
+func (s *CourseService) RemoveMember(p models.Principal, courseID int64, userID string) error {
+	defer metrics.ObserveDB("remove_course_member")()
+
+	if _, err := s.requireRole(p, courseID, models.RoleTeacher); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM course_members WHERE course_id = ? AND user_id = ?", courseID, userID); err != nil {
+		return fmt.Errorf("移除课程成员失败: %w", err)
+	}
+	if err := checkTeachers(tx, courseID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// 有过教师的课程不能变成没有教师，默认课程一开始就没有教师，只能由管理员管理
+func checkTeachers(tx *sql.Tx, courseID int64) error {
+	var teachers int
+	err := tx.QueryRow("SELECT COUNT(*) FROM course_members WHERE course_id = ? AND role = ?", courseID, string(models.RoleTeacher)).Scan(&teachers)
+	if err != nil {
+		return fmt.Errorf("查询课程教师失败: %w", err)
+	}
+	if teachers == 0 && courseID != models.DefaultCourseID {
+		return ErrLastTeacher
+	}
+	return nil
+}
+
+// 在课程中创建题库，需要教师角色
+func (s *CourseService) CreateBank(p models.Principal, courseID int64, name string) (*models.Bank, error) {
+	defer metrics.ObserveDB("create_bank")()
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("题库名称不能为空")
+	}
+	role, err := s.requireRole(p, courseID, models.RoleTeacher)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	result, err := s.db.Exec("INSERT INTO banks (course_id, name, created_at) VALUES (?, ?, ?)", courseID, name, now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("创建题库失败: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("创建题库失败: %w", err)
+	}
+	return &models.Bank{
+		ID:         id,
+		CourseID:   courseID,
+		Name:       name,
+		CreatedAt:  time.Unix(now.Unix(), 0),
+		SharedWith: []int64{},
+		Role:       role,
+		Writable:   true,
+	}, nil
+}
+
+// 用户能访问的全部题库，包括共享来的题库
+func (s *CourseService) ListBanks(p models.Principal) ([]models.Bank, error) {
+	defer metrics.ObserveDB("list_banks")()
+
+	perms, err := s.Access(p)
+	if err != nil {
+		return nil, err
+	}
+	ids := perms.IDs(models.RoleStudent)
+	if len(ids) == 0 {
+		return []models.Bank{}, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	banks, err := s.queryBanks("WHERE id IN ("+sqlPlaceholders(len(ids))+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range banks {
+		access := perms[banks[i].ID]
+		banks[i].Role = access.Role
+		banks[i].Writable = access.Writable
+	}
+	return banks, nil
+}
+
+// 按条件查询题库，附带题目数量和共享的课程
+func (s *CourseService) queryBanks(where string, args ...interface{}) ([]models.Bank, error) {
+	rows, err := s.db.Query(`SELECT id, course_id, name, created_at,
+		(SELECT COUNT(*) FROM questions WHERE bank_id = banks.id)
+		FROM banks `+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询题库失败: %w", err)
+	}
+	defer rows.Close()
+
+	banks := []models.Bank{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		var b models.Bank
+		var createdAt int64
+		if err := rows.Scan(&b.ID, &b.CourseID, &b.Name, &createdAt, &b.QuestionCount); err != nil {
+			return nil, fmt.Errorf("查询题库失败: %w", err)
+		}
+		b.CreatedAt = time.Unix(createdAt, 0)
+		b.SharedWith = []int64{}
+		index[b.ID] = len(banks)
+		banks = append(banks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询题库失败: %w", err)
+	}
+	rows.Close()
+
+	shares, err := s.db.Query("SELECT bank_id, course_id FROM bank_shares ORDER BY course_id")
+	if err != nil {
+		return nil, fmt.Errorf("查询题库共享失败: %w", err)
+	}
+	defer shares.Close()
+	for shares.Next() {
+		var bankID, courseID int64
+		if err := shares.Scan(&bankID, &courseID); err != nil {
+			return nil, fmt.Errorf("查询题库共享失败: %w", err)
+		}
+		if i, ok := index[bankID]; ok {
+			banks[i].SharedWith = append(banks[i].SharedWith, courseID)
+		}
+	}
+	return banks, shares.Err()
+}
+
+// 题库所属的课程
+func (s *CourseService) bankCourse(bankID int64) (int64, error) {
+	var courseID int64
+	err := s.db.QueryRow("SELECT course_id FROM banks WHERE id = ?", bankID).Scan(&courseID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrBankNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("查询题库失败: %w", err)
+	}
+	return courseID, nil
+}
+
+// 把题库共享给另一门课程，需要题库所属课程的教师角色
+func (s *CourseService) ShareBank(p models.Principal, bankID, courseID int64) error {
+	defer metrics.ObserveDB("share_bank")()
+
+	owner, err := s.bankCourse(bankID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.requireRole(p, owner, models.RoleTeacher); err != nil {
+		return err
+	}
+	if owner == courseID {
+		return errors.New("不能共享给题库所属的课程")
+	}
+	if _, err := s.courseRole(p, courseID); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec("INSERT OR IGNORE INTO bank_shares (bank_id, course_id) VALUES (?, ?)", bankID, courseID); err != nil {
+		return fmt.Errorf("共享题库失败: %w", err)
+	}
+	return nil
+}
+
+// 取消共享，需要题库所属课程的教师角色
+func (s *CourseService) UnshareBank(p models.Principal, bankID, courseID int64) error {
+	defer metrics.ObserveDB("unshare_bank")()
+
+	owner, err := s.bankCourse(bankID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.requireRole(p, owner, models.RoleTeacher); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec("DELETE FROM bank_shares WHERE bank_id = ? AND course_id = ?", bankID, courseID); err != nil {
+		return fmt.Errorf("取消共享失败: %w", err)
+	}
+	return nil
+}
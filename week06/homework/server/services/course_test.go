@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"question-generator/models"
+	"reflect"
+	"testing"
+)
+
+func TestCourseAccess(t *testing.T) {
+	storage := newTestStorage(t)
+	courses := NewCourseService(storage)
+
+	alice := models.Principal{UserID: "alice"}
+	bob := models.Principal{UserID: "bob"}
+	stranger := models.Principal{UserID: "stranger"}
+
+	// 开放的默认课程中所有人都是助教
+	perms, err := courses.Access(stranger)
+	if err != nil {
+		t.Fatalf("Access: %v", err)
+	}
+	if !perms.CanWrite(models.DefaultBankID) || perms.Can(models.DefaultBankID, models.RoleTeacher) {
+		t.Fatalf("default bank access = %+v", perms[models.DefaultBankID])
+	}
+
+	course, err := courses.CreateCourse(alice, "数据结构")
+	if err != nil {
+		t.Fatalf("CreateCourse: %v", err)
+	}
+	bank, err := courses.CreateBank(alice, course.ID, "期中")
+	if err != nil {
+		t.Fatalf("CreateBank: %v", err)
+	}
+	if _, err := courses.CreateBank(bob, course.ID, "期末"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("non-member CreateBank err = %v", err)
+	}
+
+	if err := courses.SetMember(alice, course.ID, "bob", models.RoleStudent); err != nil {
+		t.Fatalf("SetMember: %v", err)
+	}
+	perms, _ = courses.Access(bob)
+	if !perms.Can(bank.ID, models.RoleStudent) || perms.Can(bank.ID, models.RoleTA) || perms.CanWrite(bank.ID) {
+		t.Errorf("student access = %+v", perms[bank.ID])
+	}
+	perms, _ = courses.Access(stranger)
+	if _, ok := perms[bank.ID]; ok {
+		t.Errorf("stranger can access bank %d", bank.ID)
+	}
+
+	// 学生看不到成员列表
+	detail, err := courses.GetCourse(bob, course.ID)
+	if err != nil || len(detail.Members) != 0 || len(detail.Banks) != 1 {
+		t.Fatalf("student GetCourse = %+v, %v", detail, err)
+	}
+	if _, err := courses.GetCourse(stranger, course.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("stranger GetCourse err = %v", err)
+	}
+
+	// 共享给另一门课程后，对方课程的教师只读访问
+	other, _ := courses.CreateCourse(bob, "算法")
+	if err := courses.ShareBank(bob, bank.ID, other.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("student ShareBank err = %v", err)
+	}
+	if err := courses.ShareBank(alice, bank.ID, course.ID); err == nil {
+		t.Fatal("sharing to the owning course should fail")
+	}
+	if err := courses.ShareBank(alice, bank.ID, other.ID); err != nil {
+		t.Fatalf("ShareBank: %v", err)
+	}
+	if err := courses.RemoveMember(alice, course.ID, "bob"); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	perms, _ = courses.Access(bob)
+	if !perms.Can(bank.ID, models.RoleTeacher) || perms.CanWrite(bank.ID) {
+		t.Errorf("shared bank access = %+v", perms[bank.ID])
+	}
+	banks, err := courses.ListBanks(alice)
+	if err != nil {
+		t.Fatalf("ListBanks: %v", err)
+	}
+	var shared []int64
+	for _, b := range banks {
+		if b.ID == bank.ID {
+			shared = b.SharedWith
+		}
+	}
+	if !reflect.DeepEqual(shared, []int64{other.ID}) {
+		t.Errorf("sharedWith = %v", shared)
+	}
+
+	if err := courses.UnshareBank(alice, bank.ID, other.ID); err != nil {
+		t.Fatalf("UnshareBank: %v", err)
+	}
+	perms, _ = courses.Access(bob)
+	if _, ok := perms[bank.ID]; ok {
+		t.Errorf("bank still accessible after unshare")
+	}
+
+	// 管理员可以访问全部题库
+	perms, _ = courses.Access(models.Principal{Admin: true})
+	if !perms.CanWrite(bank.ID) || !perms.CanWrite(models.DefaultBankID) {
+		t.Errorf("admin access = %+v", perms)
+	}
+}
+
+func TestCourseLastTeacher(t *testing.T) {
+	storage := newTestStorage(t)
+	courses := NewCourseService(storage)
+	alice := models.Principal{UserID: "alice"}
+
+	course, err := courses.CreateCourse(alice, "操作系统")
+	if err != nil {
+		t.Fatalf("CreateCourse: %v", err)
+	}
+	if err := courses.SetMember(alice, course.ID, "alice", models.RoleTA); !errors.Is(err, ErrLastTeacher) {
+		t.Fatalf("demote last teacher err = %v", err)
+	}
+	if err := courses.RemoveMember(alice, course.ID, "alice"); !errors.Is(err, ErrLastTeacher) {
+		t.Fatalf("remove last teacher err = %v", err)
+	}
+
+	// 有了另一名教师后可以退出
+	if err := courses.SetMember(alice, course.ID, "carol", models.RoleTeacher); err != nil {
+		t.Fatalf("SetMember: %v", err)
+	}
+	if err := courses.RemoveMember(alice, course.ID, "alice"); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	if _, err := courses.GetCourse(alice, course.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("removed teacher GetCourse err = %v", err)
+	}
+}
+
+func TestCopyAndMoveQuestions(t *testing.T) {
+	storage := newTestStorage(t)
+	courses := NewCourseService(storage)
+	alice := models.Principal{UserID: "alice"}
+
+	course, _ := courses.CreateCourse(alice, "网络")
+	bank, err := courses.CreateBank(alice, course.ID, "练习")
+	if err != nil {
+		t.Fatalf("CreateBank: %v", err)
+	}
+
+	q := choiceQuestion("TCP握手", models.SingleChoice, 0)
+	q.Tags = []string{"tcp"}
+	id, err := storage.AddQuestion(q)
+	if err != nil {
+		t.Fatalf("AddQuestion: %v", err)
+	}
+
+	copied, err := storage.CopyQuestions([]int64{id, id}, bank.ID)
+	if err != nil || len(copied) != 1 {
+		t.Fatalf("CopyQuestions = %v, %v", copied, err)
+	}
+	c, err := storage.GetQuestionByID(copied[0])
+	if err != nil {
+		t.Fatalf("GetQuestionByID: %v", err)
+	}
+	if c.BankID != bank.ID || c.AIRes.Title != "TCP握手" || !reflect.DeepEqual(c.Tags, []string{"tcp"}) {
+		t.Errorf("copied question = %+v", c)
+	}
+	if _, err := storage.CopyQuestions([]int64{999}, bank.ID); !errors.Is(err, ErrQuestionNotFound) {
+		t.Errorf("copy missing question err = %v", err)
+	}
+
+	// 按题库筛选
+	result, err := storage.QueryQuestions(models.QuestionFilter{BankIDs: []int64{bank.ID}, Page: 1, PageSize: 10})
+	if err != nil || result.Total != 1 || result.List[0].ID != copied[0] {
+		t.Fatalf("query by bank = %+v, %v", result, err)
+	}
+	result, _ = storage.QueryQuestions(models.QuestionFilter{BankIDs: []int64{}, Page: 1, PageSize: 10})
+	if result.Total != 0 {
+		t.Errorf("empty bank list matched %d questions", result.Total)
+	}
+
+	move := models.BulkChange{Operation: models.BulkMoveToBank, BankID: bank.ID}
+	moved, err := storage.BulkUpdate([]int64{id, copied[0]}, nil, move, false)
+	if err != nil || moved.Changed != 1 {
+		t.Fatalf("move to bank = %+v, %v", moved, err)
+	}
+	banks, err := storage.QuestionBanks([]int64{id, copied[0], 999})
+	if err != nil {
+		t.Fatalf("QuestionBanks: %v", err)
+	}
+	if !reflect.DeepEqual(banks, map[int64]int64{id: bank.ID, copied[0]: bank.ID}) {
+		t.Errorf("question banks = %v", banks)
+	}
+}
@@ -0,0 +1,669 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"question-generator/metrics"
+	"question-generator/models"
+	"strings"
+	"time"
+)
+
+var (
+	// 题目没有设置评分标准，不能按评分标准批改
+	ErrNoRubric = errors.New("题目没有设置评分标准")
+	// 只有文字作答的题型可以设置评分标准
+	ErrRubricNotSupported = errors.New("只有简答题和编程题可以设置评分标准")
+	ErrResponseNotFound   = errors.New("作答不存在")
+	ErrGradingJobNotFound = errors.New("批改任务不存在")
+	// 服务正在关闭，不再接受新的批改任务
+	ErrShuttingDown = errors.New("服务正在关闭")
+	// 评分标准、教师给出的得分或批改任务的参数无效
+	ErrInvalidGrade = errors.New("参数无效")
+)
+
+// 按教师设置的评分标准批改简答题和编程题：模型逐项打分，教师可以修改分数，
+// 修改前后的分数都保存下来，用于检查模型与教师评分的一致程度
+type GraderService struct {
+	storage *StorageService
+	ai      *AIClient
+	jobs    *BackgroundJobs
+	now     func() time.Time
+}
+
+// 创建批改服务，评分标准、批改结果和批改任务与题库放在同一个数据库中。
+// 上次关闭时没有完成的任务标记为已中止
+func NewGraderService(storage *StorageService, ai *AIClient, jobs *BackgroundJobs) (*GraderService, error) {
+	_, err := storage.DB.Exec(`CREATE TABLE IF NOT EXISTS question_rubrics (
+		question_id INTEGER PRIMARY KEY,
+		criteria TEXT NOT NULL, -- 评分项的JSON数组
+		updated_at INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS response_grades (
+		attempt_id INTEGER NOT NULL,
+		question_id INTEGER NOT NULL,
+		max_score INTEGER NOT NULL, -- 批改时评分标准的总分
+		ai_grader TEXT, -- 批改的模型服务商
+		ai_scores TEXT, -- 模型给出的各项得分的JSON数组
+		ai_score INTEGER,
+		ai_graded_at INTEGER,
+		override_scores TEXT, -- 教师修改后的各项得分的JSON数组
+		override_score INTEGER,
+		override_comment TEXT,
+		override_by TEXT,
+		override_at INTEGER,
+		PRIMARY KEY (attempt_id, question_id)
+	);
+	CREATE TABLE IF NOT EXISTS grading_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		question_ids TEXT NOT NULL, -- JSON数组
+		attempt_ids TEXT, -- JSON数组，为NULL时批改全部答卷
+		provider TEXT NOT NULL,
+		status TEXT NOT NULL, -- running/done/canceled
+		total INTEGER NOT NULL,
+		graded INTEGER NOT NULL,
+		failed INTEGER NOT NULL,
+		last_error TEXT,
+		created_at INTEGER NOT NULL,
+		finished_at INTEGER
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("无法创建批改表: %w", err)
+	}
+
+	s := &GraderService{
+		storage: storage,
+		ai:      ai,
+		jobs:    jobs,
+		now:     time.Now,
+	}
+	if _, err := storage.DB.Exec("UPDATE grading_jobs SET status = ?, finished_at = ? WHERE status = ?",
+		string(models.JobCanceled), s.now().Unix(), string(models.JobRunning)); err != nil {
+		return nil, fmt.Errorf("更新未完成的批改任务失败: %w", err)
+	}
+	return s, nil
+}
+
+// 设置题目的评分标准，只影响之后的批改
+func (s *GraderService) SetRubric(questionID int64, criteria []models.RubricCriterion) (*models.Rubric, error) {
+	question, err := s.storage.GetQuestionByID(questionID)
+	if err != nil {
+		return nil, err
+	}
+	if spec, ok := models.LookupQuestionType(question.AIReq.Type); !ok || spec.Input != models.InputText {
+		return nil, ErrRubricNotSupported
+	}
+
+	cleaned := make([]models.RubricCriterion, len(criteria))
+	for i, c := range criteria {
+		cleaned[i] = models.RubricCriterion{
+			Name:        strings.TrimSpace(c.Name),
+			Description: strings.TrimSpace(c.Description),
+			Points:      c.Points,
+		}
+	}
+	if err := models.ValidateRubric(cleaned); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGrade, err)
+	}
+
+	defer metrics.ObserveDB("set_rubric")()
+
+	data, _ := json.Marshal(cleaned)
+	now := s.now()
+	if _, err := s.storage.DB.Exec(`INSERT INTO question_rubrics (question_id, criteria, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(question_id) DO UPDATE SET criteria = excluded.criteria, updated_at = excluded.updated_at`,
+		questionID, string(data), now.Unix()); err != nil {
+		return nil, fmt.Errorf("保存评分标准失败: %w", err)
+	}
+	return &models.Rubric{
+		QuestionID: questionID,
+		Criteria:   cleaned,
+		MaxScore:   models.RubricMaxScore(cleaned),
+		UpdatedAt:  time.Unix(now.Unix(), 0),
+	}, nil
+}
+
+// 查询题目的评分标准，没有设置时返回ErrNoRubric
+func (s *GraderService) GetRubric(questionID int64) (*models.Rubric, error) {
+	defer metrics.ObserveDB("get_rubric")()
+
+	var data string
+	var updatedAt int64
+	err := s.storage.DB.QueryRow("SELECT criteria, updated_at FROM question_rubrics WHERE question_id = ?", questionID).Scan(&data, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: ID=%d", ErrNoRubric, questionID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询评分标准失败: %w", err)
+	}
+
+	rubric := &models.Rubric{QuestionID: questionID, UpdatedAt: time.Unix(updatedAt, 0)}
+	if err := json.Unmarshal([]byte(data), &rubric.Criteria); err != nil {
+		return nil, fmt.Errorf("解析评分标准失败: %w", err)
+	}
+	rubric.MaxScore = models.RubricMaxScore(rubric.Criteria)
+	return rubric, nil
+}
+
+const responseGradeQuery = `SELECT r.attempt_id, r.question_id, a.user_id, COALESCE(r.answer_text, ''), r.pending,
+	g.max_score, g.ai_grader, g.ai_scores, g.ai_score, g.ai_graded_at,
+	g.override_scores, g.override_score, g.override_comment, g.override_by, g.override_at
+	FROM exam_responses r
+	JOIN exam_attempts a ON a.id = r.attempt_id
+	LEFT JOIN response_grades g ON g.attempt_id = r.attempt_id AND g.question_id = r.question_id`
+
+func scanResponseGrade(scanner interface{ Scan(...interface{}) error }) (models.ResponseGrade, error) {
+	var g models.ResponseGrade
+	var pending bool
+	var maxScore, aiScore, aiGradedAt, overrideScore, overrideAt sql.NullInt64
+	var aiGrader, aiScores, overrideScores, overrideComment, overrideBy sql.NullString
+	if err := scanner.Scan(&g.AttemptID, &g.QuestionID, &g.UserID, &g.Answer, &pending,
+		&maxScore, &aiGrader, &aiScores, &aiScore, &aiGradedAt,
+		&overrideScores, &overrideScore, &overrideComment, &overrideBy, &overrideAt); err != nil {
+		return g, err
+	}
+
+	g.MaxScore = int(maxScore.Int64)
+	if aiScore.Valid {
+		g.AI = &models.AIGrade{
+			Grader:   models.ModelProvider(aiGrader.String),
+			Score:    int(aiScore.Int64),
+			GradedAt: time.Unix(aiGradedAt.Int64, 0),
+		}
+		json.Unmarshal([]byte(aiScores.String), &g.AI.Scores)
+	}
+	if overrideScore.Valid {
+		g.Override = &models.GradeOverride{
+			Score:     int(overrideScore.Int64),
+			Comment:   overrideComment.String,
+			TeacherID: overrideBy.String,
+			CreatedAt: time.Unix(overrideAt.Int64, 0),
+		}
+		json.Unmarshal([]byte(overrideScores.String), &g.Override.Scores)
+	}
+
+	switch {
+	case g.Override != nil:
+		g.Status = models.GradeOverridden
+		g.Score = &g.Override.Score
+	case !pending && g.AI != nil:
+		g.Status = models.GradeAI
+		g.Score = &g.AI.Score
+	default:
+		g.Status = models.GradePending
+	}
+	return g, nil
+}
+
+// 查询一道题的作答及批改结果，按交卷时间排序
+func (s *GraderService) ListResponses(q models.ResponseGradeQuery) ([]models.ResponseGrade, error) {
+	defer metrics.ObserveDB("list_response_grades")()
+
+	query := responseGradeQuery + " WHERE r.question_id = ?"
+	args := []interface{}{q.QuestionID}
+	if q.AttemptID > 0 {
+		query += " AND r.attempt_id = ?"
+		args = append(args, q.AttemptID)
+	}
+	switch q.Status {
+	case "":
+	case models.GradePending:
+		query += " AND r.pending = 1"
+	case models.GradeAI:
+		query += " AND r.pending = 0 AND g.ai_score IS NOT NULL AND g.override_at IS NULL"
+	case models.GradeOverridden:
+		query += " AND g.override_at IS NOT NULL"
+	default:
+		return nil, fmt.Errorf("%w: 无效的批改状态%s", ErrInvalidGrade, q.Status)
+	}
+	query += " ORDER BY a.created_at, r.attempt_id"
+
+	rows, err := s.storage.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询作答失败: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.ResponseGrade{}
+	for rows.Next() {
+		g, err := scanResponseGrade(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描作答失败: %w", err)
+		}
+		list = append(list, g)
+	}
+	return list, rows.Err()
+}
+
+// 查询单个作答及批改结果
+func (s *GraderService) GetResponse(attemptID, questionID int64) (*models.ResponseGrade, error) {
+	defer metrics.ObserveDB("get_response_grade")()
+
+	g, err := scanResponseGrade(s.storage.DB.QueryRow(responseGradeQuery+" WHERE r.attempt_id = ? AND r.question_id = ? LIMIT 1", attemptID, questionID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: 答卷%d的题目%d", ErrResponseNotFound, attemptID, questionID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询作答失败: %w", err)
+	}
+	return &g, nil
+}
+
+// 调用模型按评分标准批改一个作答，已经批改过的作答重新批改，教师修改的分数保留。
+// 没有作答的直接记0分，不调用模型
+func (s *GraderService) Grade(ctx context.Context, attemptID, questionID int64, provider models.ModelProvider, userID string) (*models.ResponseGrade, error) {
+	response, err := s.GetResponse(attemptID, questionID)
+	if err != nil {
+		return nil, err
+	}
+	rubric, err := s.GetRubric(questionID)
+	if err != nil {
+		return nil, err
+	}
+	question, err := s.storage.GetQuestionByID(questionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if provider == "" {
+		provider = s.ai.config.ReviewModel
+	}
+	var scores []models.CriterionScore
+	if strings.TrimSpace(response.Answer) == "" {
+		for _, c := range rubric.Criteria {
+			scores = append(scores, models.CriterionScore{Criterion: c.Name, Points: c.Points, Justification: "未作答"})
+		}
+	} else {
+		scores, err = s.ai.GradeResponse(ctx, provider, userID, question, rubric.Criteria, response.Answer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.saveGrade(attemptID, questionID, rubric.MaxScore, func(tx *sql.Tx) error {
+		data, _ := json.Marshal(scores)
+		_, err := tx.Exec(`INSERT INTO response_grades (attempt_id, question_id, max_score, ai_grader, ai_scores, ai_score, ai_graded_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(attempt_id, question_id) DO UPDATE SET max_score = excluded.max_score, ai_grader = excluded.ai_grader,
+				ai_scores = excluded.ai_scores, ai_score = excluded.ai_score, ai_graded_at = excluded.ai_graded_at`,
+			attemptID, questionID, rubric.MaxScore, string(provider), string(data), sumScores(scores), s.now().Unix())
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return s.GetResponse(attemptID, questionID)
+}
+
+// 教师修改一个作答的分数，需要给出当前评分标准中每一项的得分。
+// 没有经过模型批改的作答也可以直接由教师打分
+func (s *GraderService) Override(teacherID string, attemptID, questionID int64, req models.GradeOverrideRequest) (*models.ResponseGrade, error) {
+	if _, err := s.GetResponse(attemptID, questionID); err != nil {
+		return nil, err
+	}
+	rubric, err := s.GetRubric(questionID)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := alignScores(rubric.Criteria, req.Scores)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGrade, err)
+	}
+
+	if err := s.saveGrade(attemptID, questionID, rubric.MaxScore, func(tx *sql.Tx) error {
+		data, _ := json.Marshal(scores)
+		_, err := tx.Exec(`INSERT INTO response_grades (attempt_id, question_id, max_score, override_scores, override_score, override_comment, override_by, override_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(attempt_id, question_id) DO UPDATE SET max_score = excluded.max_score, override_scores = excluded.override_scores,
+				override_score = excluded.override_score, override_comment = excluded.override_comment,
+				override_by = excluded.override_by, override_at = excluded.override_at`,
+			attemptID, questionID, rubric.MaxScore, string(data), sumScores(scores), nullIfEmpty(strings.TrimSpace(req.Comment)), teacherID, s.now().Unix())
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return s.GetResponse(attemptID, questionID)
+}
+
+// 教师给出的得分按评分标准的顺序排列，每一项必须出现且只出现一次，分数不能越界
+func alignScores(criteria []models.RubricCriterion, given []models.CriterionScore) ([]models.CriterionScore, error) {
+	byName := make(map[string]models.CriterionScore, len(given))
+	for _, item := range given {
+		name := strings.TrimSpace(item.Criterion)
+		if _, ok := byName[name]; ok {
+			return nil, fmt.Errorf("评分项重复: %s", name)
+		}
+		byName[name] = item
+	}
+	if len(byName) != len(criteria) {
+		return nil, fmt.Errorf("需要给出全部%d个评分项的得分", len(criteria))
+	}
+
+	scores := make([]models.CriterionScore, 0, len(criteria))
+	for _, c := range criteria {
+		item, ok := byName[c.Name]
+		if !ok {
+			return nil, fmt.Errorf("缺少评分项: %s", c.Name)
+		}
+		if item.Score < 0 || item.Score > c.Points {
+			return nil, fmt.Errorf("评分项%s的得分必须在0到%d之间", c.Name, c.Points)
+		}
+		scores = append(scores, models.CriterionScore{
+			Criterion:     c.Name,
+			Score:         item.Score,
+			Points:        c.Points,
+			Justification: strings.TrimSpace(item.Justification),
+		})
+	}
+	return scores, nil
+}
+
+func sumScores(scores []models.CriterionScore) int {
+	total := 0
+	for _, s := range scores {
+		total += s.Score
+	}
+	return total
+}
+
+// 在一个事务中写入批改结果，再按最终得分把作答标记为已批改并更新对错，
+// 对错变化时同步调整答卷的答对题数，题目分析因此能使用批改过的开放题
+func (s *GraderService) saveGrade(attemptID, questionID int64, maxScore int, write func(tx *sql.Tx) error) error {
+	defer metrics.ObserveDB("save_response_grade")()
+
+	tx, err := s.storage.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := write(tx); err != nil {
+		return fmt.Errorf("保存批改结果失败: %w", err)
+	}
+
+	var final int
+	if err := tx.QueryRow("SELECT COALESCE(override_score, ai_score) FROM response_grades WHERE attempt_id = ? AND question_id = ?",
+		attemptID, questionID).Scan(&final); err != nil {
+		return fmt.Errorf("查询批改结果失败: %w", err)
+	}
+	correct := maxScore > 0 && float64(final) >= models.PassRatio*float64(maxScore)
+
+	var wasCorrect bool
+	if err := tx.QueryRow("SELECT correct FROM exam_responses WHERE attempt_id = ? AND question_id = ? LIMIT 1",
+		attemptID, questionID).Scan(&wasCorrect); err != nil {
+		return fmt.Errorf("查询作答失败: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE exam_responses SET pending = 0, correct = ? WHERE attempt_id = ? AND question_id = ?",
+		correct, attemptID, questionID); err != nil {
+		return fmt.Errorf("更新作答失败: %w", err)
+	}
+	if correct != wasCorrect {
+		delta := 1
+		if !correct {
+			delta = -1
+		}
+		if _, err := tx.Exec("UPDATE exam_attempts SET correct = correct + ? WHERE id = ?", delta, attemptID); err != nil {
+			return fmt.Errorf("更新答卷失败: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// 提交批量批改任务，在后台逐个批改questionIDs的全部等待批改的作答。
+// 每道题都必须已经设置评分标准
+func (s *GraderService) StartJob(userID string, req models.GradingJobRequest) (*models.GradingJob, error) {
+	if len(req.QuestionIDs) == 0 {
+		return nil, fmt.Errorf("%w: 题目不能为空", ErrInvalidGrade)
+	}
+	for _, id := range req.QuestionIDs {
+		if _, err := s.GetRubric(id); err != nil {
+			return nil, err
+		}
+	}
+	provider := req.Provider
+	if provider == "" {
+		provider = s.ai.config.ReviewModel
+	}
+
+	targets, err := s.pendingResponses(req.QuestionIDs, req.AttemptIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.GradingJob{
+		UserID:      userID,
+		QuestionIDs: req.QuestionIDs,
+		AttemptIDs:  req.AttemptIDs,
+		Provider:    provider,
+		Status:      models.JobRunning,
+		Total:       len(targets),
+		CreatedAt:   time.Unix(s.now().Unix(), 0),
+	}
+	questionIDs, _ := json.Marshal(job.QuestionIDs)
+	var attemptIDs interface{}
+	if len(job.AttemptIDs) > 0 {
+		data, _ := json.Marshal(job.AttemptIDs)
+		attemptIDs = string(data)
+	}
+	res, err := s.storage.DB.Exec(`INSERT INTO grading_jobs (user_id, question_ids, attempt_ids, provider, status, total, graded, failed, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, 0, 0, ?)`,
+		userID, string(questionIDs), attemptIDs, string(provider), string(job.Status), job.Total, job.CreatedAt.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("保存批改任务失败: %w", err)
+	}
+	if job.ID, err = res.LastInsertId(); err != nil {
+		return nil, fmt.Errorf("获取批改任务ID失败: %w", err)
+	}
+
+	started := s.jobs.Go(func(ctx context.Context) {
+		s.runJob(ctx, *job, targets)
+	})
+	if !started {
+		s.finishJob(job, models.JobCanceled)
+		return nil, ErrShuttingDown
+	}
+	return job, nil
+}
+
+// 等待批改的一个作答
+type responseKey struct {
+	attemptID  int64
+	questionID int64
+}
+
+// 查询等待批改的作答，attemptIDs为空时不限制答卷
+func (s *GraderService) pendingResponses(questionIDs, attemptIDs []int64) ([]responseKey, error) {
+	defer metrics.ObserveDB("pending_responses")()
+
+	query := "SELECT DISTINCT attempt_id, question_id FROM exam_responses WHERE pending = 1 AND question_id IN (" + sqlPlaceholders(len(questionIDs)) + ")"
+	args := make([]interface{}, 0, len(questionIDs)+len(attemptIDs))
+	for _, id := range questionIDs {
+		args = append(args, id)
+	}
+	if len(attemptIDs) > 0 {
+		query += " AND attempt_id IN (" + sqlPlaceholders(len(attemptIDs)) + ")"
+		for _, id := range attemptIDs {
+			args = append(args, id)
+		}
+	}
+	query += " ORDER BY attempt_id, question_id"
+
+	rows, err := s.storage.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询等待批改的作答失败: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []responseKey
+	for rows.Next() {
+		var k responseKey
+		if err := rows.Scan(&k.attemptID, &k.questionID); err != nil {
+			return nil, fmt.Errorf("扫描作答失败: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// 逐个批改并记录进度。配额用完时剩下的作答都记为失败；
+// ctx取消时任务标记为已中止，没有批改的作答仍然等待批改
+func (s *GraderService) runJob(ctx context.Context, job models.GradingJob, targets []responseKey) {
+	for i, target := range targets {
+		if ctx.Err() != nil {
+			s.finishJob(&job, models.JobCanceled)
+			return
+		}
+
+		_, err := s.Grade(ctx, target.attemptID, target.questionID, job.Provider, job.UserID)
+		if err == nil {
+			job.Graded++
+		} else {
+			job.Failed++
+			job.LastError = err.Error()
+			slog.Warn("批改作答失败", "job_id", job.ID, "attempt_id", target.attemptID, "question_id", target.questionID, "error", err)
+			if errors.Is(err, ErrQuotaExceeded) {
+				job.Failed += len(targets) - i - 1
+				break
+			}
+		}
+		s.updateJob(&job)
+	}
+	s.finishJob(&job, models.JobDone)
+}
+
+func (s *GraderService) updateJob(job *models.GradingJob) {
+	defer metrics.ObserveDB("update_grading_job")()
+
+	if _, err := s.storage.DB.Exec("UPDATE grading_jobs SET graded = ?, failed = ?, last_error = ? WHERE id = ?",
+		job.Graded, job.Failed, nullIfEmpty(job.LastError), job.ID); err != nil {
+		slog.Error("更新批改任务进度失败", "job_id", job.ID, "error", err)
+	}
+}
+
+func (s *GraderService) finishJob(job *models.GradingJob, status models.GradingJobStatus) {
+	defer metrics.ObserveDB("update_grading_job")()
+
+	if _, err := s.storage.DB.Exec("UPDATE grading_jobs SET status = ?, graded = ?, failed = ?, last_error = ?, finished_at = ? WHERE id = ?",
+		string(status), job.Graded, job.Failed, nullIfEmpty(job.LastError), s.now().Unix(), job.ID); err != nil {
+		slog.Error("更新批改任务状态失败", "job_id", job.ID, "error", err)
+	}
+	slog.Info("批改任务结束", "job_id", job.ID, "status", status, "graded", job.Graded, "failed", job.Failed)
+}
+
+// 查询批改任务
+func (s *GraderService) GetJob(id int64) (*models.GradingJob, error) {
+	defer metrics.ObserveDB("get_grading_job")()
+
+	var job models.GradingJob
+	var questionIDs string
+	var attemptIDs, lastError sql.NullString
+	var createdAt int64
+	var finishedAt sql.NullInt64
+	err := s.storage.DB.QueryRow(`SELECT id, user_id, question_ids, attempt_ids, provider, status, total, graded, failed, last_error, created_at, finished_at
+		FROM grading_jobs WHERE id = ?`, id).Scan(&job.ID, &job.UserID, &questionIDs, &attemptIDs, &job.Provider, &job.Status,
+		&job.Total, &job.Graded, &job.Failed, &lastError, &createdAt, &finishedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: ID=%d", ErrGradingJobNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询批改任务失败: %w", err)
+	}
+
+	json.Unmarshal([]byte(questionIDs), &job.QuestionIDs)
+	if attemptIDs.Valid {
+		json.Unmarshal([]byte(attemptIDs.String), &job.AttemptIDs)
+	}
+	job.LastError = lastError.String
+	job.CreatedAt = time.Unix(createdAt, 0)
+	if finishedAt.Valid {
+		t := time.Unix(finishedAt.Int64, 0)
+		job.FinishedAt = &t
+	}
+	return &job, nil
+}
+
+// 比较一道题上模型和教师的评分，只统计两者都打过分的作答
+func (s *GraderService) Calibration(questionID int64) (*models.GradingCalibration, error) {
+	defer metrics.ObserveDB("grading_calibration")()
+
+	rows, err := s.storage.DB.Query(`SELECT max_score, ai_scores, ai_score, override_scores, override_score
+		FROM response_grades
+		WHERE question_id = ? AND ai_score IS NOT NULL AND override_score IS NOT NULL`, questionID)
+	if err != nil {
+		return nil, fmt.Errorf("查询批改结果失败: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.GradingCalibration{QuestionID: questionID, Criteria: []models.CriterionCalibration{}}
+	criteria := make(map[string]*models.CriterionCalibration)
+	var order []string
+	var sumDiff, sumAbs float64
+	agree := 0
+	for rows.Next() {
+		var maxScore, aiScore, overrideScore int
+		var aiScores, overrideScores string
+		if err := rows.Scan(&maxScore, &aiScores, &aiScore, &overrideScores, &overrideScore); err != nil {
+			return nil, fmt.Errorf("扫描批改结果失败: %w", err)
+		}
+
+		result.Samples++
+		diff := float64(aiScore - overrideScore)
+		sumDiff += diff
+		sumAbs += math.Abs(diff)
+		pass := models.PassRatio * float64(maxScore)
+		if (float64(aiScore) >= pass) == (float64(overrideScore) >= pass) {
+			agree++
+		}
+
+		var ai, teacher []models.CriterionScore
+		json.Unmarshal([]byte(aiScores), &ai)
+		json.Unmarshal([]byte(overrideScores), &teacher)
+		aiByName := make(map[string]int, len(ai))
+		for _, item := range ai {
+			aiByName[item.Criterion] = item.Score
+		}
+		// 评分标准修改过时只比较两次都有的评分项
+		for _, item := range teacher {
+			aiItem, ok := aiByName[item.Criterion]
+			if !ok {
+				continue
+			}
+			c, ok := criteria[item.Criterion]
+			if !ok {
+				c = &models.CriterionCalibration{Criterion: item.Criterion}
+				criteria[item.Criterion] = c
+				order = append(order, item.Criterion)
+			}
+			d := float64(aiItem - item.Score)
+			c.Samples++
+			c.MeanDiff += d
+			c.MeanAbsDiff += math.Abs(d)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取批改结果失败: %w", err)
+	}
+
+	if result.Samples > 0 {
+		n := float64(result.Samples)
+		result.MeanDiff = sumDiff / n
+		result.MeanAbsDiff = sumAbs / n
+		result.Agreement = float64(agree) / n
+	}
+	for _, name := range order {
+		c := criteria[name]
+		c.MeanDiff /= float64(c.Samples)
+		c.MeanAbsDiff /= float64(c.Samples)
+		result.Criteria = append(result.Criteria, *c)
+	}
+	return result, nil
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"question-generator/models"
+	"strings"
+	"testing"
+)
+
+// 测试用的评分标准，与录制的批改响应中的评分项一致
+var testCriteria = []models.RubricCriterion{
+	{Name: "概念准确", Description: "说明执行时机和顺序", Points: 6},
+	{Name: "表达清晰", Points: 4},
+}
+
+func TestRubric(t *testing.T) {
+	storage := newTestStorage(t)
+	client, _ := newMockAIClient(t, "")
+	grader, err := NewGraderService(storage, client, NewBackgroundJobs())
+	if err != nil {
+		t.Fatalf("NewGraderService: %v", err)
+	}
+
+	short, _ := storage.AddQuestion(&models.QuestionData{AIReq: models.QuestionRequest{Type: models.ShortAnswer}, AIRes: models.AIResponse{Title: "简述defer", Reference: "后进先出"}})
+	choice, _ := storage.AddQuestion(choiceQuestion("单选", models.SingleChoice, 0))
+
+	if _, err := grader.GetRubric(short); !errors.Is(err, ErrNoRubric) {
+		t.Errorf("GetRubric before set err = %v", err)
+	}
+	if _, err := grader.SetRubric(choice, testCriteria); !errors.Is(err, ErrRubricNotSupported) {
+		t.Errorf("rubric on choice question err = %v", err)
+	}
+	if _, err := grader.SetRubric(999, testCriteria); !errors.Is(err, ErrQuestionNotFound) {
+		t.Errorf("rubric on missing question err = %v", err)
+	}
+	invalid := [][]models.RubricCriterion{
+		nil,
+		{{Name: " ", Points: 1}},
+		{{Name: "a", Points: 1}, {Name: "a", Points: 2}},
+		{{Name: "a", Points: 0}},
+	}
+	for _, criteria := range invalid {
+		if _, err := grader.SetRubric(short, criteria); !errors.Is(err, ErrInvalidGrade) {
+			t.Errorf("SetRubric(%v) err = %v", criteria, err)
+		}
+	}
+
+	if _, err := grader.SetRubric(short, testCriteria); err != nil {
+		t.Fatalf("SetRubric: %v", err)
+	}
+	rubric, err := grader.GetRubric(short)
+	if err != nil || rubric.MaxScore != 10 || len(rubric.Criteria) != 2 || rubric.Criteria[0].Description != "说明执行时机和顺序" {
+		t.Errorf("GetRubric = %+v, %v", rubric, err)
+	}
+}
+
+func TestGradeAndOverride(t *testing.T) {
+	storage := newTestStorage(t)
+	client, mock := newMockAIClient(t, "")
+	jobs := NewBackgroundJobs()
+	grader, err := NewGraderService(storage, client, jobs)
+	if err != nil {
+		t.Fatalf("NewGraderService: %v", err)
+	}
+
+	q, _ := storage.AddQuestion(&models.QuestionData{AIReq: models.QuestionRequest{Type: models.ShortAnswer}, AIRes: models.AIResponse{Title: "简述defer", Reference: "后进先出"}})
+	attempts := make(map[string]int64)
+	for user, text := range map[string]string{"alice": "函数返回前按后进先出执行", "bob": "最后执行", "carol": ""} {
+		result, err := storage.GradeExam(user, []models.ExamAnswer{{QuestionID: q, Text: text}})
+		if err != nil {
+			t.Fatalf("GradeExam: %v", err)
+		}
+		attempts[user] = result.AttemptID
+	}
+
+	if _, err := grader.Grade(context.Background(), attempts["alice"], q, "", "teacher"); !errors.Is(err, ErrNoRubric) {
+		t.Fatalf("grade without rubric err = %v", err)
+	}
+	if _, err := grader.SetRubric(q, testCriteria); err != nil {
+		t.Fatalf("SetRubric: %v", err)
+	}
+
+	grade, err := grader.Grade(context.Background(), attempts["alice"], q, "", "teacher")
+	if err != nil {
+		t.Fatalf("Grade: %v", err)
+	}
+	if grade.Status != models.GradeAI || *grade.Score != 6 || grade.MaxScore != 10 || grade.AI.Scores[0].Justification == "" {
+		t.Errorf("AI grade = %+v", grade)
+	}
+	// 得分率达到60%记为答对，答卷的答对题数随之更新
+	var correct int
+	storage.DB.QueryRow("SELECT correct FROM exam_attempts WHERE id = ?", attempts["alice"]).Scan(&correct)
+	if correct != 1 {
+		t.Errorf("attempt correct = %d, want 1", correct)
+	}
+
+	// 分数越界的输出被拒绝，作答仍然等待批改
+	mock.Enqueue("grade_out_of_range")
+	if _, err := grader.Grade(context.Background(), attempts["bob"], q, "", "teacher"); err == nil || !strings.Contains(err.Error(), "超出") {
+		t.Errorf("out of range err = %v", err)
+	}
+	if g, _ := grader.GetResponse(attempts["bob"], q); g.Status != models.GradePending {
+		t.Errorf("bob status = %s", g.Status)
+	}
+
+	// 批量批改剩下的作答，没有作答的不调用模型
+	before := len(mock.Requests())
+	job, err := grader.StartJob("teacher", models.GradingJobRequest{QuestionIDs: []int64{q}})
+	if err != nil {
+		t.Fatalf("StartJob: %v", err)
+	}
+	if job.Total != 2 {
+		t.Errorf("job total = %d, want 2", job.Total)
+	}
+	if err := jobs.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	job, err = grader.GetJob(job.ID)
+	if err != nil || job.Status != models.JobDone || job.Graded != 2 || job.Failed != 0 || job.FinishedAt == nil {
+		t.Errorf("finished job = %+v, %v", job, err)
+	}
+	if calls := len(mock.Requests()) - before; calls != 1 {
+		t.Errorf("model calls = %d, want 1", calls)
+	}
+	if g, _ := grader.GetResponse(attempts["carol"], q); *g.Score != 0 || g.AI.Scores[0].Justification != "未作答" {
+		t.Errorf("empty answer grade = %+v", g.AI)
+	}
+	if _, err := grader.StartJob("teacher", models.GradingJobRequest{QuestionIDs: []int64{q}}); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("StartJob after shutdown err = %v", err)
+	}
+
+	// 教师改分需要给出每一项，改分后以教师为准
+	if _, err := grader.Override("teacher", attempts["alice"], q, models.GradeOverrideRequest{
+		Scores: []models.CriterionScore{{Criterion: "概念准确", Score: 2}},
+	}); !errors.Is(err, ErrInvalidGrade) {
+		t.Errorf("partial override err = %v", err)
+	}
+	grade, err = grader.Override("teacher", attempts["alice"], q, models.GradeOverrideRequest{
+		Scores:  []models.CriterionScore{{Criterion: "表达清晰", Score: 1}, {Criterion: "概念准确", Score: 2}},
+		Comment: "没有答出参数求值时机",
+	})
+	if err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	if grade.Status != models.GradeOverridden || *grade.Score != 3 || grade.AI.Score != 6 || grade.Override.TeacherID != "teacher" {
+		t.Errorf("overridden grade = %+v", grade)
+	}
+	storage.DB.QueryRow("SELECT correct FROM exam_attempts WHERE id = ?", attempts["alice"]).Scan(&correct)
+	if correct != 0 {
+		t.Errorf("attempt correct after override = %d, want 0", correct)
+	}
+
+	list, err := grader.ListResponses(models.ResponseGradeQuery{QuestionID: q, Status: models.GradeOverridden})
+	if err != nil || len(list) != 1 || list[0].UserID != "alice" {
+		t.Errorf("overridden list = %+v, %v", list, err)
+	}
+
+	calibration, err := grader.Calibration(q)
+	if err != nil {
+		t.Fatalf("Calibration: %v", err)
+	}
+	if calibration.Samples != 1 || calibration.MeanDiff != 3 || calibration.Agreement != 0 || len(calibration.Criteria) != 2 {
+		t.Errorf("calibration = %+v", calibration)
+	}
+}
+
+func TestParseGradeContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "正常", content: `{"scores":[{"criterion":"表达清晰","score":4,"justification":"好"},{"criterion":"概念准确","score":0,"justification":"错"}]}`},
+		{name: "代码块", content: "```json\n{\"scores\":[{\"criterion\":\"概念准确\",\"score\":1,\"justification\":\"a\"},{\"criterion\":\"表达清晰\",\"score\":1,\"justification\":\"b\"}]}\n```"},
+		{name: "多余字段", content: `{"scores":[],"total":3}`, wantErr: "unknown field"},
+		{name: "缺少评分项", content: `{"scores":[{"criterion":"概念准确","score":1,"justification":"a"}]}`, wantErr: "评分标准有2项"},
+		{name: "未知评分项", content: `{"scores":[{"criterion":"概念准确","score":1,"justification":"a"},{"criterion":"格式","score":1,"justification":"b"}]}`, wantErr: "缺少评分项"},
+		{name: "重复评分项", content: `{"scores":[{"criterion":"概念准确","score":1,"justification":"a"},{"criterion":"概念准确","score":1,"justification":"b"}]}`, wantErr: "重复"},
+		{name: "负分", content: `{"scores":[{"criterion":"概念准确","score":-1,"justification":"a"},{"criterion":"表达清晰","score":1,"justification":"b"}]}`, wantErr: "超出"},
+		{name: "缺少理由", content: `{"scores":[{"criterion":"概念准确","score":1,"justification":""},{"criterion":"表达清晰","score":1,"justification":"b"}]}`, wantErr: "缺少理由"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores, err := parseGradeContent(tt.content, models.OutputJSONObject, testCriteria)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGradeContent: %v", err)
+			}
+			// 按评分标准的顺序返回，带上每项满分
+			if len(scores) != 2 || scores[0].Criterion != "概念准确" || scores[1].Points != 4 {
+				t.Errorf("scores = %+v", scores)
+			}
+		})
+	}
+}
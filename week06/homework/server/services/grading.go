@@ -0,0 +1,159 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"question-generator/metrics"
+	"question-generator/models"
+	"time"
+)
+
+// 题型不能在线作答，例如编程题
+var ErrNotGradable = errors.New("该题型不支持在线作答")
+
+// 批改一份试卷，返回每道题的对错以及解析，并保存答卷供题目分析使用
+func (s *StorageService) GradeExam(userID string, answers []models.ExamAnswer) (*models.ExamResult, error) {
+	result := &models.ExamResult{
+		Total:   len(answers),
+		Results: make([]models.ExamQuestionResult, 0, len(answers)),
+	}
+
+	for _, answer := range answers {
+		question, err := s.GetQuestionByID(answer.QuestionID)
+		if err != nil {
+			return nil, err
+		}
+
+		item, err := gradeAnswer(question, answer)
+		if err != nil {
+			return nil, err
+		}
+		if item.Correct {
+			result.Correct++
+		}
+		if item.Pending {
+			result.Pending++
+		}
+		result.Results = append(result.Results, item)
+	}
+
+	// 得分只按已批改的题目计算
+	if graded := result.Total - result.Pending; graded > 0 {
+		result.Score = float64(result.Correct) * 100 / float64(graded)
+	}
+
+	attemptID, err := s.recordAttempt(userID, result)
+	if err != nil {
+		return nil, err
+	}
+	result.AttemptID = attemptID
+
+	return result, nil
+}
+
+// 按题型批改单道题，不能在线作答的题型返回错误
+func gradeAnswer(question *models.QuestionData, answer models.ExamAnswer) (models.ExamQuestionResult, error) {
+	item := models.ExamQuestionResult{
+		QuestionID:  question.ID,
+		Title:       question.AIRes.Title,
+		Explanation: question.AIRes.Explanation,
+	}
+	spec, ok := models.LookupQuestionType(question.AIReq.Type)
+	if !ok || spec.Grade == nil {
+		return item, fmt.Errorf("%w: ID=%d", ErrNotGradable, question.ID)
+	}
+	spec.Grade(&question.AIRes, answer, &item)
+	return item, nil
+}
+
+// 去掉答案和解析，只保留考生作答需要的内容
+func studentQuestion(q *models.QuestionData) *models.StudentQuestion {
+	rendered := q.HTML
+	if rendered == nil {
+		rendered = q.RenderHTML()
+	}
+	sq := &models.StudentQuestion{
+		ID:         q.ID,
+		Type:       q.AIReq.Type,
+		Input:      models.InputNone,
+		Language:   q.AIReq.Language,
+		Title:      q.AIRes.Title,
+		Options:    q.AIRes.Answer,
+		BlankCount: len(q.AIRes.Blanks),
+		Tags:       q.Tags,
+		HTML:       &models.QuestionHTML{Title: rendered.Title, Options: rendered.Options},
+	}
+	if spec, ok := models.LookupQuestionType(q.AIReq.Type); ok {
+		sq.Input = spec.Input
+	}
+	return sq
+}
+
+// 在一个事务中保存答卷和每道题的作答，并记录每道题被作答的次数
+func (s *StorageService) recordAttempt(userID string, result *models.ExamResult) (int64, error) {
+	defer metrics.ObserveDB("record_attempt")()
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO exam_attempts (user_id, total, correct, created_at) VALUES (?, ?, ?, ?)",
+		userID, result.Total, result.Correct, time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("保存答卷失败: %w", err)
+	}
+	attemptID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("获取答卷ID失败: %w", err)
+	}
+
+	args := make([]interface{}, 0, len(result.Results))
+	for _, item := range result.Results {
+		selected, _ := json.Marshal(nonNilInts(item.Selected))
+		if _, err := tx.Exec("INSERT INTO exam_responses (attempt_id, question_id, selected, correct, answer_text, pending) VALUES (?, ?, ?, ?, ?, ?)",
+			attemptID, item.QuestionID, string(selected), item.Correct, responseText(item), item.Pending); err != nil {
+			return 0, fmt.Errorf("保存作答失败: %w", err)
+		}
+		args = append(args, item.QuestionID)
+	}
+
+	if len(args) > 0 {
+		query := "UPDATE questions SET usage_count = usage_count + 1 WHERE id IN (" + sqlPlaceholders(len(args)) + ")"
+		if _, err := tx.Exec(query, args...); err != nil {
+			return 0, fmt.Errorf("更新题目使用次数失败: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return attemptID, nil
+}
+
+// 填空题和简答题的作答文字，选择题为NULL
+func responseText(item models.ExamQuestionResult) interface{} {
+	if item.Blanks != nil {
+		data, _ := json.Marshal(item.Blanks)
+		return string(data)
+	}
+	return nullIfEmpty(item.Text)
+}
+
+func nonNilInts(list []int) []int {
+	if list == nil {
+		return []int{}
+	}
+	return list
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"math"
+	"question-generator/models"
+)
+
+// 双参数logistic模型的量表因子，使logistic曲线接近正态肩形曲线
+const irtScale = 1.7
+
+// 能力值的取值范围，题目难度同样限制在该范围内
+const (
+	minTheta = -4.0
+	maxTheta = 4.0
+)
+
+// 能力为theta的考生答对该题的概率
+func irtProbability(p models.ItemParams, theta float64) float64 {
+	return 1 / (1 + math.Exp(-irtScale*p.A*(theta-p.B)))
+}
+
+// 该题在theta处提供的Fisher信息量
+func irtInformation(p models.ItemParams, theta float64) float64 {
+	prob := irtProbability(p, theta)
+	return irtScale * irtScale * p.A * p.A * prob * (1 - prob)
+}
+
+// 一次作答及其题目参数
+type irtResponse struct {
+	params  models.ItemParams
+	correct bool
+}
+
+// 用期望后验（EAP）估计能力值和标准误，先验为标准正态分布。
+// 全对或全错时极大似然估计不存在，EAP仍能给出有限的估计
+func estimateAbility(responses []irtResponse) (theta, se float64) {
+	const step = 0.05
+
+	var sum, sumTheta float64
+	var points, weights []float64
+	for t := minTheta; t <= maxTheta+step/2; t += step {
+		logWeight := -t * t / 2
+		for _, r := range responses {
+			prob := irtProbability(r.params, t)
+			if r.correct {
+				logWeight += math.Log(prob)
+			} else {
+				logWeight += math.Log(1 - prob)
+			}
+		}
+		w := math.Exp(logWeight)
+		points = append(points, t)
+		weights = append(weights, w)
+		sum += w
+		sumTheta += w * t
+	}
+	if sum == 0 {
+		return 0, 1
+	}
+
+	theta = sumTheta / sum
+	var variance float64
+	for i, t := range points {
+		variance += weights[i] * (t - theta) * (t - theta)
+	}
+	return theta, math.Sqrt(variance / sum)
+}
+
+// 没有足够作答记录时由标注难度推算的参数
+func paramsFromLabel(d models.QuestionDifficulty) models.ItemParams {
+	switch d {
+	case models.Easy:
+		return models.ItemParams{A: 1, B: -1}
+	case models.Hard:
+		return models.ItemParams{A: 1, B: 1}
+	default:
+		return models.ItemParams{A: 1, B: 0}
+	}
+}
+
+// 由答对比例和点二列相关系数近似估计参数（Lord的正态肩形换算）。
+// 区分度无法计算或不为正时区分度取1，只估计难度
+func paramsFromStats(p float64, pointBiserial *float64) models.ItemParams {
+	// 避免全对或全错时难度趋于无穷
+	p = math.Min(math.Max(p, 0.02), 0.98)
+	z := math.Sqrt2 * math.Erfinv(1-2*p) // 答对比例为p时对应的正态分位数
+
+	params := models.ItemParams{A: 1, B: z, Calibrated: true}
+	if pointBiserial != nil && *pointBiserial > 0 {
+		// 点二列相关换算为二列相关
+		density := math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+		biserial := math.Min(*pointBiserial*math.Sqrt(p*(1-p))/density, 0.95)
+		params.A = math.Min(math.Max(biserial/math.Sqrt(1-biserial*biserial), 0.2), 2.5)
+		params.B = z / biserial
+	}
+	params.B = math.Min(math.Max(params.B, minTheta), maxTheta)
+	return params
+}
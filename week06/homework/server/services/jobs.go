@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// 跟踪请求之外运行的后台任务，关闭服务时等待它们完成
+type BackgroundJobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// 创建后台任务组
+func NewBackgroundJobs() *BackgroundJobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BackgroundJobs{ctx: ctx, cancel: cancel}
+}
+
+// 启动一个后台任务，开始关闭后不再接受新任务并返回false。
+// 任务应在ctx取消时尽快退出
+func (j *BackgroundJobs) Go(fn func(ctx context.Context)) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return false
+	}
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		fn(j.ctx)
+	}()
+	return true
+}
+
+// 等待所有后台任务完成。ctx到期时取消仍在运行的任务并返回ctx的错误
+func (j *BackgroundJobs) Shutdown(ctx context.Context) error {
+	j.mu.Lock()
+	j.closed = true
+	j.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		j.cancel()
+		return nil
+	case <-ctx.Done():
+		j.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
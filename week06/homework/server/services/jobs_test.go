@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackgroundJobsShutdownWaits(t *testing.T) {
+	jobs := NewBackgroundJobs()
+
+	var finished atomic.Int32
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		jobs.Go(func(ctx context.Context) {
+			<-release
+			finished.Add(1)
+		})
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	if err := jobs.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if finished.Load() != 3 {
+		t.Errorf("finished = %d, want 3", finished.Load())
+	}
+	if jobs.Go(func(ctx context.Context) {}) {
+		t.Error("job accepted after shutdown")
+	}
+}
+
+func TestBackgroundJobsShutdownTimeout(t *testing.T) {
+	jobs := NewBackgroundJobs()
+
+	canceled := make(chan struct{})
+	jobs.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := jobs.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	select {
+	case <-canceled:
+	default:
+		t.Error("job context not canceled after timeout")
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"question-generator/models"
+	"strings"
+)
+
+// 宽松解析的结果统计
+type SalvageReport struct {
+	Salvaged int // 成功恢复的题目数
+	Dropped  int // 因格式错误或被截断而丢弃的题目数
+}
+
+// 宽松解析模型返回的题目JSON：
+// 去掉markdown代码块、注释和多余的尾逗号，把充当分隔符的中文引号换成英文引号，
+// 整体解析失败时逐个恢复questions数组中完整的题目对象
+func salvageBatchQuestions(content string) (*models.AIBatchResponse, SalvageReport, error) {
+	var report SalvageReport
+
+	cleaned := removeTrailingCommas(normalizeJSONText(stripCodeFence(content)))
+
+	var strict models.AIBatchResponse
+	if err := json.Unmarshal([]byte(cleaned), &strict); err == nil && len(strict.Questions) > 0 {
+		report.Salvaged = len(strict.Questions)
+		return &strict, report, nil
+	}
+
+	// 定位题目数组：优先找questions字段，否则把第一个'['当作数组开头
+	arrayStart := -1
+	if keyIdx := strings.Index(cleaned, `"questions"`); keyIdx >= 0 {
+		if idx := strings.IndexByte(cleaned[keyIdx:], '['); idx >= 0 {
+			arrayStart = keyIdx + idx
+		}
+	} else {
+		arrayStart = strings.IndexByte(cleaned, '[')
+	}
+	if arrayStart < 0 {
+		return nil, report, fmt.Errorf("未找到题目数组")
+	}
+
+	var batchResponse models.AIBatchResponse
+	for _, raw := range splitArrayObjects(cleaned[arrayStart+1:], &report) {
+		var question models.AIQuestion
+		if err := json.Unmarshal([]byte(raw), &question); err != nil || question.Title == "" {
+			report.Dropped++
+			continue
+		}
+		batchResponse.Questions = append(batchResponse.Questions, question)
+		report.Salvaged++
+	}
+
+	if len(batchResponse.Questions) == 0 {
+		return nil, report, fmt.Errorf("没有可恢复的完整题目，丢弃%d个", report.Dropped)
+	}
+
+	return &batchResponse, report, nil
+}
+
+// 去掉包裹内容的markdown代码块标记，代码块前后的说明文字一并去掉
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	start := strings.Index(content, "```")
+	if start < 0 {
+		return content
+	}
+
+	body := content[start+3:]
+	// 跳过```json这样的语言标记
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 && !strings.ContainsAny(body[:nl], "{[") {
+		body = body[nl+1:]
+	}
+	if end := strings.LastIndex(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body)
+}
+
+// 在字符串字面量之外去掉//和/* */注释，并把“”当作字符串分隔符换成英文双引号；
+// 英文双引号字符串内部的中文引号原样保留
+func normalizeJSONText(content string) string {
+	var sb strings.Builder
+	sb.Grow(len(content))
+
+	runes := []rune(content)
+	inString := false
+	var closing rune // 当前字符串的结束引号，'"'或'”'
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if inString {
+			switch {
+			case r == '\\' && i+1 < len(runes):
+				sb.WriteRune(r)
+				i++
+				sb.WriteRune(runes[i])
+			case r == closing:
+				sb.WriteByte('"')
+				inString = false
+			case r == '"':
+				// 中文引号开头的字符串里出现英文引号，需要转义
+				sb.WriteString(`\"`)
+			case r == '\n':
+				sb.WriteString(`\n`)
+			default:
+				sb.WriteRune(r)
+			}
+			continue
+		}
+
+		switch {
+		case r == '"':
+			inString, closing = true, '"'
+			sb.WriteByte('"')
+		case r == '“' || r == '”':
+			inString, closing = true, '”'
+			sb.WriteByte('"')
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			if i < len(runes) {
+				sb.WriteRune('\n')
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i++
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// 去掉}或]前面多余的逗号，只处理字符串字面量之外的逗号
+func removeTrailingCommas(content string) string {
+	var sb strings.Builder
+	sb.Grow(len(content))
+
+	inString := false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		if inString {
+			sb.WriteByte(c)
+			if c == '\\' && i+1 < len(content) {
+				i++
+				sb.WriteByte(content[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		if c == '"' {
+			inString = true
+		}
+
+		if c == ',' {
+			j := i + 1
+			for j < len(content) && strings.IndexByte(" \t\r\n", content[j]) >= 0 {
+				j++
+			}
+			if j < len(content) && (content[j] == '}' || content[j] == ']') {
+				continue
+			}
+		}
+
+		sb.WriteByte(c)
+	}
+
+	return sb.String()
+}
+
+// 从数组内容中切出每个顶层对象的原始文本，遇到数组结尾停止；
+// 结尾没有闭合的对象（被截断）计入丢弃数
+func splitArrayObjects(content string, report *SalvageReport) []string {
+	var objects []string
+
+	depth := 0
+	start := -1
+	inString := false
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				objects = append(objects, content[start:i+1])
+				start = -1
+			}
+		case ']':
+			if depth == 0 {
+				return objects
+			}
+		}
+	}
+
+	if start >= 0 {
+		report.Dropped++
+	}
+
+	return objects
+}
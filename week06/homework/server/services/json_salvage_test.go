@@ -0,0 +1,192 @@
+package services
+
+import (
+	"encoding/json"
+	"question-generator/models"
+	"strings"
+	"testing"
+)
+
+func TestSalvageBatchQuestions(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		titles   []string
+		salvaged int
+		dropped  int
+		wantErr  bool
+	}{
+		{
+			name:     "合法JSON",
+			content:  `{"questions":[{"title":"a","options":["x","y"],"right":[0]}]}`,
+			titles:   []string{"a"},
+			salvaged: 1,
+		},
+		{
+			name: "注释和尾逗号",
+			content: `{
+  "questions": [
+    {"title": "a", "options": ["x", "y",], "right": [1],},
+    // 更多题目...
+  ],
+}`,
+			titles:   []string{"a"},
+			salvaged: 1,
+		},
+		{
+			name:     "块注释",
+			content:  `{"questions":[/* 第一题 */{"title":"a","options":[],"right":[]}]}`,
+			titles:   []string{"a"},
+			salvaged: 1,
+		},
+		{
+			name:     "中文引号作为分隔符",
+			content:  `{“questions”:[{“title”:“a”,“options”:[“x”,“y”],“right”:[0]}]}`,
+			titles:   []string{"a"},
+			salvaged: 1,
+		},
+		{
+			name:     "字符串内的中文引号保留",
+			content:  `{"questions":[{"title":"什么是“闭包”","options":["x","y"],"right":[0]}]}`,
+			titles:   []string{"什么是“闭包”"},
+			salvaged: 1,
+		},
+		{
+			name:     "字符串内的注释符号保留",
+			content:  `{"questions":[{"title":"访问 http://example.com // 注释","options":[],"right":[]}]}`,
+			titles:   []string{"访问 http://example.com // 注释"},
+			salvaged: 1,
+		},
+		{
+			name:     "markdown代码块和前后说明",
+			content:  "下面是题目：\n```json\n{\"questions\":[{\"title\":\"a\",\"options\":[],\"right\":[]}]}\n```\n希望对你有帮助",
+			titles:   []string{"a"},
+			salvaged: 1,
+		},
+		{
+			name:     "被截断的数组",
+			content:  `{"questions":[{"title":"a","options":["x","y"],"right":[0]},{"title":"b","options":["x","y"],"right":[1]},{"title":"c","opti`,
+			titles:   []string{"a", "b"},
+			salvaged: 2,
+			dropped:  1,
+		},
+		{
+			name:     "截断在字符串中间",
+			content:  `{"questions":[{"title":"a","options":[],"right":[]},{"title":"含有 } 和 { 的`,
+			titles:   []string{"a"},
+			salvaged: 1,
+			dropped:  1,
+		},
+		{
+			name:     "单个对象格式错误",
+			content:  `{"questions":[{"title":"a","options":[],"right":[]},{"title":"b","right":"x"},{"title":"c","options":[],"right":[]}]}`,
+			titles:   []string{"a", "c"},
+			salvaged: 2,
+			dropped:  1,
+		},
+		{
+			name:     "裸数组",
+			content:  `[{"title":"a","options":[],"right":[]}]`,
+			titles:   []string{"a"},
+			salvaged: 1,
+		},
+		{
+			name:    "没有完整题目",
+			content: `{"questions":[{"title":"a","opt`,
+			dropped: 1,
+			wantErr: true,
+		},
+		{
+			name:    "不是JSON",
+			content: "抱歉，我无法生成题目。",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, report, err := salvageBatchQuestions(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if report.Salvaged != tt.salvaged || report.Dropped != tt.dropped {
+				t.Errorf("report = %+v, want salvaged=%d dropped=%d", report, tt.salvaged, tt.dropped)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(resp.Questions) != len(tt.titles) {
+				t.Fatalf("got %d questions, want %d", len(resp.Questions), len(tt.titles))
+			}
+			for i, title := range tt.titles {
+				if resp.Questions[i].Title != title {
+					t.Errorf("question %d title = %q, want %q", i, resp.Questions[i].Title, title)
+				}
+			}
+		})
+	}
+}
+
+func FuzzSalvageBatchQuestions(f *testing.F) {
+	f.Add(`{"questions":[{"title":"a","options":["x","y"],"right":[0]}]}`)
+	f.Add(`{"questions":[{"title":"a","options":[],"right":[],},// 更多题目...
+]}`)
+	f.Add(`{“questions”:[{“title”:“a”,“options”:[],“right”:[]}]}`)
+	f.Add("```json\n{\"questions\":[{\"title\":\"a\",\"options\":[],\"right\":[]}]}\n```")
+	f.Add(`{"questions":[{"title":"a","options":[],"right":[]},{"title":"b`)
+
+	f.Fuzz(func(t *testing.T, content string) {
+		resp, report, err := salvageBatchQuestions(content)
+		if report.Salvaged < 0 || report.Dropped < 0 {
+			t.Fatalf("negative counts: %+v", report)
+		}
+		if err != nil {
+			if resp != nil {
+				t.Fatalf("non-nil response with error %v", err)
+			}
+			return
+		}
+		if len(resp.Questions) == 0 || len(resp.Questions) != report.Salvaged {
+			t.Fatalf("got %d questions, report %+v", len(resp.Questions), report)
+		}
+	})
+}
+
+// 合法输入经过宽松解析后应与严格解析的结果一致
+func FuzzSalvageRoundTrip(f *testing.F) {
+	f.Add("a", "x", "y", 0)
+	f.Add("什么是“闭包”", "// 不是注释", "/* 也不是 */", 1)
+	f.Add("带,]的标题", "\"引号\"", "\\", 1)
+
+	f.Fuzz(func(t *testing.T, title, optA, optB string, right int) {
+		if strings.TrimSpace(title) == "" {
+			t.Skip()
+		}
+		want := models.AIBatchResponse{Questions: []models.AIQuestion{{
+			Title:   title,
+			Options: []string{optA, optB},
+			Right:   []int{right},
+		}}}
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Skip()
+		}
+
+		resp, report, err := salvageBatchQuestions(string(data))
+		if err != nil {
+			t.Fatalf("salvage %s: %v", data, err)
+		}
+		if report.Salvaged != 1 || report.Dropped != 0 {
+			t.Fatalf("report = %+v", report)
+		}
+		// 非法UTF-8在Marshal时会被替换，以严格解析的结果为准
+		var strict models.AIBatchResponse
+		if err := json.Unmarshal(data, &strict); err != nil {
+			t.Fatalf("strict unmarshal %s: %v", data, err)
+		}
+		got, exp := resp.Questions[0], strict.Questions[0]
+		if got.Title != exp.Title || got.Options[0] != exp.Options[0] || got.Options[1] != exp.Options[1] {
+			t.Fatalf("got %+v, want %+v", got, exp)
+		}
+	})
+}
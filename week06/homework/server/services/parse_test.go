@@ -0,0 +1,73 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"question-generator/mockllm"
+	"question-generator/models"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "重新生成testdata/golden下的期望结果")
+
+// 解析结果的快照，错误时只记录错误信息
+type parseGolden struct {
+	Questions interface{} `json:"questions,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// 用录制的模型响应逐个驱动parseBatchQuestionContent，与testdata/golden中的结果比对
+func TestParseBatchQuestionContentGolden(t *testing.T) {
+	recordings, err := mockllm.LoadRecordings()
+	if err != nil {
+		t.Fatalf("load recordings: %v", err)
+	}
+
+	for name, rec := range recordings {
+		// 审核和批改的录制响应不是题目
+		if rec.Status != http.StatusOK || name == "review" || strings.HasPrefix(name, "grade") {
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			var got parseGolden
+			resp, err := parseBatchQuestionContent(context.Background(), models.Tongyi, rec.Content)
+			if err != nil {
+				got.Error = err.Error()
+			} else {
+				got.Questions = resp.Questions
+			}
+
+			data, err := json.MarshalIndent(got, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data = append(data, '\n')
+
+			path := filepath.Join("testdata", "golden", name+".json")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden (run with -update to create): %v", err)
+			}
+			if !bytes.Equal(data, want) {
+				t.Errorf("result mismatch for %s\ngot:\n%s\nwant:\n%s", name, data, want)
+			}
+		})
+	}
+}
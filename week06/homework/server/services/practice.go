@@ -0,0 +1,487 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"question-generator/metrics"
+	"question-generator/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 题目不在错题本中
+var ErrNotInNotebook = errors.New("题目不在错题本中")
+
+// SM-2算法的参数
+const (
+	initialEase = 2.5
+	minEase     = 1.3
+	// 复习间隔达到该天数的题目视为已掌握
+	masteredInterval = 21
+)
+
+// 今日复习默认返回的到期题目数和新题数
+const (
+	defaultPracticeLimit = 20
+	defaultPracticeNew   = 5
+)
+
+// 答对但没有自评时的评分，答错时的评分
+const (
+	defaultGrade = 4
+	wrongGrade   = 1
+)
+
+// 考试之外的练习：错题本和按SM-2算法安排的复习
+type PracticeService struct {
+	storage *StorageService
+	now     func() time.Time
+}
+
+// 创建练习服务，复习表与题库放在同一个数据库中
+func NewPracticeService(storage *StorageService) (*PracticeService, error) {
+	_, err := storage.DB.Exec(`CREATE TABLE IF NOT EXISTS review_cards (
+		user_id TEXT NOT NULL,
+		question_id INTEGER NOT NULL,
+		repetitions INTEGER NOT NULL, -- 连续答对的次数
+		interval_days INTEGER NOT NULL, -- 复习间隔（天）
+		ease REAL NOT NULL, -- 难易系数
+		due_at INTEGER NOT NULL, -- 下次复习时间，unix时间戳（秒）
+		reviews INTEGER NOT NULL,
+		correct_reviews INTEGER NOT NULL,
+		lapses INTEGER NOT NULL, -- 答错的次数
+		last_reviewed_at INTEGER NOT NULL,
+		last_wrong_at INTEGER,
+		in_notebook INTEGER NOT NULL, -- 1=在错题本中
+		PRIMARY KEY (user_id, question_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_review_cards_due ON review_cards(user_id, due_at);`)
+	if err != nil {
+		return nil, fmt.Errorf("无法创建复习表: %w", err)
+	}
+
+	return &PracticeService{
+		storage: storage,
+		now:     time.Now,
+	}, nil
+}
+
+// 按SM-2算法根据本次评分（0-5）更新复习安排，评分低于3视为没有记住
+func scheduleReview(card models.ReviewCard, grade int, now time.Time) models.ReviewCard {
+	if card.Ease == 0 {
+		card.Ease = initialEase
+	}
+
+	if grade < 3 {
+		card.Repetitions = 0
+		card.Interval = 1
+	} else {
+		switch card.Repetitions {
+		case 0:
+			card.Interval = 1
+		case 1:
+			card.Interval = 6
+		default:
+			card.Interval = int(math.Round(float64(card.Interval) * card.Ease))
+		}
+		card.Repetitions++
+	}
+
+	q := float64(5 - grade)
+	card.Ease = math.Max(minEase, card.Ease+0.1-q*(0.08+q*0.02))
+	card.DueAt = now.AddDate(0, 0, card.Interval)
+	card.LastReviewedAt = now
+	return card
+}
+
+// 提交一次练习作答，批改后更新复习安排，答错的题目放入错题本
+func (s *PracticeService) Answer(userID string, req models.PracticeAnswerRequest) (*models.PracticeAnswerResult, error) {
+	question, err := s.storage.GetQuestionByID(req.QuestionID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := gradeAnswer(question, models.ExamAnswer{QuestionID: req.QuestionID, Selected: req.Selected, Blanks: req.Blanks})
+	if err != nil {
+		return nil, err
+	}
+	if result.Pending {
+		return nil, fmt.Errorf("%w: 简答题需要批改，不能用于练习", ErrNotGradable)
+	}
+	grade := wrongGrade
+	if result.Correct {
+		grade = req.Grade
+		if grade == 0 {
+			grade = defaultGrade
+		}
+	}
+
+	card, err := s.review(userID, question.ID, grade, result.Correct, true)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PracticeAnswerResult{Result: result, Card: *card}, nil
+}
+
+// 把考试中答错的题目放入错题本。已经在复习的题目按考试结果更新复习安排，
+// 答对的新题不安排复习
+func (s *PracticeService) RecordExam(userID string, result *models.ExamResult) error {
+	for _, item := range result.Results {
+		// 等待批改的题目还不知道对错
+		if item.Pending {
+			continue
+		}
+		grade := wrongGrade
+		if item.Correct {
+			grade = defaultGrade
+		}
+		if _, err := s.review(userID, item.QuestionID, grade, item.Correct, !item.Correct); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 在一个事务中读取并更新复习安排。create为false时只更新已有的安排，返回nil
+func (s *PracticeService) review(userID string, questionID int64, grade int, correct, create bool) (*models.ReviewCard, error) {
+	defer metrics.ObserveDB("practice_review")()
+
+	tx, err := s.storage.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	card, err := scanCard(tx.QueryRow("SELECT "+cardColumns+" FROM review_cards WHERE user_id = ? AND question_id = ?", userID, questionID))
+	if err == sql.ErrNoRows {
+		if !create {
+			return nil, nil
+		}
+		card = models.ReviewCard{QuestionID: questionID}
+	} else if err != nil {
+		return nil, fmt.Errorf("查询复习安排失败: %w", err)
+	}
+
+	now := s.now()
+	card = scheduleReview(card, grade, now)
+	card.Reviews++
+	if correct {
+		card.CorrectReviews++
+	} else {
+		card.Lapses++
+		card.LastWrongAt = &now
+		card.InNotebook = true
+	}
+
+	var lastWrongAt interface{}
+	if card.LastWrongAt != nil {
+		lastWrongAt = card.LastWrongAt.Unix()
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO review_cards (
+		user_id, question_id, repetitions, interval_days, ease, due_at, reviews, correct_reviews,
+		lapses, last_reviewed_at, last_wrong_at, in_notebook
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, questionID, card.Repetitions, card.Interval, card.Ease, card.DueAt.Unix(), card.Reviews, card.CorrectReviews,
+		card.Lapses, card.LastReviewedAt.Unix(), lastWrongAt, card.InNotebook,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("保存复习安排失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return &card, nil
+}
+
+// 复习表查询时使用的列，顺序与scanCard保持一致
+const cardColumns = `question_id, repetitions, interval_days, ease, due_at, reviews, correct_reviews, lapses, last_reviewed_at, last_wrong_at, in_notebook`
+
+func scanCard(row rowScanner) (models.ReviewCard, error) {
+	var card models.ReviewCard
+	var dueAt, lastReviewedAt int64
+	var lastWrongAt sql.NullInt64
+	err := row.Scan(
+		&card.QuestionID,
+		&card.Repetitions,
+		&card.Interval,
+		&card.Ease,
+		&dueAt,
+		&card.Reviews,
+		&card.CorrectReviews,
+		&card.Lapses,
+		&lastReviewedAt,
+		&lastWrongAt,
+		&card.InNotebook,
+	)
+	if err != nil {
+		return card, err
+	}
+
+	card.DueAt = time.Unix(dueAt, 0)
+	card.LastReviewedAt = time.Unix(lastReviewedAt, 0)
+	if lastWrongAt.Valid {
+		t := time.Unix(lastWrongAt.Int64, 0)
+		card.LastWrongAt = &t
+	}
+	return card, nil
+}
+
+// 可以练习的题目：指定题库中正常使用的、能自动批改的选择题、判断题和填空题，bankIDs为nil时不限制题库
+func practiceConditions(tag string, bankIDs []int64) ([]string, []interface{}) {
+	return questionConditions(models.QuestionFilter{
+		Types:   models.QuestionTypesWithInput(models.InputChoice, models.InputBlanks),
+		Status:  models.StatusActive,
+		Tag:     tag,
+		BankIDs: bankIDs,
+	})
+}
+
+// 明天零点，今天之内到期的题目都算今日复习
+func endOfDay(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+}
+
+// 今日复习：今天到期的题目按到期时间排列，再附带若干道没有练习过的新题
+func (s *PracticeService) Today(userID string, req models.PracticeTodayRequest) (*models.PracticeToday, error) {
+	defer metrics.ObserveDB("practice_today")()
+
+	limit, newCount, tag := req.Limit, defaultPracticeNew, req.Tag
+	if limit <= 0 {
+		limit = defaultPracticeLimit
+	}
+	if req.New != nil {
+		newCount = *req.New
+	}
+
+	conditions, args := practiceConditions(tag, req.BankIDs)
+	conditions = append([]string{"c.user_id = ?", "c.due_at < ?"}, conditions...)
+	args = append([]interface{}{userID, endOfDay(s.now()).Unix()}, args...)
+	from := "FROM review_cards c JOIN questions ON questions.id = c.question_id " + whereClause(conditions)
+
+	today := &models.PracticeToday{Items: []models.PracticeItem{}}
+	if err := s.storage.DB.QueryRow("SELECT COUNT(*) "+from, args...).Scan(&today.Due); err != nil {
+		return nil, fmt.Errorf("统计到期题目失败: %w", err)
+	}
+
+	cards, err := s.queryCards("SELECT "+prefixColumns("c.", cardColumns)+" "+from+" ORDER BY c.due_at, c.question_id LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		question, err := s.storage.GetQuestionByID(cards[i].QuestionID)
+		if err != nil {
+			return nil, err
+		}
+		today.Items = append(today.Items, models.PracticeItem{Question: *studentQuestion(question), Card: &cards[i]})
+	}
+
+	if newCount <= 0 {
+		return today, nil
+	}
+
+	conditions, args = practiceConditions(tag, req.BankIDs)
+	conditions = append(conditions, "id NOT IN (SELECT question_id FROM review_cards WHERE user_id = ?)")
+	args = append(args, userID, newCount)
+	rows, err := s.storage.DB.Query("SELECT "+questionColumns+" FROM questions "+whereClause(conditions)+" ORDER BY id LIMIT ?", args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询新题失败: %w", err)
+	}
+	defer rows.Close()
+
+	var fresh []models.QuestionData
+	for rows.Next() {
+		q, err := scanQuestion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描数据库行失败: %w", err)
+		}
+		fresh = append(fresh, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询新题失败: %w", err)
+	}
+	rows.Close()
+
+	if err := loadTags(s.storage.DB, fresh); err != nil {
+		return nil, err
+	}
+	for i := range fresh {
+		today.Items = append(today.Items, models.PracticeItem{Question: *studentQuestion(&fresh[i])})
+	}
+	return today, nil
+}
+
+// 错题本，最近答错的在前
+func (s *PracticeService) Notebook(userID string) ([]models.NotebookEntry, error) {
+	defer metrics.ObserveDB("practice_notebook")()
+
+	cards, err := s.queryCards(`SELECT `+prefixColumns("c.", cardColumns)+`
+	FROM review_cards c JOIN questions ON questions.id = c.question_id
+	WHERE c.user_id = ? AND c.in_notebook = 1
+	ORDER BY c.last_wrong_at DESC, c.question_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.NotebookEntry, 0, len(cards))
+	for _, card := range cards {
+		question, err := s.storage.GetQuestionByID(card.QuestionID)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, models.NotebookEntry{Question: *studentQuestion(question), Card: card})
+	}
+	return entries, nil
+}
+
+// 从错题本中移除，复习安排保持不变
+func (s *PracticeService) RemoveFromNotebook(userID string, questionID int64) error {
+	defer metrics.ObserveDB("practice_notebook_remove")()
+
+	result, err := s.storage.DB.Exec("UPDATE review_cards SET in_notebook = 0 WHERE user_id = ? AND question_id = ? AND in_notebook = 1", userID, questionID)
+	if err != nil {
+		return fmt.Errorf("更新错题本失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotInNotebook
+	}
+	return nil
+}
+
+func (s *PracticeService) queryCards(query string, args ...interface{}) ([]models.ReviewCard, error) {
+	rows, err := s.storage.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询复习安排失败: %w", err)
+	}
+	defer rows.Close()
+
+	cards := []models.ReviewCard{}
+	for rows.Next() {
+		card, err := scanCard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描复习安排失败: %w", err)
+		}
+		cards = append(cards, card)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询复习安排失败: %w", err)
+	}
+	return cards, nil
+}
+
+// 按标签统计学生的掌握情况，只统计可以练习的题目
+func (s *PracticeService) Mastery(userID string) (*models.MasteryReport, error) {
+	defer metrics.ObserveDB("practice_mastery")()
+
+	due := endOfDay(s.now()).Unix()
+	// 与今日复习使用同样的条件，新增可以自动批改的题型时不需要修改这里
+	conditions, practicableArgs := practiceConditions("", nil)
+	practicable := "questions.id IN (SELECT id FROM questions WHERE " + strings.Join(conditions, " AND ") + ")"
+	byTag := make(map[string]*models.TagMastery)
+	report := &models.MasteryReport{ByTag: []models.TagMastery{}}
+
+	// 每个标签下可练习的题目数
+	rows, err := s.storage.DB.Query(`SELECT t.tag, COUNT(*)
+	FROM question_tags t JOIN questions ON questions.id = t.question_id
+	WHERE `+practicable+`
+	GROUP BY t.tag`, practicableArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("统计题目数量失败: %w", err)
+	}
+	for rows.Next() {
+		m := &models.TagMastery{}
+		if err := rows.Scan(&m.Tag, &m.Questions); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("扫描题目数量失败: %w", err)
+		}
+		byTag[m.Tag] = m
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("统计题目数量失败: %w", err)
+	}
+	if err := s.storage.DB.QueryRow("SELECT COUNT(*) FROM questions WHERE "+practicable, practicableArgs...).Scan(&report.Overall.Questions); err != nil {
+		return nil, fmt.Errorf("统计题目数量失败: %w", err)
+	}
+
+	// 练习情况，标签为NULL的一行是全部题目的汇总
+	rows, err = s.storage.DB.Query(`SELECT t.tag,
+		COUNT(*),
+		COALESCE(SUM(CASE WHEN c.interval_days >= ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN c.due_at < ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(c.reviews), 0),
+		COALESCE(SUM(c.correct_reviews), 0)
+	FROM review_cards c
+	JOIN questions ON questions.id = c.question_id
+	LEFT JOIN question_tags t ON t.question_id = c.question_id
+	WHERE c.user_id = ? AND `+practicable+`
+	GROUP BY t.tag`, append([]interface{}{masteredInterval, due, userID}, practicableArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("统计掌握情况失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag sql.NullString
+		var practiced, mastered, dueCount, reviews, correct int
+		if err := rows.Scan(&tag, &practiced, &mastered, &dueCount, &reviews, &correct); err != nil {
+			return nil, fmt.Errorf("扫描掌握情况失败: %w", err)
+		}
+		if !tag.Valid {
+			continue
+		}
+		m, ok := byTag[tag.String]
+		if !ok {
+			continue
+		}
+		m.Practiced, m.Mastered, m.Due = practiced, mastered, dueCount
+		if reviews > 0 {
+			m.Accuracy = float64(correct) / float64(reviews)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("统计掌握情况失败: %w", err)
+	}
+	rows.Close()
+
+	// 一道题有多个标签时会在上面的查询中出现多次，汇总单独统计
+	var reviews, correct int
+	err = s.storage.DB.QueryRow(`SELECT COUNT(*),
+		COALESCE(SUM(CASE WHEN c.interval_days >= ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN c.due_at < ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(c.reviews), 0),
+		COALESCE(SUM(c.correct_reviews), 0)
+	FROM review_cards c
+	JOIN questions ON questions.id = c.question_id
+	WHERE c.user_id = ? AND `+practicable, append([]interface{}{masteredInterval, due, userID}, practicableArgs...)...).Scan(
+		&report.Overall.Practiced, &report.Overall.Mastered, &report.Overall.Due, &reviews, &correct,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("统计掌握情况失败: %w", err)
+	}
+	if reviews > 0 {
+		report.Overall.Accuracy = float64(correct) / float64(reviews)
+	}
+	report.Overall.Mastery = masteryRate(report.Overall)
+
+	for _, m := range byTag {
+		m.Mastery = masteryRate(*m)
+		report.ByTag = append(report.ByTag, *m)
+	}
+	sort.Slice(report.ByTag, func(i, j int) bool { return report.ByTag[i].Tag < report.ByTag[j].Tag })
+	return report, nil
+}
+
+func masteryRate(m models.TagMastery) float64 {
+	if m.Questions == 0 {
+		return 0
+	}
+	return float64(m.Mastered) / float64(m.Questions)
+}
+
+// 给逗号分隔的列名加上表别名前缀
+func prefixColumns(prefix, columns string) string {
+	return prefix + strings.ReplaceAll(columns, ", ", ", "+prefix)
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"question-generator/models"
+	"testing"
+	"time"
+)
+
+func TestScheduleReview(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	card := scheduleReview(models.ReviewCard{}, 4, now)
+	if card.Repetitions != 1 || card.Interval != 1 || card.Ease != initialEase {
+		t.Fatalf("first review: %+v", card)
+	}
+	card = scheduleReview(card, 4, now)
+	if card.Repetitions != 2 || card.Interval != 6 {
+		t.Fatalf("second review: %+v", card)
+	}
+	card = scheduleReview(card, 5, now)
+	if card.Repetitions != 3 || card.Interval != 15 || math.Abs(card.Ease-2.6) > 1e-9 {
+		t.Fatalf("third review: %+v", card)
+	}
+	if !card.DueAt.Equal(now.AddDate(0, 0, 15)) {
+		t.Errorf("due at %v", card.DueAt)
+	}
+
+	// 答错时重新开始，难易系数下降但不低于下限
+	card = scheduleReview(card, wrongGrade, now)
+	if card.Repetitions != 0 || card.Interval != 1 || card.Ease >= 2.6 {
+		t.Fatalf("lapse: %+v", card)
+	}
+	for i := 0; i < 10; i++ {
+		card = scheduleReview(card, wrongGrade, now)
+	}
+	if card.Ease != minEase {
+		t.Errorf("ease = %v", card.Ease)
+	}
+}
+
+func TestPracticeNotebookAndToday(t *testing.T) {
+	storage := newTestStorage(t)
+	practice, err := NewPracticeService(storage)
+	if err != nil {
+		t.Fatalf("NewPracticeService: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+	practice.now = func() time.Time { return now }
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		q := choiceQuestion("单选题", models.SingleChoice, 0)
+		q.Tags = []string{"基础"}
+		id, err := storage.AddQuestion(q)
+		if err != nil {
+			t.Fatalf("AddQuestion: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	programming, _ := storage.AddQuestion(programmingQuestion("编程题"))
+
+	if _, err := practice.Answer("alice", models.PracticeAnswerRequest{QuestionID: programming}); err == nil {
+		t.Error("programming question should be rejected")
+	}
+	if _, err := practice.Answer("alice", models.PracticeAnswerRequest{QuestionID: 9999}); !errors.Is(err, ErrQuestionNotFound) {
+		t.Errorf("missing question: %v", err)
+	}
+
+	// 考试答错的题目进入错题本，答对的新题不安排复习
+	err = practice.RecordExam("alice", &models.ExamResult{Results: []models.ExamQuestionResult{
+		{QuestionID: ids[0], Correct: false},
+		{QuestionID: ids[1], Correct: true},
+	}})
+	if err != nil {
+		t.Fatalf("RecordExam: %v", err)
+	}
+
+	notebook, err := practice.Notebook("alice")
+	if err != nil || len(notebook) != 1 || notebook[0].Question.ID != ids[0] || notebook[0].Card.Lapses != 1 {
+		t.Fatalf("notebook: %+v, %v", notebook, err)
+	}
+	if other, _ := practice.Notebook("bob"); len(other) != 0 {
+		t.Errorf("bob's notebook: %+v", other)
+	}
+
+	// 今天还没到期，错题明天复习；没练习过的两道题作为新题
+	today, err := practice.Today("alice", models.PracticeTodayRequest{})
+	if err != nil || today.Due != 0 || len(today.Items) != 2 || today.Items[0].Card != nil {
+		t.Fatalf("today: %+v, %v", today, err)
+	}
+	for _, item := range today.Items {
+		if item.Question.ID == ids[0] || item.Question.ID == programming {
+			t.Errorf("unexpected new question %d", item.Question.ID)
+		}
+	}
+
+	now = now.AddDate(0, 0, 1)
+	none := 0
+	today, err = practice.Today("alice", models.PracticeTodayRequest{New: &none})
+	if err != nil || today.Due != 1 || len(today.Items) != 1 || today.Items[0].Card == nil || today.Items[0].Question.ID != ids[0] {
+		t.Fatalf("due tomorrow: %+v, %v", today, err)
+	}
+	if today, _ := practice.Today("alice", models.PracticeTodayRequest{New: &none, Tag: "不存在"}); today.Due != 0 {
+		t.Errorf("tag filter: %+v", today)
+	}
+
+	// 复习答对后间隔变长，仍留在错题本中直到手动移除
+	result, err := practice.Answer("alice", models.PracticeAnswerRequest{QuestionID: ids[0], Selected: []int{0}, Grade: 5})
+	if err != nil || !result.Result.Correct || result.Card.Reviews != 2 || result.Card.CorrectReviews != 1 || !result.Card.InNotebook {
+		t.Fatalf("answer: %+v, %v", result, err)
+	}
+	if !result.Card.DueAt.After(now) {
+		t.Errorf("due at %v", result.Card.DueAt)
+	}
+
+	if err := practice.RemoveFromNotebook("alice", ids[0]); err != nil {
+		t.Fatalf("RemoveFromNotebook: %v", err)
+	}
+	if err := practice.RemoveFromNotebook("alice", ids[0]); !errors.Is(err, ErrNotInNotebook) {
+		t.Errorf("remove twice: %v", err)
+	}
+	if notebook, _ := practice.Notebook("alice"); len(notebook) != 0 {
+		t.Errorf("notebook after remove: %+v", notebook)
+	}
+}
+
+func TestPracticeMastery(t *testing.T) {
+	storage := newTestStorage(t)
+	practice, err := NewPracticeService(storage)
+	if err != nil {
+		t.Fatalf("NewPracticeService: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+	practice.now = func() time.Time { return now }
+
+	tags := [][]string{{"并发", "基础"}, {"基础"}, {"基础"}, nil}
+	var ids []int64
+	for _, tag := range tags {
+		q := choiceQuestion("单选题", models.SingleChoice, 0)
+		q.Tags = tag
+		id, err := storage.AddQuestion(q)
+		if err != nil {
+			t.Fatalf("AddQuestion: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	// 填空题可以自动批改，计入统计；编程题不能练习，它的标签也不出现
+	blank := &models.QuestionData{
+		AIReq: models.QuestionRequest{Type: models.FillBlank},
+		AIRes: models.AIResponse{Title: "用____启动协程", Blanks: [][]string{{"go"}}},
+		Tags:  []string{"基础"},
+	}
+	programming := programmingQuestion("编程")
+	programming.Tags = []string{"编程"}
+	for _, q := range []*models.QuestionData{blank, programming} {
+		if _, err := storage.AddQuestion(q); err != nil {
+			t.Fatalf("AddQuestion: %v", err)
+		}
+	}
+
+	// 第一道题连续答对直到间隔超过掌握标准
+	for i := 0; i < 4; i++ {
+		if _, err := practice.Answer("alice", models.PracticeAnswerRequest{QuestionID: ids[0], Selected: []int{0}, Grade: 5}); err != nil {
+			t.Fatalf("Answer: %v", err)
+		}
+	}
+	if _, err := practice.Answer("alice", models.PracticeAnswerRequest{QuestionID: ids[1], Selected: []int{1}}); err != nil {
+		t.Fatalf("Answer: %v", err)
+	}
+
+	report, err := practice.Mastery("alice")
+	if err != nil {
+		t.Fatalf("Mastery: %v", err)
+	}
+	overall := report.Overall
+	if overall.Questions != 5 || overall.Practiced != 2 || overall.Mastered != 1 || overall.Due != 0 {
+		t.Errorf("overall: %+v", overall)
+	}
+	if math.Abs(overall.Accuracy-0.8) > 1e-9 || math.Abs(overall.Mastery-0.2) > 1e-9 {
+		t.Errorf("overall rates: %+v", overall)
+	}
+
+	if len(report.ByTag) != 2 {
+		t.Fatalf("by tag: %+v", report.ByTag)
+	}
+	byTag := make(map[string]models.TagMastery)
+	for _, m := range report.ByTag {
+		byTag[m.Tag] = m
+	}
+	if m := byTag["并发"]; m.Questions != 1 || m.Mastered != 1 || m.Mastery != 1 || m.Accuracy != 1 {
+		t.Errorf("并发: %+v", m)
+	}
+	if m := byTag["基础"]; m.Questions != 4 || m.Practiced != 2 || m.Mastered != 1 {
+		t.Errorf("基础: %+v", m)
+	}
+}
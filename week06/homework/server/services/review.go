@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"question-generator/models"
+	"sort"
+	"strings"
+)
+
+// 审核模型对单道题的结果
+type reviewItem struct {
+	Index    int    `json:"index"`
+	Answer   []int  `json:"answer"`
+	Score    int    `json:"score"`
+	Comments string `json:"comments"`
+}
+
+// 审核模型返回的整体结果
+type reviewResponse struct {
+	Reviews []reviewItem `json:"reviews"`
+}
+
+// 对生成的题目做二次审核：审核模型在看不到标注答案的情况下独立作答并点评，
+// 结果写入每道题的AIRes.Review，作答与标注答案不一致的题目标记为Disagree。
+// provider为空时使用配置的默认审核模型，userID用于用量记录和配额检查
+func (c *AIClient) ReviewQuestions(ctx context.Context, provider models.ModelProvider, userID string, questions []models.QuestionData) error {
+	if len(questions) == 0 {
+		return nil
+	}
+	if provider == "" {
+		provider = c.config.ReviewModel
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ModelCallTimeout)
+	defer cancel()
+
+	call := chatCall{provider: provider, purpose: models.PurposeReview, userID: userID}
+	content, _, err := c.chatCompletion(ctx, call, buildReviewPrompt(questions), nil)
+	if err != nil {
+		return err
+	}
+
+	var response reviewResponse
+	cleaned := removeTrailingCommas(normalizeJSONText(stripCodeFence(content)))
+	if err := json.Unmarshal([]byte(cleaned), &response); err != nil {
+		return fmt.Errorf("无法解析审核结果: %w", err)
+	}
+
+	for _, item := range response.Reviews {
+		if item.Index < 0 || item.Index >= len(questions) {
+			continue
+		}
+		q := &questions[item.Index]
+
+		review := &models.QuestionReview{
+			Reviewer: provider,
+			Score:    clampScore(item.Score),
+			Comments: item.Comments,
+		}
+		if spec, ok := models.LookupQuestionType(q.AIReq.GetQuestionType()); ok && spec.Input == models.InputChoice {
+			review.ReviewerAnswer = append([]int(nil), item.Answer...)
+			sort.Ints(review.ReviewerAnswer)
+			review.Disagree = !models.SameChoices(review.ReviewerAnswer, q.AIRes.Right)
+		}
+		q.AIRes.Review = review
+	}
+
+	return nil
+}
+
+// 构建审核提示语，只给出题干和选项，不给出标注答案
+func buildReviewPrompt(questions []models.QuestionData) string {
+	var sb strings.Builder
+	sb.WriteString("你是一名严谨的编程课程出题审核员。请独立完成并审核下面的题目。\n\n")
+	sb.WriteString("要求：\n")
+	sb.WriteString("1. 对于选择题和判断题，先独立作答，在answer中给出你认为正确的所有选项索引（0代表A，1代表B，依此类推）\n")
+	sb.WriteString("2. 对于编程题、填空题和简答题，answer留空数组，只评价题目要求是否清晰、约束是否完整、答案是否唯一明确\n")
+	sb.WriteString("3. score为1到10的整数，表示题目质量，题干有歧义、存在多个可能正确答案或选项明显错误时要降低分数\n")
+	sb.WriteString("4. comments用一两句话指出题目存在的问题，没有问题时简要说明\n")
+	sb.WriteString("5. 你的回答必须是一个有效的JSON对象，不包含任何额外文字，格式如下：\n")
+	sb.WriteString(`{
+  "reviews": [
+    {"index": 0, "answer": [1], "score": 8, "comments": "点评内容"}
+  ]
+}`)
+	sb.WriteString("\n\n题目如下：\n\n")
+
+	for i, q := range questions {
+		spec, ok := models.LookupQuestionType(q.AIReq.GetQuestionType())
+		if !ok {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("题目%d（index=%d，%s）：%s\n", i+1, i, spec.Name, q.AIRes.Title))
+		if spec.Input == models.InputChoice {
+			for j, option := range q.AIRes.Answer {
+				sb.WriteString(fmt.Sprintf("%c. %s\n", 'A'+j, option))
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("请返回包含%d条审核结果的JSON，不要使用markdown格式。\n", len(questions)))
+
+	return sb.String()
+}
+
+// 将评分限制在1到10之间
+func clampScore(score int) int {
+	if score < 1 {
+		return 1
+	}
+	if score > 10 {
+		return 10
+	}
+	return score
+}
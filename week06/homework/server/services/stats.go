@@ -0,0 +1,217 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"question-generator/metrics"
+	"question-generator/models"
+	"sync"
+	"time"
+)
+
+// 题库统计服务，统计结果按天数和题库范围缓存一段时间，避免每次打开看板都做全表聚合
+type StatsService struct {
+	db    *sql.DB
+	ttl   time.Duration
+	now   func() time.Time
+	mu    sync.Mutex
+	cache map[string]cachedStats
+}
+
+type cachedStats struct {
+	stats   *models.QuestionStats
+	expires time.Time
+}
+
+// 创建统计服务，出题调用的统计依赖用量服务创建的ai_usage表
+func NewStatsService(db *sql.DB, ttl time.Duration) *StatsService {
+	return &StatsService{
+		db:    db,
+		ttl:   ttl,
+		now:   time.Now,
+		cache: make(map[string]cachedStats),
+	}
+}
+
+// 统计bankIDs中题目的概况和最近days天的新增题目，以及出题调用情况。
+// bankIDs为nil时统计全部题库，为空时没有题目；出题调用不属于题库，总是全站统计
+func (s *StatsService) Stats(days int, bankIDs []int64) (*models.QuestionStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := fmt.Sprintf("%d|%v|%v", days, bankIDs == nil, bankIDs)
+	now := s.now()
+	if cached, ok := s.cache[key]; ok && now.Before(cached.expires) {
+		return cached.stats, nil
+	}
+
+	stats, err := s.compute(now, days, bankIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 清理过期的缓存，同时使用看板的题库范围有限，过期即删除，map不会无限增长
+	for k, cached := range s.cache {
+		if !now.Before(cached.expires) {
+			delete(s.cache, k)
+		}
+	}
+	s.cache[key] = cachedStats{stats: stats, expires: now.Add(s.ttl)}
+	return stats, nil
+}
+
+// 限制题目所在题库的SQL条件，bankIDs为nil时不限制
+func bankCondition(bankIDs []int64) (string, []interface{}) {
+	if bankIDs == nil {
+		return "1", nil
+	}
+	if len(bankIDs) == 0 {
+		return "0", nil
+	}
+	args := make([]interface{}, len(bankIDs))
+	for i, id := range bankIDs {
+		args[i] = id
+	}
+	return "bank_id IN (" + sqlPlaceholders(len(bankIDs)) + ")", args
+}
+
+func (s *StatsService) compute(now time.Time, days int, bankIDs []int64) (*models.QuestionStats, error) {
+	defer metrics.ObserveDB("question_stats")()
+
+	// 时间序列从days-1天前的零点开始，包含今天
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	since := today.AddDate(0, 0, -(days - 1))
+
+	stats := &models.QuestionStats{GeneratedAt: now, Days: days}
+
+	var err error
+	if stats.Breakdown, stats.Total, err = s.countQuestions(bankIDs); err != nil {
+		return nil, err
+	}
+	if stats.AddedPerDay, err = s.addedPerDay(since, days, bankIDs); err != nil {
+		return nil, err
+	}
+	if stats.Generation, err = s.generationStats(since); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// 按题型、难度、编程语言和标签统计题目数量。题目可能有多个标签，总数单独统计
+func (s *StatsService) countQuestions(bankIDs []int64) ([]models.QuestionCount, int, error) {
+	where, args := bankCondition(bankIDs)
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM questions WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("统计题目数量失败: %w", err)
+	}
+
+	rows, err := s.db.Query(`SELECT question_type, difficulty, COALESCE(language, '') AS lang, COALESCE(t.tag, '') AS tag, COUNT(*)
+	FROM questions
+	LEFT JOIN question_tags t ON t.question_id = questions.id
+	WHERE `+where+`
+	GROUP BY question_type, difficulty, lang, tag
+	ORDER BY question_type, difficulty, lang, tag`, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("统计题目数量失败: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []models.QuestionCount{}
+	for rows.Next() {
+		var c models.QuestionCount
+		if err := rows.Scan(&c.Type, &c.Difficulty, &c.Language, &c.Tag, &c.Count); err != nil {
+			return nil, 0, fmt.Errorf("扫描题目数量失败: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("统计题目数量失败: %w", err)
+	}
+	return counts, total, nil
+}
+
+// 统计每天新增的题目数量，没有新增的日期补0。没有入库时间的旧题目不计入
+func (s *StatsService) addedPerDay(since time.Time, days int, bankIDs []int64) ([]models.DailyCount, error) {
+	where, args := bankCondition(bankIDs)
+	rows, err := s.db.Query(`SELECT date(created_at, 'unixepoch', 'localtime') AS d, COUNT(*)
+	FROM questions
+	WHERE created_at >= ? AND `+where+`
+	GROUP BY d`, append([]interface{}{since.Unix()}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("统计新增题目失败: %w", err)
+	}
+	defer rows.Close()
+
+	added := make(map[string]int)
+	for rows.Next() {
+		var date string
+		var count int
+		if err := rows.Scan(&date, &count); err != nil {
+			return nil, fmt.Errorf("扫描新增题目失败: %w", err)
+		}
+		added[date] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("统计新增题目失败: %w", err)
+	}
+
+	series := make([]models.DailyCount, 0, days)
+	for i := 0; i < days; i++ {
+		date := since.AddDate(0, 0, i).Format("2006-01-02")
+		series = append(series, models.DailyCount{Date: date, Count: added[date]})
+	}
+	return series, nil
+}
+
+// 按服务商统计出题调用的成功率和平均耗时
+func (s *StatsService) generationStats(since time.Time) (models.GenerationStats, error) {
+	result := models.GenerationStats{ByProvider: []models.ProviderGeneration{}}
+
+	rows, err := s.db.Query(`SELECT provider,
+		COUNT(*),
+		COALESCE(SUM(CASE WHEN success = 0 THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN success = 1 THEN latency_ms ELSE 0 END), 0)
+	FROM ai_usage
+	WHERE purpose = ? AND created_at >= ?
+	GROUP BY provider
+	ORDER BY provider`, string(models.PurposeGenerate), since.Unix())
+	if err != nil {
+		return result, fmt.Errorf("统计出题调用失败: %w", err)
+	}
+	defer rows.Close()
+
+	var totalLatency int64
+	for rows.Next() {
+		var p models.ProviderGeneration
+		var latency int64
+		if err := rows.Scan(&p.Provider, &p.Requests, &p.Failures, &latency); err != nil {
+			return result, fmt.Errorf("扫描出题调用失败: %w", err)
+		}
+		p.GenerationSummary = summarizeGeneration(p.Requests, p.Failures, latency)
+		result.ByProvider = append(result.ByProvider, p)
+
+		result.Requests += p.Requests
+		result.Failures += p.Failures
+		totalLatency += latency
+	}
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("统计出题调用失败: %w", err)
+	}
+
+	result.GenerationSummary = summarizeGeneration(result.Requests, result.Failures, totalLatency)
+	return result, nil
+}
+
+// 由调用次数、失败次数和成功调用的总耗时计算成功率和平均耗时
+func summarizeGeneration(requests, failures int, successLatencyMs int64) models.GenerationSummary {
+	summary := models.GenerationSummary{Requests: requests, Failures: failures}
+	if requests > 0 {
+		summary.SuccessRate = float64(requests-failures) / float64(requests)
+	}
+	if succeeded := requests - failures; succeeded > 0 {
+		summary.AvgLatencyMs = float64(successLatencyMs) / float64(succeeded)
+	}
+	return summary
+}
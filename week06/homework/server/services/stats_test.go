@@ -0,0 +1,99 @@
+package services
+
+import (
+	"question-generator/config"
+	"question-generator/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuestionStats(t *testing.T) {
+	storage := newTestStorage(t)
+	usage, err := NewUsageService(storage.DB, &config.Configuration{})
+	if err != nil {
+		t.Fatalf("NewUsageService: %v", err)
+	}
+
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.Local)
+	stats := NewStatsService(storage.DB, time.Minute)
+	stats.now = func() time.Time { return now }
+
+	add := func(q *models.QuestionData, language models.ProgrammingLanguage, createdAt time.Time, tags ...string) {
+		t.Helper()
+		q.AIReq.Language = language
+		q.CreatedAt = createdAt
+		q.Tags = tags
+		if _, err := storage.AddQuestion(q); err != nil {
+			t.Fatalf("AddQuestion: %v", err)
+		}
+	}
+	add(choiceQuestion("单选1", models.SingleChoice, 0), models.Go, now.Add(-time.Hour), "并发", "通道")
+	add(choiceQuestion("单选2", models.SingleChoice, 1), models.Python, now.AddDate(0, 0, -1))
+	add(programmingQuestion("编程"), models.Go, now.AddDate(0, 0, -1), "并发")
+	add(choiceQuestion("很早以前", models.MultiChoice, 0, 1), "", now.AddDate(0, 0, -30))
+
+	records := []models.UsageRecord{
+		{Provider: models.Tongyi, Purpose: models.PurposeGenerate, LatencyMs: 1000, Success: true},
+		{Provider: models.Tongyi, Purpose: models.PurposeGenerate, LatencyMs: 3000, Success: true},
+		{Provider: models.Tongyi, Purpose: models.PurposeGenerate, LatencyMs: 9000, Success: false},
+		{Provider: models.DeepSeek, Purpose: models.PurposeGenerate, LatencyMs: 2000, Success: true},
+		{Provider: models.DeepSeek, Purpose: models.PurposeReview, LatencyMs: 5000, Success: true},
+		{Provider: models.DeepSeek, Purpose: models.PurposeGenerate, Success: true, CreatedAt: now.AddDate(0, 0, -10)},
+	}
+	for _, rec := range records {
+		if rec.CreatedAt.IsZero() {
+			rec.CreatedAt = now
+		}
+		if err := usage.Record(rec); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	got, err := stats.Stats(7, nil)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+
+	if got.Total != 4 {
+		t.Errorf("total = %d, want 4", got.Total)
+	}
+	wantCounts := []models.QuestionCount{
+		{Type: models.SingleChoice, Difficulty: models.Medium, Language: models.Go, Tag: "并发", Count: 1},
+		{Type: models.SingleChoice, Difficulty: models.Medium, Language: models.Go, Tag: "通道", Count: 1},
+		{Type: models.SingleChoice, Difficulty: models.Medium, Language: models.Python, Count: 1},
+		{Type: models.MultiChoice, Difficulty: models.Medium, Count: 1},
+		{Type: models.Programming, Difficulty: models.Hard, Language: models.Go, Tag: "并发", Count: 1},
+	}
+	if !reflect.DeepEqual(got.Breakdown, wantCounts) {
+		t.Errorf("breakdown = %+v", got.Breakdown)
+	}
+
+	if len(got.AddedPerDay) != 7 || got.AddedPerDay[0].Date != "2026-03-04" {
+		t.Fatalf("addedPerDay = %+v", got.AddedPerDay)
+	}
+	if last := got.AddedPerDay[6]; last != (models.DailyCount{Date: "2026-03-10", Count: 1}) {
+		t.Errorf("today = %+v", last)
+	}
+	if yesterday := got.AddedPerDay[5]; yesterday.Count != 2 {
+		t.Errorf("yesterday = %+v", yesterday)
+	}
+
+	gen := got.Generation
+	if gen.Requests != 4 || gen.Failures != 1 || gen.SuccessRate != 0.75 || gen.AvgLatencyMs != 2000 {
+		t.Errorf("generation = %+v", gen.GenerationSummary)
+	}
+	if len(gen.ByProvider) != 2 || gen.ByProvider[1].Provider != models.Tongyi || gen.ByProvider[1].AvgLatencyMs != 2000 {
+		t.Errorf("byProvider = %+v", gen.ByProvider)
+	}
+
+	// 缓存有效期内不重新统计
+	add(choiceQuestion("新题", models.SingleChoice, 0), models.Go, now)
+	if cached, _ := stats.Stats(7, nil); cached.Total != 4 {
+		t.Errorf("cached total = %d, want 4", cached.Total)
+	}
+	now = now.Add(time.Minute)
+	if fresh, _ := stats.Stats(7, nil); fresh.Total != 5 {
+		t.Errorf("total after ttl = %d, want 5", fresh.Total)
+	}
+}
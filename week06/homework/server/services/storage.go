@@ -2,14 +2,16 @@ package services
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"question-generator/metrics"
 	"question-generator/models"
-	"sort"
 	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -19,104 +21,365 @@ type StorageService struct {
 	DB      *sql.DB
 }
 
-// 创建新的存储服务
-func NewStorageService() *StorageService {
-	dataDir := "./data"
-	os.MkdirAll(dataDir, 0755)
+// 在指定目录下打开或创建题库数据库
+func OpenStorageService(dataDir string) (*StorageService, error) {
+	return OpenStorageFile(filepath.Join(dataDir, "questions.db"))
+}
+
+// 打开或创建指定路径的题库数据库，数据目录为文件所在目录
+func OpenStorageFile(dbPath string) (*StorageService, error) {
+	dataDir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("无法创建数据目录: %w", err)
+	}
 
-	// 打开或创建SQLite数据库
-	dbPath := filepath.Join(dataDir, "questions.db")
-	db, err := sql.Open("sqlite", dbPath)
+	// 打开或创建SQLite数据库。后台的webhook投递与请求同时写库，
+	// 数据库被锁时等待一段时间而不是立即失败
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)")
 	if err != nil {
-		log.Fatalf("无法打开数据库: %v", err)
+		return nil, fmt.Errorf("无法打开数据库: %w", err)
 	}
 
 	// 创建题目表，区分选择题和编程题字段
 	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS questions (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		title TEXT NOT NULL,
-		question_type INTEGER NOT NULL, -- 1=单选题, 2=多选题, 3=编程题
+		question_type INTEGER NOT NULL, -- 1=单选题, 2=多选题, 3=编程题, 4=判断题, 5=填空题, 6=简答题
 		difficulty INTEGER DEFAULT 2, -- 1=简单, 2=中等, 3=困难，默认为中等
 		answer TEXT, -- 对于选择题存储选项
-		right_answer TEXT -- 对于选择题存储正确答案
+		right_answer TEXT, -- 对于选择题存储正确答案
+		explanation TEXT, -- 题目整体解析
+		rationale TEXT, -- 对于选择题存储每个选项的错误原因
+		payload TEXT, -- 填空题、简答题等题型的答案，JSON格式
+		created_at INTEGER, -- 入库时间，unix时间戳（秒）
+		language TEXT, -- 出题时指定的编程语言
+		usage_count INTEGER NOT NULL DEFAULT 0, -- 在考试中被作答的次数
+		status TEXT NOT NULL DEFAULT 'active', -- active=正常, draft=草稿, archived=已归档
+		bank_id INTEGER NOT NULL DEFAULT 1 -- 所在题库
+	)`)
+
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("无法创建数据库表: %w", err)
+	}
+
+	// 创建题目标签表，一个题目可以有多个标签
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS question_tags (
+		question_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (question_id, tag)
 	)`)
 
 	if err != nil {
-		log.Fatalf("无法创建数据库表: %v", err)
+		db.Close()
+		return nil, fmt.Errorf("无法创建数据库表: %w", err)
+	}
+
+	// 创建答卷记录表，保存每次交卷和每道题的作答，用于题目分析
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS exam_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		total INTEGER NOT NULL, -- 试卷题数
+		correct INTEGER NOT NULL, -- 答对题数
+		created_at INTEGER NOT NULL -- 交卷时间，unix时间戳（秒）
+	);
+	CREATE TABLE IF NOT EXISTS exam_responses (
+		attempt_id INTEGER NOT NULL,
+		question_id INTEGER NOT NULL,
+		selected TEXT NOT NULL, -- 所选选项下标的JSON数组
+		correct INTEGER NOT NULL, -- 1=答对, 0=答错
+		answer_text TEXT, -- 填空题各空作答的JSON数组或简答题的作答
+		pending INTEGER NOT NULL DEFAULT 0 -- 1=等待批改
+	);
+	CREATE INDEX IF NOT EXISTS idx_exam_responses_question ON exam_responses(question_id);
+	CREATE INDEX IF NOT EXISTS idx_exam_responses_attempt ON exam_responses(attempt_id)`)
+
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("无法创建数据库表: %w", err)
+	}
+
+	// 创建图片表和题目引用图片的关联表，图片文件按内容哈希保存在数据目录下
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS attachments (
+		id TEXT PRIMARY KEY, -- 内容的SHA-256
+		name TEXT NOT NULL, -- 第一次上传时的文件名
+		mime_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created_at INTEGER NOT NULL -- 最近一次上传的时间，unix时间戳（秒）
+	);
+	CREATE TABLE IF NOT EXISTS question_attachments (
+		question_id INTEGER NOT NULL,
+		attachment_id TEXT NOT NULL,
+		PRIMARY KEY (question_id, attachment_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_question_attachments_attachment ON question_attachments(attachment_id)`)
+
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("无法创建数据库表: %w", err)
+	}
+
+	// 创建课程、成员、题库和题库共享表
+	if err := createCourseTables(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("无法创建数据库表: %w", err)
+	}
+
+	// 为旧版本数据库补齐新增的列
+	if err := migrateQuestionsTable(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("无法升级数据库表: %w", err)
 	}
 
 	return &StorageService{
 		DataDir: dataDir,
 		DB:      db,
-	}
+	}, nil
 }
 
-// 保存问题数据到SQLite数据库
-func (s *StorageService) SaveQuestion(data *models.QuestionData) error {
-	questionType := data.AIReq.GetQuestionType()
+// 题目表查询时使用的列，顺序与scanQuestion保持一致
+const questionColumns = `id, title, question_type, difficulty, answer, right_answer, explanation, rationale, created_at, language, usage_count, status, bank_id, payload`
 
-	tx, err := s.DB.Begin()
-	if err != nil {
-		return fmt.Errorf("启动事务失败: %w", err)
+// 新增的列及其定义，旧数据库启动时按需补齐
+type columnMigration struct {
+	column     string
+	definition string
+}
+
+// 题目表新增的列
+var questionMigrations = []columnMigration{
+	{"explanation", "TEXT"},
+	{"rationale", "TEXT"},
+	{"created_at", "INTEGER"}, // unix时间戳（秒），旧题目为NULL
+	{"language", "TEXT"},
+	{"usage_count", "INTEGER NOT NULL DEFAULT 0"},
+	{"status", "TEXT NOT NULL DEFAULT 'active'"},
+	{"bank_id", "INTEGER NOT NULL DEFAULT 1"}, // 升级前的题目都放入默认题库
+	{"payload", "TEXT"},
+}
+
+// 作答记录表新增的列
+var responseMigrations = []columnMigration{
+	{"answer_text", "TEXT"},
+	{"pending", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// 列表筛选和排序用到的索引，排序索引带上id以支持游标分页
+var questionIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_questions_created ON questions(COALESCE(created_at, 0), id)",
+	"CREATE INDEX IF NOT EXISTS idx_questions_difficulty ON questions(difficulty, id)",
+	"CREATE INDEX IF NOT EXISTS idx_questions_usage ON questions(usage_count, id)",
+	"CREATE INDEX IF NOT EXISTS idx_questions_language ON questions(language)",
+	"CREATE INDEX IF NOT EXISTS idx_questions_status ON questions(status)",
+	"CREATE INDEX IF NOT EXISTS idx_questions_bank ON questions(bank_id, id)",
+	"CREATE INDEX IF NOT EXISTS idx_question_tags_tag ON question_tags(tag, question_id)",
+}
+
+// 为questions和exam_responses表补上缺少的列，并创建索引
+func migrateQuestionsTable(db *sql.DB) error {
+	if err := addMissingColumns(db, "questions", questionMigrations); err != nil {
+		return err
+	}
+	if err := addMissingColumns(db, "exam_responses", responseMigrations); err != nil {
+		return err
 	}
 
-	var stmt *sql.Stmt
-	var execParams []interface{}
+	for _, index := range questionIndexes {
+		if _, err := db.Exec(index); err != nil {
+			return fmt.Errorf("创建索引失败: %w", err)
+		}
+	}
+	return nil
+}
 
-	if questionType == models.Programming {
-		stmt, err = tx.Prepare(`INSERT INTO questions (
-			title, question_type, difficulty
-		) VALUES (?, ?, ?)`)
+// 检查表已有的列，缺少的列用ALTER TABLE补上
+func addMissingColumns(db *sql.DB, table string, migrations []columnMigration) error {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return fmt.Errorf("读取表结构失败: %w", err)
+	}
 
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("准备SQL语句失败: %w", err)
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			rows.Close()
+			return fmt.Errorf("扫描表结构失败: %w", err)
 		}
+		existing[name] = true
+	}
+	rows.Close()
 
-		execParams = []interface{}{
-			data.AIRes.Title,
-			int(questionType),
-			int(data.Difficulty),
+	for _, m := range migrations {
+		if existing[m.column] {
+			continue
 		}
-	} else {
-		answerJSON, err := json.Marshal(data.AIRes.Answer)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("序列化选项失败: %w", err)
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, m.column, m.definition)); err != nil {
+			return fmt.Errorf("添加列%s.%s失败: %w", table, m.column, err)
 		}
+	}
+	return nil
+}
 
-		rightJSON, err := json.Marshal(data.AIRes.Right)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("序列化正确答案失败: %w", err)
-		}
+// sql.Row和sql.Rows共同的扫描接口
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-		stmt, err = tx.Prepare(`INSERT INTO questions (
-			title, question_type, difficulty, answer, right_answer
-		) VALUES (?, ?, ?, ?, ?)`)
+// 将一行题目数据扫描为QuestionData
+func scanQuestion(row rowScanner) (models.QuestionData, error) {
+	var q models.QuestionData
+	var questionType int
+	var difficulty int
+	var answerJSON, rightJSON, explanation, rationaleJSON, language, payload sql.NullString
+	var createdAt sql.NullInt64
 
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("准备SQL语句失败: %w", err)
-		}
+	err := row.Scan(
+		&q.ID,
+		&q.AIRes.Title,
+		&questionType,
+		&difficulty,
+		&answerJSON,
+		&rightJSON,
+		&explanation,
+		&rationaleJSON,
+		&createdAt,
+		&language,
+		&q.UsageCount,
+		&q.Status,
+		&q.BankID,
+		&payload,
+	)
+	if err != nil {
+		return q, err
+	}
 
-		execParams = []interface{}{
-			data.AIRes.Title,
-			int(questionType),
-			int(data.Difficulty),
-			string(answerJSON),
-			string(rightJSON),
-		}
+	if createdAt.Valid {
+		q.CreatedAt = time.Unix(createdAt.Int64, 0)
+	}
+
+	q.AIReq.Type = models.QuestionType(questionType)
+	q.Difficulty = models.QuestionDifficulty(difficulty)
+	q.AIReq.Difficulty = models.QuestionDifficulty(difficulty)
+	q.AIRes.Explanation = explanation.String
+	q.AIReq.Language = models.ProgrammingLanguage(language.String)
+
+	// 按题型解析答案部分
+	if spec, ok := models.LookupQuestionType(q.AIReq.Type); ok {
+		spec.Decode(models.StoredAnswer{
+			Options:   answerJSON.String,
+			Right:     rightJSON.String,
+			Rationale: rationaleJSON.String,
+			Payload:   payload.String,
+		}, &q.AIRes)
+	}
+
+	q.HTML = q.RenderHTML()
+	return q, nil
+}
+
+// 题目的入库时间，未指定时使用当前时间
+func questionCreatedAt(data *models.QuestionData) int64 {
+	if data.CreatedAt.IsZero() {
+		return time.Now().Unix()
+	}
+	return data.CreatedAt.Unix()
+}
+
+// 题目所在的题库，未指定时为默认题库
+func questionBankID(data *models.QuestionData) int64 {
+	if data.BankID == 0 {
+		return models.DefaultBankID
+	}
+	return data.BankID
+}
+
+// 题目的状态，未指定时为正常使用
+func questionStatus(data *models.QuestionData) models.QuestionStatus {
+	if data.Status == "" {
+		return models.StatusActive
+	}
+	return data.Status
+}
+
+// 新增题目的语句，参数由questionInsertArgs生成
+const questionInsertSQL = `INSERT INTO questions (
+	title, question_type, difficulty, answer, right_answer, explanation, rationale, payload, created_at, language, status, bank_id
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// 按题型编码题目的答案部分
+func encodeQuestion(data *models.QuestionData) (models.StoredAnswer, error) {
+	spec, ok := models.LookupQuestionType(data.AIReq.GetQuestionType())
+	if !ok {
+		return models.StoredAnswer{}, fmt.Errorf("不支持的题目类型: %d", data.AIReq.GetQuestionType())
+	}
+	return spec.Encode(&data.AIRes)
+}
+
+// 新增题目时的参数，与questionInsertSQL的列一一对应
+func questionInsertArgs(data *models.QuestionData) ([]interface{}, error) {
+	stored, err := encodeQuestion(data)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		data.AIRes.Title,
+		int(data.AIReq.GetQuestionType()),
+		int(data.Difficulty),
+		nullIfEmpty(stored.Options),
+		nullIfEmpty(stored.Right),
+		data.AIRes.Explanation,
+		nullIfEmpty(stored.Rationale),
+		nullIfEmpty(stored.Payload),
+		questionCreatedAt(data),
+		string(data.AIReq.Language),
+		string(questionStatus(data)),
+		questionBankID(data),
+	}, nil
+}
+
+// 空字符串存为NULL
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
 	}
+	return s
+}
+
+// 保存问题数据到SQLite数据库
+func (s *StorageService) SaveQuestion(data *models.QuestionData) error {
+	defer metrics.ObserveDB("save_question")()
 
+	execParams, err := questionInsertArgs(data)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("启动事务失败: %w", err)
+	}
+
+	stmt, err := tx.Prepare(questionInsertSQL)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("准备SQL语句失败: %w", err)
+	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(execParams...)
+	result, err := stmt.Exec(execParams...)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("插入数据失败: %w", err)
 	}
 
+	if err := insertTags(tx, result, data.Tags); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("提交事务失败: %w", err)
 	}
@@ -125,6 +388,8 @@ func (s *StorageService) SaveQuestion(data *models.QuestionData) error {
 }
 
 func (s *StorageService) SaveQuestions(questionList []models.QuestionData) error {
+	defer metrics.ObserveDB("save_questions")()
+
 	if len(questionList) == 0 {
 		return nil
 	}
@@ -134,57 +399,30 @@ func (s *StorageService) SaveQuestions(questionList []models.QuestionData) error
 		return fmt.Errorf("启动事务失败: %w", err)
 	}
 
-	stmtProgramming, err := tx.Prepare(`INSERT INTO questions (
-		title, question_type, difficulty
-	) VALUES (?, ?, ?)`)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("准备编程题SQL语句失败: %w", err)
-	}
-	defer stmtProgramming.Close()
-
-	stmtChoice, err := tx.Prepare(`INSERT INTO questions (
-		title, question_type, difficulty, answer, right_answer
-	) VALUES (?, ?, ?, ?, ?)`)
+	stmt, err := tx.Prepare(questionInsertSQL)
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("准备选择题SQL语句失败: %w", err)
+		return fmt.Errorf("准备SQL语句失败: %w", err)
 	}
-	defer stmtChoice.Close()
+	defer stmt.Close()
 
 	for _, question := range questionList {
-		if question.AIReq.GetQuestionType() == models.Programming {
-			_, err = stmtProgramming.Exec(
-				question.AIRes.Title,
-				int(question.AIReq.GetQuestionType()),
-				int(question.Difficulty),
-			)
-		} else {
-			answerJSON, err := json.Marshal(question.AIRes.Answer)
-			if err != nil {
-				tx.Rollback()
-				return fmt.Errorf("序列化选项失败: %w", err)
-			}
-
-			rightJSON, err := json.Marshal(question.AIRes.Right)
-			if err != nil {
-				tx.Rollback()
-				return fmt.Errorf("序列化正确答案失败: %w", err)
-			}
-
-			_, err = stmtChoice.Exec(
-				question.AIRes.Title,
-				int(question.AIReq.GetQuestionType()),
-				int(question.Difficulty),
-				string(answerJSON),
-				string(rightJSON),
-			)
+		args, err := questionInsertArgs(&question)
+		if err != nil {
+			tx.Rollback()
+			return err
 		}
 
+		result, err := stmt.Exec(args...)
 		if err != nil {
 			tx.Rollback()
 			return fmt.Errorf("插入数据失败: %w", err)
 		}
+
+		if err := insertTags(tx, result, question.Tags); err != nil {
+			tx.Rollback()
+			return err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -196,9 +434,9 @@ func (s *StorageService) SaveQuestions(questionList []models.QuestionData) error
 
 // 从数据库中获取所有题目
 func (s *StorageService) GetAllQuestions() ([]models.QuestionData, error) {
-	rows, err := s.DB.Query(`SELECT 
-		id, title, question_type, difficulty, answer, right_answer
-	FROM questions`)
+	defer metrics.ObserveDB("get_all_questions")()
+
+	rows, err := s.DB.Query("SELECT " + questionColumns + " FROM questions")
 
 	if err != nil {
 		return nil, fmt.Errorf("查询数据库失败: %w", err)
@@ -209,348 +447,401 @@ func (s *StorageService) GetAllQuestions() ([]models.QuestionData, error) {
 	var questions []models.QuestionData
 
 	for rows.Next() {
-		var q models.QuestionData
-		var questionType int
-		var difficulty int
-		var answerJSON, rightJSON sql.NullString
-
-		err := rows.Scan(
-			&q.ID,
-			&q.AIRes.Title,
-			&questionType,
-			&difficulty,
-			&answerJSON,
-			&rightJSON,
-		)
-
+		q, err := scanQuestion(rows)
 		if err != nil {
 			return nil, fmt.Errorf("扫描数据库行失败: %w", err)
 		}
 
-		q.AIReq.Type = models.QuestionType(questionType)
-		q.Difficulty = models.QuestionDifficulty(difficulty)
-		q.AIReq.Difficulty = models.QuestionDifficulty(difficulty)
-
-		if q.AIReq.Type == models.Programming {
-		} else {
-			// 选择题解析选项和正确答案
-			if answerJSON.Valid && answerJSON.String != "" {
-				json.Unmarshal([]byte(answerJSON.String), &q.AIRes.Answer)
-			}
-
-			if rightJSON.Valid && rightJSON.String != "" {
-				json.Unmarshal([]byte(rightJSON.String), &q.AIRes.Right)
-			}
-		}
-
 		questions = append(questions, q)
 	}
+	rows.Close()
+
+	if err := loadTags(s.DB, questions); err != nil {
+		return nil, err
+	}
 
 	return questions, nil
 }
 
 // 查询题目列表，支持分页和条件查询
 func (s *StorageService) ListQuestions(page, pageSize int, questionType int, difficulty int, title string) ([]models.QuestionData, int, error) {
-	// 计算偏移量
-	offset := (page - 1) * pageSize
+	filter := models.QuestionFilter{Title: title, Page: page, PageSize: pageSize}
+	if questionType > 0 {
+		filter.Types = []models.QuestionType{models.QuestionType(questionType)}
+	}
+	if difficulty > 0 {
+		filter.Difficulties = []models.QuestionDifficulty{models.QuestionDifficulty(difficulty)}
+	}
 
-	// 构建WHERE条件
-	var conditions []string
-	var args []interface{}
+	result, err := s.QueryQuestions(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result.List, result.Total, nil
+}
 
-	if questionType > 0 {
-		conditions = append(conditions, "question_type = ?")
-		args = append(args, questionType)
+// 排序字段对应的SQL表达式，与questionIndexes中的索引保持一致
+var questionSortColumns = map[models.QuestionSort]string{
+	models.SortByID:         "id",
+	models.SortByCreated:    "COALESCE(created_at, 0)",
+	models.SortByDifficulty: "difficulty",
+	models.SortByUsage:      "usage_count",
+}
+
+// 游标记录上一页最后一条题目的排序值和ID，同时记下排序方式，防止换了排序后继续使用旧游标
+type questionCursor struct {
+	Sort models.QuestionSort `json:"s"`
+	Asc  bool                `json:"a,omitempty"`
+	Key  int64               `json:"k"`
+	ID   int64               `json:"id"`
+}
+
+// 无效的分页游标
+var ErrInvalidCursor = errors.New("无效的分页游标")
+
+func encodeQuestionCursor(c questionCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeQuestionCursor(value string) (questionCursor, error) {
+	var c questionCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, ErrInvalidCursor
 	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
 
-	if difficulty > 0 {
-		conditions = append(conditions, "difficulty = ?")
-		args = append(args, difficulty)
+// 排序字段在题目上的取值，用于生成下一页游标
+func questionSortKey(q *models.QuestionData, sort models.QuestionSort) int64 {
+	switch sort {
+	case models.SortByCreated:
+		if q.CreatedAt.IsZero() {
+			return 0
+		}
+		return q.CreatedAt.Unix()
+	case models.SortByDifficulty:
+		return int64(q.Difficulty)
+	case models.SortByUsage:
+		return int64(q.UsageCount)
+	default:
+		return q.ID
 	}
+}
 
-	if title != "" {
-		conditions = append(conditions, "title LIKE ?")
-		args = append(args, "%"+title+"%")
+// 按筛选条件查询题目。不传游标时按页码分页并统计总数；
+// 传入游标时从游标位置继续向后取，深翻页不需要扫描前面的行
+func (s *StorageService) QueryQuestions(filter models.QuestionFilter) (*models.QuestionListResponse, error) {
+	defer metrics.ObserveDB("list_questions")()
+
+	if filter.Sort == "" {
+		filter.Sort = models.SortByID
+	}
+	sortExpr, ok := questionSortColumns[filter.Sort]
+	if !ok {
+		return nil, fmt.Errorf("不支持的排序字段: %s", filter.Sort)
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = 10
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	conditions, args := questionConditions(filter)
+
+	result := &models.QuestionListResponse{List: []models.QuestionData{}}
+
+	// 只有按页码分页时统计总数
+	if filter.Cursor == "" {
+		countQuery := "SELECT COUNT(*) FROM questions " + whereClause(conditions)
+		if err := s.DB.QueryRow(countQuery, args...).Scan(&result.Total); err != nil {
+			return nil, fmt.Errorf("查询总数失败: %w", err)
+		}
 	}
 
-	whereClause := ""
-	if len(conditions) > 0 {
-		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	direction, compare := "DESC", "<"
+	if filter.Asc {
+		direction, compare = "ASC", ">"
 	}
 
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM questions %s", whereClause)
-	var total int
-	err := s.DB.QueryRow(countQuery, args...).Scan(&total)
-	if err != nil {
-		return nil, 0, fmt.Errorf("查询总数失败: %w", err)
+	limitArgs := []interface{}{filter.PageSize}
+	if filter.Cursor != "" {
+		cursor, err := decodeQuestionCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != filter.Sort || cursor.Asc != filter.Asc {
+			return nil, ErrInvalidCursor
+		}
+		conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", sortExpr, compare))
+		args = append(args, cursor.Key, cursor.Key, cursor.ID)
+	} else {
+		limitArgs = append(limitArgs, (filter.Page-1)*filter.PageSize)
 	}
 
-	query := fmt.Sprintf(`SELECT 
-		id, title, question_type, difficulty, answer, right_answer
+	query := fmt.Sprintf(`SELECT %s
 	FROM questions
 	%s
-	ORDER BY id DESC
-	LIMIT ? OFFSET ?`, whereClause)
-
-	queryArgs := append(args, pageSize, offset)
+	ORDER BY %s %s, id %s
+	LIMIT ?`, questionColumns, whereClause(conditions), sortExpr, direction, direction)
+	if filter.Cursor == "" {
+		query += " OFFSET ?"
+	}
 
-	rows, err := s.DB.Query(query, queryArgs...)
+	rows, err := s.DB.Query(query, append(args, limitArgs...)...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("查询数据失败: %w", err)
+		return nil, fmt.Errorf("查询数据失败: %w", err)
 	}
 	defer rows.Close()
 
-	var questions []models.QuestionData
-
 	for rows.Next() {
-		var q models.QuestionData
-		var questionType int
-		var answerJSON, rightJSON sql.NullString
-
-		err := rows.Scan(
-			&q.ID,
-			&q.AIRes.Title,
-			&questionType,
-			&q.Difficulty,
-			&answerJSON,
-			&rightJSON,
-		)
-
+		q, err := scanQuestion(rows)
 		if err != nil {
-			return nil, 0, fmt.Errorf("扫描数据库行失败: %w", err)
+			return nil, fmt.Errorf("扫描数据库行失败: %w", err)
 		}
 
-		// 设置题目类型
-		q.AIReq.Type = models.QuestionType(questionType)
+		result.List = append(result.List, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询数据失败: %w", err)
+	}
+	rows.Close()
+
+	if err := loadTags(s.DB, result.List); err != nil {
+		return nil, err
+	}
 
-		if q.AIReq.Type == models.Programming {
-		} else {
-			// 选择题解析选项和正确答案
-			if answerJSON.Valid && answerJSON.String != "" {
-				json.Unmarshal([]byte(answerJSON.String), &q.AIRes.Answer)
-			}
+	// 取满一页时可能还有下一页
+	if len(result.List) == filter.PageSize {
+		last := &result.List[len(result.List)-1]
+		result.NextCursor = encodeQuestionCursor(questionCursor{
+			Sort: filter.Sort,
+			Asc:  filter.Asc,
+			Key:  questionSortKey(last, filter.Sort),
+			ID:   last.ID,
+		})
+	}
+
+	return result, nil
+}
+
+// 由筛选条件构建WHERE条件和参数，不包含排序和分页
+func questionConditions(filter models.QuestionFilter) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if len(filter.Types) > 0 {
+		conditions = append(conditions, "question_type IN ("+sqlPlaceholders(len(filter.Types))+")")
+		for _, t := range filter.Types {
+			args = append(args, int(t))
+		}
+	}
+
+	if len(filter.Difficulties) > 0 {
+		conditions = append(conditions, "difficulty IN ("+sqlPlaceholders(len(filter.Difficulties))+")")
+		for _, d := range filter.Difficulties {
+			args = append(args, int(d))
+		}
+	}
+
+	if filter.Language != "" {
+		conditions = append(conditions, "language = ?")
+		args = append(args, string(filter.Language))
+	}
+
+	if filter.Tag != "" {
+		conditions = append(conditions, "id IN (SELECT question_id FROM question_tags WHERE tag = ?)")
+		args = append(args, filter.Tag)
+	}
 
-			if rightJSON.Valid && rightJSON.String != "" {
-				json.Unmarshal([]byte(rightJSON.String), &q.AIRes.Right)
+	if filter.BankIDs != nil {
+		if len(filter.BankIDs) == 0 {
+			conditions = append(conditions, "0")
+		} else {
+			conditions = append(conditions, "bank_id IN ("+sqlPlaceholders(len(filter.BankIDs))+")")
+			for _, id := range filter.BankIDs {
+				args = append(args, id)
 			}
 		}
+	}
 
-		questions = append(questions, q)
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, string(filter.Status))
 	}
 
-	return questions, total, nil
+	if filter.Title != "" {
+		conditions = append(conditions, "title LIKE ?")
+		args = append(args, "%"+filter.Title+"%")
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.CreatedFrom.Unix())
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.CreatedTo.Unix())
+	}
+
+	return conditions, args
+}
+
+// 生成n个以逗号分隔的占位符
+func sqlPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
 }
 
+// 题目不存在
+var ErrQuestionNotFound = errors.New("题目不存在")
+
 // 获取单个题目
 func (s *StorageService) GetQuestionByID(id int64) (*models.QuestionData, error) {
-	query := `SELECT 
-		id, title, question_type, difficulty, answer, right_answer
-	FROM questions
-	WHERE id = ?`
+	defer metrics.ObserveDB("get_question")()
 
-	var q models.QuestionData
-	var questionType int
-	var difficulty int
-	var answerJSON, rightJSON sql.NullString
-
-	err := s.DB.QueryRow(query, id).Scan(
-		&q.ID,
-		&q.AIRes.Title,
-		&questionType,
-		&difficulty,
-		&answerJSON,
-		&rightJSON,
-	)
+	query := "SELECT " + questionColumns + " FROM questions WHERE id = ?"
 
+	q, err := scanQuestion(s.DB.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("题目不存在: ID=%d", id)
+			return nil, fmt.Errorf("%w: ID=%d", ErrQuestionNotFound, id)
 		}
 		return nil, fmt.Errorf("查询题目失败: %w", err)
 	}
 
-	q.AIReq.Type = models.QuestionType(questionType)
-	q.Difficulty = models.QuestionDifficulty(difficulty)
-	q.AIReq.Difficulty = models.QuestionDifficulty(difficulty)
-
-	// 根据题目类型解析不同字段
-	if q.AIReq.Type == models.Programming {
-	} else {
-		// 选择题解析选项和正确答案
-		if answerJSON.Valid && answerJSON.String != "" {
-			json.Unmarshal([]byte(answerJSON.String), &q.AIRes.Answer)
-		}
-
-		if rightJSON.Valid && rightJSON.String != "" {
-			json.Unmarshal([]byte(rightJSON.String), &q.AIRes.Right)
-		}
+	questions := []models.QuestionData{q}
+	if err := loadTags(s.DB, questions); err != nil {
+		return nil, err
 	}
 
-	return &q, nil
+	return &questions[0], nil
 }
 
 // 手动添加题目
 func (s *StorageService) AddQuestion(data *models.QuestionData) (int64, error) {
+	defer metrics.ObserveDB("add_question")()
+
+	args, err := questionInsertArgs(data)
+	if err != nil {
+		return 0, err
+	}
+
 	tx, err := s.DB.Begin()
 	if err != nil {
 		return 0, fmt.Errorf("启动事务失败: %w", err)
 	}
 
-	var result sql.Result
-	var stmt *sql.Stmt
-
-	if data.AIReq.GetQuestionType() == models.Programming {
-		stmt, err = tx.Prepare(`INSERT INTO questions (
-			title, question_type, difficulty
-		) VALUES (?, ?, ?)`)
-
-		if err != nil {
-			tx.Rollback()
-			return 0, fmt.Errorf("准备SQL语句失败: %w", err)
-		}
-		defer stmt.Close()
-
-		result, err = stmt.Exec(
-			data.AIRes.Title,
-			int(data.AIReq.GetQuestionType()),
-			int(data.Difficulty),
-		)
-	} else {
-		answerJSON, err := json.Marshal(data.AIRes.Answer)
-		if err != nil {
-			tx.Rollback()
-			return 0, fmt.Errorf("序列化选项失败: %w", err)
-		}
-
-		sortedRight := make([]int, len(data.AIRes.Right))
-		copy(sortedRight, data.AIRes.Right)
-		sort.Ints(sortedRight)
-
-		rightJSON, err := json.Marshal(sortedRight)
-		if err != nil {
-			tx.Rollback()
-			return 0, fmt.Errorf("序列化正确答案失败: %w", err)
-		}
-
-		stmt, err = tx.Prepare(`INSERT INTO questions (
-			title, question_type, difficulty, answer, right_answer
-		) VALUES (?, ?, ?, ?, ?)`)
-
-		if err != nil {
-			tx.Rollback()
-			return 0, fmt.Errorf("准备SQL语句失败: %w", err)
-		}
-		defer stmt.Close()
-
-		result, err = stmt.Exec(
-			data.AIRes.Title,
-			int(data.AIReq.GetQuestionType()),
-			int(data.Difficulty),
-			string(answerJSON),
-			string(rightJSON),
-		)
+	stmt, err := tx.Prepare(questionInsertSQL)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("准备SQL语句失败: %w", err)
 	}
+	defer stmt.Close()
 
+	result, err := stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
 		return 0, fmt.Errorf("插入数据失败: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return 0, fmt.Errorf("提交事务失败: %w", err)
+	if err := insertTags(tx, result, data.Tags); err != nil {
+		tx.Rollback()
+		return 0, err
 	}
 
 	// 获取新插入的ID
 	id, err := result.LastInsertId()
 	if err != nil {
+		tx.Rollback()
 		return 0, fmt.Errorf("获取插入ID失败: %w", err)
 	}
 
+	if err := linkAttachments(tx, id, data.AttachmentRefs()); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("提交事务失败: %w", err)
+	}
+
 	return id, nil
 }
 
 // 编辑题目
 func (s *StorageService) EditQuestion(id int64, data *models.QuestionData) error {
+	defer metrics.ObserveDB("edit_question")()
+
 	var originalType int
 	err := s.DB.QueryRow("SELECT question_type FROM questions WHERE id = ?", id).Scan(&originalType)
 	if err != nil {
 		return fmt.Errorf("获取原始题目信息失败: %w", err)
 	}
 
+	// 题型改变时按新题型重写全部答案列
+	stored, err := encodeQuestion(data)
+	if err != nil {
+		return err
+	}
+
 	tx, err := s.DB.Begin()
 	if err != nil {
 		return fmt.Errorf("启动事务失败: %w", err)
 	}
 
-	var stmt *sql.Stmt
-	newType := int(data.AIReq.GetQuestionType())
-
-	if newType == int(models.Programming) {
-		// 如果是编程题：清空answer和right_answer字段，只更新标题、题目类型和难度
-		stmt, err = tx.Prepare(`UPDATE questions SET
-			title = ?,
-			question_type = ?,
-			difficulty = ?,
-			answer = NULL,
-			right_answer = NULL
-		WHERE id = ?`)
-
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("准备SQL语句失败: %w", err)
-		}
-		defer stmt.Close()
-
-		_, err = stmt.Exec(
-			data.AIRes.Title,
-			newType,
-			int(data.Difficulty),
-			id,
-		)
-	} else {
-		answerJSON, err := json.Marshal(data.AIRes.Answer)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("序列化选项失败: %w", err)
-		}
-
-		sortedRight := make([]int, len(data.AIRes.Right))
-		copy(sortedRight, data.AIRes.Right)
-		sort.Ints(sortedRight)
-
-		rightJSON, err := json.Marshal(sortedRight)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("序列化正确答案失败: %w", err)
-		}
-
-		stmt, err = tx.Prepare(`UPDATE questions SET
-			title = ?,
-			question_type = ?,
-			difficulty = ?,
-			answer = ?,
-			right_answer = ?
-		WHERE id = ?`)
+	_, err = tx.Exec(`UPDATE questions SET
+		title = ?,
+		question_type = ?,
+		difficulty = ?,
+		answer = ?,
+		right_answer = ?,
+		explanation = ?,
+		rationale = ?,
+		payload = ?,
+		language = ?,
+		status = COALESCE(NULLIF(?, ''), status)
+	WHERE id = ?`,
+		data.AIRes.Title,
+		int(data.AIReq.GetQuestionType()),
+		int(data.Difficulty),
+		nullIfEmpty(stored.Options),
+		nullIfEmpty(stored.Right),
+		data.AIRes.Explanation,
+		nullIfEmpty(stored.Rationale),
+		nullIfEmpty(stored.Payload),
+		string(data.AIReq.Language),
+		string(data.Status),
+		id,
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("更新数据失败: %w", err)
+	}
 
-		if err != nil {
+	// 没有传入标签时保留原有标签
+	if data.Tags != nil {
+		if err := replaceTags(tx, id, data.Tags); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("准备SQL语句失败: %w", err)
+			return err
 		}
-		defer stmt.Close()
-
-		_, err = stmt.Exec(
-			data.AIRes.Title,
-			newType,
-			int(data.Difficulty),
-			string(answerJSON),
-			string(rightJSON),
-			id,
-		)
 	}
 
-	if err != nil {
+	if err := linkAttachments(tx, id, data.AttachmentRefs()); err != nil {
 		tx.Rollback()
-		return fmt.Errorf("更新数据失败: %w", err)
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -560,8 +851,43 @@ func (s *StorageService) EditQuestion(id int64, data *models.QuestionData) error
 	return nil
 }
 
+// 以题目ID为键的关联表，删除题目时一起清理。不再被引用的图片由AttachmentService定期清理
+var questionRowTables = []struct {
+	table string
+	name  string
+}{
+	{"question_tags", "标签"},
+	{"question_attachments", "图片引用"},
+	{"exam_responses", "作答记录"},
+	{"response_grades", "批改结果"},
+	{"question_rubrics", "评分标准"},
+	{"review_cards", "练习记录"},
+	{"adaptive_responses", "自适应测试作答"},
+}
+
+// 在事务中删除题目的关联数据。练习、批改和自适应测试的表由各自的服务创建，
+// 只打开题库的场合（命令行工具）可能还没有这些表，跳过即可
+func deleteQuestionRows(tx *sql.Tx, placeholders string, args []interface{}) error {
+	for _, t := range questionRowTables {
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", t.table).Scan(&exists); err != nil {
+			return fmt.Errorf("查询%s表失败: %w", t.name, err)
+		}
+		if exists == 0 {
+			continue
+		}
+		query := fmt.Sprintf("DELETE FROM %s WHERE question_id IN (%s)", t.table, placeholders)
+		if _, err := tx.Exec(query, args...); err != nil {
+			return fmt.Errorf("删除%s失败: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
 // 删除题目
 func (s *StorageService) DeleteQuestions(ids []int64) error {
+	defer metrics.ObserveDB("delete_questions")()
+
 	if len(ids) == 0 {
 		return fmt.Errorf("没有指定要删除的题目ID")
 	}
@@ -577,11 +903,21 @@ func (s *StorageService) DeleteQuestions(ids []int64) error {
 
 	query := fmt.Sprintf("DELETE FROM questions WHERE id IN (%s)", strings.Join(placeholders, ","))
 
-	result, err := s.DB.Exec(query, args...)
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("删除题目失败: %w", err)
 	}
 
+	if err := deleteQuestionRows(tx, strings.Join(placeholders, ","), args); err != nil {
+		return err
+	}
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("获取影响行数失败: %w", err)
@@ -591,5 +927,86 @@ func (s *StorageService) DeleteQuestions(ids []int64) error {
 		return fmt.Errorf("没有找到指定的题目")
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+
 	return nil
 }
+
+// 查出题目所在的题库，不存在的题目不在结果中
+func (s *StorageService) QuestionBanks(ids []int64) (map[int64]int64, error) {
+	defer metrics.ObserveDB("question_banks")()
+
+	banks := make(map[int64]int64, len(ids))
+	for start := 0; start < len(ids); start += tagQueryBatch {
+		end := min(start+tagQueryBatch, len(ids))
+		args := make([]interface{}, 0, end-start)
+		for _, id := range ids[start:end] {
+			args = append(args, id)
+		}
+
+		rows, err := s.DB.Query("SELECT id, bank_id FROM questions WHERE id IN ("+sqlPlaceholders(len(args))+")", args...)
+		if err != nil {
+			return nil, fmt.Errorf("查询题目所在题库失败: %w", err)
+		}
+		for rows.Next() {
+			var id, bankID int64
+			if err := rows.Scan(&id, &bankID); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("查询题目所在题库失败: %w", err)
+			}
+			banks[id] = bankID
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("查询题目所在题库失败: %w", err)
+		}
+	}
+	return banks, nil
+}
+
+// 把题目复制到指定题库，返回新题目的ID。标签和图片引用一起复制，
+// 作答次数从零开始。任何一道题复制失败时整体回滚
+func (s *StorageService) CopyQuestions(ids []int64, bankID int64) ([]int64, error) {
+	defer metrics.ObserveDB("copy_questions")()
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	copied := make([]int64, 0, len(ids))
+	for _, id := range uniqueIDs(ids) {
+		result, err := tx.Exec(`INSERT INTO questions (
+			title, question_type, difficulty, answer, right_answer, explanation, rationale, payload, created_at, language, status, bank_id
+		) SELECT title, question_type, difficulty, answer, right_answer, explanation, rationale, payload, ?, language, status, ?
+		FROM questions WHERE id = ?`, now, bankID, id)
+		if err != nil {
+			return nil, fmt.Errorf("复制题目%d失败: %w", id, err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return nil, fmt.Errorf("%w: ID=%d", ErrQuestionNotFound, id)
+		}
+		newID, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("获取插入ID失败: %w", err)
+		}
+
+		if _, err := tx.Exec("INSERT INTO question_tags (question_id, tag) SELECT ?, tag FROM question_tags WHERE question_id = ?", newID, id); err != nil {
+			return nil, fmt.Errorf("复制标签失败: %w", err)
+		}
+		if _, err := tx.Exec("INSERT INTO question_attachments (question_id, attachment_id) SELECT ?, attachment_id FROM question_attachments WHERE question_id = ?", newID, id); err != nil {
+			return nil, fmt.Errorf("复制图片引用失败: %w", err)
+		}
+		copied = append(copied, newID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return copied, nil
+}
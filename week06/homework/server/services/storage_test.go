@@ -0,0 +1,506 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"question-generator/config"
+	"question-generator/models"
+	"reflect"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// 在临时目录中创建存储服务
+func newTestStorage(t *testing.T) *StorageService {
+	t.Helper()
+
+	storage, err := OpenStorageService(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenStorageService: %v", err)
+	}
+	t.Cleanup(func() { storage.DB.Close() })
+	return storage
+}
+
+func choiceQuestion(title string, qType models.QuestionType, right ...int) *models.QuestionData {
+	return &models.QuestionData{
+		AIReq: models.QuestionRequest{Type: qType},
+		AIRes: models.AIResponse{
+			Title:       title,
+			Answer:      []string{"A", "B", "C", "D"},
+			Right:       right,
+			Explanation: title + "的解析",
+			Rationale:   []string{"A错误", "", "C错误", "D错误"},
+		},
+		Difficulty: models.Medium,
+	}
+}
+
+func programmingQuestion(title string) *models.QuestionData {
+	return &models.QuestionData{
+		AIReq:      models.QuestionRequest{Type: models.Programming},
+		AIRes:      models.AIResponse{Title: title, Explanation: "思路"},
+		Difficulty: models.Hard,
+	}
+}
+
+func TestAddAndGetQuestion(t *testing.T) {
+	storage := newTestStorage(t)
+
+	id, err := storage.AddQuestion(choiceQuestion("多选", models.MultiChoice, 3, 1))
+	if err != nil {
+		t.Fatalf("AddQuestion: %v", err)
+	}
+
+	q, err := storage.GetQuestionByID(id)
+	if err != nil {
+		t.Fatalf("GetQuestionByID: %v", err)
+	}
+	if q.AIRes.Title != "多选" || q.AIReq.Type != models.MultiChoice || q.Difficulty != models.Medium {
+		t.Errorf("unexpected question %+v", q)
+	}
+	if !reflect.DeepEqual(q.AIRes.Right, []int{1, 3}) {
+		t.Errorf("right = %v, want sorted [1 3]", q.AIRes.Right)
+	}
+	if q.AIRes.Explanation != "多选的解析" || len(q.AIRes.Rationale) != 4 {
+		t.Errorf("explanation/rationale not stored: %+v", q.AIRes)
+	}
+
+	if _, err := storage.GetQuestionByID(id + 100); err == nil {
+		t.Error("want error for missing question")
+	}
+}
+
+func TestProgrammingQuestionHasNoOptions(t *testing.T) {
+	storage := newTestStorage(t)
+
+	id, err := storage.AddQuestion(programmingQuestion("实现LRU"))
+	if err != nil {
+		t.Fatalf("AddQuestion: %v", err)
+	}
+
+	q, err := storage.GetQuestionByID(id)
+	if err != nil {
+		t.Fatalf("GetQuestionByID: %v", err)
+	}
+	if len(q.AIRes.Answer) != 0 || len(q.AIRes.Right) != 0 || q.AIRes.Explanation != "思路" {
+		t.Errorf("unexpected programming question %+v", q.AIRes)
+	}
+}
+
+func TestSaveQuestionsAndList(t *testing.T) {
+	storage := newTestStorage(t)
+
+	batch := []models.QuestionData{
+		*choiceQuestion("Go单选一", models.SingleChoice, 0),
+		*choiceQuestion("Go单选二", models.SingleChoice, 1),
+		*choiceQuestion("Java多选", models.MultiChoice, 0, 2),
+		*programmingQuestion("Go编程"),
+	}
+	if err := storage.SaveQuestions(batch); err != nil {
+		t.Fatalf("SaveQuestions: %v", err)
+	}
+	if err := storage.SaveQuestion(choiceQuestion("单独保存", models.SingleChoice, 2)); err != nil {
+		t.Fatalf("SaveQuestion: %v", err)
+	}
+
+	all, err := storage.GetAllQuestions()
+	if err != nil {
+		t.Fatalf("GetAllQuestions: %v", err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("got %d questions, want 5", len(all))
+	}
+
+	tests := []struct {
+		name       string
+		page       int
+		pageSize   int
+		qType      int
+		difficulty int
+		title      string
+		wantTotal  int
+		wantTitles []string
+	}{
+		{name: "全部按id倒序", page: 1, pageSize: 2, wantTotal: 5, wantTitles: []string{"单独保存", "Go编程"}},
+		{name: "第二页", page: 2, pageSize: 2, wantTotal: 5, wantTitles: []string{"Java多选", "Go单选二"}},
+		{name: "按类型", page: 1, pageSize: 10, qType: int(models.SingleChoice), wantTotal: 3, wantTitles: []string{"单独保存", "Go单选二", "Go单选一"}},
+		{name: "按难度", page: 1, pageSize: 10, difficulty: int(models.Hard), wantTotal: 1, wantTitles: []string{"Go编程"}},
+		{name: "模糊搜索", page: 1, pageSize: 10, title: "Go", wantTotal: 3, wantTitles: []string{"Go编程", "Go单选二", "Go单选一"}},
+		{name: "组合条件", page: 1, pageSize: 10, qType: int(models.MultiChoice), title: "Go", wantTotal: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, total, err := storage.ListQuestions(tt.page, tt.pageSize, tt.qType, tt.difficulty, tt.title)
+			if err != nil {
+				t.Fatalf("ListQuestions: %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+			var titles []string
+			for _, q := range list {
+				titles = append(titles, q.AIRes.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.wantTitles) {
+				t.Errorf("titles = %v, want %v", titles, tt.wantTitles)
+			}
+		})
+	}
+}
+
+func TestQueryQuestions(t *testing.T) {
+	storage := newTestStorage(t)
+
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.Local) }
+	add := func(q *models.QuestionData, language models.ProgrammingLanguage, difficulty models.QuestionDifficulty, createdAt time.Time) int64 {
+		t.Helper()
+		q.AIReq.Language = language
+		q.Difficulty = difficulty
+		q.CreatedAt = createdAt
+		id, err := storage.AddQuestion(q)
+		if err != nil {
+			t.Fatalf("AddQuestion: %v", err)
+		}
+		return id
+	}
+	add(choiceQuestion("Go简单", models.SingleChoice, 0), models.Go, models.Easy, day(3))
+	pyHard := add(choiceQuestion("Python困难", models.MultiChoice, 0, 1), models.Python, models.Hard, day(1))
+	add(programmingQuestion("Go编程"), models.Go, models.Hard, day(2))
+	add(choiceQuestion("Java中等", models.SingleChoice, 1), models.Java, models.Medium, day(5))
+
+	if _, err := storage.GradeExam("alice", []models.ExamAnswer{{QuestionID: pyHard, Selected: []int{0}}}); err != nil {
+		t.Fatalf("GradeExam: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		filter     models.QuestionFilter
+		wantTotal  int
+		wantTitles []string
+	}{
+		{name: "按语言", filter: models.QuestionFilter{Language: models.Go}, wantTotal: 2, wantTitles: []string{"Go编程", "Go简单"}},
+		{name: "多个题型", filter: models.QuestionFilter{Types: []models.QuestionType{models.MultiChoice, models.Programming}}, wantTotal: 2, wantTitles: []string{"Go编程", "Python困难"}},
+		{name: "多个难度", filter: models.QuestionFilter{Difficulties: []models.QuestionDifficulty{models.Easy, models.Medium}}, wantTotal: 2, wantTitles: []string{"Java中等", "Go简单"}},
+		{name: "入库时间范围", filter: models.QuestionFilter{CreatedFrom: day(2), CreatedTo: day(4)}, wantTotal: 2, wantTitles: []string{"Go编程", "Go简单"}},
+		{name: "按入库时间升序", filter: models.QuestionFilter{Sort: models.SortByCreated, Asc: true}, wantTotal: 4, wantTitles: []string{"Python困难", "Go编程", "Go简单", "Java中等"}},
+		{name: "按难度倒序", filter: models.QuestionFilter{Sort: models.SortByDifficulty}, wantTotal: 4, wantTitles: []string{"Go编程", "Python困难", "Java中等", "Go简单"}},
+		{name: "按使用次数", filter: models.QuestionFilter{Sort: models.SortByUsage, PageSize: 1}, wantTotal: 4, wantTitles: []string{"Python困难"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := storage.QueryQuestions(tt.filter)
+			if err != nil {
+				t.Fatalf("QueryQuestions: %v", err)
+			}
+			if result.Total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", result.Total, tt.wantTotal)
+			}
+			var titles []string
+			for _, q := range result.List {
+				titles = append(titles, q.AIRes.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.wantTitles) {
+				t.Errorf("titles = %v, want %v", titles, tt.wantTitles)
+			}
+		})
+	}
+
+	// 游标分页依次取完所有题目，难度相同时按id倒序
+	filter := models.QuestionFilter{Sort: models.SortByDifficulty, PageSize: 3}
+	var titles []string
+	for page := 0; ; page++ {
+		result, err := storage.QueryQuestions(filter)
+		if err != nil {
+			t.Fatalf("QueryQuestions page %d: %v", page, err)
+		}
+		for _, q := range result.List {
+			titles = append(titles, q.AIRes.Title)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		filter.Cursor = result.NextCursor
+	}
+	if want := []string{"Go编程", "Python困难", "Java中等", "Go简单"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("cursor pages = %v, want %v", titles, want)
+	}
+
+	// 排序方式变化后旧游标失效
+	filter.Sort = models.SortByCreated
+	if _, err := storage.QueryQuestions(filter); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("mismatched cursor: err = %v", err)
+	}
+}
+
+func TestEditQuestion(t *testing.T) {
+	storage := newTestStorage(t)
+
+	id, err := storage.AddQuestion(choiceQuestion("原题", models.SingleChoice, 0))
+	if err != nil {
+		t.Fatalf("AddQuestion: %v", err)
+	}
+
+	edited := choiceQuestion("改后", models.MultiChoice, 2, 0)
+	edited.Difficulty = models.Easy
+	if err := storage.EditQuestion(id, edited); err != nil {
+		t.Fatalf("EditQuestion: %v", err)
+	}
+	q, _ := storage.GetQuestionByID(id)
+	if q.AIRes.Title != "改后" || q.AIReq.Type != models.MultiChoice || q.Difficulty != models.Easy {
+		t.Errorf("unexpected question after edit %+v", q)
+	}
+	if !reflect.DeepEqual(q.AIRes.Right, []int{0, 2}) {
+		t.Errorf("right = %v", q.AIRes.Right)
+	}
+
+	// 改为编程题后选项和错误原因被清空
+	if err := storage.EditQuestion(id, programmingQuestion("改成编程题")); err != nil {
+		t.Fatalf("EditQuestion: %v", err)
+	}
+	q, _ = storage.GetQuestionByID(id)
+	if q.AIReq.Type != models.Programming || len(q.AIRes.Answer) != 0 || len(q.AIRes.Rationale) != 0 {
+		t.Errorf("options not cleared: %+v", q)
+	}
+
+	if err := storage.EditQuestion(id+100, edited); err == nil {
+		t.Error("want error editing missing question")
+	}
+}
+
+func TestDeleteQuestions(t *testing.T) {
+	storage := newTestStorage(t)
+
+	var ids []int64
+	for _, title := range []string{"一", "二", "三"} {
+		id, err := storage.AddQuestion(choiceQuestion(title, models.SingleChoice, 0))
+		if err != nil {
+			t.Fatalf("AddQuestion: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// 练习、批改和自适应测试的表由各自的服务创建
+	if _, err := NewPracticeService(storage); err != nil {
+		t.Fatalf("NewPracticeService: %v", err)
+	}
+	if _, err := NewGraderService(storage, nil, NewBackgroundJobs()); err != nil {
+		t.Fatalf("NewGraderService: %v", err)
+	}
+	if _, err := NewAdaptiveService(storage, nil, config.AdaptiveConfig{}); err != nil {
+		t.Fatalf("NewAdaptiveService: %v", err)
+	}
+	related := []string{"exam_responses", "response_grades", "question_rubrics", "review_cards", "adaptive_responses"}
+	for i, id := range ids {
+		for _, stmt := range []string{
+			"INSERT INTO exam_responses (attempt_id, question_id, selected, correct) VALUES (1, ?, '[0]', 1)",
+			"INSERT INTO response_grades (attempt_id, question_id, max_score) VALUES (1, ?, 10)",
+			"INSERT INTO question_rubrics (question_id, criteria, updated_at) VALUES (?, '[]', 0)",
+			"INSERT INTO review_cards (user_id, question_id, repetitions, interval_days, ease, due_at, reviews, correct_reviews, lapses, last_reviewed_at, in_notebook) VALUES ('alice', ?, 0, 1, 2.5, 0, 1, 1, 0, 0, 0)",
+			fmt.Sprintf("INSERT INTO adaptive_responses (session_id, seq, question_id, a, b, calibrated, selected, correct, theta, se) VALUES ('s', %d, ?, 1, 0, 0, '[0]', 1, 0, 1)", i),
+		} {
+			if _, err := storage.DB.Exec(stmt, id); err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+	}
+
+	if err := storage.DeleteQuestions(ids[:2]); err != nil {
+		t.Fatalf("DeleteQuestions: %v", err)
+	}
+	_, total, _ := storage.ListQuestions(1, 10, 0, 0, "")
+	if total != 1 {
+		t.Errorf("total after delete = %d, want 1", total)
+	}
+	// 被删除题目的关联数据一起清理，其他题目的保留
+	for _, table := range related {
+		var deleted, kept int
+		storage.DB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE question_id IN (?, ?)", table), ids[0], ids[1]).Scan(&deleted)
+		storage.DB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE question_id = ?", table), ids[2]).Scan(&kept)
+		if deleted != 0 || kept != 1 {
+			t.Errorf("%s: %d rows left for deleted questions, %d for kept, want 0 and 1", table, deleted, kept)
+		}
+	}
+
+	if err := storage.DeleteQuestions(ids[:2]); err == nil {
+		t.Error("want error deleting already deleted questions")
+	}
+	if err := storage.DeleteQuestions(nil); err == nil {
+		t.Error("want error for empty ids")
+	}
+}
+
+func TestGradeExam(t *testing.T) {
+	storage := newTestStorage(t)
+
+	single, _ := storage.AddQuestion(choiceQuestion("单选", models.SingleChoice, 1))
+	multi, _ := storage.AddQuestion(choiceQuestion("多选", models.MultiChoice, 0, 1))
+
+	result, err := storage.GradeExam("alice", []models.ExamAnswer{
+		{QuestionID: single, Selected: []int{1}},
+		{QuestionID: multi, Selected: []int{1, 2}},
+	})
+	if err != nil {
+		t.Fatalf("GradeExam: %v", err)
+	}
+	if result.Total != 2 || result.Correct != 1 || result.Score != 50 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !result.Results[0].Correct || result.Results[0].Rationale != nil {
+		t.Errorf("unexpected first result %+v", result.Results[0])
+	}
+	wrong := result.Results[1]
+	if wrong.Correct || wrong.Explanation != "多选的解析" {
+		t.Errorf("unexpected second result %+v", wrong)
+	}
+	// 选中的1是正确选项，不返回错误原因；2是错误选项
+	if !reflect.DeepEqual(wrong.Rationale, map[int]string{2: "C错误"}) {
+		t.Errorf("rationale = %v", wrong.Rationale)
+	}
+
+	// 每次交卷记录题目的使用次数
+	if q, _ := storage.GetQuestionByID(single); q.UsageCount != 1 {
+		t.Errorf("usage count = %d, want 1", q.UsageCount)
+	}
+
+	// 编程题提交思路说明，等待按评分标准批改
+	programming, _ := storage.AddQuestion(programmingQuestion("编程"))
+	result, err = storage.GradeExam("alice", []models.ExamAnswer{{QuestionID: programming, Text: "用哈希表加双向链表"}})
+	if err != nil {
+		t.Fatalf("GradeExam programming: %v", err)
+	}
+	if !result.Results[0].Pending || result.Pending != 1 || result.Score != 0 {
+		t.Errorf("programming result = %+v", result)
+	}
+}
+
+func TestQuestionTypesRoundTrip(t *testing.T) {
+	storage := newTestStorage(t)
+
+	questions := []*models.QuestionData{
+		{AIReq: models.QuestionRequest{Type: models.TrueFalse}, AIRes: models.AIResponse{Title: "map并发安全", Right: []int{1}}},
+		{AIReq: models.QuestionRequest{Type: models.FillBlank}, AIRes: models.AIResponse{Title: "用____启动协程", Blanks: [][]string{{"go", "go语句"}}}},
+		{AIReq: models.QuestionRequest{Type: models.ShortAnswer}, AIRes: models.AIResponse{Title: "简述defer", Reference: "后进先出"}},
+	}
+	for _, q := range questions {
+		id, err := storage.AddQuestion(q)
+		if err != nil {
+			t.Fatalf("AddQuestion(%s): %v", q.AIRes.Title, err)
+		}
+		got, err := storage.GetQuestionByID(id)
+		if err != nil {
+			t.Fatalf("GetQuestionByID: %v", err)
+		}
+		if got.AIReq.Type != q.AIReq.Type || !reflect.DeepEqual(got.AIRes.Blanks, q.AIRes.Blanks) || got.AIRes.Reference != q.AIRes.Reference {
+			t.Errorf("round trip %+v, want %+v", got.AIRes, q.AIRes)
+		}
+	}
+
+	// 判断题的选项固定
+	tf, _ := storage.GetQuestionByID(1)
+	if !reflect.DeepEqual(tf.AIRes.Answer, models.TrueFalseOptions) {
+		t.Errorf("true/false options = %v", tf.AIRes.Answer)
+	}
+
+	invalid := []*models.QuestionData{
+		{AIReq: models.QuestionRequest{Type: models.TrueFalse}, AIRes: models.AIResponse{Title: "缺答案"}},
+		{AIReq: models.QuestionRequest{Type: models.FillBlank}, AIRes: models.AIResponse{Title: "空答案", Blanks: [][]string{{" "}}}},
+		{AIReq: models.QuestionRequest{Type: models.ShortAnswer}, AIRes: models.AIResponse{Title: "缺参考答案"}},
+		{AIReq: models.QuestionRequest{Type: 99}, AIRes: models.AIResponse{Title: "未知题型"}},
+		{AIReq: models.QuestionRequest{Type: models.SingleChoice}, AIRes: models.AIResponse{Title: "单选题没有答案", Answer: []string{"A", "B"}}},
+		{AIReq: models.QuestionRequest{Type: models.SingleChoice}, AIRes: models.AIResponse{Title: "单选题两个答案", Answer: []string{"A", "B"}, Right: []int{0, 1}}},
+		{AIReq: models.QuestionRequest{Type: models.MultiChoice}, AIRes: models.AIResponse{Title: "多选题一个答案", Answer: []string{"A", "B", "C"}, Right: []int{1}}},
+		{AIReq: models.QuestionRequest{Type: models.MultiChoice}, AIRes: models.AIResponse{Title: "多选题重复答案", Answer: []string{"A", "B", "C"}, Right: []int{1, 1}}},
+	}
+	for _, q := range invalid {
+		if err := q.Validate(); err == nil {
+			t.Errorf("Validate(%s) should fail", q.AIRes.Title)
+		}
+	}
+}
+
+func TestGradeExamQuestionTypes(t *testing.T) {
+	storage := newTestStorage(t)
+
+	tf, _ := storage.AddQuestion(&models.QuestionData{AIReq: models.QuestionRequest{Type: models.TrueFalse}, AIRes: models.AIResponse{Title: "判断", Right: []int{0}}})
+	blank, _ := storage.AddQuestion(&models.QuestionData{AIReq: models.QuestionRequest{Type: models.FillBlank}, AIRes: models.AIResponse{Title: "填空", Blanks: [][]string{{"go"}, {"chan", "channel"}}}})
+	short, _ := storage.AddQuestion(&models.QuestionData{AIReq: models.QuestionRequest{Type: models.ShortAnswer}, AIRes: models.AIResponse{Title: "简答", Reference: "要点"}})
+
+	result, err := storage.GradeExam("alice", []models.ExamAnswer{
+		{QuestionID: tf, Selected: []int{0}},
+		{QuestionID: blank, Blanks: []string{" GO ", "Channel"}},
+		{QuestionID: short, Text: "我的回答"},
+	})
+	if err != nil {
+		t.Fatalf("GradeExam: %v", err)
+	}
+	// 简答题等待批改，不计入得分
+	if result.Correct != 2 || result.Pending != 1 || result.Score != 100 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !reflect.DeepEqual(result.Results[1].BlankResults, []bool{true, true}) {
+		t.Errorf("blank results = %v", result.Results[1].BlankResults)
+	}
+	pending := result.Results[2]
+	if !pending.Pending || pending.Correct || pending.Text != "我的回答" || pending.Reference != "要点" {
+		t.Errorf("short answer result = %+v", pending)
+	}
+
+	// 少填一个空算错
+	result, _ = storage.GradeExam("alice", []models.ExamAnswer{{QuestionID: blank, Blanks: []string{"go"}}})
+	if result.Correct != 0 || !reflect.DeepEqual(result.Results[0].BlankResults, []bool{true, false}) {
+		t.Errorf("partial blanks = %+v", result.Results[0])
+	}
+
+	var text string
+	var pendingFlag bool
+	if err := storage.DB.QueryRow("SELECT answer_text, pending FROM exam_responses WHERE question_id = ?", short).Scan(&text, &pendingFlag); err != nil {
+		t.Fatalf("query response: %v", err)
+	}
+	if text != "我的回答" || !pendingFlag {
+		t.Errorf("stored response = %q, %v", text, pendingFlag)
+	}
+}
+
+// 旧版本数据库缺少新增的列，打开时应自动补齐且保留原有数据
+func TestMigrateOldDatabase(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "questions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE questions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		question_type INTEGER NOT NULL,
+		difficulty INTEGER DEFAULT 2,
+		answer TEXT,
+		right_answer TEXT
+	);
+	INSERT INTO questions (title, question_type, answer, right_answer) VALUES ('旧题', 1, '["a","b"]', '[0]');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	storage, err := OpenStorageService(dir)
+	if err != nil {
+		t.Fatalf("OpenStorageService: %v", err)
+	}
+	defer storage.DB.Close()
+
+	q, err := storage.GetQuestionByID(1)
+	if err != nil {
+		t.Fatalf("GetQuestionByID: %v", err)
+	}
+	if q.AIRes.Title != "旧题" || q.AIRes.Explanation != "" || q.AIRes.Rationale != nil {
+		t.Errorf("unexpected migrated question %+v", q)
+	}
+}
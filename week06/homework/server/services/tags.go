@@ -0,0 +1,128 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"question-generator/models"
+	"sort"
+	"strings"
+)
+
+// 单个标签的最大长度（字符数）
+const maxTagLength = 32
+
+// 每次查询标签时最多带上的题目ID数
+const tagQueryBatch = 500
+
+// *sql.DB和*sql.Tx共同的查询接口
+type sqlQueryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// 去掉标签两端空白，丢弃空标签和重复标签，超长的标签截断
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if runes := []rune(tag); len(runes) > maxTagLength {
+			tag = string(runes[:maxTagLength])
+		}
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// 为刚插入的题目写入标签
+func insertTags(tx *sql.Tx, result sql.Result, tags []string) error {
+	tags = normalizeTags(tags)
+	if len(tags) == 0 {
+		return nil
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("获取插入ID失败: %w", err)
+	}
+	return addTags(tx, id, tags)
+}
+
+// 给题目添加标签，已有的标签忽略
+func addTags(q sqlQueryer, id int64, tags []string) error {
+	for _, tag := range normalizeTags(tags) {
+		if _, err := q.Exec("INSERT OR IGNORE INTO question_tags (question_id, tag) VALUES (?, ?)", id, tag); err != nil {
+			return fmt.Errorf("保存标签失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// 用tags替换题目原有的全部标签
+func replaceTags(q sqlQueryer, id int64, tags []string) error {
+	if _, err := q.Exec("DELETE FROM question_tags WHERE question_id = ?", id); err != nil {
+		return fmt.Errorf("删除标签失败: %w", err)
+	}
+	return addTags(q, id, tags)
+}
+
+// 批量读取题目的标签，每个题目的标签按字母顺序排列
+func loadTags(q sqlQueryer, questions []models.QuestionData) error {
+	if len(questions) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]int, len(questions))
+	for i := range questions {
+		questions[i].Tags = []string{}
+		index[questions[i].ID] = i
+	}
+
+	// 分批查询，避免超出SQLite的参数个数限制
+	for start := 0; start < len(questions); start += tagQueryBatch {
+		end := start + tagQueryBatch
+		if end > len(questions) {
+			end = len(questions)
+		}
+		args := make([]interface{}, 0, end-start)
+		for _, question := range questions[start:end] {
+			args = append(args, question.ID)
+		}
+		if err := scanTags(q, args, func(id int64, tag string) {
+			if i, ok := index[id]; ok {
+				questions[i].Tags = append(questions[i].Tags, tag)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+
+	for i := range questions {
+		sort.Strings(questions[i].Tags)
+	}
+	return nil
+}
+
+func scanTags(q sqlQueryer, ids []interface{}, fn func(id int64, tag string)) error {
+	rows, err := q.Query("SELECT question_id, tag FROM question_tags WHERE question_id IN ("+sqlPlaceholders(len(ids))+")", ids...)
+	if err != nil {
+		return fmt.Errorf("查询标签失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return fmt.Errorf("扫描标签失败: %w", err)
+		}
+		fn(id, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("查询标签失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"question-generator/config"
+	"question-generator/metrics"
+	"question-generator/models"
+	"sync"
+	"time"
+)
+
+// 配额用完时返回的错误，可用errors.Is判断
+var ErrQuotaExceeded = errors.New("出题配额已用完")
+
+// 负责记录模型调用的tokens用量和费用，并在调用前检查配额
+type UsageService struct {
+	db     *sql.DB
+	prices map[string]config.ModelPrice
+	mu     sync.RWMutex
+	quotas config.QuotaConfig // 可以热加载，读写时需持有mu
+	now    func() time.Time
+}
+
+// 创建用量服务，用量表与题库放在同一个数据库中
+func NewUsageService(db *sql.DB, cfg *config.Configuration) (*UsageService, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ai_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		model TEXT NOT NULL,
+		purpose TEXT NOT NULL, -- generate=出题, review=审核
+		prompt_tokens INTEGER DEFAULT 0,
+		completion_tokens INTEGER DEFAULT 0,
+		cost REAL DEFAULT 0, -- 单位为元
+		latency_ms INTEGER DEFAULT 0,
+		success INTEGER NOT NULL, -- 1=成功, 0=失败
+		created_at INTEGER NOT NULL -- unix时间戳（秒）
+	);
+	CREATE INDEX IF NOT EXISTS idx_ai_usage_created ON ai_usage(created_at);
+	CREATE INDEX IF NOT EXISTS idx_ai_usage_user ON ai_usage(user_id, created_at);`)
+	if err != nil {
+		return nil, fmt.Errorf("无法创建用量表: %w", err)
+	}
+
+	return &UsageService{
+		db:     db,
+		prices: cfg.ModelPrices,
+		quotas: cfg.Quotas,
+		now:    time.Now,
+	}, nil
+}
+
+// 替换配额设置，用于热加载配置
+func (u *UsageService) SetQuotas(quotas config.QuotaConfig) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.quotas = quotas
+}
+
+// 按单价表计算费用，没有配置单价的模型费用为0
+func (u *UsageService) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := u.prices[model]
+	if !ok {
+		return 0
+	}
+	return float64(promptTokens)/1000*price.PromptPer1K + float64(completionTokens)/1000*price.CompletionPer1K
+}
+
+// 记录一次模型调用
+func (u *UsageService) Record(rec models.UsageRecord) error {
+	defer metrics.ObserveDB("record_usage")()
+
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = u.now()
+	}
+	if rec.Cost == 0 {
+		rec.Cost = u.Cost(rec.Model, rec.PromptTokens, rec.CompletionTokens)
+	}
+
+	success := 0
+	if rec.Success {
+		success = 1
+	}
+
+	_, err := u.db.Exec(`INSERT INTO ai_usage (
+		user_id, provider, model, purpose, prompt_tokens, completion_tokens, cost, latency_ms, success, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.UserID,
+		string(rec.Provider),
+		rec.Model,
+		string(rec.Purpose),
+		rec.PromptTokens,
+		rec.CompletionTokens,
+		rec.Cost,
+		rec.LatencyMs,
+		success,
+		rec.CreatedAt.Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("记录用量失败: %w", err)
+	}
+	return nil
+}
+
+// 调用模型前检查用户和全局的日、月配额。userID应来自验证过的身份（令牌中的用户或客户端IP），
+// 不能是客户端自己声明的标识，否则换一个标识就能绕过用户配额。
+// 检查和记录不在同一事务中，并发请求可能略微超出配额
+func (u *UsageService) CheckQuota(userID string) error {
+	statuses, err := u.quotaStatus(userID, true)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if status.Limit > 0 && status.Used >= status.Limit {
+			// 错误信息会返回给用户，全站的费用只告诉用户配额已用完
+			if status.Scope == "global_daily" || status.Scope == "global_monthly" {
+				return fmt.Errorf("%w: %s的配额已用完", ErrQuotaExceeded, quotaScopeNames[status.Scope])
+			}
+			return fmt.Errorf("%w: %s已使用%.4f元，配额%.4f元", ErrQuotaExceeded, quotaScopeNames[status.Scope], status.Used, status.Limit)
+		}
+	}
+	return nil
+}
+
+// 配额范围的显示名称
+var quotaScopeNames = map[string]string{
+	"user_daily":     "用户今日",
+	"user_monthly":   "用户本月",
+	"global_daily":   "全站今日",
+	"global_monthly": "全站本月",
+}
+
+// 返回用户和全局的配额使用情况。userID为空时没有可以对应的用户，只返回全局配额，
+// 不能把全站的费用当作某个用户的用量
+func (u *UsageService) QuotaStatus(userID string) ([]models.QuotaStatus, error) {
+	return u.quotaStatus(userID, true)
+}
+
+// global为false时只返回用户自己的配额，全站的费用只给管理员看
+func (u *UsageService) quotaStatus(userID string, global bool) ([]models.QuotaStatus, error) {
+	now := u.now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	u.mu.RLock()
+	quotas := u.quotas
+	u.mu.RUnlock()
+
+	checks := []struct {
+		scope   string
+		limit   float64
+		since   time.Time
+		perUser bool
+	}{
+		{"user_daily", quotas.UserDaily, dayStart, true},
+		{"user_monthly", quotas.UserMonthly, monthStart, true},
+		{"global_daily", quotas.GlobalDaily, dayStart, false},
+		{"global_monthly", quotas.GlobalMonthly, monthStart, false},
+	}
+
+	statuses := make([]models.QuotaStatus, 0, len(checks))
+	for _, check := range checks {
+		if check.perUser && userID == "" || !check.perUser && !global {
+			continue
+		}
+		user := ""
+		if check.perUser {
+			user = userID
+		}
+		used, err := u.costSince(check.since, user)
+		if err != nil {
+			return nil, err
+		}
+
+		status := models.QuotaStatus{Scope: check.scope, Used: used, Limit: check.limit}
+		if check.limit > 0 && check.limit > used {
+			status.Remain = check.limit - used
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// 统计某个时间点之后的费用，userID为空时统计全部用户
+func (u *UsageService) costSince(since time.Time, userID string) (float64, error) {
+	defer metrics.ObserveDB("usage_cost")()
+
+	query := "SELECT COALESCE(SUM(cost), 0) FROM ai_usage WHERE created_at >= ?"
+	args := []interface{}{since.Unix()}
+	if userID != "" {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+
+	var cost float64
+	if err := u.db.QueryRow(query, args...).Scan(&cost); err != nil {
+		return 0, fmt.Errorf("统计费用失败: %w", err)
+	}
+	return cost, nil
+}
+
+// 生成[from, to)时间范围内的用量报表，userID不为空时只统计该用户。
+// global为true时报表中包含全站配额，只应提供给管理员
+func (u *UsageService) Report(from, to time.Time, userID string, global bool) (*models.UsageReport, error) {
+	where := "WHERE created_at >= ? AND created_at < ?"
+	args := []interface{}{from.Unix(), to.Unix()}
+	if userID != "" {
+		where += " AND user_id = ?"
+		args = append(args, userID)
+	}
+
+	report := &models.UsageReport{From: from, To: to, UserID: userID}
+
+	total, err := u.groupUsage("''", where, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(total) > 0 {
+		report.Total = total[0].UsageSummary
+	}
+
+	if report.ByModel, err = u.groupUsage("provider || '/' || model", where, args); err != nil {
+		return nil, err
+	}
+	if report.ByUser, err = u.groupUsage("user_id", where, args); err != nil {
+		return nil, err
+	}
+	if report.ByDay, err = u.groupUsage("date(created_at, 'unixepoch', 'localtime')", where, args); err != nil {
+		return nil, err
+	}
+	if report.Quotas, err = u.quotaStatus(userID, global); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// 按keyExpr分组汇总用量
+func (u *UsageService) groupUsage(keyExpr, where string, args []interface{}) ([]models.UsageGroup, error) {
+	defer metrics.ObserveDB("usage_report")()
+
+	query := fmt.Sprintf(`SELECT %s AS k,
+		COUNT(*),
+		COALESCE(SUM(CASE WHEN success = 0 THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(prompt_tokens), 0),
+		COALESCE(SUM(completion_tokens), 0),
+		COALESCE(SUM(cost), 0)
+	FROM ai_usage
+	%s
+	GROUP BY k
+	ORDER BY k`, keyExpr, where)
+
+	rows, err := u.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("统计用量失败: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []models.UsageGroup{}
+	for rows.Next() {
+		var g models.UsageGroup
+		if err := rows.Scan(&g.Key, &g.Requests, &g.Failures, &g.PromptTokens, &g.CompletionTokens, &g.Cost); err != nil {
+			return nil, fmt.Errorf("扫描用量失败: %w", err)
+		}
+		g.TotalTokens = g.PromptTokens + g.CompletionTokens
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"question-generator/config"
+	"question-generator/mockllm"
+	"question-generator/models"
+	"testing"
+	"time"
+)
+
+// 创建带用量记录的客户端，单价为1元/千tokens便于计算
+func newUsageAIClient(t *testing.T, quotas config.QuotaConfig) (*AIClient, *UsageService, *mockllm.Server) {
+	t.Helper()
+
+	mock, err := mockllm.NewServer()
+	if err != nil {
+		t.Fatalf("start mock server: %v", err)
+	}
+	t.Cleanup(func() { mock.Close() })
+
+	cfg := &config.Configuration{
+		QwenAPIKey:  "mock",
+		QwenAPIURL:  mock.URL,
+		ReviewModel: models.Tongyi,
+		ModelPrices: map[string]config.ModelPrice{"qwen-turbo": {PromptPer1K: 1, CompletionPer1K: 2}},
+		Quotas:      quotas,
+	}
+
+	storage := newTestStorage(t)
+	usage, err := NewUsageService(storage.DB, cfg)
+	if err != nil {
+		t.Fatalf("NewUsageService: %v", err)
+	}
+	return NewAIClient(cfg, usage), usage, mock
+}
+
+func TestUsageRecorded(t *testing.T) {
+	client, usage, mock := newUsageAIClient(t, config.QuotaConfig{})
+
+	if _, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 2, "alice"); err != nil {
+		t.Fatalf("BatchGenerateQuestions: %v", err)
+	}
+	if err := client.ReviewQuestions(context.Background(), "", "bob", nil); err != nil {
+		t.Fatalf("ReviewQuestions: %v", err)
+	}
+	mock.Enqueue("server_error")
+	if _, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 2, "bob"); err == nil {
+		t.Fatal("want provider error")
+	}
+
+	now := time.Now()
+	report, err := usage.Report(now.Add(-time.Hour), now.Add(time.Hour), "", true)
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+
+	// single_choice录制响应为420输入、310输出tokens
+	total := report.Total
+	if total.Requests != 2 || total.Failures != 1 || total.PromptTokens != 420 || total.CompletionTokens != 310 {
+		t.Errorf("total = %+v", total)
+	}
+	if want := 0.42 + 0.62; math.Abs(total.Cost-want) > 1e-9 {
+		t.Errorf("cost = %v, want %v", total.Cost, want)
+	}
+	if len(report.ByUser) != 2 || report.ByUser[0].Key != "alice" || report.ByUser[1].Failures != 1 {
+		t.Errorf("byUser = %+v", report.ByUser)
+	}
+	if len(report.ByModel) != 1 || report.ByModel[0].Key != "tongyi/qwen-turbo" {
+		t.Errorf("byModel = %+v", report.ByModel)
+	}
+	if len(report.ByDay) != 1 {
+		t.Errorf("byDay = %+v", report.ByDay)
+	}
+
+	if len(report.Quotas) != 2 || report.Quotas[0].Scope != "global_daily" {
+		t.Errorf("admin quotas = %+v", report.Quotas)
+	}
+
+	report, err = usage.Report(now.Add(-time.Hour), now.Add(time.Hour), "bob", false)
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if report.Total.Requests != 1 || report.Total.Cost != 0 {
+		t.Errorf("bob total = %+v", report.Total)
+	}
+	// 普通用户只看到自己的配额
+	if len(report.Quotas) != 2 || report.Quotas[0].Scope != "user_daily" || report.Quotas[1].Scope != "user_monthly" {
+		t.Errorf("bob quotas = %+v", report.Quotas)
+	}
+}
+
+func TestQuotaEnforced(t *testing.T) {
+	tests := []struct {
+		name   string
+		quotas config.QuotaConfig
+		other  bool // 第二次调用换一个用户
+		want   bool // 第二次调用是否被拒绝
+	}{
+		{name: "不限制", quotas: config.QuotaConfig{}, want: false},
+		{name: "用户日配额", quotas: config.QuotaConfig{UserDaily: 1}, want: true},
+		{name: "用户日配额不影响其他用户", quotas: config.QuotaConfig{UserDaily: 1}, other: true, want: false},
+		{name: "用户月配额", quotas: config.QuotaConfig{UserMonthly: 1}, want: true},
+		{name: "全局日配额", quotas: config.QuotaConfig{GlobalDaily: 1}, other: true, want: true},
+		{name: "全局月配额", quotas: config.QuotaConfig{GlobalMonthly: 1}, other: true, want: true},
+		{name: "配额未用完", quotas: config.QuotaConfig{UserDaily: 5, GlobalMonthly: 5}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _, mock := newUsageAIClient(t, tt.quotas)
+
+			// 第一次调用花费1.04元
+			if _, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 2, "alice"); err != nil {
+				t.Fatalf("first call: %v", err)
+			}
+
+			user := "alice"
+			if tt.other {
+				user = "bob"
+			}
+			_, err := client.BatchGenerateQuestions(context.Background(), &models.QuestionRequest{}, 2, user)
+			if got := errors.Is(err, ErrQuotaExceeded); got != tt.want {
+				t.Fatalf("quota exceeded = %v (err %v), want %v", got, err, tt.want)
+			}
+			if tt.want && len(mock.Requests()) != 1 {
+				t.Errorf("provider called despite quota: %d requests", len(mock.Requests()))
+			}
+		})
+	}
+}
+
+func TestQuotaStatusWindows(t *testing.T) {
+	_, usage, _ := newUsageAIClient(t, config.QuotaConfig{UserDaily: 10, UserMonthly: 100})
+
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.Local)
+	usage.now = func() time.Time { return now }
+
+	records := []struct {
+		at   time.Time
+		cost float64
+	}{
+		{now.Add(-time.Hour), 3},                               // 今天
+		{now.AddDate(0, 0, -1), 20},                            // 本月但不是今天
+		{time.Date(2026, 2, 28, 23, 0, 0, 0, time.Local), 500}, // 上个月
+	}
+	for _, r := range records {
+		if err := usage.Record(models.UsageRecord{UserID: "alice", Provider: models.Tongyi, Model: "x", Purpose: models.PurposeGenerate, Cost: r.cost, Success: true, CreatedAt: r.at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses, err := usage.QuotaStatus("alice")
+	if err != nil {
+		t.Fatalf("QuotaStatus: %v", err)
+	}
+	want := map[string]float64{"user_daily": 3, "user_monthly": 23, "global_daily": 3, "global_monthly": 23}
+	for _, s := range statuses {
+		if s.Used != want[s.Scope] {
+			t.Errorf("%s used = %v, want %v", s.Scope, s.Used, want[s.Scope])
+		}
+	}
+	if statuses[0].Remain != 7 || statuses[1].Remain != 77 {
+		t.Errorf("remain = %v / %v", statuses[0].Remain, statuses[1].Remain)
+	}
+
+	// 没有用户时只有全局配额
+	statuses, err = usage.QuotaStatus("")
+	if err != nil {
+		t.Fatalf("QuotaStatus: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].Scope != "global_daily" || statuses[1].Scope != "global_monthly" {
+		t.Errorf("statuses without user = %+v", statuses)
+	}
+}
@@ -0,0 +1,554 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"question-generator/config"
+	"question-generator/metrics"
+	"question-generator/models"
+	"strconv"
+	"time"
+)
+
+// 投递请求携带的请求头
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	// 值为"sha256="加上HMAC-SHA256的十六进制结果，签名内容为"时间戳.请求体"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// 每轮最多投递的事件数，投递记录查询默认返回的条数
+const (
+	deliveryBatch        = 20
+	defaultDeliveryLimit = 100
+)
+
+// 记录的投递失败原因和响应体的最大长度
+const maxDeliveryError = 512
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook不存在")
+	ErrDeliveryNotFound = errors.New("投递记录不存在")
+	ErrInvalidWebhook   = errors.New("无效的webhook订阅")
+)
+
+// 把题库事件投递给订阅的webhook。事件先写入数据库再由后台投递，
+// 接收方返回2xx之前会按指数退避重试，保证至少投递一次
+type WebhookService struct {
+	db     *sql.DB
+	cfg    config.WebhookConfig
+	client *http.Client
+	now    func() time.Time
+	wake   chan struct{}
+}
+
+// 创建webhook服务，订阅和投递记录与题库放在同一个数据库中
+func NewWebhookService(db *sql.DB, cfg config.WebhookConfig) (*WebhookService, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		events TEXT NOT NULL, -- 订阅的事件，JSON数组
+		secret TEXT NOT NULL,
+		active INTEGER NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event_id TEXT NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL, -- pending / delivered / dead
+		attempts INTEGER NOT NULL,
+		next_attempt_at INTEGER NOT NULL,
+		last_status INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		delivered_at INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);`)
+	if err != nil {
+		return nil, fmt.Errorf("无法创建webhook表: %w", err)
+	}
+
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	return &WebhookService{
+		db:     db,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+	}, nil
+}
+
+// 检查订阅请求，返回去重后的事件列表
+func validateWebhook(req *models.WebhookRequest) ([]models.WebhookEvent, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: 地址必须是http或https URL", ErrInvalidWebhook)
+	}
+	if len(req.Events) == 0 {
+		return nil, fmt.Errorf("%w: 至少需要订阅一个事件", ErrInvalidWebhook)
+	}
+
+	seen := make(map[models.WebhookEvent]bool)
+	var events []models.WebhookEvent
+	for _, event := range req.Events {
+		if !event.Valid() {
+			return nil, fmt.Errorf("%w: 不支持的事件%s", ErrInvalidWebhook, event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// 创建订阅，返回的订阅中包含签名密钥
+func (s *WebhookService) Create(req models.WebhookRequest) (*models.Webhook, error) {
+	events, err := validateWebhook(&req)
+	if err != nil {
+		return nil, err
+	}
+	defer metrics.ObserveDB("webhook_create")()
+
+	hook := &models.Webhook{
+		URL:       req.URL,
+		Events:    events,
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: s.now(),
+		Secret:    req.Secret,
+	}
+	if hook.Secret == "" {
+		b := make([]byte, 32)
+		rand.Read(b)
+		hook.Secret = hex.EncodeToString(b)
+	}
+
+	eventsJSON, _ := json.Marshal(hook.Events)
+	result, err := s.db.Exec("INSERT INTO webhooks (url, events, secret, active, created_at) VALUES (?, ?, ?, ?, ?)",
+		hook.URL, string(eventsJSON), hook.Secret, hook.Active, hook.CreatedAt.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("保存webhook失败: %w", err)
+	}
+	hook.ID, _ = result.LastInsertId()
+	return hook, nil
+}
+
+// 修改订阅，已经生成的投递记录不受影响
+func (s *WebhookService) Update(id int64, req models.WebhookRequest) (*models.Webhook, error) {
+	events, err := validateWebhook(&req)
+	if err != nil {
+		return nil, err
+	}
+	defer metrics.ObserveDB("webhook_update")()
+
+	eventsJSON, _ := json.Marshal(events)
+	var active interface{}
+	if req.Active != nil {
+		active = *req.Active
+	}
+	result, err := s.db.Exec(`UPDATE webhooks SET url = ?, events = ?,
+		secret = COALESCE(NULLIF(?, ''), secret), active = COALESCE(?, active)
+	WHERE id = ?`, req.URL, string(eventsJSON), req.Secret, active, id)
+	if err != nil {
+		return nil, fmt.Errorf("更新webhook失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrWebhookNotFound
+	}
+
+	hooks, err := s.queryWebhooks("WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return &hooks[0], nil
+}
+
+// 删除订阅及其投递记录
+func (s *WebhookService) Delete(id int64) error {
+	defer metrics.ObserveDB("webhook_delete")()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("删除webhook失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrWebhookNotFound
+	}
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return fmt.Errorf("删除投递记录失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// 所有订阅，不包含签名密钥
+func (s *WebhookService) List() ([]models.Webhook, error) {
+	defer metrics.ObserveDB("webhook_list")()
+	return s.queryWebhooks("ORDER BY id")
+}
+
+func (s *WebhookService) queryWebhooks(clause string, args ...interface{}) ([]models.Webhook, error) {
+	rows, err := s.db.Query("SELECT id, url, events, active, created_at FROM webhooks "+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询webhook失败: %w", err)
+	}
+	defer rows.Close()
+
+	hooks := []models.Webhook{}
+	for rows.Next() {
+		var hook models.Webhook
+		var events string
+		var createdAt int64
+		if err := rows.Scan(&hook.ID, &hook.URL, &events, &hook.Active, &createdAt); err != nil {
+			return nil, fmt.Errorf("扫描webhook失败: %w", err)
+		}
+		json.Unmarshal([]byte(events), &hook.Events)
+		hook.CreatedAt = time.Unix(createdAt, 0)
+		hooks = append(hooks, hook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询webhook失败: %w", err)
+	}
+	if len(hooks) == 0 && len(args) > 0 {
+		return nil, ErrWebhookNotFound
+	}
+	return hooks, nil
+}
+
+// 发布事件：为每个订阅了该事件的启用中的webhook生成一条投递记录，由后台投递
+func (s *WebhookService) Publish(event models.WebhookEvent, data interface{}) error {
+	defer metrics.ObserveDB("webhook_publish")()
+
+	hooks, err := s.queryWebhooks("WHERE active = 1")
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	payload := models.WebhookPayload{ID: newSessionID(), Event: event, CreatedAt: now, Data: data}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("启动事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	queued := 0
+	for _, hook := range hooks {
+		if !subscribed(hook.Events, event) {
+			continue
+		}
+		_, err := tx.Exec(`INSERT INTO webhook_deliveries (
+			webhook_id, event_id, event, payload, status, attempts, next_attempt_at, created_at
+		) VALUES (?, ?, ?, ?, ?, 0, ?, ?)`,
+			hook.ID, payload.ID, string(event), string(body), string(models.DeliveryPending), now.Unix(), now.Unix())
+		if err != nil {
+			return fmt.Errorf("保存投递记录失败: %w", err)
+		}
+		queued++
+	}
+	if queued == 0 {
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	s.notify()
+	return nil
+}
+
+// 发布事件，失败时只记录日志，不影响触发事件的请求
+func (s *WebhookService) Emit(event models.WebhookEvent, data interface{}) {
+	if err := s.Publish(event, data); err != nil {
+		slog.Warn("发布webhook事件失败", "event", event, "error", err)
+	}
+}
+
+func subscribed(events []models.WebhookEvent, event models.WebhookEvent) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// 唤醒后台投递，不阻塞
+func (s *WebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 后台投递循环，直到ctx取消。有新事件时立即投递，否则按固定间隔检查到期的重试。
+// 停用的订阅暂不投递，重新启用后继续投递积压的事件
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.deliverDue(ctx); err != nil {
+			slog.Warn("投递webhook失败", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// 一条待投递的记录及其目标
+type pendingDelivery struct {
+	id       int64
+	eventID  string
+	event    string
+	payload  []byte
+	attempts int
+	url      string
+	secret   string
+}
+
+// 投递所有到期的记录，每批投递完后重新查询，直到没有到期的记录
+func (s *WebhookService) deliverDue(ctx context.Context) error {
+	for ctx.Err() == nil {
+		due, err := s.dueDeliveries()
+		if err != nil {
+			return err
+		}
+		for _, d := range due {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := s.deliver(ctx, d); err != nil {
+				return err
+			}
+		}
+		if len(due) < deliveryBatch {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *WebhookService) dueDeliveries() ([]pendingDelivery, error) {
+	defer metrics.ObserveDB("webhook_due")()
+
+	rows, err := s.db.Query(`SELECT d.id, d.event_id, d.event, d.payload, d.attempts, w.url, w.secret
+	FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.status = ? AND d.next_attempt_at <= ? AND w.active = 1
+	ORDER BY d.next_attempt_at, d.id LIMIT ?`, string(models.DeliveryPending), s.now().Unix(), deliveryBatch)
+	if err != nil {
+		return nil, fmt.Errorf("查询待投递记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var due []pendingDelivery
+	for rows.Next() {
+		var d pendingDelivery
+		var payload string
+		if err := rows.Scan(&d.id, &d.eventID, &d.event, &payload, &d.attempts, &d.url, &d.secret); err != nil {
+			return nil, fmt.Errorf("扫描待投递记录失败: %w", err)
+		}
+		d.payload = []byte(payload)
+		due = append(due, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询待投递记录失败: %w", err)
+	}
+	return due, nil
+}
+
+// 计算签名，接收方用相同的密钥对"时间戳.请求体"计算后比较
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// 投递一次并保存结果，只有保存结果失败时返回错误
+func (s *WebhookService) deliver(ctx context.Context, d pendingDelivery) error {
+	statusCode, err := s.send(ctx, d)
+	if ctx.Err() != nil {
+		// 关闭服务时中断的投递不计入次数，下次启动后重新投递
+		return nil
+	}
+
+	defer metrics.ObserveDB("webhook_deliver")()
+	now := s.now()
+	attempts := d.attempts + 1
+	if err == nil {
+		_, err := s.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = ?, last_status = ?, last_error = '', delivered_at = ?
+		WHERE id = ?`, string(models.DeliveryDelivered), attempts, statusCode, now.Unix(), d.id)
+		if err != nil {
+			return fmt.Errorf("保存投递结果失败: %w", err)
+		}
+		return nil
+	}
+
+	status := models.DeliveryPending
+	next := now.Add(s.retryDelay(attempts))
+	if attempts >= s.cfg.MaxAttempts {
+		status = models.DeliveryDead
+		slog.Warn("webhook投递失败次数过多，进入死信列表", "delivery_id", d.id, "event", d.event, "url", d.url, "error", err)
+	}
+	message := err.Error()
+	if len(message) > maxDeliveryError {
+		message = message[:maxDeliveryError]
+	}
+	_, err = s.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_status = ?, last_error = ?
+	WHERE id = ?`, string(status), attempts, next.Unix(), statusCode, message, d.id)
+	if err != nil {
+		return fmt.Errorf("保存投递结果失败: %w", err)
+	}
+	return nil
+}
+
+// 第n次失败后的等待时间
+func (s *WebhookService) retryDelay(n int) time.Duration {
+	delay := s.cfg.RetryBase
+	for i := 1; i < n && delay < s.cfg.RetryMax; i++ {
+		delay *= 2
+	}
+	if delay > s.cfg.RetryMax {
+		delay = s.cfg.RetryMax
+	}
+	return delay
+}
+
+// 发送请求，接收方返回2xx以外的状态码时返回错误
+func (s *WebhookService) send(ctx context.Context, d pendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, d.event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(d.id, 10))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(d.secret, timestamp, d.payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxDeliveryError))
+		return resp.StatusCode, fmt.Errorf("接收方返回%d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// 投递记录，最近的在前。status为dead时即死信列表
+func (s *WebhookService) Deliveries(req models.DeliveryListRequest) ([]models.WebhookDelivery, error) {
+	defer metrics.ObserveDB("webhook_deliveries")()
+
+	var conditions []string
+	var args []interface{}
+	if req.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, string(req.Status))
+	}
+	if req.WebhookID != 0 {
+		conditions = append(conditions, "webhook_id = ?")
+		args = append(args, req.WebhookID)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+
+	rows, err := s.db.Query(`SELECT id, webhook_id, event_id, event, payload, status, attempts, next_attempt_at,
+		last_status, last_error, created_at, delivered_at
+	FROM webhook_deliveries `+whereClause(conditions)+` ORDER BY id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("查询投递记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload string
+		var nextAttemptAt, createdAt int64
+		var deliveredAt sql.NullInt64
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &payload, &d.Status, &d.Attempts, &nextAttemptAt,
+			&d.LastStatus, &d.LastError, &createdAt, &deliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("扫描投递记录失败: %w", err)
+		}
+		d.Payload = json.RawMessage(payload)
+		d.NextAttemptAt = time.Unix(nextAttemptAt, 0)
+		d.CreatedAt = time.Unix(createdAt, 0)
+		if deliveredAt.Valid {
+			t := time.Unix(deliveredAt.Int64, 0)
+			d.DeliveredAt = &t
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("查询投递记录失败: %w", err)
+	}
+	return deliveries, nil
+}
+
+// 重新投递：把记录恢复为待投递并清零投递次数，死信和已投递成功的记录都可以重新投递
+func (s *WebhookService) Redeliver(id int64) error {
+	defer metrics.ObserveDB("webhook_redeliver")()
+
+	result, err := s.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?
+	WHERE id = ?`, string(models.DeliveryPending), s.now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("更新投递记录失败: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDeliveryNotFound
+	}
+	s.notify()
+	return nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"question-generator/config"
+	"question-generator/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录收到的投递，fail为true时返回500
+type testReceiver struct {
+	mu       sync.Mutex
+	fail     bool
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	if r.fail {
+		http.Error(w, "暂时不可用", http.StatusInternalServerError)
+	}
+}
+
+func (r *testReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func newTestWebhooks(t *testing.T) (*WebhookService, *time.Time) {
+	t.Helper()
+	storage := newTestStorage(t)
+	webhooks, err := NewWebhookService(storage.DB, config.WebhookConfig{
+		MaxAttempts: 3,
+		RetryBase:   time.Minute,
+		RetryMax:    90 * time.Second,
+		Timeout:     5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewWebhookService: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	webhooks.now = func() time.Time { return now }
+	return webhooks, &now
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	webhooks, now := newTestWebhooks(t)
+	ctx := context.Background()
+
+	good := &testReceiver{}
+	goodServer := httptest.NewServer(good)
+	defer goodServer.Close()
+	flaky := &testReceiver{fail: true}
+	flakyServer := httptest.NewServer(flaky)
+	defer flakyServer.Close()
+
+	hook, err := webhooks.Create(models.WebhookRequest{
+		URL:    goodServer.URL,
+		Events: []models.WebhookEvent{models.EventQuestionCreated, models.EventExamSubmitted, models.EventQuestionCreated},
+	})
+	if err != nil || hook.Secret == "" || len(hook.Events) != 2 || !hook.Active {
+		t.Fatalf("Create: %+v, %v", hook, err)
+	}
+	flakyHook, err := webhooks.Create(models.WebhookRequest{URL: flakyServer.URL, Events: []models.WebhookEvent{models.EventQuestionCreated}, Secret: "s"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := webhooks.Publish(models.EventQuestionCreated, map[string]int{"id": 1}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	// 没有订阅的事件不生成投递记录
+	webhooks.Publish(models.EventQuestionDeleted, models.QuestionDeletedData{ID: 1})
+	if all, _ := webhooks.Deliveries(models.DeliveryListRequest{}); len(all) != 2 {
+		t.Fatalf("deliveries: %+v", all)
+	}
+
+	if err := webhooks.deliverDue(ctx); err != nil {
+		t.Fatalf("deliverDue: %v", err)
+	}
+
+	// 请求体带签名，事件ID在各个订阅之间相同
+	if good.count() != 1 {
+		t.Fatalf("good receiver got %d requests", good.count())
+	}
+	req, body := good.requests[0], good.bodies[0]
+	if req.Header.Get(WebhookEventHeader) != "question.created" {
+		t.Errorf("event header: %v", req.Header)
+	}
+	if req.Header.Get(WebhookSignatureHeader) != SignWebhook(hook.Secret, req.Header.Get(WebhookTimestampHeader), body) {
+		t.Errorf("signature mismatch: %v", req.Header)
+	}
+	var payload models.WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Event != models.EventQuestionCreated || payload.ID == "" {
+		t.Errorf("payload: %s", body)
+	}
+
+	delivered, _ := webhooks.Deliveries(models.DeliveryListRequest{Status: models.DeliveryDelivered})
+	if len(delivered) != 1 || delivered[0].WebhookID != hook.ID || delivered[0].EventID != payload.ID || delivered[0].DeliveredAt == nil {
+		t.Fatalf("delivered: %+v", delivered)
+	}
+
+	// 失败后按指数退避重试，用尽次数后进入死信列表
+	pending, _ := webhooks.Deliveries(models.DeliveryListRequest{Status: models.DeliveryPending})
+	if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastStatus != http.StatusInternalServerError ||
+		!pending[0].NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("after first failure: %+v", pending)
+	}
+	webhooks.deliverDue(ctx)
+	if flaky.count() != 1 {
+		t.Errorf("retried before due: %d", flaky.count())
+	}
+
+	*now = now.Add(time.Minute)
+	webhooks.deliverDue(ctx)
+	pending, _ = webhooks.Deliveries(models.DeliveryListRequest{Status: models.DeliveryPending})
+	if len(pending) != 1 || !pending[0].NextAttemptAt.Equal(now.Add(90*time.Second)) {
+		t.Fatalf("after second failure: %+v", pending)
+	}
+
+	*now = now.Add(90 * time.Second)
+	webhooks.deliverDue(ctx)
+	dead, _ := webhooks.Deliveries(models.DeliveryListRequest{Status: models.DeliveryDead})
+	if len(dead) != 1 || dead[0].WebhookID != flakyHook.ID || dead[0].Attempts != 3 || dead[0].LastError == "" {
+		t.Fatalf("dead letters: %+v", dead)
+	}
+
+	// 接收方恢复后手动重新投递
+	flaky.mu.Lock()
+	flaky.fail = false
+	flaky.mu.Unlock()
+	if err := webhooks.Redeliver(dead[0].ID); err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	webhooks.deliverDue(ctx)
+	if dead, _ := webhooks.Deliveries(models.DeliveryListRequest{WebhookID: flakyHook.ID}); dead[0].Status != models.DeliveryDelivered {
+		t.Errorf("after redeliver: %+v", dead[0])
+	}
+	if err := webhooks.Redeliver(9999); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("redeliver missing: %v", err)
+	}
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	webhooks, _ := newTestWebhooks(t)
+
+	for _, req := range []models.WebhookRequest{
+		{URL: "ftp://example.com", Events: []models.WebhookEvent{models.EventQuestionCreated}},
+		{URL: "http://example.com"},
+		{URL: "http://example.com", Events: []models.WebhookEvent{"question.viewed"}},
+	} {
+		if _, err := webhooks.Create(req); !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("Create(%+v): %v", req, err)
+		}
+	}
+
+	hook, err := webhooks.Create(models.WebhookRequest{URL: "http://example.com/hook", Events: []models.WebhookEvent{models.EventExamSubmitted}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// 停用后不再生成投递记录，密钥为空时保持不变
+	inactive := false
+	updated, err := webhooks.Update(hook.ID, models.WebhookRequest{URL: "https://example.com/hook", Events: []models.WebhookEvent{models.EventExamSubmitted}, Active: &inactive})
+	if err != nil || updated.Active || updated.URL != "https://example.com/hook" || updated.Secret != "" {
+		t.Fatalf("Update: %+v, %v", updated, err)
+	}
+	var secret string
+	webhooks.db.QueryRow("SELECT secret FROM webhooks WHERE id = ?", hook.ID).Scan(&secret)
+	if secret != hook.Secret {
+		t.Errorf("secret changed")
+	}
+	webhooks.Publish(models.EventExamSubmitted, nil)
+	if all, _ := webhooks.Deliveries(models.DeliveryListRequest{}); len(all) != 0 {
+		t.Errorf("inactive webhook got deliveries: %+v", all)
+	}
+
+	if _, err := webhooks.Update(9999, models.WebhookRequest{URL: "http://example.com", Events: []models.WebhookEvent{models.EventExamSubmitted}}); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("update missing: %v", err)
+	}
+	if err := webhooks.Delete(hook.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := webhooks.Delete(hook.ID); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("delete twice: %v", err)
+	}
+	if hooks, _ := webhooks.List(); len(hooks) != 0 {
+		t.Errorf("List: %+v", hooks)
+	}
+}